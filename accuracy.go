@@ -0,0 +1,332 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// moveAnalysis is how a move of the player compares with the engine's choice in the same position.
+type moveAnalysis struct {
+	Move   int      `json:"move"` // numbered from 1
+	Played string   `json:"played"`
+	Best   string   `json:"best"`
+	Match  bool     `json:"match"`
+	Loss   *float64 `json:"loss,omitempty"` // points lost against the engine's move, by the engine's score estimate
+}
+
+// gameAnalysis is the engine analysis of the moves of the player in a game.
+// Analyses are cached in $CHAMGO_HOME/analysis by the hash of the moves and the engine, see analysisPath.
+type gameAnalysis struct {
+	Name     string         `json:"name,omitempty"`
+	Hash     string         `json:"hash"` // movesHash of the game
+	Engine   string         `json:"engine"`
+	Color    string         `json:"color"` // the player, "b" or "w"
+	Level    int32          `json:"level"`
+	Saved    time.Time      `json:"saved"`
+	Analyzed time.Time      `json:"analyzed"`
+	Moves    []moveAnalysis `json:"moves"`
+}
+
+// matchRate returns the share of the player's moves that were the engine's choice.
+func (a *gameAnalysis) matchRate() float64 {
+	if len(a.Moves) == 0 {
+		return 0
+	}
+	n := 0
+	for _, m := range a.Moves {
+		if m.Match {
+			n++
+		}
+	}
+	return float64(n) / float64(len(a.Moves))
+}
+
+// meanLoss returns the average points lost per move, and false if the engine did not estimate scores.
+func (a *gameAnalysis) meanLoss() (float64, bool) {
+	var sum float64
+	n := 0
+	for _, m := range a.Moves {
+		if m.Loss != nil {
+			sum += *m.Loss
+			n++
+		}
+	}
+	if n == 0 {
+		return 0, false
+	}
+	return sum / float64(n), true
+}
+
+// analysisPath returns the cache file of the analysis of the game with moves hash by engine.
+func analysisPath(hash, engine string) (string, error) {
+	dir, err := chamgoDir("analysis")
+	if err != nil {
+		return "", err
+	}
+	key := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '.' {
+			return r
+		}
+		return '_'
+	}, engine)
+	return filepath.Join(dir, hash+"-"+key+".json"), nil
+}
+
+// cachedAnalysis returns the cached analysis of the game with moves hash by engine, or nil if it was not analyzed.
+func cachedAnalysis(hash, engine string) *gameAnalysis {
+	fn, err := analysisPath(hash, engine)
+	if err != nil {
+		return nil
+	}
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return nil
+	}
+	var a gameAnalysis
+	if err := json.Unmarshal(b, &a); err != nil {
+		return nil
+	}
+	return &a
+}
+
+func (a *gameAnalysis) cache() error {
+	fn, err := analysisPath(a.Hash, a.Engine)
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(a, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(fn, b)
+}
+
+// parseGTPScore parses a final_score response such as "B+3.5" into the points black leads by.
+func parseGTPScore(s string) (float64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if s == "0" || s == "DRAW" || s == "JIGO" {
+		return 0, nil
+	}
+	if len(s) < 3 || s[1] != '+' || s[0] != 'B' && s[0] != 'W' {
+		return 0, fmt.Errorf("bad score %q", s)
+	}
+	v, err := strconv.ParseFloat(s[2:], 64)
+	if err != nil {
+		return 0, fmt.Errorf("bad score %q", s)
+	}
+	if s[0] == 'W' {
+		v = -v
+	}
+	return v, nil
+}
+
+// known reports whether the engine knows the GTP command.
+func (e *gtpEngine) known(command string) bool {
+	r, err := e.send("known_command", command)
+	return err == nil && r == "true"
+}
+
+// bestMove returns the engine's move for the side s, without playing it.
+func (e *gtpEngine) bestMove(s Stone, regGenmove bool) (string, error) {
+	if regGenmove {
+		v, err := e.send("reg_genmove", gtpColor(s))
+		return strings.ToUpper(v), err
+	}
+	v, err := e.send("genmove", gtpColor(s))
+	if err != nil {
+		return "", err
+	}
+	if !strings.EqualFold(v, "resign") {
+		if _, err := e.send("undo"); err != nil {
+			return "", err
+		}
+	}
+	return strings.ToUpper(v), nil
+}
+
+// scoreAfter returns the engine's estimate of the points black leads by after s plays at vertex v.
+func (e *gtpEngine) scoreAfter(s Stone, v string) (float64, error) {
+	if _, err := e.send("play", gtpColor(s), v); err != nil {
+		return 0, err
+	}
+	r, err := e.send("final_score")
+	if _, uerr := e.send("undo"); err == nil {
+		err = uerr
+	}
+	if err != nil {
+		return 0, err
+	}
+	return parseGTPScore(r)
+}
+
+// analyze compares each move of the player in g with the move the engine would play instead.
+func analyze(ctx context.Context, e *gtpEngine, g *Game, player Stone) ([]moveAnalysis, error) {
+	if err := e.setup(&Game{Size: g.Size}); err != nil {
+		return nil, err
+	}
+	regGenmove, scoring := e.known("reg_genmove"), e.known("final_score")
+	var ms []moveAnalysis
+	for i, m := range g.Moves {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if !m.IsPass() && !g.onBoard(m) {
+			continue
+		}
+		played := gtpVertex(int(m.X), int(m.Y), int(g.Size))
+		if m.Stone() == player {
+			best, err := e.bestMove(player, regGenmove)
+			if err != nil {
+				return nil, err
+			}
+			a := moveAnalysis{Move: i + 1, Played: played, Best: best, Match: best == strings.ToUpper(played)}
+			if scoring && a.Match {
+				a.Loss = new(float64)
+			} else if scoring && best != "RESIGN" {
+				bs, err1 := e.scoreAfter(player, best)
+				ps, err2 := e.scoreAfter(player, played)
+				if err1 == nil && err2 == nil {
+					loss := bs - ps
+					if player == White {
+						loss = -loss
+					}
+					loss = max(loss, 0) // the engine's estimates are noisy, and its move is taken as the best one
+					a.Loss = &loss
+				}
+			}
+			ms = append(ms, a)
+		}
+		if _, err := e.send("play", gtpColor(m.Stone()), played); err != nil {
+			return nil, err
+		}
+	}
+	return ms, nil
+}
+
+func accuracyCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("accuracy", flag.ContinueOnError)
+	avx := fs.String("a", "", "Champion Go archive")
+	slot := fs.String("g", "latest", `the game: an entry name, "latest" or "latest-online"`)
+	all := fs.Bool("all", false, "analyze every local and online game of the archive")
+	color := fs.String("color", "", "the color of the player, b or w, by default the human player of each game")
+	moves := fs.Bool("moves", false, "list each move of the player")
+	refresh := fs.Bool("refresh", false, "analyze the games again instead of using cached analyses")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *color != "" && *color != "b" && *color != "w" {
+		return fmt.Errorf("-color must be b or w")
+	}
+
+	var games []gameEntry
+	if *all {
+		for _, online := range []bool{false, true} {
+			gs, err := listGames(ctx, *avx, online)
+			if err != nil {
+				return err
+			}
+			games = append(games, gs...)
+		}
+	} else {
+		name, body, err := readEntry(ctx, *avx, *slot)
+		if err != nil {
+			return err
+		}
+		if body == nil {
+			return errNoGames
+		}
+		games = append(games, gameEntry{Name: name, Body: body})
+	}
+
+	var e *gtpEngine
+	defer func() {
+		if e != nil {
+			e.Close()
+		}
+	}()
+	var as []*gameAnalysis
+	for _, ge := range games {
+		g, err := Decode(ge.Body)
+		if err != nil {
+			note(event{"event": "skip", "entry": ge.Name, "error": err.Error()}, "skipping %s: %v", ge.Name, err)
+			continue
+		}
+		c := *color
+		if c == "" {
+			c = "b"
+			if g.Color == 1 {
+				c = "w"
+			}
+		}
+		hash := movesHash(ge.Body)
+		a := cachedAnalysis(hash, engineSpec)
+		if a == nil || *refresh || a.Color != c {
+			if e == nil {
+				if e, err = startEngine(ctx); err != nil {
+					return err
+				}
+			}
+			player := Black
+			if c == "w" {
+				player = White
+			}
+			ms, err := analyze(ctx, e, g, player)
+			if err != nil {
+				return fmt.Errorf("%s: %w", ge.Name, err)
+			}
+			a = &gameAnalysis{Hash: hash, Engine: engineSpec, Color: c, Analyzed: time.Now().UTC(), Moves: ms}
+			if err := a.cache(); err != nil {
+				note(event{"event": "cache", "error": err.Error()}, "not caching the analysis: %v", err)
+			}
+			recordAnalysis(ctx, a)
+		}
+		a.Name, a.Level, a.Saved = ge.Name, g.Level, time.Unix(int64(g.Saved), 0).UTC()
+		as = append(as, a)
+	}
+
+	output(as, func(w io.Writer) {
+		var total gameAnalysis
+		for _, a := range as {
+			fmt.Fprintf(w, "%s: %s\n", a.Name, accuracySummary(a))
+			if *moves {
+				for _, m := range a.Moves {
+					fmt.Fprintf(w, "  %3d %-4s engine %-6s", m.Move, m.Played, m.Best)
+					if m.Loss != nil {
+						fmt.Fprintf(w, " %5.1f", *m.Loss)
+					}
+					fmt.Fprintln(w)
+				}
+			}
+			total.Moves = append(total.Moves, a.Moves...)
+		}
+		if len(as) > 1 {
+			fmt.Fprintf(w, "all %d games: %s\n", len(as), accuracySummary(&total))
+		}
+	})
+	if len(as) == 0 {
+		return errNoGames
+	}
+	return nil
+}
+
+func accuracySummary(a *gameAnalysis) string {
+	n := 0
+	for _, m := range a.Moves {
+		if m.Match {
+			n++
+		}
+	}
+	s := fmt.Sprintf("%d of %d moves match the engine (%.1f%%)", n, len(a.Moves), 100*a.matchRate())
+	if l, ok := a.meanLoss(); ok {
+		s += fmt.Sprintf(", %.2f points lost a move", l)
+	}
+	return s
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// ankiCard is a flashcard of a position where the player lost points: the position before the move on the front,
+// and the engine's move and the player's on the back.
+type ankiCard struct {
+	Game  string   `json:"game"`
+	Hash  string   `json:"hash"`
+	Front string   `json:"front"`
+	Back  string   `json:"back"`
+	Tags  []string `json:"tags"`
+
+	loss float64
+}
+
+// ankiCards returns the cards of the moves of the analysis a of g losing at least minLoss points.
+func ankiCards(name string, g *Game, a *gameAnalysis, minLoss float64, gameTags []string) []ankiCard {
+	var cards []ankiCard
+	for _, m := range a.Moves {
+		if m.Loss == nil || *m.Loss < minLoss || m.Move < 1 || m.Move > len(g.Moves) {
+			continue
+		}
+		b, err := replay(&Game{Size: g.Size, Moves: g.Moves[:m.Move-1]})
+		if err != nil {
+			continue
+		}
+		var last []svgMark
+		if m.Move > 1 {
+			prev := g.Moves[m.Move-2]
+			last = append(last, svgMark{X: int(prev.X), Y: int(prev.Y), Color: "#d00"})
+		}
+		side := "Black"
+		if g.Moves[m.Move-1].Stone() == White {
+			side = "White"
+		}
+
+		var front, back strings.Builder
+		b.SVG(&front, last...)
+		fmt.Fprintf(&front, "<p>%s to play, move %d</p>", side, m.Move)
+		var marks []svgMark
+		if x, y, err := parseVertex(m.Best, b.Size); err == nil {
+			marks = append(marks, svgMark{X: x, Y: y, Color: "#080", Label: "A"})
+		}
+		if x, y, err := parseVertex(m.Played, b.Size); err == nil {
+			marks = append(marks, svgMark{X: x, Y: y, Color: "#d00", Label: "X"})
+		}
+		b.SVG(&back, marks...)
+		fmt.Fprintf(&back, "<p>A: the engine's %s. X: %s played, losing %.1f points.</p>", m.Best, m.Played, *m.Loss)
+
+		tags := append([]string{"chamgo"}, gameTags...)
+		cards = append(cards, ankiCard{Game: name, Hash: a.Hash, Front: front.String(), Back: back.String(), Tags: tags, loss: *m.Loss})
+	}
+	return cards
+}
+
+// writeAnkiCSV writes the cards as a CSV file for the import of Anki 2.1.55 and later, which reads the header comments.
+// The boards are inline SVG in the HTML fields, so the deck needs no media files.
+func writeAnkiCSV(w io.Writer, cards []ankiCard) error {
+	fmt.Fprint(w, "#separator:Comma\n#html:true\n#tags column:3\n")
+	cw := csv.NewWriter(w)
+	for _, c := range cards {
+		if err := cw.Write([]string{c.Front, c.Back, strings.Join(c.Tags, " ")}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func ankiCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("anki", flag.ContinueOnError)
+	avx := fs.String("a", "", "Champion Go archive")
+	slot := fs.String("g", "latest", `the game: an entry name, "latest" or "latest-online"`)
+	all := fs.Bool("all", false, "use every local and online game of the archive")
+	n := fs.Int("n", 20, "the number of cards, of the moves losing the most points")
+	minLoss := fs.Float64("min", 2, "leave out the moves losing fewer points")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	var games []gameEntry
+	if *all {
+		for _, online := range []bool{false, true} {
+			gs, err := listGames(ctx, *avx, online)
+			if err != nil {
+				return err
+			}
+			games = append(games, gs...)
+		}
+	} else {
+		name, body, err := readEntry(ctx, *avx, *slot)
+		if err != nil {
+			return err
+		}
+		if body == nil {
+			return errNoGames
+		}
+		games = append(games, gameEntry{Name: name, Body: body})
+	}
+	tags, err := readTags()
+	if err != nil {
+		return err
+	}
+
+	var cards []ankiCard
+	for _, ge := range games {
+		g, err := Decode(ge.Body)
+		if err != nil {
+			note(event{"event": "skip", "entry": ge.Name, "error": err.Error()}, "skipping %s: %v", ge.Name, err)
+			continue
+		}
+		hash := movesHash(ge.Body)
+		a := findAnalysis(hash)
+		if a == nil {
+			note(event{"event": "skip", "entry": ge.Name, "error": "not analyzed"}, "skipping %s, which chamgo accuracy has not analyzed", ge.Name)
+			continue
+		}
+		cards = append(cards, ankiCards(ge.Name, g, a, *minLoss, tags[hash].Tags)...)
+	}
+	sort.SliceStable(cards, func(i, j int) bool { return cards[i].loss > cards[j].loss })
+	cards = cards[:min(*n, len(cards))]
+
+	var werr error
+	output(cards, func(w io.Writer) { werr = writeAnkiCSV(w, cards) })
+	return werr
+}
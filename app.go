@@ -0,0 +1,439 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// goApp is an iOS Go app whose archives chamgo edits. Everywhere else games are Champion Go records:
+// the games of other apps are converted to records as they are read from an archive, and back as they are written.
+// Apps saving records or SGF files are added without code in $CHAMGO_HOME/apps.json, a JSON array of them.
+type goApp struct {
+	Name   string    `json:"name"`
+	About  string    `json:"about,omitempty"`
+	Match  string    `json:"match,omitempty"` // a regular expression matching the iTunesMetadata.plist of its archives
+	Dirs   [2]string `json:"dirs"`            // its local and online games, the same directory if it has no online games
+	Ext    string    `json:"ext,omitempty"`   // the extension of its game files, or "" for any file
+	Format string    `json:"format"`          // the format of its game files, a key of appFormats
+
+	// Unverified marks apps whose Match, Dirs, Ext and Format are guesses, not checked against archives of the app.
+	Unverified bool `json:"unverified,omitempty"`
+
+	match *regexp.Regexp
+}
+
+// appFormat converts the game files of an app from and to Champion Go records.
+type appFormat struct {
+	toRecord   func(b []byte) ([]byte, error)            // nil for records
+	fromRecord func(record, orig []byte) ([]byte, error) // orig is the game file replaced, or nil for new games
+}
+
+// appFormats are the formats of game files chamgo converts.
+var appFormats = map[string]appFormat{
+	"record": {},
+	"sgf":    {toRecord: sgfRecord, fromRecord: recordSGF},
+}
+
+func (a *goApp) format() appFormat { return appFormats[a.Format] }
+
+// apps are the apps built into chamgo, Champion Go first.
+//
+// CrazyStone DeepLearning, by the makers of Champion Go, and SmartGo One are taken to keep their games as SGF files
+// in their Documents directories, with no separate online games. Only the moves and date of their games are read,
+// and recordSGF keeps the other properties of the games it rewrites.
+//
+// Both definitions are unverified guesses: no archives of the apps were at hand to check their container layouts
+// and game files, and testdata/crazystone.imazingapp and testdata/smartgo.imazingapp are laid out after the guesses.
+// Definitions in apps.json replace them.
+var apps = []*goApp{
+	{
+		Name:   "champion",
+		About:  "Champion Go",
+		Dirs:   [2]string{"Container/Documents/game/", "Container/Documents/game-online/"},
+		Format: "record",
+	},
+	{
+		Name:       "crazystone",
+		About:      "CrazyStone DeepLearning",
+		Match:      `(?i)crazy\s*stone\s*deep\s*learning`,
+		Dirs:       [2]string{"Container/Documents/", "Container/Documents/"},
+		Ext:        ".sgf",
+		Format:     "sgf",
+		Unverified: true,
+	},
+	{
+		Name:       "smartgo",
+		About:      "SmartGo One",
+		Match:      `(?i)smart\s*go\s*one`,
+		Dirs:       [2]string{"Container/Documents/", "Container/Documents/"},
+		Ext:        ".sgf",
+		Format:     "sgf",
+		Unverified: true,
+	},
+}
+
+// appsName is the file in $CHAMGO_HOME of the apps added to the built-in ones.
+const appsName = "apps.json"
+
+// knownApps returns the built-in apps followed by the apps of apps.json, which may replace built-in apps of the same name.
+func knownApps() ([]*goApp, error) {
+	home, err := chamgoPath("")
+	if err != nil {
+		return nil, err
+	}
+	fn := filepath.Join(home, appsName)
+	b, err := ioutil.ReadFile(fn)
+	if os.IsNotExist(err) {
+		return apps, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var added []*goApp
+	if err := json.Unmarshal(b, &added); err != nil {
+		return nil, fmt.Errorf("%s: %w", fn, err)
+	}
+	known := append([]*goApp(nil), apps...)
+	for _, a := range added {
+		if _, ok := appFormats[a.Format]; !ok || a.Name == "" || a.Name == "auto" || a.Dirs[0] == "" || a.Dirs[1] == "" {
+			return nil, fmt.Errorf("%s: app %q needs a name, dirs and a format of record or sgf", fn, a.Name)
+		}
+		for i, k := range known {
+			if k.Name == a.Name {
+				known = append(known[:i], known[i+1:]...)
+				break
+			}
+		}
+		known = append(known, a)
+	}
+	return known, nil
+}
+
+// currentApp is the app of the archives, set by -app or detected from the first archive with an iTunesMetadata.plist.
+var currentApp = apps[0]
+
+var appDetected = false
+
+type appFlag struct{}
+
+func (appFlag) String() string { return currentApp.Name }
+
+func (appFlag) Set(s string) error {
+	if s == "auto" {
+		appDetected = false
+		return nil
+	}
+	known, err := knownApps()
+	if err != nil {
+		return err
+	}
+	var names []string
+	for _, a := range known {
+		if a.Name == s {
+			currentApp, appDetected = a, true
+			return nil
+		}
+		names = append(names, a.Name)
+	}
+	return fmt.Errorf("must be auto, %s", strings.Join(names, ", "))
+}
+
+func appNames() []string {
+	var names []string
+	for _, a := range apps {
+		names = append(names, a.Name)
+	}
+	return names
+}
+
+// appUsage returns the names of the built-in apps for the -app help, with the unverified ones marked.
+func appUsage() string {
+	var names []string
+	for _, a := range apps {
+		if a.Unverified {
+			names = append(names, a.Name+" (unverified)")
+		} else {
+			names = append(names, a.Name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// matchMu guards the match fields of the apps, compiled as archives are opened.
+var matchMu sync.Mutex
+
+// matcher returns the compiled Match of a.
+func (a *goApp) matcher() (*regexp.Regexp, error) {
+	matchMu.Lock()
+	defer matchMu.Unlock()
+	if a.match == nil {
+		m, err := regexp.Compile(a.Match)
+		if err != nil {
+			return nil, fmt.Errorf("app %s: %w", a.Name, err)
+		}
+		a.match = m
+	}
+	return a.match, nil
+}
+
+// archiveApp returns the app of the archive a from its iTunesMetadata.plist, the last of the known apps matching it,
+// or nil if a has none.
+func archiveApp(a *archive) (*goApp, error) {
+	for _, f := range a.Files {
+		if f.Name != "iTunesMetadata.plist" {
+			continue
+		}
+		b, err := f.ReadAll()
+		if err != nil {
+			return nil, err
+		}
+		known, err := knownApps()
+		if err != nil {
+			return nil, err
+		}
+		app := apps[0]
+		for _, k := range known {
+			if k.Match == "" {
+				continue
+			}
+			m, err := k.matcher()
+			if err != nil {
+				return nil, err
+			}
+			if m.Match(b) {
+				app = k
+			}
+		}
+		return app, nil
+	}
+	return nil, nil
+}
+
+// detectApp sets currentApp from the archive a, unless -app chose one or it was already detected.
+// With the settings of an injection, it sets their app instead, unless it is set.
+func detectApp(ctx context.Context, a *archive) error {
+	if s, ok := ctx.Value(settingsKey{}).(*settings); ok {
+		if s.app != nil {
+			return nil
+		}
+		app, err := archiveApp(a)
+		s.app = app
+		return err
+	}
+	if appDetected {
+		return nil
+	}
+	app, err := archiveApp(a)
+	if err != nil || app == nil {
+		return err
+	}
+	currentApp, appDetected = app, true
+	return nil
+}
+
+// hasOnlineGames reports whether a keeps its online games apart from the local ones.
+func (a *goApp) hasOnlineGames() bool { return a.Dirs[1] != a.Dirs[0] }
+
+// isGameFile reports whether the entry name is a local or online game of the current app.
+func isGameFile(name string, online bool) bool { return currentApp.isGameFile(name, online) }
+
+// isGameFile reports whether the entry name is a local or online game of a.
+// The games of apps without online games are all local.
+func (a *goApp) isGameFile(name string, online bool) bool {
+	if online && !a.hasOnlineGames() {
+		return false
+	}
+	return inDir(name, a.gameDir(online)) && (a.Ext == "" || strings.EqualFold(path.Ext(name), a.Ext))
+}
+
+// gameDir returns the directory of the local or online games of a.
+func (a *goApp) gameDir(online bool) string {
+	if online {
+		return a.Dirs[1]
+	}
+	return a.Dirs[0]
+}
+
+// appRecord returns the game file b of the current app as a Champion Go record.
+func appRecord(name string, b []byte) ([]byte, error) { return currentApp.record(name, b) }
+
+// record returns the game file b of a as a Champion Go record.
+func (a *goApp) record(name string, b []byte) ([]byte, error) {
+	toRecord := a.format().toRecord
+	if toRecord == nil {
+		return b, nil
+	}
+	r, err := toRecord(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return r, nil
+}
+
+// appEdit returns e with the records it writes to the game files of the app converted to the app's format,
+// from the game files of the archive avxName they replace.
+func appEdit(ctx context.Context, avxName string, e avxEdit) (avxEdit, error) {
+	app := settingsOf(ctx).app
+	fromRecord := app.format().fromRecord
+	if fromRecord == nil {
+		return e, nil
+	}
+	var a *archive
+	if len(e.Replace) > 0 {
+		var err error
+		if a, err = openArchive(ctx, avxName); err != nil {
+			return e, err
+		}
+		defer a.Close()
+	}
+	convert := func(m map[string][]byte) (map[string][]byte, error) {
+		if m == nil {
+			return nil, nil
+		}
+		c := make(map[string][]byte, len(m))
+		for name, b := range m {
+			if app.isGameFile(name, false) || app.isGameFile(name, true) {
+				var orig []byte
+				var err error
+				if a != nil {
+					if f := a.file(name); f != nil {
+						if orig, err = f.ReadAll(); err != nil {
+							return nil, err
+						}
+					}
+				}
+				if b, err = fromRecord(b, orig); err != nil {
+					return nil, fmt.Errorf("%s: %w", name, err)
+				}
+			}
+			c[name] = b
+		}
+		return c, nil
+	}
+	var err error
+	if e.Replace, err = convert(e.Replace); err != nil {
+		return e, err
+	}
+	e.Add, err = convert(e.Add)
+	return e, err
+}
+
+// sgfRecord returns the SGF game b as a record, dated by its DT property.
+func sgfRecord(b []byte) ([]byte, error) {
+	nodes, err := parseSGF(string(b))
+	if err != nil {
+		return nil, invalid(err)
+	}
+	size, moves, err := sgfMoves(nodes)
+	if err != nil {
+		return nil, err
+	}
+	g := &Game{Size: int32(size), Moves: moves}
+	if dt := nodes[0]["DT"]; len(dt) > 0 && len(dt[0]) >= 10 {
+		if t, err := time.ParseInLocation("2006-01-02", dt[0][:10], time.Local); err == nil {
+			g.Started, g.Saved = int32(t.Unix()), int32(t.Unix())
+		}
+	}
+	return g.Encode(), nil
+}
+
+// sgfRecordProps are the properties of SGF games that their records hold, written from the record.
+var sgfRecordProps = []string{"SZ", "DT", "AB", "AW", "AE", "PL", "B", "W", "BL", "WL"}
+
+// recordSGF returns the record b as an SGF game, dated by its saved date. The properties of the SGF game orig
+// it replaces that records do not hold are kept: those of its root, such as the players, komi, rules and comments,
+// and, if the record starts with all the moves of orig, its setup stones and the properties of its move nodes,
+// such as comments, followed by the further moves of the record. Only the main line of orig is kept.
+func recordSGF(b, orig []byte) ([]byte, error) {
+	g, err := Decode(b)
+	if err != nil {
+		return nil, err
+	}
+	var nodes []sgfNode
+	if orig != nil {
+		// An original that cannot be read is replaced whole.
+		nodes, _ = parseSGF(string(orig))
+	}
+	if len(nodes) == 0 {
+		nodes = []sgfNode{{"GM": {"1"}, "FF": {"4"}, "CA": {"UTF-8"}, "AP": {"chamgo"}}}
+	}
+	size, moves, err := sgfMoves(nodes)
+	kept := err == nil && size == int(g.Size) && len(moves) <= len(g.Moves)
+	for i := 0; kept && i < len(moves); i++ {
+		m, n := moves[i], g.Moves[i]
+		kept = m.X == n.X && m.Y == n.Y && m.Color == n.Color
+	}
+	if !kept {
+		root := make(sgfNode)
+		for id, v := range nodes[0] {
+			if !slices.Contains(sgfRecordProps, id) {
+				root[id] = v
+			}
+		}
+		nodes, moves = []sgfNode{root}, nil
+	}
+	nodes[0]["SZ"] = []string{strconv.Itoa(int(g.Size))}
+	if g.Saved > 0 {
+		nodes[0]["DT"] = []string{time.Unix(int64(g.Saved), 0).Format("2006-01-02")}
+	}
+	var s strings.Builder
+	s.WriteByte('(')
+	for _, n := range nodes {
+		writeSGFNode(&s, n)
+	}
+	for _, m := range g.Moves[len(moves):] {
+		s.WriteString(g.sgfMove(m))
+	}
+	s.WriteString(")\n")
+	return []byte(s.String()), nil
+}
+
+func appsCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("apps", flag.ContinueOnError)
+	avx := fs.String("a", "", "an archive whose app is detected")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("usage: chamgo apps [-a archive]")
+	}
+	known, err := knownApps()
+	if err != nil {
+		return err
+	}
+	if *avx != "" {
+		a, err := openArchive(ctx, *avx)
+		if err != nil {
+			return err
+		}
+		a.Close()
+		note(event{"event": "app", "archive": *avx, "app": currentApp.Name}, "%s: %s", *avx, currentApp.Name)
+	}
+	output(known, func(w io.Writer) {
+		for _, a := range known {
+			fmt.Fprintf(w, "%-12s %-6s %s", a.Name, a.Format, a.Dirs[0])
+			if a.hasOnlineGames() {
+				fmt.Fprintf(w, ", %s", a.Dirs[1])
+			}
+			fmt.Fprintf(w, "  %s", a.About)
+			if a.Unverified {
+				fmt.Fprintf(w, " (unverified)")
+			}
+			fmt.Fprintln(w)
+		}
+	})
+	return nil
+}
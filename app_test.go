@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestAppFixtures reads the archives of testdata, which are laid out as chamgo takes the archives of their apps to be.
+func TestAppFixtures(t *testing.T) {
+	tests := []struct {
+		archive string
+		app     string
+		moves   map[string]int // the number of moves of each local game
+	}{
+		{"testdata/crazystone.imazingapp", "crazystone", map[string]int{
+			"Container/Documents/game1.sgf": 3,
+			"Container/Documents/game2.sgf": 5, // two setup stones, as moves with a pass between them
+		}},
+		{"testdata/smartgo.imazingapp", "smartgo", map[string]int{
+			"Container/Documents/Kifu 1.sgf": 3,
+			"Container/Documents/Kifu 2.sgf": 5,
+		}},
+	}
+	ctx := context.Background()
+	for _, tt := range tests {
+		sess, err := NewSession(Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		a, err := sess.Open(ctx, tt.archive)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.archive, err)
+		}
+		if sess.settings.app.Name != tt.app {
+			t.Errorf("%s: detected %s, want %s", tt.archive, sess.settings.app.Name, tt.app)
+		}
+		games, err := a.Games(ctx, false)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.archive, err)
+		}
+		if len(games) != len(tt.moves) {
+			t.Errorf("%s: %d games, want %d", tt.archive, len(games), len(tt.moves))
+		}
+		for _, ge := range games {
+			g, err := Decode(ge.Body)
+			if err != nil {
+				t.Errorf("%s: %v", ge.Name, err)
+				continue
+			}
+			if want, ok := tt.moves[ge.Name]; !ok || len(g.Moves) != want || g.Size != 9 {
+				t.Errorf("%s: %d moves on %d×%d, want %d on 9×9", ge.Name, len(g.Moves), g.Size, g.Size, want)
+			}
+		}
+		if online, err := a.Games(ctx, true); err != nil || len(online) != 0 {
+			t.Errorf("%s: %d online games, %v", tt.archive, len(online), err)
+		}
+		sess.Close()
+	}
+}
+
+func TestRecordSGF(t *testing.T) {
+	const orig = "(;GM[1]FF[4]SZ[9]DT[2023-04-02]PB[Bob]KM[0.5]GC[a \\] b]AB[cg][gc];W[ee]C[Center];B[ec])"
+	record, err := sgfRecord([]byte(orig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := Decode(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	level := *g
+	level.Level = 7
+	longer := *g
+	longer.Moves = append(append([]Move(nil), g.Moves...), newMove(White, 3, 3))
+	flipped := *g
+	flipped.Moves = append([]Move(nil), g.Moves...)
+	symmetryTransform(symmetries["flip180"]).Apply(context.Background(), &flipped)
+
+	tests := []struct {
+		name    string
+		g       *Game
+		orig    string
+		want    string
+		without []string
+	}{
+		{"same moves", &level, orig,
+			"(;GM[1]FF[4]SZ[9]AB[cg][gc]DT[2023-04-02]GC[a \\] b]KM[0.5]PB[Bob];W[ee]C[Center];B[ec])\n", nil},
+		{"more moves", &longer, orig,
+			"(;GM[1]FF[4]SZ[9]AB[cg][gc]DT[2023-04-02]GC[a \\] b]KM[0.5]PB[Bob];W[ee]C[Center];B[ec];W[cc])\n", nil},
+		{"other moves", &flipped, orig, "KM[0.5]PB[Bob]", []string{"AB[", "C[Center]"}},
+		{"new game", &level, "", "(;GM[1]FF[4]CA[UTF-8]AP[chamgo]SZ[9]DT[2023-04-02];B[gc]", nil},
+	}
+	for _, tt := range tests {
+		var o []byte
+		if tt.orig != "" {
+			o = []byte(tt.orig)
+		}
+		b, err := recordSGF(tt.g.Encode(), o)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !strings.Contains(string(b), tt.want) {
+			t.Errorf("%s: %s, want %s", tt.name, b, tt.want)
+		}
+		for _, w := range tt.without {
+			if strings.Contains(string(b), w) {
+				t.Errorf("%s: %s has %s", tt.name, b, w)
+			}
+		}
+		if _, err := sgfRecord(b); err != nil {
+			t.Errorf("%s: %s: %v", tt.name, b, err)
+		}
+	}
+}
+
+// TestInjectSGFApp injects into the SGF games of the SmartGo One fixture, which keep their other properties.
+func TestInjectSGFApp(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out.imazingapp")
+	if err := inject(context.Background(), Options{Archive: "testdata/smartgo.imazingapp", Out: out, Player: "b"}); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sgf := string(zipEntries(t, b)["Container/Documents/Kifu 2.sgf"])
+	for _, want := range []string{"PB[Bob]", "KM[0.5]", "HA[2]", "AB[cg][gc]", ";W[ee]C[Center];B[ec]"} {
+		if !strings.Contains(sgf, want) {
+			t.Errorf("%s has no %s", sgf, want)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// autosaveRe matches the base names that the record the app keeps the game in progress in, outside of the game
+// directories, may have. It is a guess: no backup with such a record has been examined, so it covers the usual
+// spellings, and readAutosave only takes a record when exactly one matches.
+var autosaveRe = regexp.MustCompile(`(?i)auto.?save|current.?game|resume|in.?progress`)
+
+// readAutosave returns the name and record of the autosaved game of an archive: the one record of the app's container
+// named like one by autosaveRe that looks like the records the app writes. None or several are errors, rather than
+// a guess at which is the game in progress.
+func readAutosave(ctx context.Context, avxName string) (string, []byte, error) {
+	a, err := openArchive(ctx, avxName)
+	if err != nil {
+		return "", nil, err
+	}
+	defer a.Close()
+	return autosaveIn(ctx, a, avxName)
+}
+
+// autosaveIn is readAutosave on the opened archive a of avxName.
+func autosaveIn(ctx context.Context, a *archive, avxName string) (string, []byte, error) {
+	s := settingsOf(ctx)
+	var found []gameEntry
+	for _, f := range a.Files {
+		if err := ctx.Err(); err != nil {
+			return "", nil, err
+		}
+		if f.IsDir || !inDir(f.Name, "Container/") || inDir(f.Name, s.app.gameDir(false)) || inDir(f.Name, s.app.gameDir(true)) ||
+			!autosaveRe.MatchString(path.Base(f.Name)) || f.Size < headerLen {
+			continue
+		}
+		b, err := f.ReadAll()
+		if err != nil {
+			return "", nil, err
+		}
+		g, err := s.decode(b)
+		if err != nil || len(g.problems(len(b))) > 0 {
+			continue
+		}
+		found = append(found, gameEntry{Name: f.Name, Body: b})
+	}
+	switch len(found) {
+	case 0:
+		return "", nil, &codedError{exitNoGames, fmt.Errorf("%s has no autosaved game", avxName)}
+	case 1:
+		return found[0].Name, found[0].Body, nil
+	}
+	names := make([]string, len(found))
+	for i, ge := range found {
+		names[i] = ge.Name
+	}
+	return "", nil, fmt.Errorf("several records of %s may be the autosaved game: %s; leave out the others with -exclude",
+		avxName, strings.Join(names, ", "))
+}
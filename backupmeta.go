@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// backupPlists are the property lists at the top of an iTunes or Finder device backup.
+// Info.plist describes the device for iTunes, Manifest.plist holds the lockdown information of the device
+// and whether the backup is encrypted, and Status.plist whether the backup completed.
+var backupPlists = []string{"Info.plist", "Manifest.plist", "Status.plist"}
+
+// backupMeta is the metadata of a device backup directory.
+type backupMeta struct {
+	Dir     string
+	raw     map[string][]byte // the property lists as read, whose formats are kept when rewriting them
+	plists  map[string]*plistDict
+	changed map[string]bool
+}
+
+// readBackupMeta reads the top-level property lists of the device backup dir.
+func readBackupMeta(dir string) (*backupMeta, error) {
+	m := &backupMeta{Dir: dir, raw: make(map[string][]byte), plists: make(map[string]*plistDict), changed: make(map[string]bool)}
+	for _, name := range backupPlists {
+		b, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		v, err := parsePlist(b)
+		if err != nil {
+			return nil, invalid(fmt.Errorf("%s: %w", name, err))
+		}
+		d, ok := v.(*plistDict)
+		if !ok {
+			return nil, invalid(fmt.Errorf("%s is not a dictionary", name))
+		}
+		m.raw[name], m.plists[name] = b, d
+	}
+	return m, nil
+}
+
+// get returns the value at the key path of the property list name, such as "Lockdown", "UniqueDeviceID".
+func (m *backupMeta) get(name string, keys ...string) (interface{}, bool) {
+	var v interface{} = m.plists[name]
+	for _, k := range keys {
+		d, ok := v.(*plistDict)
+		if !ok {
+			return nil, false
+		}
+		if v, ok = d.Get(k); !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+func (m *backupMeta) getString(name string, keys ...string) string {
+	s, _ := m.get(name, keys...)
+	str, _ := s.(string)
+	return str
+}
+
+// backupKeys are the keys Finder expects in the backup property lists, with the types of their values.
+var backupKeys = []struct {
+	plist string
+	keys  []string
+	kind  string
+}{
+	{"Info.plist", []string{"Device Name"}, "string"},
+	{"Info.plist", []string{"Product Version"}, "string"},
+	{"Info.plist", []string{"Unique Identifier"}, "string"},
+	{"Info.plist", []string{"Last Backup Date"}, "date"},
+	{"Manifest.plist", []string{"Version"}, "string"},
+	{"Manifest.plist", []string{"Date"}, "date"},
+	{"Manifest.plist", []string{"IsEncrypted"}, "bool"},
+	{"Manifest.plist", []string{"Lockdown"}, "dict"},
+	{"Manifest.plist", []string{"Lockdown", "UniqueDeviceID"}, "string"},
+	{"Manifest.plist", []string{"Lockdown", "ProductVersion"}, "string"},
+	{"Manifest.plist", []string{"Lockdown", "DeviceName"}, "string"},
+	{"Status.plist", []string{"Version"}, "string"},
+	{"Status.plist", []string{"Date"}, "date"},
+	{"Status.plist", []string{"IsFullBackup"}, "bool"},
+	{"Status.plist", []string{"BackupState"}, "string"},
+	{"Status.plist", []string{"SnapshotState"}, "string"},
+	{"Status.plist", []string{"UUID"}, "string"},
+}
+
+func plistKind(v interface{}) string {
+	switch v.(type) {
+	case string:
+		return "string"
+	case time.Time:
+		return "date"
+	case bool:
+		return "bool"
+	case *plistDict:
+		return "dict"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// problems returns what would make Finder or iTunes reject the backup.
+func (m *backupMeta) problems() []string {
+	var ps []string
+	for _, k := range backupKeys {
+		v, ok := m.get(k.plist, k.keys...)
+		name := k.plist + " " + strings.Join(k.keys, ".")
+		switch {
+		case !ok:
+			ps = append(ps, name+" is missing")
+		case plistKind(v) != k.kind:
+			ps = append(ps, fmt.Sprintf("%s is a %s instead of a %s", name, plistKind(v), k.kind))
+		}
+	}
+	if id, udid := m.getString("Info.plist", "Unique Identifier"), m.getString("Manifest.plist", "Lockdown", "UniqueDeviceID"); id != "" && udid != "" && !strings.EqualFold(id, udid) {
+		ps = append(ps, fmt.Sprintf("Info.plist is of device %s but Manifest.plist of device %s", id, udid))
+	}
+	if v, lv := m.getString("Info.plist", "Product Version"), m.getString("Manifest.plist", "Lockdown", "ProductVersion"); v != "" && lv != "" && v != lv {
+		ps = append(ps, fmt.Sprintf("Info.plist is of iOS %s but Manifest.plist of iOS %s", v, lv))
+	}
+	if s := m.getString("Status.plist", "SnapshotState"); s != "" && s != "finished" {
+		ps = append(ps, fmt.Sprintf("the backup did not complete, its snapshot state is %q", s))
+	}
+	if !exists(filepath.Join(m.Dir, "Manifest.db")) && !exists(filepath.Join(m.Dir, "Manifest.mbdb")) {
+		ps = append(ps, "Manifest.db is missing")
+	}
+	return ps
+}
+
+func exists(name string) bool {
+	_, err := os.Stat(name)
+	return err == nil
+}
+
+// touch sets the dates of the backup to t, as Finder does when it updates a backup,
+// so that the backup is listed with the date its files were last changed.
+func (m *backupMeta) touch(t time.Time) {
+	t = t.UTC().Truncate(time.Second)
+	for _, k := range []struct{ plist, key string }{
+		{"Info.plist", "Last Backup Date"},
+		{"Manifest.plist", "Date"},
+		{"Status.plist", "Date"},
+	} {
+		if _, ok := m.plists[k.plist].Get(k.key); ok {
+			m.plists[k.plist].Set(k.key, t)
+			m.changed[k.plist] = true
+		}
+	}
+}
+
+// write rewrites the changed property lists, each in its original format.
+func (m *backupMeta) write() error {
+	for _, name := range backupPlists {
+		if !m.changed[name] {
+			continue
+		}
+		var b bytes.Buffer
+		if err := writePlist(&b, m.plists[name], m.raw[name]); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		fn := filepath.Join(m.Dir, name)
+		if err := ioutil.WriteFile(fn+".tmp", b.Bytes(), 0644); err != nil {
+			return err
+		}
+		if err := os.Rename(fn+".tmp", fn); err != nil {
+			return err
+		}
+		m.raw[name] = b.Bytes()
+		delete(m.changed, name)
+	}
+	return nil
+}
+
+// updateBackup updates the metadata of the device backup dir after its files were added or changed.
+func updateBackup(dir string) error {
+	m, err := readBackupMeta(dir)
+	if err != nil {
+		return err
+	}
+	m.touch(time.Now())
+	return m.write()
+}
+
+func backupCmd(ctx context.Context, args []string) error {
+	usage := fmt.Errorf("usage: chamgo backup info|check|touch backup-directory")
+	if len(args) == 0 {
+		return usage
+	}
+	fs := flag.NewFlagSet("backup "+args[0], flag.ContinueOnError)
+	if err := parseFlags(fs, args[1:]); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usage
+	}
+	dir := fs.Arg(0)
+
+	switch args[0] {
+	case "info":
+		m, err := readBackupMeta(dir)
+		if err != nil {
+			return err
+		}
+		type info struct {
+			Device     string      `json:"device"`
+			Product    string      `json:"product"`
+			IOS        string      `json:"ios"`
+			UDID       string      `json:"udid"`
+			Encrypted  interface{} `json:"encrypted"`
+			LastBackup interface{} `json:"last_backup"`
+			State      string      `json:"state"`
+		}
+		r := info{
+			Device:  m.getString("Manifest.plist", "Lockdown", "DeviceName"),
+			Product: m.getString("Manifest.plist", "Lockdown", "ProductType"),
+			IOS:     m.getString("Manifest.plist", "Lockdown", "ProductVersion"),
+			UDID:    m.getString("Manifest.plist", "Lockdown", "UniqueDeviceID"),
+			State:   m.getString("Status.plist", "SnapshotState"),
+		}
+		r.Encrypted, _ = m.get("Manifest.plist", "IsEncrypted")
+		r.LastBackup, _ = m.get("Status.plist", "Date")
+		output(r, func(w io.Writer) {
+			fmt.Fprintf(w, "device %s (%s), iOS %s\nudid %s\nencrypted %v\nlast backup %v, %s\n",
+				r.Device, r.Product, r.IOS, r.UDID, r.Encrypted, r.LastBackup, r.State)
+		})
+		return nil
+	case "check":
+		m, err := readBackupMeta(dir)
+		if err != nil {
+			return err
+		}
+		ps := m.problems()
+		for _, p := range ps {
+			note(event{"event": "problem", "problem": p}, "%s", p)
+		}
+		if len(ps) > 0 {
+			return invalid(fmt.Errorf("%s: %d problems", dir, len(ps)))
+		}
+		return nil
+	case "touch":
+		return updateBackup(dir)
+	}
+	return usage
+}
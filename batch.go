@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// batchItem injects the game Source into the online game Slot, for the human player of color Player at Level.
+type batchItem struct {
+	Source string
+	Slot   string
+	Player string
+	Level  int
+}
+
+// parseBatchMap parses a mapping file of batch injections, one a line, such as
+//
+//	# local game          online game          player and level, optional
+//	3f2a9c01b7de          latest-online        w 7
+//	Container/Documents/game/12  Container/Documents/game-online/4
+//
+// The games are selectors as -g takes them: entry names, fingerprints, latest or latest-online.
+// Lines without a player and level take the defaults p and level.
+func parseBatchMap(r io.Reader, p string, level int) ([]batchItem, error) {
+	var items []batchItem
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		f := strings.Fields(line)
+		if len(f) == 0 {
+			continue
+		}
+		if len(f) < 2 || len(f) > 4 {
+			return nil, fmt.Errorf("line %d: expected \"game online-game [b|w] [level]\"", n)
+		}
+		it := batchItem{Source: f[0], Slot: f[1], Player: p, Level: level}
+		for _, v := range f[2:] {
+			switch l, err := strconv.Atoi(v); {
+			case v == "b" || v == "w":
+				it.Player = v
+			case err == nil && l >= 1 && l <= 10:
+				it.Level = l
+			default:
+				return nil, fmt.Errorf("line %d: %q is neither b, w nor a level from 1 to 10", n, v)
+			}
+		}
+		items = append(items, it)
+	}
+	return items, sc.Err()
+}
+
+// assignBatch asks which local game goes into which online game until every online game is assigned or it is told to stop.
+func assignBatch(ctx context.Context, avx, p string, level int) ([]batchItem, error) {
+	local, err := listGames(ctx, avx, false)
+	if err != nil {
+		return nil, err
+	}
+	online, err := listGames(ctx, avx, true)
+	if err != nil {
+		return nil, err
+	}
+	if len(local) == 0 || len(online) == 0 {
+		return nil, errNoGames
+	}
+	in := bufio.NewReader(selectInput)
+	// selectGame reads the same buffered input, as bufio.NewReader returns in itself.
+	saved := selectInput
+	selectInput = in
+	defer func() { selectInput = saved }()
+
+	var items []batchItem
+	for len(online) > 0 {
+		src, err := selectGame(local, "local")
+		if err != nil {
+			return nil, err
+		}
+		slot, err := selectGame(online, "online")
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, batchItem{Source: src.Name, Slot: slot.Name, Player: p, Level: level})
+		for i, e := range online {
+			if e.Name == slot.Name {
+				online = append(online[:i], online[i+1:]...)
+				break
+			}
+		}
+		fmt.Fprintf(os.Stderr, "%s goes into %s\n", path.Base(src.Name), path.Base(slot.Name))
+		if len(online) == 0 {
+			break
+		}
+		more, err := ask(in, "assign another game (y/n)", "y")
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(strings.ToLower(more), "y") {
+			break
+		}
+	}
+	return items, nil
+}
+
+func batchCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("batch", flag.ContinueOnError)
+	avx := fs.String("a", "", "Champion Go archive")
+	mapping := fs.String("map", "", "the mapping file of the local games injected into each online game")
+	interactive := fs.Bool("i", false, "ask which local game goes into which online game instead of reading a mapping file")
+	p := fs.String("p", "b", "the color of the human player, unless the mapping gives one")
+	level := fs.Int("l", 10, "computer level, unless the mapping gives one")
+	out := fs.String("o", "-", "output archive")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if fs.NArg() > 0 || (*mapping == "") == !*interactive || (*p != "b" && *p != "w") {
+		return fmt.Errorf("usage: chamgo batch -a archive -map mapping.txt | -i [flags]")
+	}
+	if *level < 1 || *level > 10 {
+		return fmt.Errorf("level must be between 1 and 10")
+	}
+
+	var items []batchItem
+	var err error
+	if *interactive {
+		items, err = assignBatch(ctx, *avx, *p, *level)
+	} else {
+		var f *os.File
+		if f, err = os.Open(*mapping); err != nil {
+			return err
+		}
+		items, err = parseBatchMap(f, *p, *level)
+		f.Close()
+		if err != nil {
+			err = fmt.Errorf("%s: %w", *mapping, err)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return fmt.Errorf("no games to inject")
+	}
+
+	replace := make(map[string][]byte)
+	for _, it := range items {
+		src, body, err := readEntry(ctx, *avx, it.Source)
+		if err != nil {
+			return err
+		}
+		slot, base, err := readEntry(ctx, *avx, it.Slot)
+		if err != nil {
+			return err
+		}
+		if body == nil || base == nil {
+			return errNoGames
+		}
+		if currentApp.hasOnlineGames() && !isGameFile(slot, true) {
+			return fmt.Errorf("%s is not an online game", slot)
+		}
+		if _, ok := replace[slot]; ok {
+			return fmt.Errorf("%s is assigned twice", slot)
+		}
+		body = append([]byte(nil), body...)
+		if err := setPlayer(body, it.Player); err != nil {
+			return fmt.Errorf("%s: %w", src, err)
+		}
+		setLevel(body, byte(it.Level))
+		touchDates(body)
+		replace[slot] = body
+		note(event{"event": "inject", "game": src, "entry": slot, "player": it.Player, "level": it.Level},
+			"injecting %s into %s, human %s at level %d", src, slot, it.Player, it.Level)
+	}
+	return writeAvxFile(ctx, *out, *avx, avxEdit{Replace: replace})
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"time"
+)
+
+// benchPhase is the mean time of a phase of chamgo bench over its runs.
+type benchPhase struct {
+	Phase   string  `json:"phase"`
+	Games   int     `json:"games"`
+	Bytes   int64   `json:"bytes"` // of the records
+	Seconds float64 `json:"seconds"`
+}
+
+// benchResult is the output of chamgo bench.
+type benchResult struct {
+	Archive string       `json:"archive"`
+	Runs    int          `json:"runs"`
+	Go      string       `json:"go"` // the Go version and platform chamgo was built with
+	Phases  []benchPhase `json:"phases"`
+}
+
+// bench times reading the games of the archive avx, decoding them, applying the transforms ts, encoding them
+// and rewriting the archive with them, to ioutil.Discard, n times.
+func bench(ctx context.Context, avx string, ts []Transform, n int) (*benchResult, error) {
+	r := &benchResult{Archive: avx, Runs: n, Go: fmt.Sprintf("%s %s/%s", runtime.Version(), runtime.GOOS, runtime.GOARCH)}
+	phases := []string{"read", "decode", "transform", "encode", "rewrite"}
+	total := make(map[string]time.Duration)
+	var games []gameEntry
+	var decoded []*Game
+	var size int64
+	timed := func(phase string, f func() error) error {
+		start := time.Now()
+		err := f()
+		total[phase] += time.Since(start)
+		return err
+	}
+	for run := 0; run < n; run++ {
+		err := timed("read", func() error {
+			a, err := openArchive(ctx, avx)
+			if err != nil {
+				return err
+			}
+			defer a.Close()
+			games, size = nil, 0
+			for _, f := range a.Files {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				if f.IsDir || !isGameFile(f.Name, false) && !isGameFile(f.Name, true) {
+					continue
+				}
+				body, err := f.ReadAll()
+				if err == nil {
+					body, err = appRecord(f.Name, body)
+				}
+				if err != nil {
+					return err
+				}
+				games = append(games, gameEntry{Name: f.Name, Body: body})
+				size += int64(len(body))
+			}
+			return nil
+		})
+		if err == nil {
+			err = timed("decode", func() error {
+				decoded = decoded[:0]
+				for _, ge := range games {
+					g, err := Decode(ge.Body)
+					if err != nil {
+						return fmt.Errorf("%s: %w", ge.Name, err)
+					}
+					decoded = append(decoded, g)
+				}
+				return nil
+			})
+		}
+		if err == nil {
+			err = timed("transform", func() error {
+				for _, g := range decoded {
+					for _, t := range ts {
+						if err := t.Apply(ctx, g); err != nil {
+							return err
+						}
+					}
+				}
+				return nil
+			})
+		}
+		replace := make(map[string][]byte)
+		if err == nil {
+			err = timed("encode", func() error {
+				for i, g := range decoded {
+					replace[games[i].Name] = g.Encode()
+				}
+				return nil
+			})
+		}
+		if err == nil {
+			err = timed("rewrite", func() error {
+				return writeAvx(ctx, ioutil.Discard, avx, avxEdit{Replace: replace})
+			})
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, p := range phases {
+		r.Phases = append(r.Phases, benchPhase{Phase: p, Games: len(games), Bytes: size, Seconds: total[p].Seconds() / float64(n)})
+	}
+	return r, nil
+}
+
+func benchCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("bench", flag.ContinueOnError)
+	avx := fs.String("a", "", "Champion Go archive")
+	chain := fs.String("t", "flip180", "the transforms timed, comma separated")
+	n := fs.Int("n", 3, "the number of runs, whose mean times are shown")
+	cpuProfile := fs.String("cpuprofile", "", "write a CPU profile of the runs to this file, for go tool pprof")
+	memProfile := fs.String("memprofile", "", "write a memory profile after the runs to this file, for go tool pprof")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *avx == "" || fs.NArg() > 0 || *n < 1 {
+		return fmt.Errorf("usage: chamgo bench -a archive [-t transforms] [-n runs] [-cpuprofile file] [-memprofile file]")
+	}
+	ts, err := parseTransforms(*chain)
+	if err != nil {
+		return err
+	}
+
+	if *cpuProfile != "" {
+		f, err := os.Create(*cpuProfile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if err := pprof.StartCPUProfile(f); err != nil {
+			return err
+		}
+	}
+	r, err := bench(ctx, *avx, ts, *n)
+	if *cpuProfile != "" {
+		pprof.StopCPUProfile()
+	}
+	if err != nil {
+		return err
+	}
+	if *memProfile != "" {
+		f, err := os.Create(*memProfile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		runtime.GC()
+		if err := pprof.WriteHeapProfile(f); err != nil {
+			return err
+		}
+	}
+
+	output(r, func(w io.Writer) {
+		fmt.Fprintf(w, "%s, %d games, %s of records, mean of %d runs, %s\n", r.Archive, r.Phases[0].Games, formatBytes(r.Phases[0].Bytes), r.Runs, r.Go)
+		for _, p := range r.Phases {
+			d := time.Duration(p.Seconds * float64(time.Second))
+			perGame := time.Duration(0)
+			if p.Games > 0 {
+				perGame = d / time.Duration(p.Games)
+			}
+			fmt.Fprintf(w, "%-10s %12v %12v/game\n", p.Phase, d.Round(time.Microsecond), perGame)
+		}
+	})
+	return nil
+}
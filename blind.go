@@ -0,0 +1,37 @@
+package main
+
+import "fmt"
+
+// blindMode is set by -blind for blind go training: "hidden" draws boards without their stones,
+// and "one-color" draws all stones black, so that the colors have to be remembered.
+// It applies to the boards of replay, train and the SVG images, but not to GTP showboard.
+var blindMode = "none"
+
+type blindFlag struct{}
+
+func (blindFlag) String() string { return blindMode }
+
+func (blindFlag) Set(s string) error {
+	if s != "none" && s != "hidden" && s != "one-color" {
+		return fmt.Errorf(`must be "none", "hidden" or "one-color"`)
+	}
+	blindMode = s
+	return nil
+}
+
+// shown returns b as it is drawn in the -blind mode.
+func (b *Board) shown() *Board {
+	if blindMode == "none" {
+		return b
+	}
+	c := *b
+	c.Points = make([]Stone, len(b.Points))
+	if blindMode == "one-color" {
+		for i, s := range b.Points {
+			if s != Empty {
+				c.Points[i] = Black
+			}
+		}
+	}
+	return &c
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Board is a go board that plays moves, removing captured stones.
+type Board struct {
+	Size      int
+	Points    []Stone // row major
+	Prisoners [3]int  // the number of stones captured by black and white
+	ko        int     // the point that cannot be played because of ko, or -1
+	rules     Rules
+	seen      map[string]bool // the positions played so far, for superko
+}
+
+// NewBoard returns an empty board checking moves against the current rules.
+func NewBoard(size int) *Board { return newBoardRules(size, rules) }
+
+// newBoardRules returns an empty board of size on which moves are checked against r.
+func newBoardRules(size int, r Rules) *Board {
+	b := &Board{Size: size, Points: make([]Stone, size*size), ko: -1, rules: r, seen: make(map[string]bool)}
+	b.seen[b.positionKey(White)] = true
+	return b
+}
+
+// clone returns a copy of b that plays independently of it.
+func (b *Board) clone() *Board {
+	c := *b
+	c.Points = append([]Stone(nil), b.Points...)
+	c.seen = make(map[string]bool, len(b.seen))
+	for k := range b.seen {
+		c.seen[k] = true
+	}
+	return &c
+}
+
+func (b *Board) At(x, y int) Stone { return b.Points[(y-1)*b.Size+x-1] }
+
+func (b *Board) neighbors(p int) []int {
+	ns := make([]int, 0, 4)
+	x, y := p%b.Size, p/b.Size
+	if x > 0 {
+		ns = append(ns, p-1)
+	}
+	if x < b.Size-1 {
+		ns = append(ns, p+1)
+	}
+	if y > 0 {
+		ns = append(ns, p-b.Size)
+	}
+	if y < b.Size-1 {
+		ns = append(ns, p+b.Size)
+	}
+	return ns
+}
+
+func (b *Board) hasLiberty(p int) bool {
+	for _, n := range b.neighbors(p) {
+		if b.Points[n] == Empty {
+			return true
+		}
+	}
+	return false
+}
+
+// group returns the points of the group at p and its number of liberties.
+func (b *Board) group(p int) ([]int, int) {
+	color := b.Points[p]
+	seen := map[int]bool{p: true}
+	libs := make(map[int]bool)
+	stack := []int{p}
+	var stones []int
+	for len(stack) > 0 {
+		q := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		stones = append(stones, q)
+		for _, n := range b.neighbors(q) {
+			switch {
+			case b.Points[n] == Empty:
+				libs[n] = true
+			case b.Points[n] == color && !seen[n]:
+				seen[n] = true
+				stack = append(stack, n)
+			}
+		}
+	}
+	return stones, len(libs)
+}
+
+// Play plays the stone s at x, y and returns the number of stones it captured.
+// A move at 0, 0 is a pass.
+func (b *Board) Play(s Stone, x, y int) (int, error) {
+	if x == 0 && y == 0 {
+		b.ko = -1
+		b.seen[b.positionKey(s)] = true
+		return 0, nil
+	}
+	if x < 1 || x > b.Size || y < 1 || y > b.Size {
+		return 0, fmt.Errorf("%d,%d is outside of the board", x, y)
+	}
+	p := (y-1)*b.Size + x - 1
+	if b.Points[p] != Empty {
+		return 0, fmt.Errorf("%d,%d is occupied", x, y)
+	}
+	if p == b.ko {
+		return 0, fmt.Errorf("%d,%d is forbidden by ko", x, y)
+	}
+
+	b.Points[p] = s
+	var captured []int
+	for _, n := range b.neighbors(p) {
+		if b.Points[n] != s.Opponent() {
+			continue
+		}
+		if stones, libs := b.group(n); libs == 0 {
+			for _, q := range stones {
+				b.Points[q] = Empty
+			}
+			captured = append(captured, stones...)
+		}
+	}
+	stones, libs := b.group(p)
+	if libs == 0 && !b.rules.Suicide {
+		b.Points[p] = Empty
+		return 0, fmt.Errorf("%d,%d is suicide", x, y)
+	}
+	if libs == 0 {
+		for _, q := range stones {
+			b.Points[q] = Empty
+		}
+	}
+	key := b.positionKey(s)
+	if b.rules.Superko != "" && b.seen[key] {
+		for _, q := range stones {
+			b.Points[q] = s
+		}
+		for _, q := range captured {
+			b.Points[q] = s.Opponent()
+		}
+		b.Points[p] = Empty
+		return 0, fmt.Errorf("%d,%d repeats an earlier position, forbidden by %s superko", x, y, b.rules.Superko)
+	}
+	b.seen[key] = true
+
+	if libs == 0 {
+		b.Prisoners[s.Opponent()] += len(stones)
+	}
+	b.ko = -1
+	if len(captured) == 1 && len(stones) == 1 && libs == 1 {
+		b.ko = captured[0]
+	}
+	b.Prisoners[s] += len(captured)
+	return len(captured), nil
+}
+
+// checkSize rejects the board sizes no record has, before a board of the size is allocated.
+func checkSize(size int32) error {
+	if size < 1 || int(size) > len(columns) {
+		return invalid(fmt.Errorf("board size %d", size))
+	}
+	return nil
+}
+
+// replay plays the moves of g on a new board.
+func replay(g *Game) (*Board, error) { return replayRules(g, rules) }
+
+// replayRules plays the moves of g on a new board, under r.
+func replayRules(g *Game, r Rules) (*Board, error) {
+	if err := checkSize(g.Size); err != nil {
+		return nil, err
+	}
+	b := newBoardRules(int(g.Size), r)
+	for i, m := range g.Moves {
+		if !m.IsPass() && !g.onBoard(m) {
+			continue
+		}
+		if _, err := b.Play(m.Stone(), int(m.X), int(m.Y)); err != nil {
+			return b, invalid(fmt.Errorf("move %d: %w", i+1, err))
+		}
+	}
+	return b, nil
+}
+
+// columns are the column labels of a board, skipping I as is customary.
+const columns = "ABCDEFGHJKLMNOPQRSTUVWXYZ"
+
+// Print draws b in text, with row 1 at the bottom as in GTP coordinates.
+func (b *Board) Print(w io.Writer) {
+	header := "   " + strings.Join(strings.Split(columns[:b.Size], ""), " ")
+	fmt.Fprintln(w, header)
+	for y := 1; y <= b.Size; y++ {
+		row := b.Size - y + 1
+		fmt.Fprintf(w, "%2d", row)
+		for x := 1; x <= b.Size; x++ {
+			c := "."
+			switch b.At(x, y) {
+			case Black:
+				c = "X"
+			case White:
+				c = "O"
+			}
+			fmt.Fprint(w, " "+c)
+		}
+		fmt.Fprintf(w, " %2d\n", row)
+	}
+	fmt.Fprintln(w, header)
+}
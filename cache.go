@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// noCache is set by -no-cache to read every game of an archive again instead of its cached index.
+var noCache = false
+
+// writeCache is set by -cache to write the indexes of the archives read to the cache, which is otherwise only read,
+// so that listing or searching an archive leaves nothing behind unless asked to.
+var writeCache = false
+
+// useCache reports whether games are looked up in the cached indexes: not with -no-cache,
+// nor with the games database, which records the games as they are read.
+func (s *settings) useCache() bool { return !s.noCache && !resultsEnabled() }
+
+// cacheKeep is the number of archive indexes kept in $CHAMGO_HOME/cache, the least recently used ones being removed.
+const cacheKeep = 20
+
+// gameMeta is what listing and finding the games of an archive needs of a record,
+// cached so that the record need not be inflated and decoded again.
+type gameMeta struct {
+	Name   string `json:"name"`
+	Online bool   `json:"online"`
+	// Size and CRC32 are those of the entry in the archive, before games of other apps are converted.
+	Size   int64  `json:"size"`
+	CRC32  uint32 `json:"crc32"`
+	Header []byte `json:"header"` // the header of the record, as scanHeaders returns it
+	Hash   string `json:"hash"`   // movesHash of the record
+}
+
+// gameIndexFile is the cached index of an archive.
+type gameIndexFile struct {
+	Archive string     `json:"archive"` // the path the archive was indexed at
+	App     string     `json:"app"`
+	Mode    string     `json:"mode"` // the decoding mode
+	Games   []gameMeta `json:"games"`
+}
+
+// hashed reports whether all the games of idx have their hashes.
+func (idx *gameIndexFile) hashed() bool {
+	for _, g := range idx.Games {
+		if g.Hash == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// entryKey identifies the contents of a game entry across archives.
+type entryKey struct {
+	Name  string
+	Size  int64
+	CRC32 uint32
+}
+
+// archiveChecksum returns a checksum of the entries of a, from their names, sizes and CRC-32s,
+// and of what changes how they are read: the app and the decoding mode of s.
+// The CRC-32s of zip entries come from the zip directory, so that nothing is inflated.
+func archiveChecksum(s *settings, a *archive) (string, error) {
+	h := sha256.New()
+	h.Write([]byte(s.app.Name + "\x00" + s.decodeMode + "\x00"))
+	for _, f := range a.Files {
+		if f.IsDir {
+			continue
+		}
+		crc, err := f.checksum()
+		if err != nil {
+			return "", err
+		}
+		h.Write([]byte(f.Name + "\x00"))
+		binary.Write(h, binary.LittleEndian, [2]int64{f.Size, int64(crc)})
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// gameIndex returns the local and online games of the archive avxName in archive order,
+// from $CHAMGO_HOME/cache if the archive has not changed since it was indexed.
+func gameIndex(ctx context.Context, avxName string) ([]gameMeta, error) {
+	a, err := openArchive(ctx, avxName)
+	if err != nil {
+		return nil, err
+	}
+	defer a.Close()
+	return indexArchive(ctx, a, avxName, true)
+}
+
+// indexArchive is gameIndex on the opened archive a of avxName, with the hashes of the games only if hashes.
+// Without them, only the headers of the games that are not in the cache are read, as scanHeaders does.
+// The index is written to the cache only with writeCache.
+func indexArchive(ctx context.Context, a *archive, avxName string, hashes bool) ([]gameMeta, error) {
+	s := settingsOf(ctx)
+	sum, err := archiveChecksum(s, a)
+	if err != nil {
+		return nil, err
+	}
+	fn := ""
+	if !s.noCache {
+		if dir, err := chamgoPath("cache"); err == nil {
+			fn = filepath.Join(dir, sum+".json")
+			if idx, err := readGameIndex(fn); err == nil && (!hashes || idx.hashed()) {
+				if s.writeCache {
+					now := time.Now()
+					os.Chtimes(fn, now, now)
+				}
+				return idx.Games, nil
+			}
+		}
+	}
+
+	// The games of an updated archive whose entries are unchanged are taken from the indexes of earlier archives,
+	// so that a new daily backup only has its new and changed games read.
+	var known map[entryKey]gameMeta
+	if fn != "" {
+		known = knownGames(s, filepath.Dir(fn))
+	}
+	var games []gameMeta
+	for _, f := range a.Files {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		online := s.app.isGameFile(f.Name, true)
+		if f.IsDir || !online && !s.app.isGameFile(f.Name, false) {
+			continue
+		}
+		if g, ok := known[entryKey{f.Name, f.Size, f.crc}]; ok && (g.Hash != "" || !hashes) {
+			g.Online = online
+			games = append(games, g)
+			continue
+		}
+		g := gameMeta{Name: f.Name, Online: online, Size: f.Size, CRC32: f.crc}
+		if !hashes && s.app.format().toRecord == nil {
+			if g.Header, err = readHeader(f); err != nil {
+				return nil, err
+			}
+			games = append(games, g)
+			continue
+		}
+		// Hashes need the whole record, and other apps' games are converted whole.
+		body, err := f.ReadAll()
+		if err == nil {
+			body, err = s.app.record(f.Name, body)
+		}
+		if err != nil {
+			return nil, err
+		}
+		g.Header = body[:min(len(body), headerLen)]
+		if hashes {
+			g.Hash = movesHash(body)
+		}
+		games = append(games, g)
+	}
+	if fn != "" && s.writeCache {
+		abs, _ := filepath.Abs(avxName)
+		b, err := json.Marshal(gameIndexFile{Archive: abs, App: s.app.Name, Mode: s.decodeMode, Games: games})
+		if err == nil {
+			_, err = chamgoDir("cache")
+		}
+		if err == nil && writeFileAtomic(fn, b) == nil {
+			pruneCache(filepath.Dir(fn))
+		}
+	}
+	return games, nil
+}
+
+func readGameIndex(fn string) (*gameIndexFile, error) {
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	var idx gameIndexFile
+	if err := json.Unmarshal(b, &idx); err != nil {
+		return nil, err
+	}
+	return &idx, nil
+}
+
+// knownGames returns the games of the indexes of dir that were made for the app and decoding mode of s,
+// by their entries, from the most recently used index that has their hashes, or else the most recently used one.
+func knownGames(s *settings, dir string) map[entryKey]gameMeta {
+	known := make(map[entryKey]gameMeta)
+	for _, fi := range cachedIndexes(dir) {
+		idx, err := readGameIndex(filepath.Join(dir, fi.Name()))
+		if err != nil || idx.App != s.app.Name || idx.Mode != s.decodeMode {
+			continue
+		}
+		for _, g := range idx.Games {
+			k := entryKey{g.Name, g.Size, g.CRC32}
+			if old, ok := known[k]; !ok || old.Hash == "" && g.Hash != "" {
+				known[k] = g
+			}
+		}
+	}
+	return known
+}
+
+// cachedIndexes returns the indexes of dir, the most recently used first.
+func cachedIndexes(dir string) []os.FileInfo {
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var idx []os.FileInfo
+	for _, fi := range fis {
+		if strings.HasSuffix(fi.Name(), ".json") {
+			idx = append(idx, fi)
+		}
+	}
+	sort.Slice(idx, func(i, j int) bool { return idx[i].ModTime().After(idx[j].ModTime()) })
+	return idx
+}
+
+// pruneCache removes the indexes of dir but the cacheKeep most recently used ones.
+func pruneCache(dir string) {
+	idx := cachedIndexes(dir)
+	for _, fi := range idx[min(cacheKeep, len(idx)):] {
+		os.Remove(filepath.Join(dir, fi.Name()))
+	}
+}
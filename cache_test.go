@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIndexArchive(t *testing.T) {
+	avx := synthArchive(t, synthOptions{Version: currentLayout.Version, Local: 3, Online: 2, Size: 9, Moves: 10, Seed: 7})
+	type run struct{ write, hashes bool }
+	tests := []struct {
+		name   string
+		runs   []run
+		files  int  // the number of indexes in the cache afterwards
+		hashed bool // whether the cached index has the hashes of the games
+	}{
+		{"headers, read only", []run{{false, false}}, 0, false},
+		{"hashes, read only", []run{{false, true}}, 0, false},
+		{"headers, written", []run{{true, false}}, 1, false},
+		{"hashes, written", []run{{true, true}}, 1, true},
+		{"hashes after headers", []run{{true, false}, {true, true}}, 1, true},
+		{"headers after hashes", []run{{true, true}, {false, false}}, 1, true},
+	}
+	ctx := context.Background()
+	games := make(map[string][]byte)
+	for _, online := range []bool{false, true} {
+		gs, err := listGames(ctx, avx, online)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, g := range gs {
+			games[g.Name] = g.Body
+		}
+	}
+	for _, tt := range tests {
+		home := t.TempDir()
+		t.Setenv("CHAMGO_HOME", home)
+		var idx []gameMeta
+		var last run
+		for _, r := range tt.runs {
+			s := flagSettings()
+			s.writeCache = r.write
+			a, err := openArchive(ctx, avx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			idx, err = indexArchive(withSettings(ctx, s), a, avx, r.hashes)
+			a.Close()
+			if err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			last = r
+		}
+		if len(idx) != len(games) {
+			t.Errorf("%s: %d games, want %d", tt.name, len(idx), len(games))
+		}
+		for _, g := range idx {
+			body := games[g.Name]
+			if !bytes.Equal(g.Header, body[:headerLen]) {
+				t.Errorf("%s: %s: header % x, want % x", tt.name, g.Name, g.Header, body[:headerLen])
+			}
+			if want := movesHash(body); last.hashes && g.Hash != want || g.Hash != "" && g.Hash != want {
+				t.Errorf("%s: %s: hash %q, want %q", tt.name, g.Name, g.Hash, want)
+			}
+		}
+		fis, _ := os.ReadDir(filepath.Join(home, "cache"))
+		if len(fis) != tt.files {
+			t.Errorf("%s: %d cached indexes, want %d", tt.name, len(fis), tt.files)
+			continue
+		}
+		if tt.files > 0 {
+			cached, err := readGameIndex(filepath.Join(home, "cache", fis[0].Name()))
+			if err != nil || cached.hashed() != tt.hashed {
+				t.Errorf("%s: cached index hashed %v, %v, want %v", tt.name, cached != nil && cached.hashed(), err, tt.hashed)
+			}
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// checkedEntry is an entry of an archive that failed to read back.
+type checkedEntry struct {
+	Archive string `json:"archive"`
+	Entry   string `json:"entry"`
+	Error   string `json:"error"`
+}
+
+// checkArchive reads every entry of the archive name in parallel, which verifies the CRCs of zip entries,
+// the authentication codes of AES-encrypted ones and the checksum of gzipped tarballs, and returns the entries that failed.
+func checkArchive(ctx context.Context, name string) ([]checkedEntry, int, error) {
+	a, err := openArchive(ctx, name)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer a.Close()
+
+	files := make(chan *archiveFile)
+	var mu sync.Mutex
+	var bad []checkedEntry
+	var wg sync.WaitGroup
+	for w := 0; w < runtime.NumCPU(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for f := range files {
+				if err := readToEnd(f); err != nil {
+					mu.Lock()
+					bad = append(bad, checkedEntry{Archive: name, Entry: f.Name, Error: err.Error()})
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	n := 0
+	for _, f := range a.Files {
+		if ctx.Err() != nil {
+			break
+		}
+		if !f.IsDir {
+			files <- f
+			n++
+		}
+	}
+	close(files)
+	wg.Wait()
+	sort.Slice(bad, func(i, j int) bool { return bad[i].Entry < bad[j].Entry })
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+	return bad, n, nil
+}
+
+func readToEnd(f *archiveFile) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	_, err = io.Copy(ioutil.Discard, rc)
+	return err
+}
+
+func checkCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("check", flag.ContinueOnError)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("usage: chamgo check archive...")
+	}
+
+	bad := []checkedEntry{}
+	for _, avx := range fs.Args() {
+		b, n, err := checkArchive(ctx, avx)
+		if err != nil {
+			return fmt.Errorf("%s: %w", avx, err)
+		}
+		bad = append(bad, b...)
+		note(event{"event": "check", "archive": avx, "entries": n, "corrupted": len(b)}, "%s: %d entries, %d corrupted", avx, n, len(b))
+	}
+	output(bad, func(w io.Writer) {
+		for _, b := range bad {
+			fmt.Fprintf(w, "%s: %s: %s\n", b.Archive, b.Entry, b.Error)
+		}
+	})
+	if len(bad) > 0 {
+		return invalid(fmt.Errorf("%d corrupted entries", len(bad)))
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// clipboardCommands are the commands used to paste from and copy to the system clipboard, tried in order.
+func clipboardCommands() (pasteCmds, copyCmds [][]string) {
+	switch runtime.GOOS {
+	case "darwin":
+		return [][]string{{"pbpaste"}}, [][]string{{"pbcopy"}}
+	case "windows":
+		return [][]string{{"powershell.exe", "-NoProfile", "-Command", "Get-Clipboard -Raw"}}, [][]string{{"clip.exe"}}
+	}
+	return [][]string{{"wl-paste", "--no-newline"}, {"xclip", "-selection", "clipboard", "-o"}, {"xsel", "--clipboard", "--output"}},
+		[][]string{{"wl-copy"}, {"xclip", "-selection", "clipboard"}, {"xsel", "--clipboard", "--input"}}
+}
+
+func findClipboardCommand(cmds [][]string) ([]string, error) {
+	var names []string
+	for _, c := range cmds {
+		if _, err := exec.LookPath(c[0]); err == nil {
+			return c, nil
+		}
+		names = append(names, c[0])
+	}
+	return nil, fmt.Errorf("no clipboard command found, tried %s", strings.Join(names, ", "))
+}
+
+func readClipboard() (string, error) {
+	pasteCmds, _ := clipboardCommands()
+	c, err := findClipboardCommand(pasteCmds)
+	if err != nil {
+		return "", err
+	}
+	out, err := exec.Command(c[0], c[1:]...).Output()
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", c[0], err)
+	}
+	return string(out), nil
+}
+
+func writeClipboard(s string) error {
+	_, copyCmds := clipboardCommands()
+	c, err := findClipboardCommand(copyCmds)
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(c[0], c[1:]...)
+	cmd.Stdin = bytes.NewBufferString(s)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %w", c[0], err)
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The completion scripts call chamgo __complete with the words of the command line up to the one being completed,
+// which prints the candidates for that word one a line, or nothing to complete file names.
+const bashCompletion = `_chamgo() {
+	local IFS=$'\n'
+	COMPREPLY=($(chamgo __complete "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null))
+}
+complete -o default -F _chamgo chamgo
+`
+
+const zshCompletion = `#compdef chamgo
+_chamgo() {
+	local -a candidates
+	candidates=("${(@f)$(chamgo __complete "${(@)words[2,CURRENT]}" 2>/dev/null)}")
+	if [[ -n ${candidates[1]} ]]; then
+		compadd -Q -a candidates
+	else
+		_files
+	fi
+}
+compdef _chamgo chamgo
+`
+
+const fishCompletion = `function __chamgo_complete
+	chamgo __complete (commandline -opc)[2..-1] (commandline -ct) 2>/dev/null
+end
+complete -c chamgo -a '(__chamgo_complete)'
+`
+
+// gameFlags are the flags whose values are game selectors, completed from the archive of -a.
+var gameFlags = map[string]bool{"g": true, "slot": true}
+
+// flagValues returns the values completing the flag name, or nil.
+func flagValues(name string) []string {
+	switch name {
+	case "app":
+		return append([]string{"auto"}, appNames()...)
+	case "select":
+		return []string{"latest", "interactive"}
+	case "blind":
+		return []string{"none", "hidden", "one-color"}
+	case "mode":
+		return []string{"computer", "human"}
+	case "engine":
+		return engineNames()
+	case "rules":
+		return rulesetNames()
+	case "p", "tomove":
+		return []string{"b", "w"}
+	}
+	return nil
+}
+
+// flagUsageRe matches the flags in the usage of a flag set, and actionsRe the actions in the usage of a command.
+var (
+	flagUsageRe = regexp.MustCompile(`(?m)^  -(\S+)`)
+	actionsRe   = regexp.MustCompile(`usage: chamgo (?:\[[^\]]*\] )?\S+ ([a-z-]+(?:\|[a-z-]+)+)`)
+)
+
+// commandUsage returns what chamgo prints for args followed by -h, which is the usage of the flags of a command,
+// or the usage error of a command taking an action first.
+func commandUsage(ctx context.Context, args ...string) string {
+	self, err := os.Executable()
+	if err != nil {
+		return ""
+	}
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	var out bytes.Buffer
+	cmd := exec.CommandContext(ctx, self, append(args, "-h")...)
+	cmd.Stdout, cmd.Stderr = &out, &out
+	cmd.Run()
+	return out.String()
+}
+
+// gameSelectors returns the games of the archive avx as game selectors, by name and by fingerprint.
+func gameSelectors(ctx context.Context, avx string) []string {
+	c := []string{"latest", "latest-online", "autosave"}
+	for _, online := range []bool{false, true} {
+		games, err := listGames(ctx, avx, online)
+		if err != nil {
+			return c
+		}
+		for _, ge := range games {
+			c = append(c, ge.Name, fingerprint(movesHash(ge.Body)))
+		}
+	}
+	return c
+}
+
+// completions returns the candidates for the last of the words of a chamgo command line, without chamgo itself.
+func completions(ctx context.Context, words []string) []string {
+	cur, words := words[len(words)-1], words[:len(words)-1]
+	flagName := func(w string) string {
+		name, _, _ := strings.Cut(strings.TrimLeft(w, "-"), "=")
+		return name
+	}
+	// takesValue reports whether the word w is a global or main flag followed by its value.
+	takesValue := func(w string) bool {
+		if !strings.HasPrefix(w, "-") || strings.Contains(w, "=") {
+			return false
+		}
+		f := flag.Lookup(flagName(w))
+		if f == nil {
+			return false
+		}
+		b, ok := f.Value.(interface{ IsBoolFlag() bool })
+		return !ok || !b.IsBoolFlag()
+	}
+
+	var cmd string
+	var rest []string
+	avx := ""
+	for i := 0; i < len(words); i++ {
+		w := words[i]
+		if flagName(w) == "a" {
+			if _, v, ok := strings.Cut(w, "="); ok {
+				avx = v
+			} else if i+1 < len(words) {
+				avx = words[i+1]
+			}
+		}
+		if cmd == "" && !strings.HasPrefix(w, "-") {
+			if _, ok := commands[w]; ok {
+				cmd, rest = w, words[i+1:]
+				continue
+			}
+		}
+		if cmd == "" && takesValue(w) {
+			i++
+		}
+	}
+
+	var c []string
+	prev := ""
+	if len(words) > 0 {
+		prev = words[len(words)-1]
+	}
+	switch {
+	case strings.HasPrefix(prev, "-") && !strings.Contains(prev, "=") && gameFlags[flagName(prev)]:
+		if avx == "" {
+			return nil
+		}
+		c = gameSelectors(ctx, avx)
+	case strings.HasPrefix(prev, "-") && !strings.Contains(prev, "=") && flagValues(flagName(prev)) != nil:
+		c = flagValues(flagName(prev))
+	case cmd == "" && strings.HasPrefix(cur, "-"):
+		flag.VisitAll(func(f *flag.Flag) { c = append(c, "-"+f.Name) })
+	case cmd == "":
+		for name := range commands {
+			if !strings.HasPrefix(name, "_") {
+				c = append(c, name)
+			}
+		}
+	case strings.HasPrefix(cur, "-"):
+		args := []string{cmd}
+		if len(rest) > 0 && !strings.HasPrefix(rest[0], "-") {
+			args = append(args, rest[0])
+		}
+		for _, m := range flagUsageRe.FindAllStringSubmatch(commandUsage(ctx, args...), -1) {
+			c = append(c, "-"+m[1])
+		}
+	case len(rest) == 0:
+		if m := actionsRe.FindStringSubmatch(commandUsage(ctx, cmd)); m != nil {
+			c = strings.Split(m[1], "|")
+		}
+	}
+	var matching []string
+	for _, s := range c {
+		if strings.HasPrefix(s, cur) {
+			matching = append(matching, s)
+		}
+	}
+	sort.Strings(matching)
+	return matching
+}
+
+// __complete is registered apart from the other commands, since it completes their names.
+func init() {
+	commands["__complete"] = completeCmd
+}
+
+func completeCmd(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		args = []string{""}
+	}
+	for _, c := range completions(ctx, args) {
+		fmt.Println(c)
+	}
+	return nil
+}
+
+func completionCmd(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: chamgo completion bash|zsh|fish")
+	}
+	scripts := map[string]string{"bash": bashCompletion, "zsh": zshCompletion, "fish": fishCompletion}
+	s, ok := scripts[args[0]]
+	if !ok {
+		return fmt.Errorf("usage: chamgo completion bash|zsh|fish")
+	}
+	fmt.Print(s)
+	return nil
+}
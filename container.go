@@ -0,0 +1,363 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// archive is an opened Champion Go archive: an .imazingapp zip, a tarball,
+// or a directory tree already extracted from one, such as the output of iMazing's "extract app".
+type archive struct {
+	Files []*archiveFile
+	close func() error
+}
+
+// archiveFile is an entry of an archive.
+// Names use forward slashes, and directory names end with a slash, as in zip files.
+type archiveFile struct {
+	Name  string
+	IsDir bool
+	// raw is the name of the entry in the archive when Name normalizes it, which the entry keeps when written.
+	raw  string
+	Size int64 // the uncompressed size
+	// Encrypted is set for AES-encrypted zip entries, which are encrypted again when written to zip files.
+	Encrypted bool
+	open      func() (io.ReadCloser, error)
+	// zip is the entry of a zip archive, whose compressed data is copied as is when the entry is not edited.
+	zip *zip.File
+	// crc is the CRC-32 of the contents, if hasCRC, from the zip directory or computed when the entry was read.
+	crc    uint32
+	hasCRC bool
+}
+
+func (f *archiveFile) Open() (io.ReadCloser, error) { return f.open() }
+
+// outName returns the name f is written with under the edit e: the new name e gives it, or else its name in the archive.
+func (f *archiveFile) outName(e avxEdit) string {
+	if n, ok := e.Rename[f.Name]; ok {
+		return n
+	}
+	if f.raw != "" {
+		return f.raw
+	}
+	return f.Name
+}
+
+func (f *archiveFile) ReadAll() ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return ioutil.ReadAll(rc)
+}
+
+// checksum returns the CRC-32 of the contents of f, reading them unless the zip directory has it.
+func (f *archiveFile) checksum() (uint32, error) {
+	if !f.hasCRC {
+		b, err := f.ReadAll()
+		if err != nil {
+			return 0, err
+		}
+		f.crc, f.hasCRC = crc32.ChecksumIEEE(b), true
+	}
+	return f.crc, nil
+}
+
+// file returns the entry name of a, or nil if it has none.
+func (a *archive) file(name string) *archiveFile {
+	for _, f := range a.Files {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+func (a *archive) Close() error {
+	if a.close == nil {
+		return nil
+	}
+	return a.close()
+}
+
+// rawEdit returns e with the entries of a named by their names in a, which the files of a directory tree keep on disk.
+func (a *archive) rawEdit(e avxEdit) avxEdit {
+	raw := make(map[string]string)
+	for _, f := range a.Files {
+		if f.raw != "" {
+			raw[f.Name] = f.raw
+		}
+	}
+	if len(raw) == 0 {
+		return e
+	}
+	name := func(n string) string {
+		if r, ok := raw[n]; ok {
+			return r
+		}
+		return n
+	}
+	r := avxEdit{Replace: make(map[string][]byte), Rename: make(map[string]string), Delete: make(map[string]bool), Add: e.Add, NoJournal: e.NoJournal}
+	for n, body := range e.Replace {
+		r.Replace[name(n)] = body
+	}
+	for from, to := range e.Rename {
+		r.Rename[name(from)] = to
+	}
+	for n := range e.Delete {
+		r.Delete[name(n)] = true
+	}
+	return r
+}
+
+func isDir(name string) bool {
+	fi, err := os.Stat(name)
+	return err == nil && fi.IsDir()
+}
+
+// openArchive opens the archive name, which is a zip file, a tarball or a directory.
+// Only the entries selected by -include and -exclude, or the settings of ctx, are listed.
+func openArchive(ctx context.Context, name string) (*archive, error) {
+	s := settingsOf(ctx)
+	a, err := openArchiveFiles(name, s.password)
+	if err != nil {
+		return nil, err
+	}
+	files := a.Files[:0]
+	for _, f := range a.Files {
+		if n := normalizeEntryName(f.Name); n != f.Name {
+			f.raw, f.Name = f.Name, n
+		}
+		f.IsDir = f.IsDir || strings.HasSuffix(f.Name, "/")
+		if s.entryIncluded(f.Name, f.IsDir) {
+			files = append(files, f)
+		}
+	}
+	a.Files = files
+	if err := detectApp(ctx, a); err != nil {
+		a.Close()
+		return nil, err
+	}
+	return a, nil
+}
+
+// openArchiveFiles opens the archive name with all its entries, decrypting AES-encrypted zip entries with password.
+func openArchiveFiles(name, password string) (*archive, error) {
+	if isDir(name) {
+		return openDirArchive(name)
+	}
+	if ok, err := sniffTar(name); err != nil {
+		return nil, err
+	} else if ok {
+		return openTarArchive(name)
+	}
+	r, err := zip.OpenReader(name)
+	if err != nil {
+		return nil, err
+	}
+	a := &archive{close: r.Close}
+	for _, f := range r.File {
+		f := f
+		af := &archiveFile{Name: f.Name, IsDir: f.Mode().IsDir(), Size: int64(f.UncompressedSize64), open: f.Open, zip: f, crc: f.CRC32, hasCRC: true}
+		if f.Method == zipAESMethod {
+			// AE-2 entries leave the CRC-32 out.
+			af.hasCRC = f.CRC32 != 0
+			af.zip = nil
+			af.Encrypted = true
+			af.open = func() (io.ReadCloser, error) {
+				body, err := openZipAES(f, password)
+				if err != nil {
+					return nil, err
+				}
+				return ioutil.NopCloser(bytes.NewReader(body)), nil
+			}
+		}
+		a.Files = append(a.Files, af)
+	}
+	return a, nil
+}
+
+func openDirArchive(root string) (*archive, error) {
+	a := &archive{}
+	err := filepath.Walk(root, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		if fi.IsDir() {
+			a.Files = append(a.Files, &archiveFile{Name: name + "/", IsDir: true, open: func() (io.ReadCloser, error) {
+				return ioutil.NopCloser(strings.NewReader("")), nil
+			}})
+			return nil
+		}
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+		a.Files = append(a.Files, &archiveFile{Name: name, Size: fi.Size(), open: func() (io.ReadCloser, error) { return os.Open(p) }})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(a.Files, func(i, j int) bool { return a.Files[i].Name < a.Files[j].Name })
+	return a, nil
+}
+
+// readArchiveFile returns the contents of the entry name of the archive avxName.
+func readArchiveFile(ctx context.Context, avxName, name string) ([]byte, error) {
+	a, err := openArchive(ctx, avxName)
+	if err != nil {
+		return nil, err
+	}
+	defer a.Close()
+	if f := a.file(name); f != nil {
+		return f.ReadAll()
+	}
+	return nil, fmt.Errorf("%s: %s not found", avxName, name)
+}
+
+// writeDir writes the archive avxName with the edit e as a directory tree at out.
+// If out is the directory avxName itself, only the edited files are written.
+func writeDir(ctx context.Context, out, avxName string, e avxEdit) error {
+	if sameFile(out, avxName) {
+		a, err := openArchive(ctx, avxName)
+		if err != nil {
+			return err
+		}
+		a.Close()
+		return editDirInPlace(out, a.rawEdit(e))
+	}
+	a, err := openArchive(ctx, avxName)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+	for _, f := range a.Files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if e.Delete[f.Name] {
+			continue
+		}
+		// Directory trees separate names with slashes, whatever the archive does.
+		dst, err := safeJoin(out, strings.ReplaceAll(f.outName(e), `\`, "/"))
+		if err != nil {
+			return err
+		}
+		if f.IsDir {
+			if err := os.MkdirAll(dst, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+		body, ok := e.Replace[f.Name]
+		if !ok {
+			if body, err = f.ReadAll(); err != nil {
+				return err
+			}
+		}
+		if err := writeFileAll(dst, body); err != nil {
+			return err
+		}
+	}
+	return addToDir(out, e.Add)
+}
+
+// addToDir writes the added entries to the directory tree dir.
+func addToDir(dir string, add map[string][]byte) error {
+	for _, name := range sortedKeys(add) {
+		dst, err := safeJoin(dir, name)
+		if err != nil {
+			return err
+		}
+		if err := writeFileAll(dst, add[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func editDirInPlace(dir string, e avxEdit) error {
+	for name, body := range e.Replace {
+		dst, err := safeJoin(dir, name)
+		if err != nil {
+			return err
+		}
+		if err := writeFileAll(dst, body); err != nil {
+			return err
+		}
+	}
+	for name := range e.Delete {
+		dst, err := safeJoin(dir, name)
+		if err != nil {
+			return err
+		}
+		if err := os.RemoveAll(dst); err != nil {
+			return err
+		}
+	}
+	// Move renamed files aside first, so that renames may swap names.
+	tmp := make(map[string]string)
+	for from := range e.Rename {
+		src, err := safeJoin(dir, from)
+		if err != nil {
+			return err
+		}
+		tmp[from] = src + ".chamgo-rename"
+		if err := os.Rename(src, tmp[from]); err != nil {
+			return err
+		}
+	}
+	for from, to := range e.Rename {
+		dst, err := safeJoin(dir, to)
+		if err != nil {
+			return err
+		}
+		if err := os.Rename(tmp[from], dst); err != nil {
+			return err
+		}
+	}
+	return addToDir(dir, e.Add)
+}
+
+// safeJoin joins an archive entry name to dir, rejecting names that escape it.
+func safeJoin(dir, name string) (string, error) {
+	p := filepath.Join(dir, filepath.FromSlash(name))
+	if rel, err := filepath.Rel(dir, p); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("entry %s is outside of %s", name, dir)
+	}
+	return p, nil
+}
+
+func writeFileAll(name string, body []byte) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(name, body, 0644)
+}
+
+func sameFile(a, b string) bool {
+	fa, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	fb, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(fa, fb)
+}
@@ -0,0 +1,15 @@
+#!/bin/sh
+# Builds libchamgo, the record codec of chamgo as a C shared library, and its header libchamgo.h.
+set -e
+cd "$(dirname "$0")"
+case "$(go env GOOS)" in
+darwin) lib=libchamgo.dylib ;;
+windows) lib=chamgo.dll ;;
+*) lib=libchamgo.so ;;
+esac
+tmp=$(mktemp -d)
+trap 'rm -rf "$tmp"' EXIT
+cp ../*.go ../demo.imazingapp chamgo_cgo.go "$tmp"
+(cd "$tmp" && CGO_ENABLED=1 go build -buildmode=c-shared -o "$lib" *.go)
+mv "$tmp/$lib" .
+mv "$tmp/${lib%.*}.h" libchamgo.h
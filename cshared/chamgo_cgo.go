@@ -0,0 +1,111 @@
+//go:build ignore
+
+package main
+
+/*
+#include <stdint.h>
+#include <stdlib.h>
+#include <string.h>
+*/
+import "C"
+
+import (
+	"context"
+	"encoding/json"
+	"unsafe"
+)
+
+// This file is built with the sources of chamgo by build.sh into a C shared library, as it cannot sit next to them:
+// "go build *.go" would then need a C compiler on every platform. Its ignore build constraint keeps go build ./...
+// from building this directory alone, while the go command still builds the file when build.sh names it.
+//
+// Every function returns 0 on success, or else the exit code chamgo would exit with, see exitcode.go,
+// and sets *err to the message. Strings and buffers returned through pointers, including *err,
+// are allocated with malloc and freed by the caller with chamgo_free.
+
+// cBytes copies the n bytes at p.
+func cBytes(p *C.uint8_t, n C.size_t) []byte {
+	if p == nil {
+		return nil
+	}
+	return C.GoBytes(unsafe.Pointer(p), C.int(n))
+}
+
+// setBytes returns b in *out and *n, allocated with malloc.
+func setBytes(b []byte, out **C.uint8_t, n *C.size_t) {
+	p := C.malloc(C.size_t(max(len(b), 1)))
+	if len(b) > 0 {
+		C.memcpy(p, unsafe.Pointer(&b[0]), C.size_t(len(b)))
+	}
+	*out, *n = (*C.uint8_t)(p), C.size_t(len(b))
+}
+
+// status sets *errp to the message of err if it is not nil, and returns its exit code.
+func status(err error, errp **C.char) C.int {
+	if err == nil {
+		return 0
+	}
+	if errp != nil {
+		*errp = C.CString(err.Error())
+	}
+	return C.int(exitCode(err))
+}
+
+//export chamgo_decode
+func chamgo_decode(record *C.uint8_t, n C.size_t, out **C.char, errp **C.char) C.int {
+	g, err := Decode(cBytes(record, n))
+	if err != nil {
+		return status(err, errp)
+	}
+	b, err := json.Marshal(g)
+	if err != nil {
+		return status(err, errp)
+	}
+	*out = C.CString(string(b))
+	return 0
+}
+
+//export chamgo_encode
+func chamgo_encode(game *C.char, base *C.uint8_t, baseLen C.size_t, out **C.uint8_t, n *C.size_t, errp **C.char) C.int {
+	b, err := libEncode([]byte(C.GoString(game)), cBytes(base, baseLen))
+	if err != nil {
+		return status(err, errp)
+	}
+	setBytes(b, out, n)
+	return 0
+}
+
+//export chamgo_transform
+func chamgo_transform(record *C.uint8_t, recordLen C.size_t, chain *C.char, out **C.uint8_t, n *C.size_t, errp **C.char) C.int {
+	b, err := applyTransforms(context.Background(), cBytes(record, recordLen), C.GoString(chain))
+	if err != nil {
+		return status(err, errp)
+	}
+	setBytes(b, out, n)
+	return 0
+}
+
+//export chamgo_to_sgf
+func chamgo_to_sgf(record *C.uint8_t, n C.size_t, out **C.char, errp **C.char) C.int {
+	g, err := Decode(cBytes(record, n))
+	if err != nil {
+		return status(err, errp)
+	}
+	*out = C.CString(writeSGF(g))
+	return 0
+}
+
+//export chamgo_from_sgf
+func chamgo_from_sgf(sgf *C.char, base *C.uint8_t, baseLen C.size_t, out **C.uint8_t, n *C.size_t, errp **C.char) C.int {
+	b, err := sgfGame(context.Background(), C.GoString(sgf), cBytes(base, baseLen))
+	if err != nil {
+		return status(err, errp)
+	}
+	setBytes(b, out, n)
+	return 0
+}
+
+//export chamgo_free
+func chamgo_free(p unsafe.Pointer) {
+	C.free(p)
+}
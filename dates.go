@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// stringList is a repeatable string flag, such as the game selectors of dates -g.
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+// parseShift parses a duration such as -90m, 2h30m or, in days, 3d and -1d.
+func parseShift(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("bad number of days %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// parseDateTime parses a local date with an optional time, as 2006-01-02 or 2006-01-02 15:04, or "now".
+func parseDateTime(s string) (time.Time, error) {
+	if s == "now" {
+		return clock(), nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02T15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf(`bad date %q, expected 2006-01-02, 2006-01-02 15:04 or "now"`, s)
+}
+
+func datesCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("dates", flag.ContinueOnError)
+	avx := fs.String("a", "", "Champion Go archive")
+	out := fs.String("o", "-", "output archive")
+	var slots stringList
+	fs.Var(&slots, "g", `a game: an entry name, fingerprint, "latest", "latest-online" or "autosave", may be repeated`)
+	all := fs.Bool("all", false, "change every local and online game")
+	since := fs.String("since", "", "with -all, only the games saved since the day, as 2006-01-02")
+	until := fs.String("until", "", "with -all, only the games saved before the day, as 2006-01-02")
+	shiftFlag := fs.String("shift", "", "shift the dates by this duration, such as -2h or 3d")
+	startedFlag := fs.String("started", "", "set the started dates, as 2006-01-02 15:04 or now")
+	savedFlag := fs.String("saved", "", "set the saved dates, as 2006-01-02 15:04 or now")
+	stepFlag := fs.String("step", "", "with -started or -saved, add this duration for each following game, such as 1m")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	set := *startedFlag != "" || *savedFlag != ""
+	if fs.NArg() > 0 || (*shiftFlag != "") == set || *all && len(slots) > 0 || *stepFlag != "" && !set {
+		return fmt.Errorf("usage: chamgo dates [-all | -g game...] -a archive [-o out] -shift duration | [-started date] [-saved date] [-step duration]")
+	}
+	var shift, step time.Duration
+	var started, saved, from, to time.Time
+	var err error
+	for _, d := range []struct {
+		flag string
+		s    string
+		t    *time.Time
+	}{{"-started", *startedFlag, &started}, {"-saved", *savedFlag, &saved}, {"-since", *since, &from}, {"-until", *until, &to}} {
+		if d.s == "" {
+			continue
+		}
+		if *d.t, err = parseDateTime(d.s); err != nil {
+			return fmt.Errorf("%s: %w", d.flag, err)
+		}
+	}
+	for _, d := range []struct {
+		flag string
+		s    string
+		v    *time.Duration
+	}{{"-shift", *shiftFlag, &shift}, {"-step", *stepFlag, &step}} {
+		if d.s == "" {
+			continue
+		}
+		if *d.v, err = parseShift(d.s); err != nil {
+			return fmt.Errorf("%s: %w", d.flag, err)
+		}
+	}
+
+	// The games are changed in the order of -g, or with -all in the order they were saved,
+	// so that -step keeps or sets the order of the app's list.
+	var games []gameEntry
+	if *all {
+		for _, online := range []bool{false, true} {
+			gs, err := listGames(ctx, *avx, online)
+			if err != nil {
+				return err
+			}
+			games = append(games, gs...)
+		}
+		savedAt := func(e gameEntry) int32 { t, _ := getSavedDate(e.Body); return t }
+		sort.SliceStable(games, func(i, j int) bool { return savedAt(games[i]) < savedAt(games[j]) })
+	} else {
+		if len(slots) == 0 {
+			slots = stringList{"latest"}
+		}
+		for _, s := range slots {
+			name, body, err := readEntry(ctx, *avx, s)
+			if err != nil {
+				return err
+			}
+			if name == "" {
+				return errNoGames
+			}
+			games = append(games, gameEntry{Name: name, Body: body})
+		}
+	}
+
+	e := avxEdit{Replace: make(map[string][]byte)}
+	for _, ge := range games {
+		if _, ok := e.Replace[ge.Name]; ok {
+			return fmt.Errorf("%s is selected twice", ge.Name)
+		}
+		g, err := Decode(ge.Body)
+		if err != nil {
+			return fmt.Errorf("%s: %w", ge.Name, err)
+		}
+		was := time.Unix(int64(g.Saved), 0)
+		if *all && (!from.IsZero() && was.Before(from) || !to.IsZero() && !was.Before(to)) {
+			continue
+		}
+		oldStarted, oldSaved := g.Started, g.Saved
+		if set {
+			offset := time.Duration(len(e.Replace)) * step
+			if !started.IsZero() {
+				g.Started = int32(started.Add(offset).Unix())
+			}
+			if !saved.IsZero() {
+				g.Saved = int32(saved.Add(offset).Unix())
+			}
+			// A game cannot be saved before it started.
+			if g.Started > g.Saved {
+				if started.IsZero() {
+					g.Started = g.Saved
+				} else {
+					g.Saved = g.Started
+				}
+			}
+		} else {
+			g.Started += int32(shift / time.Second)
+			g.Saved += int32(shift / time.Second)
+		}
+		note(event{"event": "dates", "entry": ge.Name, "started": g.Started, "saved": g.Saved},
+			"%s: started %s -> %s, saved %s -> %s", ge.Name, formatDate(oldStarted), formatDate(g.Started), formatDate(oldSaved), formatDate(g.Saved))
+		e.Replace[ge.Name] = g.Encode()
+	}
+	note(event{"event": "summary", "changed": len(e.Replace)}, "%d games changed", len(e.Replace))
+	if len(e.Replace) == 0 {
+		return errNoGames
+	}
+	return writeAvxFile(ctx, *out, *avx, e)
+}
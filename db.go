@@ -0,0 +1,402 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// indexName is the SQLite database an SGF collection is indexed in, at the top of the collection.
+const indexName = "chamgo-index.db"
+
+// indexDepth is how many positions of each game are hashed by default, enough for openings and joseki.
+const indexDepth = 80
+
+// indexedGame is the entry of an SGF file in the index of a collection, a row of its games table.
+type indexedGame struct {
+	Path     string // relative to the collection
+	Size     int64
+	Modified time.Time
+	Error    string // why the file could not be read, if it could not
+
+	Black     string
+	White     string
+	BlackRank string
+	WhiteRank string
+	Date      string
+	Result    string
+	Event     string
+	Handicap  int
+	Source    string // the collection whose conventions were applied, as GoGoD
+	BoardSize int
+	Moves     int
+	Hashes    []string // the hashes of the positions after each move, as in Board.CanonicalHash, in the positions table
+}
+
+// The tables of the index of a collection: games, with a row for each SGF file, and positions,
+// with the hash of each position of a game after its move-th move, game being the rowid of the game.
+var (
+	indexGameColumns = []string{"path TEXT NOT NULL", "size INTEGER NOT NULL", "modified INTEGER NOT NULL", "error TEXT",
+		"black TEXT", "white TEXT", "black_rank TEXT", "white_rank TEXT", "date TEXT", "result TEXT", "event TEXT",
+		"handicap INTEGER", "source TEXT", "board_size INTEGER", "moves INTEGER"}
+	indexPositionColumns = []string{"game INTEGER NOT NULL", "move INTEGER NOT NULL", "hash TEXT NOT NULL"}
+)
+
+// row returns the row of e in the games table, with its modification time in Unix nanoseconds.
+func (e indexedGame) row() []interface{} {
+	return []interface{}{e.Path, e.Size, e.Modified.UnixNano(), sqlNull(e.Error),
+		sqlNull(e.Black), sqlNull(e.White), sqlNull(e.BlackRank), sqlNull(e.WhiteRank), sqlNull(e.Date), sqlNull(e.Result), sqlNull(e.Event),
+		e.Handicap, sqlNull(e.Source), e.BoardSize, e.Moves}
+}
+
+func indexedGameOf(r sqliteRow) indexedGame {
+	return indexedGame{Path: r.str(0), Size: r.int(1), Modified: time.Unix(0, r.int(2)), Error: r.str(3),
+		Black: r.str(4), White: r.str(5), BlackRank: r.str(6), WhiteRank: r.str(7), Date: r.str(8), Result: r.str(9), Event: r.str(10),
+		Handicap: int(r.int(11)), Source: r.str(12), BoardSize: int(r.int(13)), Moves: int(r.int(14))}
+}
+
+// indexPath returns the index of the SGF collection dir.
+func indexPath(dir string) string {
+	return filepath.Join(dir, indexName)
+}
+
+// indexSGF reads the SGF file fn into an index entry, hashing the first depth positions.
+func indexSGF(fn string, depth int) indexedGame {
+	var e indexedGame
+	s, err := ioutil.ReadFile(fn)
+	if err != nil {
+		e.Error = err.Error()
+		return e
+	}
+	nodes, err := parseSGF(string(s))
+	if err != nil {
+		e.Error = err.Error()
+		return e
+	}
+	size, moves, err := sgfMoves(nodes)
+	if err != nil {
+		e.Error = err.Error()
+		return e
+	}
+	prop := func(id string) string {
+		if v := nodes[0][id]; len(v) > 0 {
+			return strings.TrimSpace(v[0])
+		}
+		return ""
+	}
+	e.Black, e.White = prop("PB"), prop("PW")
+	e.BlackRank, e.WhiteRank = prop("BR"), prop("WR")
+	e.Date, e.Result, e.Event = prop("DT"), prop("RE"), prop("EV")
+	e.Handicap, e.BoardSize, e.Moves = sgfHandicap(nodes[0]), size, len(moves)
+	if isGoGoD(fn, nodes[0]) {
+		gogodEntry(&e, fn, nodes[0])
+	}
+
+	b := NewBoard(size)
+	for _, m := range moves[:min(depth, len(moves))] {
+		if _, err := b.Play(m.Stone(), int(m.X), int(m.Y)); err != nil {
+			break
+		}
+		h, _ := b.CanonicalHash()
+		e.Hashes = append(e.Hashes, formatHash(h))
+	}
+	return e
+}
+
+// readIndex returns the entries of the index of the SGF collection dir, with their hashes, keyed by path.
+func readIndex(dir string) (map[string]indexedGame, error) {
+	db, err := openSQLiteFile(indexPath(dir))
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	rows, err := db.table("games")
+	if err != nil {
+		return nil, err
+	}
+	positions, err := db.table("positions")
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int64]*indexedGame)
+	for _, r := range rows {
+		e := indexedGameOf(r)
+		byID[r.RowID] = &e
+	}
+	// Positions are written in order, game by game.
+	for _, p := range positions {
+		if e := byID[p.int(0)]; e != nil && int(p.int(1)) == len(e.Hashes)+1 {
+			e.Hashes = append(e.Hashes, p.str(2))
+		}
+	}
+	games := make(map[string]indexedGame)
+	for _, e := range byID {
+		games[e.Path] = *e
+	}
+	return games, nil
+}
+
+// writeIndex writes the index of the SGF collection dir, replacing the previous one at once.
+func writeIndex(dir string, games []indexedGame) error {
+	gt := sqliteTable{Name: "games", Columns: indexGameColumns, Indexes: []sqliteIndex{
+		{Name: "games_path", Unique: true, Columns: []int{0}},
+		{Name: "games_date", Columns: []int{8}},
+	}}
+	pt := sqliteTable{Name: "positions", Columns: indexPositionColumns, Indexes: []sqliteIndex{
+		{Name: "positions_hash", Columns: []int{2, 0}},
+	}}
+	for i, e := range games {
+		gt.Rows = append(gt.Rows, e.row())
+		for m, h := range e.Hashes {
+			pt.Rows = append(pt.Rows, []interface{}{i + 1, m + 1, h})
+		}
+	}
+	return writeSQLite(indexPath(dir), []sqliteTable{gt, pt})
+}
+
+// indexCollection indexes the SGF files of dir, reusing the entries of unchanged files from the previous index.
+func indexCollection(ctx context.Context, dir string, depth int) error {
+	old, err := readIndex(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	fns, err := sgfFiles(ctx, dir)
+	if err != nil {
+		return err
+	}
+
+	var games []indexedGame
+	indexed, reused, failed := 0, 0, 0
+	for _, fn := range fns {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		fi, err := os.Stat(fn)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, fn)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		e, ok := old[rel]
+		if ok && e.Size == fi.Size() && e.Modified.Equal(fi.ModTime()) && (e.Error != "" || len(e.Hashes) >= min(depth, e.Moves)) {
+			reused++
+		} else {
+			e = indexSGF(fn, depth)
+			e.Path, e.Size, e.Modified = rel, fi.Size(), fi.ModTime()
+			indexed++
+		}
+		if e.Error != "" {
+			failed++
+			note(event{"event": "skipped", "path": rel, "error": e.Error}, "skipped %s: %s", rel, e.Error)
+		}
+		games = append(games, e)
+	}
+	if err := writeIndex(dir, games); err != nil {
+		return err
+	}
+	note(event{"event": "summary", "games": len(fns), "indexed": indexed, "unchanged": reused, "skipped": failed},
+		"%d games in %s, %d indexed, %d unchanged, %d skipped", len(fns), indexPath(dir), indexed, reused, failed)
+	return nil
+}
+
+// indexQuery selects games of an index. Empty fields match every game.
+type indexQuery struct {
+	Player string // part of the name of either player, ignoring case
+	Date   string // prefix of the date
+	Result string // prefix of the result, such as "B+"
+	Event  string // part of the event, ignoring case
+	Hash   string // the canonical hash of a position reached in the indexed moves, in any orientation
+}
+
+// match reports whether e matches q but for the hash, which queryIndex looks up in the positions index.
+func (q indexQuery) match(e indexedGame) bool {
+	if e.Error != "" {
+		return false
+	}
+	if q.Player != "" {
+		p := strings.ToLower(q.Player)
+		if !strings.Contains(strings.ToLower(e.Black), p) && !strings.Contains(strings.ToLower(e.White), p) {
+			return false
+		}
+	}
+	if q.Event != "" && !strings.Contains(strings.ToLower(e.Event), strings.ToLower(q.Event)) {
+		return false
+	}
+	return strings.HasPrefix(e.Date, q.Date) && strings.HasPrefix(strings.ToUpper(e.Result), strings.ToUpper(q.Result))
+}
+
+// queryIndex returns the games of the index of the SGF collection dir matching q, sorted by path.
+// Games are looked up by the hash or the date through the indexes of the database, and otherwise scanned.
+func queryIndex(dir string, q indexQuery) ([]indexedGame, error) {
+	db, err := openSQLiteFile(indexPath(dir))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%s is not indexed, run chamgo db index %s", dir, dir)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	root, err := db.root("table", "games")
+	if err != nil {
+		return nil, err
+	}
+	var rows []sqliteRow
+	if q.Hash != "" || q.Date != "" {
+		// The entries of positions_hash hold the rowid of the game after the hash.
+		index, from, to, game := "games_date", interface{}(q.Date), sqlitePrefixEnd(q.Date), -1
+		if q.Hash != "" {
+			index, from, to, game = "positions_hash", q.Hash, q.Hash+"\x00", 1
+		}
+		iroot, err := db.root("index", index)
+		if err != nil {
+			return nil, err
+		}
+		entries, err := db.indexRange(iroot, from, to)
+		if err != nil {
+			return nil, err
+		}
+		seen := make(map[int64]bool)
+		for _, e := range entries {
+			id := e.RowID
+			if game >= 0 {
+				id = e.int(game)
+			}
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			r, ok, err := db.row(root, id)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				rows = append(rows, r)
+			}
+		}
+	} else if rows, err = db.rows(root); err != nil {
+		return nil, err
+	}
+	var found []indexedGame
+	for _, r := range rows {
+		if e := indexedGameOf(r); q.match(e) {
+			found = append(found, e)
+		}
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].Path < found[j].Path })
+	return found, nil
+}
+
+// diagramHash returns the canonical hash of the position in the board diagram file fn.
+func diagramHash(fn string) (string, error) {
+	p, err := readDiagram(fn, Empty)
+	if err != nil {
+		return "", err
+	}
+	h, _ := p.toBoard().CanonicalHash()
+	return formatHash(h), nil
+}
+
+func dbCmd(ctx context.Context, args []string) error {
+	usage := fmt.Errorf("usage: chamgo db index|query [flags] [directory] | chamgo db hash diagram.txt")
+	if len(args) == 0 {
+		return usage
+	}
+	fs := flag.NewFlagSet("db "+args[0], flag.ContinueOnError)
+	depth := fs.Int("depth", indexDepth, "hash the positions of this many moves of each game")
+	player := fs.String("player", "", "games of the player whose name contains this")
+	date := fs.String("date", "", "games played on dates starting with this, such as 2016-03")
+	result := fs.String("result", "", "games with results starting with this, such as B+ or W+R")
+	eventFlag := fs.String("event", "", "games of the events whose names contain this, such as Honinbo")
+	position := fs.String("position", "", "games reaching the position of this board diagram")
+	hash := fs.String("hash", "", "games reaching the position with this canonical hash, as printed by chamgo db hash")
+	if err := parseFlags(fs, args[1:]); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		return usage
+	}
+	dir := fs.Arg(0)
+	if args[0] == "hash" {
+		if fs.NArg() != 1 {
+			return usage
+		}
+		p, err := readDiagram(dir, Empty)
+		if err != nil {
+			return err
+		}
+		b := p.toBoard()
+		h, sym := b.CanonicalHash()
+		type hashes struct {
+			Hash      string `json:"hash"`
+			Canonical string `json:"canonical"`
+			Symmetry  string `json:"symmetry,omitempty"`
+		}
+		r := hashes{formatHash(b.Hash()), formatHash(h), sym}
+		output(r, func(w io.Writer) {
+			fmt.Fprintf(w, "hash %s\ncanonical %s", r.Hash, r.Canonical)
+			if r.Symmetry != "" {
+				fmt.Fprintf(w, " under %s", r.Symmetry)
+			}
+			fmt.Fprintln(w)
+		})
+		return nil
+	}
+	if dir == "" {
+		var err error
+		if dir, err = chamgoDir("sgf"); err != nil {
+			return err
+		}
+	}
+
+	switch args[0] {
+	case "index":
+		return indexCollection(ctx, dir, *depth)
+	case "query":
+		q := indexQuery{Player: *player, Date: *date, Result: *result, Event: *eventFlag, Hash: strings.ToLower(*hash)}
+		if *position != "" {
+			h, err := diagramHash(*position)
+			if err != nil {
+				return err
+			}
+			q.Hash = h
+		}
+		found, err := queryIndex(dir, q)
+		if err != nil {
+			return err
+		}
+		type match struct {
+			Path   string `json:"path"`
+			Black  string `json:"black"`
+			White  string `json:"white"`
+			Date   string `json:"date"`
+			Result string `json:"result"`
+			Event  string `json:"event,omitempty"`
+			Moves  int    `json:"moves"`
+		}
+		matches := []match{}
+		for _, e := range found {
+			matches = append(matches, match{filepath.Join(dir, filepath.FromSlash(e.Path)), e.Black, e.White, e.Date, e.Result, e.Event, e.Moves})
+		}
+		output(matches, func(w io.Writer) {
+			for _, m := range matches {
+				fmt.Fprintf(w, "%s\t%s - %s\t%s\t%s\t%d moves", m.Path, m.Black, m.White, m.Date, m.Result, m.Moves)
+				if m.Event != "" {
+					fmt.Fprintf(w, "\t%s", m.Event)
+				}
+				fmt.Fprintln(w)
+			}
+		})
+		return nil
+	}
+	return usage
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestIndexCollection(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.sgf":     "(;GM[1]FF[4]SZ[19]PB[Lee Sedol]PW[Cho U]DT[2016-03-09]RE[W+R]EV[Meijin];B[pd];W[dd];B[pq])",
+		"sub/b.sgf": "(;GM[1]FF[4]SZ[19]PB[Ke Jie]PW[Lee Sedol]DT[2017-05-23]RE[B+0.5];B[dp];W[pp])", // a's first move, reflected
+		"bad.sgf":   "(;GM[1]FF[4]SZ[19];B[pd]",
+	}
+	for name, s := range files {
+		fn := filepath.Join(dir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(fn), 0755)
+		if err := ioutil.WriteFile(fn, []byte(s), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := queryIndex(dir, indexQuery{}); err == nil {
+		t.Error("no error querying a collection not indexed")
+	}
+	ctx := context.Background()
+	if err := indexCollection(ctx, dir, indexDepth); err != nil {
+		t.Fatal(err)
+	}
+	games, err := readIndex(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := games["a.sgf"]
+	if len(games) != 3 || games["bad.sgf"].Error == "" || a.Black != "Lee Sedol" || a.Moves != 3 || len(a.Hashes) != 3 || a.Event != "Meijin" {
+		t.Fatalf("index %+v", games)
+	}
+	first := a.Hashes[0]
+
+	tests := []struct {
+		name string
+		q    indexQuery
+		want []string
+	}{
+		{"all", indexQuery{}, []string{"a.sgf", "sub/b.sgf"}},
+		{"player", indexQuery{Player: "LEE"}, []string{"a.sgf", "sub/b.sgf"}},
+		{"date", indexQuery{Date: "2016"}, []string{"a.sgf"}},
+		{"date and result", indexQuery{Date: "201", Result: "b+"}, []string{"sub/b.sgf"}},
+		{"no date", indexQuery{Date: "1999"}, nil},
+		{"hash", indexQuery{Hash: first}, []string{"a.sgf", "sub/b.sgf"}},
+		{"hash and player", indexQuery{Hash: first, Player: "ke"}, []string{"sub/b.sgf"}},
+		{"later hash", indexQuery{Hash: a.Hashes[2]}, []string{"a.sgf"}},
+		{"unknown hash", indexQuery{Hash: "0000000000000000"}, nil},
+	}
+	for _, tt := range tests {
+		found, err := queryIndex(dir, tt.q)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		var got []string
+		for _, e := range found {
+			got = append(got, e.Path)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	// Reindexing keeps the entries of unchanged files, and rereads the others.
+	if err := ioutil.WriteFile(filepath.Join(dir, "bad.sgf"), []byte(files["a.sgf"]), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := indexCollection(ctx, dir, indexDepth); err != nil {
+		t.Fatal(err)
+	}
+	again, err := readIndex(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fixed := again["bad.sgf"]; fixed.Error != "" || !reflect.DeepEqual(fixed.Hashes, a.Hashes) {
+		t.Errorf("reindexed %+v", fixed)
+	}
+	if b := again["sub/b.sgf"]; !b.Modified.Equal(games["sub/b.sgf"].Modified) || !reflect.DeepEqual(b.Hashes, games["sub/b.sgf"].Hashes) {
+		t.Errorf("unchanged entry %+v, was %+v", b, games["sub/b.sgf"])
+	}
+}
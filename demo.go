@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// demoArchive is a synthetic archive of random games, written by
+// chamgo synth -local 4 -online 2 -moves 60 -autosave -seed 2024 -o demo.imazingapp,
+// for trying chamgo without a backup of one's own.
+//
+//go:embed demo.imazingapp
+var demoArchive []byte
+
+// demoTour are the commands suggested with the demo archive.
+var demoTour = []string{
+	"chamgo summary -a %s",
+	"chamgo hexdump -a %s",
+	"chamgo sgf -a %s -g latest-online",
+	"chamgo replay -a %s",
+	"chamgo -select interactive -a %s -p w > injected.imazingapp",
+}
+
+// demo is registered apart from the other commands, since it runs them.
+func init() {
+	commands["demo"] = demoCmd
+}
+
+func demoCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("demo", flag.ContinueOnError)
+	out := fs.String("o", "chamgo-demo.imazingapp", "where the demo archive is written")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	// With a command, it is run on a temporary copy of the demo archive given as its -a flag.
+	if fs.NArg() > 0 {
+		cmd, ok := commands[fs.Arg(0)]
+		if !ok {
+			return fmt.Errorf("usage: chamgo demo [-o demo.imazingapp] [command [flags]]")
+		}
+		dir, err := ioutil.TempDir("", "chamgo-demo")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(dir)
+		fn := filepath.Join(dir, "demo.imazingapp")
+		if err := ioutil.WriteFile(fn, demoArchive, 0644); err != nil {
+			return err
+		}
+		return cmd(ctx, append([]string{"-a", fn}, fs.Args()[1:]...))
+	}
+
+	if exists(*out) {
+		return fmt.Errorf("%s exists, remove it or choose another -o", *out)
+	}
+	if err := ioutil.WriteFile(*out, demoArchive, 0644); err != nil {
+		return err
+	}
+	note(event{"event": "demo", "archive": *out}, "wrote the demo archive %s, with 4 local games, 2 online games and an autosaved game; try:", *out)
+	if !jsonOutput {
+		for _, c := range demoTour {
+			fmt.Fprintf(os.Stderr, "    "+c+"\n", *out)
+		}
+	}
+	return nil
+}
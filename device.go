@@ -0,0 +1,445 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// backupFile is a file of a device backup, as listed in the Files table of its Manifest.db.
+type backupFile struct {
+	ID           string // the SHA-1 of the domain and the relative path, naming the file in the backup
+	Domain       string
+	RelativePath string
+	Flags        int64  // 1 for files, 2 for directories
+	Blob         []byte // the MBFile keyed archive with the size and dates of the file
+	BlobOffset   int    // the offset of Blob in Manifest.db, or -1 if it is not stored contiguously
+}
+
+// backupFilePath returns where the file with the ID id is stored in the device backup dir.
+func backupFilePath(dir, id string) string {
+	return filepath.Join(dir, id[:2], id)
+}
+
+// readManifestDB returns the files of the device backup dir and the contents of its Manifest.db.
+func readManifestDB(dir string) ([]backupFile, []byte, error) {
+	b, err := ioutil.ReadFile(filepath.Join(dir, "Manifest.db"))
+	if err != nil {
+		return nil, nil, err
+	}
+	db, err := openSQLite(b)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Manifest.db: %w", err)
+	}
+	rows, err := db.table("Files")
+	if err != nil {
+		return nil, nil, fmt.Errorf("Manifest.db: %w", err)
+	}
+	var files []backupFile
+	for _, r := range rows {
+		if len(r.Values) < 5 {
+			return nil, nil, invalid(fmt.Errorf("Manifest.db: Files has %d columns, expected 5", len(r.Values)))
+		}
+		f := backupFile{BlobOffset: r.Offsets[4]}
+		f.ID, _ = r.Values[0].(string)
+		f.Domain, _ = r.Values[1].(string)
+		f.RelativePath, _ = r.Values[2].(string)
+		f.Flags, _ = r.Values[3].(int64)
+		f.Blob, _ = r.Values[4].([]byte)
+		if len(f.ID) != 40 {
+			return nil, nil, invalid(fmt.Errorf("Manifest.db: bad file ID %q", f.ID))
+		}
+		files = append(files, f)
+	}
+	return files, b, nil
+}
+
+// findAppDomain returns the backup domain of Champion Go, the app domain with saved games,
+// or the domain of the app with the bundle ID bundle.
+func findAppDomain(files []backupFile, bundle string) (string, error) {
+	if bundle != "" {
+		return "AppDomain-" + bundle, nil
+	}
+	found := make(map[string]bool)
+	for _, f := range files {
+		if strings.HasPrefix(f.Domain, "AppDomain-") &&
+			(inDir("Container/"+f.RelativePath, gameDir(false)) || inDir("Container/"+f.RelativePath, gameDir(true))) {
+			found[f.Domain] = true
+		}
+	}
+	domains := sortedKeys(found)
+	switch len(domains) {
+	case 0:
+		return "", errNoGames
+	case 1:
+		return domains[0], nil
+	}
+	return "", fmt.Errorf("several apps have saved games, pick one with -bundle: %s", strings.Join(domains, ", "))
+}
+
+// deviceBackupDir returns the backup of the device udid in the backup directory root,
+// or the most recent backup if udid is empty.
+func deviceBackupDir(root, udid string) (string, error) {
+	if udid != "" {
+		return filepath.Join(root, udid), nil
+	}
+	fns, err := filepath.Glob(filepath.Join(root, "*", "Manifest.plist"))
+	if err != nil {
+		return "", err
+	}
+	sort.Slice(fns, func(i, j int) bool {
+		fi, _ := os.Stat(fns[i])
+		fj, _ := os.Stat(fns[j])
+		return fi != nil && fj != nil && fi.ModTime().After(fj.ModTime())
+	})
+	if len(fns) == 0 {
+		return "", fmt.Errorf("no device backups in %s", root)
+	}
+	return filepath.Dir(fns[0]), nil
+}
+
+// idevicebackup2 runs idevicebackup2 of libimobiledevice, showing its progress on stderr.
+func idevicebackup2(ctx context.Context, args ...string) error {
+	cmd := exec.CommandContext(ctx, "idevicebackup2", args...)
+	cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+	if err := cmd.Run(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return unsupported(fmt.Errorf("idevicebackup2 not found, install libimobiledevice"))
+		}
+		return fmt.Errorf("idevicebackup2 %s: %w", args[0], err)
+	}
+	return nil
+}
+
+// openDeviceBackup returns the checked metadata and files of the device backup dir.
+func openDeviceBackup(dir string) (*backupMeta, []backupFile, []byte, error) {
+	meta, err := readBackupMeta(dir)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if enc, _ := meta.get("Manifest.plist", "IsEncrypted"); enc == true {
+		return nil, nil, nil, unsupported(fmt.Errorf("%s is encrypted, turn off backup encryption for the device", dir))
+	}
+	if ps := meta.problems(); len(ps) > 0 {
+		return nil, nil, nil, invalid(fmt.Errorf("%s: %s", dir, strings.Join(ps, "; ")))
+	}
+	files, db, err := readManifestDB(dir)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return meta, files, db, nil
+}
+
+// deviceFlags are the flags shared by pull and push.
+func deviceFlags(fs *flag.FlagSet) (backups, udid, bundle *string) {
+	return fs.String("backup", "", "directory of device backups, by default $CHAMGO_HOME/backups"),
+		fs.String("u", "", "UDID of the device, by default the only connected one, or the most recent backup"),
+		fs.String("bundle", "", "bundle ID of the app, by default the app with saved games")
+}
+
+func backupRoot(dir string) (string, error) {
+	if dir != "" {
+		return dir, nil
+	}
+	return chamgoDir("backups")
+}
+
+func pullCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("pull", flag.ContinueOnError)
+	backups, udid, bundle := deviceFlags(fs)
+	fresh := fs.Bool("fresh", true, "make a new backup with idevicebackup2 rather than reading the last one")
+	out := fs.String("o", "-", "output archive of the app's files")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	root, err := backupRoot(*backups)
+	if err != nil {
+		return err
+	}
+	if *fresh {
+		bargs := []string{"backup"}
+		if *udid != "" {
+			bargs = append(bargs, "-u", *udid)
+		}
+		if err := idevicebackup2(ctx, append(bargs, root)...); err != nil {
+			return err
+		}
+	}
+	dir, err := deviceBackupDir(root, *udid)
+	if err != nil {
+		return err
+	}
+	_, files, _, err := openDeviceBackup(dir)
+	if err != nil {
+		return err
+	}
+	domain, err := findAppDomain(files, *bundle)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempDir("", "chamgo-pull-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	n := 0
+	for _, f := range files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if f.Domain != domain || f.Flags != 1 {
+			continue
+		}
+		b, err := ioutil.ReadFile(backupFilePath(dir, f.ID))
+		if err != nil {
+			return err
+		}
+		dst, err := safeJoin(tmp, "Container/"+f.RelativePath)
+		if err != nil {
+			return err
+		}
+		if err := writeFileAll(dst, b); err != nil {
+			return err
+		}
+		n++
+	}
+	note(event{"event": "pull", "backup": dir, "domain": domain, "files": n}, "%d files of %s from %s", n, domain, dir)
+	return writeAvxFile(ctx, *out, tmp, avxEdit{NoJournal: true})
+}
+
+// setMBFileSize sets the size recorded in the MBFile keyed archive at off in db, in place.
+// This works when the new size takes as many bytes as the old one and the integer is not shared with other fields,
+// as Manifest.db cannot be rewritten otherwise without an SQLite library.
+func setMBFileSize(db []byte, off int, blob []byte, size int64) error {
+	if off < 0 {
+		return unsupported(fmt.Errorf("the file record spills onto an overflow page"))
+	}
+	pos, width, err := mbfileSizeOffset(blob)
+	if err != nil {
+		return err
+	}
+	if width < 8 && size >= 1<<(8*uint(width)) {
+		return unsupported(fmt.Errorf("the size %d needs more than the %d bytes of the recorded size", size, width))
+	}
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], uint64(size))
+	copy(db[off+pos:off+pos+width], b[8-width:])
+	return nil
+}
+
+// mbfileSizeOffset returns the offset and width of the big endian integer holding the Size of an MBFile keyed archive.
+func mbfileSizeOffset(b []byte) (int, int, error) {
+	bad := invalid(fmt.Errorf("unexpected MBFile record"))
+	if !isBinaryPlist(b) || len(b) < 8+32 {
+		return 0, 0, bad
+	}
+	t := b[len(b)-32:]
+	offSize, refSize := int(t[6]), int(t[7])
+	num := binary.BigEndian.Uint64(t[8:])
+	tableOff := binary.BigEndian.Uint64(t[24:])
+	if offSize < 1 || offSize > 8 || refSize < 1 || refSize > 8 || tableOff > uint64(len(b)) || num > (uint64(len(b))-tableOff)/uint64(offSize) {
+		return 0, 0, bad
+	}
+	offset := func(ref uint64) (int, bool) {
+		if ref >= num {
+			return 0, false
+		}
+		o := readBEUint(b[tableOff+ref*uint64(offSize):], offSize)
+		return int(o), o < tableOff
+	}
+	// collection returns the references of the array or dictionary at o.
+	collection := func(o int) (byte, []uint64, bool) {
+		kind, n, pos := b[o]>>4, uint64(b[o]&0xf), o+1
+		if kind != 0xa && kind != 0xd {
+			return 0, nil, false
+		}
+		if n == 0xf {
+			if pos >= len(b) || b[pos]>>4 != 1 || 1<<(b[pos]&0xf) > 8 {
+				return 0, nil, false
+			}
+			w := 1 << (b[pos] & 0xf)
+			if pos+1+w > len(b) {
+				return 0, nil, false
+			}
+			n, pos = readBEUint(b[pos+1:], w), pos+1+w
+		}
+		if kind == 0xd {
+			n *= 2
+		}
+		if n > uint64(len(b)) || pos+int(n)*refSize > len(b) {
+			return 0, nil, false
+		}
+		refs := make([]uint64, n)
+		for i := range refs {
+			refs[i] = readBEUint(b[pos+i*refSize:], refSize)
+		}
+		return kind, refs, true
+	}
+	isKey := func(ref uint64, key string) bool {
+		o, ok := offset(ref)
+		return ok && b[o] == 0x50|byte(len(key)) && bytes.HasPrefix(b[o+1:], []byte(key))
+	}
+
+	// Find the dictionary with a Size key, and check that no other collection refers to its integer.
+	var sizeRef uint64
+	found, uses := false, 0
+	for ref := uint64(0); ref < num; ref++ {
+		o, ok := offset(ref)
+		if !ok {
+			return 0, 0, bad
+		}
+		kind, refs, ok := collection(o)
+		if !ok {
+			continue
+		}
+		if kind == 0xd {
+			keys, values := refs[:len(refs)/2], refs[len(refs)/2:]
+			for i, k := range keys {
+				if isKey(k, "Size") && !found {
+					sizeRef, found = values[i], true
+				}
+			}
+		}
+		for _, r := range refs {
+			if found && r == sizeRef {
+				uses++
+			}
+		}
+	}
+	if !found {
+		return 0, 0, bad
+	}
+	if uses != 1 {
+		return 0, 0, unsupported(fmt.Errorf("the recorded size is shared with other fields"))
+	}
+	o, ok := offset(sizeRef)
+	if !ok || b[o]>>4 != 1 {
+		return 0, 0, bad
+	}
+	width := 1 << (b[o] & 0xf)
+	if width > 8 || o+1+width > len(b) {
+		return 0, 0, bad
+	}
+	return o + 1, width, nil
+}
+
+func pushCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("push", flag.ContinueOnError)
+	backups, udid, bundle := deviceFlags(fs)
+	avx := fs.String("a", "", "archive of the app's files, as written by chamgo pull and edited by chamgo")
+	dryRun := fs.Bool("n", false, "only update the backup, without restoring it to the device")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	root, err := backupRoot(*backups)
+	if err != nil {
+		return err
+	}
+	dir, err := deviceBackupDir(root, *udid)
+	if err != nil {
+		return err
+	}
+	unlock, err := lockOutput(ctx, dir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	_, files, db, err := openDeviceBackup(dir)
+	if err != nil {
+		return err
+	}
+	domain, err := findAppDomain(files, *bundle)
+	if err != nil {
+		return err
+	}
+	byPath := make(map[string]backupFile)
+	for _, f := range files {
+		if f.Domain == domain {
+			byPath[f.RelativePath] = f
+		}
+	}
+
+	a, err := openArchive(ctx, *avx)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+	changed := make(map[string][]byte) // new contents by file ID
+	dbChanged := false
+	for _, af := range a.Files {
+		if af.IsDir || !inDir(af.Name, "Container/") {
+			continue
+		}
+		rel := strings.TrimPrefix(af.Name, "Container/")
+		f, ok := byPath[rel]
+		if !ok {
+			return unsupported(fmt.Errorf("%s is not in the backup, and adding files would need rewriting Manifest.db", af.Name))
+		}
+		body, err := af.ReadAll()
+		if err != nil {
+			return err
+		}
+		old, err := ioutil.ReadFile(backupFilePath(dir, f.ID))
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(old, body) {
+			continue
+		}
+		if len(old) != len(body) {
+			if err := setMBFileSize(db, f.BlobOffset, f.Blob, int64(len(body))); err != nil {
+				return fmt.Errorf("%s: %w", af.Name, err)
+			}
+			dbChanged = true
+		}
+		changed[f.ID] = body
+		note(event{"event": "update", "entry": af.Name, "file": f.ID}, "updated %s", af.Name)
+	}
+	if len(changed) == 0 {
+		note(event{"event": "unchanged", "backup": dir}, "%s already has these files", dir)
+		return nil
+	}
+
+	for _, id := range sortedKeys(changed) {
+		if err := writeFileAtomic(backupFilePath(dir, id), changed[id]); err != nil {
+			return err
+		}
+	}
+	if dbChanged {
+		if err := writeFileAtomic(filepath.Join(dir, "Manifest.db"), db); err != nil {
+			return err
+		}
+	}
+	if err := updateBackup(dir); err != nil {
+		return err
+	}
+	if *dryRun {
+		return nil
+	}
+	// Restoring replaces all the data of the device with the backup, which is why push restores the backup made by pull.
+	rargs := []string{"restore", "--reboot"}
+	if *udid != "" {
+		rargs = append(rargs, "-u", *udid)
+	}
+	return idevicebackup2(ctx, append(rargs, root)...)
+}
+
+// writeFileAtomic replaces the file name with b, so that an interrupted write leaves the old contents.
+func writeFileAtomic(name string, b []byte) error {
+	if err := ioutil.WriteFile(name+".chamgo-tmp", b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(name+".chamgo-tmp", name)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// entryOverhead is a generous estimate of the bytes a zip or tar archive adds per entry.
+const entryOverhead = 1024
+
+// estimateSize returns about how many bytes writing the archive avxName with the edit e takes.
+// Outputs are stored uncompressed, so this is the sum of the uncompressed sizes of the entries.
+func estimateSize(ctx context.Context, avxName string, e avxEdit) (int64, error) {
+	a, err := openArchive(ctx, avxName)
+	if err != nil {
+		return 0, err
+	}
+	defer a.Close()
+	var n int64
+	for _, f := range a.Files {
+		if e.Delete[f.Name] {
+			continue
+		}
+		size := f.Size
+		if b, ok := e.Replace[f.Name]; ok {
+			size = int64(len(b))
+		}
+		n += size + entryOverhead
+	}
+	for _, b := range e.Add {
+		n += int64(len(b)) + entryOverhead
+	}
+	return n, nil
+}
+
+// diskFree returns the bytes available to the user on the file system holding dir,
+// and false if that cannot be found out.
+// It uses df and PowerShell rather than system calls, which differ between systems
+// and would need build constraints, which go build ignores when given a list of files.
+func diskFree(dir string) (int64, bool) {
+	if runtime.GOOS == "windows" {
+		out, err := exec.Command("powershell", "-NoProfile", "-Command",
+			fmt.Sprintf("(Get-Item -LiteralPath '%s').PSDrive.Free", strings.ReplaceAll(dir, "'", "''"))).Output()
+		if err != nil {
+			return 0, false
+		}
+		n, err := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+		return n, err == nil
+	}
+	out, err := exec.Command("df", "-Pk", dir).Output()
+	if err != nil {
+		return 0, false
+	}
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	fields := strings.Fields(lines[len(lines)-1])
+	if len(lines) < 2 || len(fields) < 4 {
+		return 0, false
+	}
+	kb, err := strconv.ParseInt(fields[3], 10, 64)
+	return kb * 1024, err == nil
+}
+
+// existingDir returns the closest existing directory of name, which may not exist yet.
+func existingDir(name string) string {
+	dir := filepath.Dir(strings.TrimRight(name, `/\`))
+	for !isDir(dir) && filepath.Dir(dir) != dir {
+		dir = filepath.Dir(dir)
+	}
+	return dir
+}
+
+// checkDiskSpace fails early if the file system of out has clearly too little space
+// for writing the archive avxName with the edit e, rather than dying mid-write with a truncated output.
+// The previous version kept by -keep is counted as well.
+func checkDiskSpace(ctx context.Context, out, avxName string, e avxEdit) error {
+	need, err := estimateSize(ctx, avxName, e)
+	if err != nil {
+		return err
+	}
+	if settingsOf(ctx).keepVersions > 0 {
+		if fi, err := os.Stat(out); err == nil && !fi.IsDir() {
+			need += fi.Size()
+		}
+	}
+	dir := existingDir(out)
+	free, ok := diskFree(dir)
+	if !ok || free >= need {
+		return nil
+	}
+	return &codedError{exitIO, fmt.Errorf("not enough free space in %s to write %s: about %s needed, %s available",
+		dir, out, formatBytes(need), formatBytes(free))}
+}
+
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1f GB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f kB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d bytes", n)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+// endgamePlay is a boundary play with its estimated value.
+type endgamePlay struct {
+	Vertex string  `json:"vertex"`
+	Swing  float64 `json:"swing"` // the difference of the scores after black and after white plays there
+	Value  float64 `json:"value"` // the miai value, half the swing, as endgame values are usually counted
+}
+
+// endgamePlays estimates the values of the boundary plays of the current position of e: the empty points
+// next to stones that neither side owns in almost all playouts and that are not eyes or seki liberties.
+// The swing of a point is the mean score after black plays there, with white to move,
+// minus the mean score after white plays there, with black to move, from n playouts each.
+// Sorting the plays by value gives the order in which they are usually played, the largest first.
+func (e *mcEngine) endgamePlays(n int) []endgamePlay {
+	b := e.board
+	_, own := e.estimate()
+	seki := sekiPoints(b)
+	var points []int
+	var afterBlack, afterWhite []*Board
+	for p, s := range b.Points {
+		if s != Empty || seki[p] || math.Abs(own[p]) > 0.95 || mcEye(b, Black, p) || mcEye(b, White, p) {
+			continue
+		}
+		nextToStone := false
+		for _, q := range b.neighbors(p) {
+			nextToStone = nextToStone || b.Points[q] != Empty
+		}
+		if !nextToStone {
+			continue
+		}
+		x, y := p%b.Size+1, p/b.Size+1
+		bb, wb := b.clone(), b.clone()
+		if _, err := bb.Play(Black, x, y); err != nil {
+			continue
+		}
+		if _, err := wb.Play(White, x, y); err != nil {
+			continue
+		}
+		points = append(points, p)
+		afterBlack, afterWhite = append(afterBlack, bb), append(afterWhite, wb)
+	}
+	if len(points) == 0 {
+		return nil
+	}
+	black, white := make([]float64, len(points)), make([]float64, len(points))
+	e.parallel(afterBlack, White, n*len(points), func(i int, score float64, _ []Stone) { black[i] += score })
+	e.parallel(afterWhite, Black, n*len(points), func(i int, score float64, _ []Stone) { white[i] += score })
+	plays := make([]endgamePlay, len(points))
+	for i, p := range points {
+		swing := (black[i] - white[i]) / float64(n)
+		plays[i] = endgamePlay{Vertex: gtpVertex(p%b.Size+1, p/b.Size+1, b.Size), Swing: swing, Value: swing / 2}
+	}
+	sort.SliceStable(plays, func(i, j int) bool { return plays[i].Value > plays[j].Value })
+	return plays
+}
+
+func endgameCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("endgame", flag.ContinueOnError)
+	avx := fs.String("a", "", "Champion Go archive")
+	slot := fs.String("g", "latest", `the game: an entry name, "latest" or "latest-online"`)
+	at := fs.Int("at", 0, "the position after this move, by default the last")
+	playouts := fs.Int("playouts", 200, "the playouts after each side plays each point")
+	top := fs.Int("n", 10, "the number of plays printed")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 || *playouts < 1 {
+		return fmt.Errorf("usage: chamgo endgame [-at move] [-n plays] [-a archive [-g game] | game.sgf]")
+	}
+	name, g, err := loadGame(ctx, *avx, *slot, fs.Args())
+	if err != nil {
+		return err
+	}
+	moves := g.Moves
+	if *at > 0 {
+		moves = moves[:min(*at, len(moves))]
+	}
+	e, err := newMCEngine(int(g.Size), *playouts)
+	if err != nil {
+		return err
+	}
+	for i, m := range moves {
+		if !m.IsPass() && !g.onBoard(m) {
+			continue
+		}
+		if err := e.play(m.Stone(), int(m.X), int(m.Y)); err != nil {
+			return invalid(fmt.Errorf("move %d: %w", i+1, err))
+		}
+	}
+	plays := e.endgamePlays(*playouts)
+	if len(plays) > *top {
+		plays = plays[:*top]
+	}
+	output(plays, func(w io.Writer) {
+		fmt.Fprintf(w, "%s after move %d, %s to play\n", name, len(moves), colorName(e.toMove()))
+		for _, p := range plays {
+			fmt.Fprintf(w, "%-4s %5.1f points (swing %.1f)\n", p.Vertex, p.Value, p.Swing)
+		}
+	})
+	return nil
+}
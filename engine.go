@@ -0,0 +1,308 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// engineProfile knows how to launch a GTP engine at a strength level from 1 to 10 under the current rules.
+type engineProfile struct {
+	Name    string
+	Command string
+	About   string
+	// args returns the command line arguments for playing at level under the rules r.
+	args func(level int, r Rules) ([]string, error)
+}
+
+// engineProfiles are the engines selectable by name with -engine.
+var engineProfiles = map[string]engineProfile{
+	"gnugo": {
+		Name: "gnugo", Command: "gnugo", About: "GNU Go, levels 1 to 10 are its own levels",
+		args: func(level int, r Rules) ([]string, error) {
+			args := []string{"--mode", "gtp", "--level", strconv.Itoa(level)}
+			// GNU Go only knows area and territory scoring, and always forbids suicide.
+			if r.Superko != "" {
+				return append(args, "--chinese-rules"), nil
+			}
+			return append(args, "--japanese-rules"), nil
+		},
+	},
+	"pachi": {
+		Name: "pachi", Command: "pachi", About: "Pachi, level n plays 100 << (n-1) playouts a move",
+		args: func(level int, r Rules) ([]string, error) {
+			return []string{"-r", strings.ReplaceAll(r.Name, "-", "_"), "-t", fmt.Sprintf("=%d", 100<<(level-1))}, nil
+		},
+	},
+	"katago": {
+		Name: "katago", Command: "katago", About: "KataGo, level n searches 1 << (n-1) visits a move, with $CHAMGO_KATAGO_MODEL and $CHAMGO_KATAGO_CONFIG",
+		args: func(level int, r Rules) ([]string, error) {
+			args := []string{"gtp"}
+			if m := os.Getenv("CHAMGO_KATAGO_MODEL"); m != "" {
+				args = append(args, "-model", m)
+			}
+			if c := os.Getenv("CHAMGO_KATAGO_CONFIG"); c != "" {
+				args = append(args, "-config", c)
+			}
+			return append(args, "-override-config", fmt.Sprintf("rules=%s,maxVisits=%d,ponderingEnabled=false", r.Name, 1<<(level-1))), nil
+		},
+	},
+	"mc": {
+		Name: "mc", Command: selfCommand(), About: "chamgo's built-in Monte Carlo engine, level n plays 25 << (n-1) playouts a move, weak but needing no other program",
+		args: func(level int, r Rules) ([]string, error) {
+			return []string{"-rules", r.Name, "mc-engine", "-playouts", strconv.Itoa(25 << (level - 1))}, nil
+		},
+	},
+	"leelaz": {
+		Name: "leelaz", Command: "leelaz", About: "Leela Zero, level n plays 1 << (n-1) playouts a move, with the weights in $CHAMGO_LEELAZ_WEIGHTS",
+		args: func(level int, r Rules) ([]string, error) {
+			if r.Name != "chinese" {
+				return nil, unsupported(fmt.Errorf("Leela Zero only plays by chinese rules, not %s", r.Name))
+			}
+			args := []string{"--gtp", "--noponder", "-p", strconv.Itoa(1 << (level - 1))}
+			if w := os.Getenv("CHAMGO_LEELAZ_WEIGHTS"); w != "" {
+				args = append(args, "-w", w)
+			}
+			return args, nil
+		},
+	},
+}
+
+// selfCommand returns the path of the running chamgo, which runs the built-in engine.
+func selfCommand() string {
+	if exe, err := os.Executable(); err == nil {
+		return exe
+	}
+	return os.Args[0]
+}
+
+// engineKomi is the komi given to engines under each ruleset, as the archive does not record the komi of games.
+var engineKomi = map[string]float64{"japanese": 6.5, "chinese": 7.5, "aga": 7.5, "new-zealand": 7, "tromp-taylor": 7.5}
+
+// engineSpec is the engine selected by -engine: a profile name with an optional level such as "katago:5",
+// or a GTP command line of an engine without a profile.
+var engineSpec = os.Getenv("CHAMGO_ENGINE")
+
+// engineCommand returns the command line of the engine spec under the current rules.
+func engineCommand(spec string) ([]string, error) {
+	if spec == "" {
+		return nil, fmt.Errorf("no engine, pick one with -engine: %s", strings.Join(engineNames(), ", "))
+	}
+	name, lv, hasLevel := strings.Cut(spec, ":")
+	p, ok := engineProfiles[name]
+	if !ok {
+		return strings.Fields(spec), nil
+	}
+	level := 10
+	if hasLevel {
+		var err error
+		if level, err = strconv.Atoi(lv); err != nil || level < 1 || level > 10 {
+			return nil, fmt.Errorf("engine level must be between 1 and 10, got %q", lv)
+		}
+	}
+	args, err := p.args(level, rules)
+	if err != nil {
+		return nil, err
+	}
+	return append([]string{p.Command}, args...), nil
+}
+
+func engineNames() []string {
+	var names []string
+	for name := range engineProfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// gtpEngine is a GTP engine running as a subprocess.
+type gtpEngine struct {
+	cmd *exec.Cmd
+	in  io.WriteCloser
+	out *bufio.Reader
+}
+
+// startEngine starts the engine selected by -engine, set up for the current rules.
+func startEngine(ctx context.Context) (*gtpEngine, error) {
+	argv, err := engineCommand(engineSpec)
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Stderr = ioutil.Discard
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return nil, unsupported(fmt.Errorf("engine %s not found", argv[0]))
+		}
+		return nil, err
+	}
+	return &gtpEngine{cmd: cmd, in: in, out: bufio.NewReader(out)}, nil
+}
+
+// send sends a GTP command and returns the engine's response.
+func (e *gtpEngine) send(command string, args ...string) (string, error) {
+	line := strings.Join(append([]string{command}, args...), " ")
+	if _, err := fmt.Fprintln(e.in, line); err != nil {
+		return "", fmt.Errorf("engine: %s: %w", command, err)
+	}
+	var resp []string
+	for {
+		l, err := e.out.ReadString('\n')
+		if err != nil {
+			return "", fmt.Errorf("engine: %s: %w", command, err)
+		}
+		l = strings.TrimRight(l, "\r\n")
+		if l == "" && len(resp) > 0 {
+			break
+		}
+		if l != "" {
+			resp = append(resp, l)
+		}
+	}
+	r := strings.Join(resp, "\n")
+	switch {
+	case strings.HasPrefix(r, "="):
+		return strings.TrimSpace(r[1:]), nil
+	case strings.HasPrefix(r, "?"):
+		return "", fmt.Errorf("engine: %s: %s", line, strings.TrimSpace(r[1:]))
+	}
+	return "", fmt.Errorf("engine: %s: bad response %q", line, r)
+}
+
+// setup sets the engine's board to the moves of g.
+func (e *gtpEngine) setup(g *Game) error {
+	if _, err := e.send("boardsize", strconv.Itoa(int(g.Size))); err != nil {
+		return err
+	}
+	if _, err := e.send("clear_board"); err != nil {
+		return err
+	}
+	if _, err := e.send("komi", strconv.FormatFloat(engineKomi[rules.Name], 'f', -1, 64)); err != nil {
+		return err
+	}
+	for _, m := range g.Moves {
+		if !m.IsPass() && !g.onBoard(m) {
+			continue
+		}
+		if _, err := e.send("play", gtpColor(m.Stone()), gtpVertex(int(m.X), int(m.Y), int(g.Size))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close quits the engine.
+func (e *gtpEngine) Close() error {
+	e.send("quit")
+	e.in.Close()
+	done := make(chan error, 1)
+	go func() { done <- e.cmd.Wait() }()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(5 * time.Second):
+		e.cmd.Process.Kill()
+		return <-done
+	}
+}
+
+func gtpColor(s Stone) string {
+	if s == White {
+		return "W"
+	}
+	return "B"
+}
+
+// gtpVertex formats the point x, y of a board of size n, where y increases downwards, as a GTP vertex.
+// Points with no column letter are formatted as x,y, which engines reject.
+func gtpVertex(x, y, size int) string {
+	if x == 0 && y == 0 {
+		return "pass"
+	}
+	if x < 1 || x > len(columns) {
+		return fmt.Sprintf("%d,%d", x, y)
+	}
+	return fmt.Sprintf("%c%d", columns[x-1], size-y+1)
+}
+
+func engineCmd(ctx context.Context, args []string) error {
+	usage := fmt.Errorf("usage: chamgo [-engine name:level] engine list|check")
+	if len(args) == 0 {
+		return usage
+	}
+	fs := flag.NewFlagSet("engine "+args[0], flag.ContinueOnError)
+	if err := parseFlags(fs, args[1:]); err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "list":
+		type profile struct {
+			Name    string   `json:"name"`
+			About   string   `json:"about"`
+			Command []string `json:"command,omitempty"`
+			Error   string   `json:"error,omitempty"`
+		}
+		var ps []profile
+		for _, name := range engineNames() {
+			p := profile{Name: name, About: engineProfiles[name].About}
+			argv, err := engineCommand(name)
+			if err != nil {
+				p.Error = err.Error()
+			}
+			p.Command = argv
+			ps = append(ps, p)
+		}
+		output(ps, func(w io.Writer) {
+			for _, p := range ps {
+				fmt.Fprintf(w, "%-7s %s\n", p.Name, p.About)
+				if p.Error != "" {
+					fmt.Fprintf(w, "        %s\n", p.Error)
+				} else {
+					fmt.Fprintf(w, "        %s\n", strings.Join(p.Command, " "))
+				}
+			}
+		})
+		return nil
+	case "check":
+		e, err := startEngine(ctx)
+		if err != nil {
+			return err
+		}
+		defer e.Close()
+		name, err := e.send("name")
+		if err != nil {
+			return err
+		}
+		version, _ := e.send("version")
+		argv, _ := engineCommand(engineSpec)
+		type check struct {
+			Command []string `json:"command"`
+			Name    string   `json:"name"`
+			Version string   `json:"version"`
+		}
+		c := check{argv, name, version}
+		output(c, func(w io.Writer) { fmt.Fprintf(w, "%s %s: %s\n", c.Name, c.Version, strings.Join(c.Command, " ")) })
+		return nil
+	}
+	return usage
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Entry names differ with the tool that wrote the archive: Windows tools may separate them with backslashes,
+// and macOS ones decompose accented letters (NFD), where iOS and most zip tools keep them precomposed (NFC).
+// Names are normalized when archives are opened, so that the game directories and the entries named on the command line
+// are found whichever way they were written.
+
+// composed lists, for each combining mark, the letters it follows and the precomposed letters they make together.
+// It covers the Latin letters, with the precomposed ones that take a second mark as in Vietnamese, and kana,
+// which is all that is expected in entry names; the standard library has no Unicode normalization tables.
+var composed = map[rune][2]string{
+	'\u0300': {"AEINOUWYaeinouwyÜüĒēŌōÂâĂăÊêÔôƠơƯư", "ÀÈÌǸÒÙẀỲàèìǹòùẁỳǛǜḔḕṐṑẦầẰằỀềỒồỜờỪừ"},
+	'\u0301': {"ACEGIKLMNOPRSUWYZacegiklmnoprsuwyzÜüÅåÇçĒēÏïÕõŌōŨũÂâĂăÊêÔôƠơƯư", "ÁĆÉǴÍḰĹḾŃÓṔŔŚÚẂÝŹáćéǵíḱĺḿńóṕŕśúẃýźǗǘǺǻḈḉḖḗḮḯṌṍṒṓṸṹẤấẮắẾếỐốỚớỨứ"},
+	'\u0302': {"ACEGHIJOSUWYZaceghijosuwyzẠạẸẹỌọ", "ÂĈÊĜĤÎĴÔŜÛŴŶẐâĉêĝĥîĵôŝûŵŷẑẬậỆệỘộ"},
+	'\u0303': {"AEINOUVYaeinouvyÂâĂăÊêÔôƠơƯư", "ÃẼĨÑÕŨṼỸãẽĩñõũṽỹẪẫẴẵỄễỖỗỠỡỮữ"},
+	'\u0304': {"AEGIOUYaegiouyÜüÄäȦȧǪǫÖöÕõȮȯḶḷṚṛ", "ĀĒḠĪŌŪȲāēḡīōūȳǕǖǞǟǠǡǬǭȪȫȬȭȰȱḸḹṜṝ"},
+	'\u0306': {"AEGIOUaegiouȨȩẠạ", "ĂĔĞĬŎŬăĕğĭŏŭḜḝẶặ"},
+	'\u0307': {"ABCDEFGHIMNOPRSTWXYZabcdefghmnoprstwxyzŚśŠšṢṣ", "ȦḂĊḊĖḞĠḢİṀṄȮṖṘṠṪẆẊẎŻȧḃċḋėḟġḣṁṅȯṗṙṡṫẇẋẏżṤṥṦṧṨṩ"},
+	'\u0308': {"AEHIOUWXYaehiotuwxyÕõŪū", "ÄËḦÏÖÜẄẌŸäëḧïöẗüẅẍÿṎṏṺṻ"},
+	'\u0309': {"AEIOUYaeiouyÂâĂăÊêÔôƠơƯư", "ẢẺỈỎỦỶảẻỉỏủỷẨẩẲẳỂểỔổỞởỬử"},
+	'\u030a': {"AUauwy", "ÅŮåůẘẙ"},
+	'\u030b': {"OUou", "ŐŰőű"},
+	'\u030c': {"ACDEGHIKLNORSTUZacdeghijklnorstuzÜü", "ǍČĎĚǦȞǏǨĽŇǑŘŠŤǓŽǎčďěǧȟǐǰǩľňǒřšťǔžǙǚ"},
+	'\u030f': {"AEIORUaeioru", "ȀȄȈȌȐȔȁȅȉȍȑȕ"},
+	'\u0311': {"AEIORUaeioru", "ȂȆȊȎȒȖȃȇȋȏȓȗ"},
+	'\u031b': {"OUou", "ƠƯơư"},
+	'\u0323': {"ABDEHIKLMNORSTUVWYZabdehiklmnorstuvwyzƠơƯư", "ẠḄḌẸḤỊḲḶṂṆỌṚṢṬỤṾẈỴẒạḅḍẹḥịḳḷṃṇọṛṣṭụṿẉỵẓỢợỰự"},
+	'\u0324': {"Uu", "Ṳṳ"},
+	'\u0325': {"Aa", "Ḁḁ"},
+	'\u0326': {"STst", "ȘȚșț"},
+	'\u0327': {"CDEGHKLNRSTcdeghklnrst", "ÇḐȨĢḨĶĻŅŖŞŢçḑȩģḩķļņŗşţ"},
+	'\u0328': {"AEIOUaeiou", "ĄĘĮǪŲąęįǫų"},
+	'\u032d': {"DELNTUdelntu", "ḒḘḼṊṰṶḓḙḽṋṱṷ"},
+	'\u032e': {"Hh", "Ḫḫ"},
+	'\u0330': {"EIUeiu", "ḚḬṴḛḭṵ"},
+	'\u0331': {"BDKLNRTZbdhklnrtz", "ḆḎḴḺṈṞṮẔḇḏẖḵḻṉṟṯẕ"},
+	'\u3099': {"かきくけこさしすせそたちつてとはひふへほうゝカキクケコサシスセソタチツテトハヒフヘホウワヰヱヲヽ", "がぎぐげござじずぜぞだぢづでどばびぶべぼゔゞガギグゲゴザジズゼゾダヂヅデドバビブベボヴヷヸヹヺヾ"},
+	'\u309a': {"はひふへほハヒフヘホ", "ぱぴぷぺぽパピプペポ"},
+}
+
+// compositions maps letters followed by a combining mark to their precomposed letter.
+var compositions = make(map[[2]rune]rune)
+
+func init() {
+	for mark, c := range composed {
+		bases, letters := []rune(c[0]), []rune(c[1])
+		for i, b := range bases {
+			compositions[[2]rune{b, mark}] = letters[i]
+		}
+	}
+}
+
+// markClass returns the canonical combining class of the combining marks of composed, which orders and blocks them,
+// 255 for the other nonspacing marks, blocking all the marks after them, and 0 for letters.
+func markClass(r rune) int {
+	switch {
+	case r == '\u3099' || r == '\u309a':
+		return 8
+	case r == '\u0327' || r == '\u0328':
+		return 202
+	case r == '\u031b':
+		return 216
+	case r >= '\u0323' && r <= '\u0331':
+		return 220
+	case r >= '\u0300' && r <= '\u0311':
+		return 230
+	case unicode.Is(unicode.Mn, r):
+		return 255
+	}
+	return 0
+}
+
+// composeMarks returns s with the letters followed by combining marks replaced by their precomposed letters, as Unicode
+// composes them: a run of marks is composed one mark at a time, and a mark that does not compose with the letter
+// is kept after it, without stopping the marks of another class after it from composing.
+func composeMarks(s string) string {
+	if isASCII(s) || !utf8.ValidString(s) {
+		return s
+	}
+	var b strings.Builder
+	var letter rune = -1
+	var marks []rune // the marks after letter that it did not compose with
+	last := 0        // the class of the last of marks
+	for _, r := range s {
+		class := markClass(r)
+		if class > 0 && letter >= 0 {
+			if c, ok := compositions[[2]rune{letter, r}]; ok && (len(marks) == 0 || last < class) {
+				letter = c
+				continue
+			}
+			marks, last = append(marks, r), class
+			continue
+		}
+		if letter >= 0 {
+			b.WriteRune(letter)
+			b.WriteString(string(marks))
+		}
+		letter, marks = r, marks[:0]
+	}
+	if letter >= 0 {
+		b.WriteRune(letter)
+		b.WriteString(string(marks))
+	}
+	return b.String()
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeEntryName returns the entry name with slashes as separators, precomposed letters and no leading "./".
+func normalizeEntryName(name string) string {
+	name = strings.ReplaceAll(name, `\`, "/")
+	for strings.HasPrefix(name, "./") {
+		name = name[2:]
+	}
+	return composeMarks(name)
+}
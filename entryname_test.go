@@ -0,0 +1,146 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestComposeMarks(t *testing.T) {
+	tests := []struct {
+		s, want string
+	}{
+		{"game/1", "game/1"},
+		{"Partie de Rene\u0301", "Partie de Ren\u00e9"},
+		{"e\u0301e\u0300", "\u00e9\u00e8"},
+		{"Vie\u0323\u0302t", "Vi\u1ec7t"}, // a run of two marks
+		{"Nguye\u0302\u0303n", "Nguy\u1ec5n"},
+		{"u\u0308\u0301", "\u01d8"},
+		{"s\u0323\u0307", "\u1e69"},
+		{"e\u0327\u0306", "\u1e1d"},
+		{"o\u031b\u0323\u0300", "\u1ee3\u0300"},                  // the grave does not compose with the letter made of the others
+		{"x\u0323\u0308", "\u1e8d\u0323"},                        // the dot below does not compose, and does not block the diaeresis
+		{"a\u0301\u0301", "\u00e1\u0301"},                        // the second acute is blocked by nothing but composes with nothing
+		{"e\u20d7\u0301", "e\u20d7\u0301"},                       // a mark of unknown class blocks the marks after it
+		{"\u0301e\u0301", "\u0301\u00e9"},                        // a mark without a letter
+		{"\u304b\u3099\u30fc\u30cf\u309a", "\u304c\u30fc\u30d1"}, // kana
+		{"bad \xff\u0301", "bad \xff\u0301"},
+	}
+	for _, tt := range tests {
+		if got := composeMarks(tt.s); got != tt.want {
+			t.Errorf("composeMarks(%+q) = %+q, want %+q", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeEntryName(t *testing.T) {
+	tests := []struct {
+		name, want string
+	}{
+		{"Container/Documents/game/1", "Container/Documents/game/1"},
+		{`Container\Documents\game\1`, "Container/Documents/game/1"},
+		{"././Container/Documents/", "Container/Documents/"},
+		{"Container/Documents/Kifu Rene\u0301.sgf", "Container/Documents/Kifu Ren\u00e9.sgf"},
+	}
+	for _, tt := range tests {
+		if got := normalizeEntryName(tt.name); got != tt.want {
+			t.Errorf("normalizeEntryName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestWriteEntryNames rewrites archives whose entry names are not normalized, which must keep their names.
+func TestWriteEntryNames(t *testing.T) {
+	const decomposed, game = "Container/Documents/Rene\u0301.plist", `Container\Documents\game\0`
+	g := synthGame(rand.New(rand.NewSource(9)), currentLayout, 9, 4, synthEpoch).Encode()
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.zip")
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, body := range map[string][]byte{decomposed: []byte("plist"), game: g, "./iTunesMetadata.plist": []byte("metadata")} {
+		w, _ := zw.Create(name)
+		w.Write(body)
+	}
+	zw.Close()
+	if err := ioutil.WriteFile(in, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	replaced := append([]byte(nil), g...)
+	setLevel(replaced, 9)
+	e := avxEdit{Replace: map[string][]byte{"Container/Documents/game/0": replaced, "Container/Documents/Ren\u00e9.plist": []byte("edited")}}
+	tests := []struct {
+		out  string
+		want map[string]string
+	}{
+		{"out.zip", map[string]string{decomposed: "edited", game: string(replaced), "./iTunesMetadata.plist": "metadata"}},
+		{"out.tar", map[string]string{decomposed: "edited", game: string(replaced), "./iTunesMetadata.plist": "metadata"}},
+		{"out/", map[string]string{decomposed: "edited", "Container/Documents/game/0": string(replaced), "iTunesMetadata.plist": "metadata"}},
+	}
+	ctx := context.Background()
+	for _, tt := range tests {
+		out := dir + string(filepath.Separator) + tt.out
+		if err := writeAvxFile(ctx, out, in, e); err != nil {
+			t.Fatalf("%s: %v", tt.out, err)
+		}
+		got := make(map[string]string)
+		b, err := ioutil.ReadFile(out)
+		if err != nil && tt.out != "out/" {
+			t.Fatal(err)
+		}
+		switch tt.out {
+		case "out.zip":
+			for name, body := range zipEntries(t, b) {
+				got[name] = string(body)
+			}
+		case "out.tar":
+			tr := tar.NewReader(bytes.NewReader(b))
+			for {
+				h, err := tr.Next()
+				if err != nil {
+					break
+				}
+				body, _ := ioutil.ReadAll(tr)
+				got[h.Name] = string(body)
+			}
+		default:
+			a, err := openArchiveFiles(out, "")
+			if err != nil {
+				t.Fatalf("%s: %v", tt.out, err)
+			}
+			for _, f := range a.Files {
+				if !f.IsDir {
+					b, _ := f.ReadAll()
+					got[f.Name] = string(b)
+				}
+			}
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: entries %q, want %q", tt.out, sortedKeys(got), sortedKeys(tt.want))
+		}
+		for name, body := range tt.want {
+			if got[name] != body {
+				t.Errorf("%s: %+q holds %q, want %q", tt.out, name, got[name], body)
+			}
+		}
+	}
+
+	// Editing the directory tree in place replaces its files rather than adding normalized ones.
+	tree := filepath.Join(dir, "out")
+	if err := writeAvxFile(ctx, tree, tree, avxEdit{Replace: map[string][]byte{"Container/Documents/Ren\u00e9.plist": []byte("again")}}); err != nil {
+		t.Fatal(err)
+	}
+	fis, err := os.ReadDir(filepath.Join(tree, "Container", "Documents"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := ioutil.ReadFile(filepath.Join(tree, filepath.FromSlash(decomposed))); len(fis) != 2 || string(b) != "again" {
+		t.Errorf("in place: %d files, %s holds %q", len(fis), decomposed, b)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+// sortEntries is set by -sort-entries to write archives in a canonical entry order rather than in the order
+// of the input archive with the added entries last, so that successive outputs differ as little as possible.
+var sortEntries = false
+
+// entryLess orders entry names directory by directory: a directory comes before its files, its files in name order
+// before its subdirectories, and each subdirectory with everything in it before the next one.
+func entryLess(a, b string) bool {
+	split := func(name string) ([]string, string) {
+		dir, file := path.Split(name)
+		return strings.Split(strings.TrimSuffix(dir, "/"), "/"), file
+	}
+	da, fa := split(a)
+	db, fb := split(b)
+	for i := 0; i < len(da) && i < len(db); i++ {
+		if da[i] != db[i] {
+			return da[i] < db[i]
+		}
+	}
+	if len(da) != len(db) {
+		return len(da) < len(db)
+	}
+	return fa < fb
+}
+
+// outputFiles returns the files of a in the order they are written with the edit e. If sorted, as with -sort-entries,
+// the entries added by e are included as files, encrypted if a has encrypted files, and the caller drops e.Add.
+func outputFiles(a *archive, e avxEdit, sorted bool) []*archiveFile {
+	if !sorted {
+		return a.Files
+	}
+	files := append([]*archiveFile(nil), a.Files...)
+	encrypted := false
+	for _, f := range a.Files {
+		encrypted = encrypted || f.Encrypted
+	}
+	for name, body := range e.Add {
+		files = append(files, &archiveFile{Name: name, Size: int64(len(body)), Encrypted: encrypted, open: memOpener(body)})
+	}
+	outName := func(f *archiveFile) string {
+		if n, ok := e.Rename[f.Name]; ok {
+			return n
+		}
+		return f.Name
+	}
+	sort.SliceStable(files, func(i, j int) bool { return entryLess(outName(files[i]), outName(files[j])) })
+	return files
+}
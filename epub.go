@@ -0,0 +1,288 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"html"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// epubFigureMoves is the number of moves of each diagram of an EPUB chapter.
+const epubFigureMoves = 50
+
+// epubChapter is a game of an EPUB book: its summary lines and its diagrams.
+type epubChapter struct {
+	Title   string
+	Summary []string
+	Game    *Game
+	saved   time.Time
+}
+
+// epubChapterXHTML returns the chapter c as an XHTML page, with a diagram every epubFigureMoves moves as inline SVG.
+func epubChapterXHTML(c *epubChapter) ([]byte, error) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `<?xml version="1.0" encoding="utf-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml"><head><title>%s</title><link rel="stylesheet" href="style.css"/></head><body>
+<h1>%s</h1>
+`, html.EscapeString(c.Title), html.EscapeString(c.Title))
+	for _, s := range c.Summary {
+		fmt.Fprintf(&b, "<p>%s</p>\n", html.EscapeString(s))
+	}
+	for from := 1; from <= len(c.Game.Moves); from += epubFigureMoves {
+		f, err := makeFigure(c.Game, from, from+epubFigureMoves-1)
+		if err != nil {
+			return nil, err
+		}
+		b.WriteString(`<div class="figure">`)
+		f.SVG(&b)
+		fmt.Fprintf(&b, "<p>Moves %d–%d", f.From, f.To)
+		if len(f.At) > 0 {
+			fmt.Fprintf(&b, ". %s", html.EscapeString(strings.Join(f.At, ", ")))
+		}
+		b.WriteString(".</p></div>\n")
+	}
+	b.WriteString("</body></html>\n")
+	return b.Bytes(), nil
+}
+
+// writeEpub writes the chapters as an EPUB 3 book.
+func writeEpub(w io.Writer, title string, chapters []*epubChapter) error {
+	zw := zip.NewWriter(w)
+	// The mimetype must come first and be stored uncompressed.
+	f, err := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	io.WriteString(f, "application/epub+zip")
+
+	files := map[string]string{
+		"META-INF/container.xml": `<?xml version="1.0" encoding="utf-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+<rootfiles><rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/></rootfiles>
+</container>
+`,
+		"OEBPS/style.css": "body{font-family:serif}.figure{text-align:center;page-break-inside:avoid}.figure svg{width:90%;height:auto}\n",
+	}
+	var manifest, spine, nav strings.Builder
+	for i, c := range chapters {
+		x, err := epubChapterXHTML(c)
+		if err != nil {
+			return fmt.Errorf("%s: %w", c.Title, err)
+		}
+		name := fmt.Sprintf("game%03d.xhtml", i+1)
+		files["OEBPS/"+name] = string(x)
+		fmt.Fprintf(&manifest, "<item id=\"g%d\" href=\"%s\" media-type=\"application/xhtml+xml\" properties=\"svg\"/>\n", i+1, name)
+		fmt.Fprintf(&spine, "<itemref idref=\"g%d\"/>\n", i+1)
+		fmt.Fprintf(&nav, "<li><a href=\"%s\">%s</a></li>\n", name, html.EscapeString(c.Title))
+	}
+	t := html.EscapeString(title)
+	files["OEBPS/nav.xhtml"] = fmt.Sprintf(`<?xml version="1.0" encoding="utf-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops"><head><title>%s</title></head><body>
+<nav epub:type="toc"><h1>%s</h1><ol>
+%s</ol></nav>
+</body></html>
+`, t, t, nav.String())
+	files["OEBPS/content.opf"] = fmt.Sprintf(`<?xml version="1.0" encoding="utf-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="id">
+<metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+<dc:identifier id="id">urn:chamgo:%s</dc:identifier>
+<dc:title>%s</dc:title>
+<dc:language>en</dc:language>
+<meta property="dcterms:modified">%s</meta>
+</metadata>
+<manifest>
+<item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+<item id="css" href="style.css" media-type="text/css"/>
+%s</manifest>
+<spine>
+%s</spine>
+</package>
+`, epubID(chapters), t, clock().UTC().Format("2006-01-02T15:04:05Z"), manifest.String(), spine.String())
+
+	for _, name := range sortedKeys(files) {
+		f, err := zw.Create(name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, files[name]); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// epubID identifies a book by the games of its chapters, so that rebuilding it replaces it on e-readers.
+func epubID(chapters []*epubChapter) string {
+	h := sha256.New()
+	for _, c := range chapters {
+		io.WriteString(h, c.Game.movesHash())
+	}
+	return hex.EncodeToString(h.Sum(nil))[:32]
+}
+
+// archiveChapters returns the chapters of the local and online games of avx saved from since to until, in saved order,
+// with their results from the games database.
+func archiveChapters(ctx context.Context, avx string, since, until time.Time) ([]*epubChapter, error) {
+	results := make(map[string]string)
+	if resultsEnabled() {
+		fn, _ := resultsPath()
+		gs, err := readResults(fn)
+		if err != nil {
+			return nil, err
+		}
+		for _, g := range gs {
+			results[g.Hash] = g.Result
+		}
+	}
+	var chapters []*epubChapter
+	for _, online := range []bool{false, true} {
+		games, err := listGames(ctx, avx, online)
+		if err != nil {
+			return nil, err
+		}
+		for _, ge := range games {
+			g, err := Decode(ge.Body)
+			if err != nil {
+				note(event{"event": "skip", "entry": ge.Name, "error": err.Error()}, "skipping %s: %v", ge.Name, err)
+				continue
+			}
+			saved := time.Unix(int64(g.Saved), 0).Local()
+			if saved.Before(since) || !until.IsZero() && !saved.Before(until) {
+				continue
+			}
+			hash := g.movesHash()
+			opponent := "against another human"
+			if g.Mode == ModeComputer {
+				opponent = fmt.Sprintf("against level %d", g.Level)
+			}
+			summary := []string{
+				fmt.Sprintf("%d×%d, %s, %d moves, saved %s.", g.Size, g.Size, opponent, len(g.Moves), saved.Format("2006-01-02 15:04")),
+			}
+			if r := results[hash]; r != "" {
+				summary = append(summary, "Result: "+r+".")
+			} else {
+				summary = append(summary, "Result not recorded.")
+			}
+			if a := findAnalysis(hash); a != nil {
+				s := fmt.Sprintf("By %s, the player matched %.1f%% of the engine's moves", a.Engine, 100*a.matchRate())
+				if l, ok := a.meanLoss(); ok {
+					s += fmt.Sprintf(", losing %.2f points a move", l)
+				}
+				summary = append(summary, s+".")
+			}
+			chapters = append(chapters, &epubChapter{Title: fmt.Sprintf("%s, %s", saved.Format("2006-01-02"), ge.Name), Summary: summary, Game: g, saved: saved})
+		}
+	}
+	sort.SliceStable(chapters, func(i, j int) bool { return chapters[i].saved.Before(chapters[j].saved) })
+	return chapters, nil
+}
+
+// sgfChapter returns the chapter of the SGF file fn, with the players, date, event and result of its root node.
+func sgfChapter(fn string) (*epubChapter, error) {
+	s, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := parseSGF(string(s))
+	if err != nil {
+		return nil, invalid(fmt.Errorf("%s: %w", fn, err))
+	}
+	size, moves, err := sgfMoves(nodes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fn, err)
+	}
+	prop := func(id string) string {
+		if v := nodes[0][id]; len(v) > 0 {
+			return strings.TrimSpace(v[0])
+		}
+		return ""
+	}
+	title := fn
+	if pb, pw := prop("PB"), prop("PW"); pb != "" || pw != "" {
+		title = fmt.Sprintf("%s (B) vs %s (W)", pb, pw)
+	}
+	summary := []string{fmt.Sprintf("%d×%d, %d moves.", size, size, len(moves))}
+	for _, p := range [][2]string{{"DT", "Date"}, {"EV", "Event"}, {"RE", "Result"}} {
+		if v := prop(p[0]); v != "" {
+			summary = append(summary, p[1]+": "+v+".")
+		}
+	}
+	return &epubChapter{Title: title, Summary: summary, Game: &Game{Size: int32(size), Moves: moves}}, nil
+}
+
+func epubCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("epub", flag.ContinueOnError)
+	avx := fs.String("a", "", "Champion Go archive")
+	out := fs.String("o", "", "the EPUB file, by default stdout")
+	title := fs.String("title", "Champion Go games", "the title of the book")
+	sinceFlag := fs.String("since", "", "the first day of the games of the archive, as 2006-01-02")
+	untilFlag := fs.String("until", "", "the day after the games of the archive, as 2006-01-02")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	var chapters []*epubChapter
+	if fs.NArg() > 0 {
+		for _, fn := range fs.Args() {
+			c, err := sgfChapter(fn)
+			if err != nil {
+				return err
+			}
+			chapters = append(chapters, c)
+		}
+	} else {
+		var since, until time.Time
+		for _, d := range []struct {
+			flag string
+			s    *string
+			t    *time.Time
+		}{{"-since", sinceFlag, &since}, {"-until", untilFlag, &until}} {
+			if *d.s == "" {
+				continue
+			}
+			t, err := time.ParseInLocation("2006-01-02", *d.s, time.Local)
+			if err != nil {
+				return fmt.Errorf("%s: %w", d.flag, err)
+			}
+			*d.t = t
+		}
+		var err error
+		if chapters, err = archiveChapters(ctx, *avx, since, until); err != nil {
+			return err
+		}
+	}
+	if len(chapters) == 0 {
+		return errNoGames
+	}
+
+	var b bytes.Buffer
+	if err := writeEpub(&b, *title, chapters); err != nil {
+		return err
+	}
+	if *out == "" || *out == "-" {
+		if jsonOutput {
+			return fmt.Errorf("the book cannot be written to stdout with -json, use -o")
+		}
+		_, err := os.Stdout.Write(b.Bytes())
+		return err
+	}
+	if err := writeFileAtomic(*out, b.Bytes()); err != nil {
+		return err
+	}
+	output(map[string]interface{}{"file": *out, "chapters": len(chapters)}, func(w io.Writer) {
+		fmt.Fprintf(w, "wrote %s, %d chapters\n", *out, len(chapters))
+	})
+	return nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"flag"
+	"io"
+	"io/fs"
+	"os"
+)
+
+// Exit codes of chamgo, so that wrapper scripts can react to failures without parsing messages.
+const (
+	exitOK          = 0
+	exitError       = 1 // any other error
+	exitUsage       = 2 // bad flags, as the flag package exits with
+	exitNoGames     = 3 // the archive has no game to work on
+	exitUnsupported = 4 // the archive, record or file format is not supported
+	exitInvalid     = 5 // a game, move or output failed validation
+	exitIO          = 6 // reading or writing a file failed
+)
+
+// codedError is an error with the exit code it causes.
+type codedError struct {
+	code int
+	err  error
+}
+
+func (e *codedError) Error() string { return e.err.Error() }
+func (e *codedError) Unwrap() error { return e.err }
+
+var errNoGames = &codedError{exitNoGames, errors.New("no game found")}
+
+// unsupported marks err as caused by an unsupported format.
+func unsupported(err error) error { return &codedError{exitUnsupported, err} }
+
+// invalid marks err as a validation failure.
+func invalid(err error) error { return &codedError{exitInvalid, err} }
+
+// parseFlags parses the flags of a command from args. Flag sets return their errors rather than exit,
+// so that bad flags are reported like other errors, in JSON with -json, where the usage is left out.
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if jsonOutput {
+		fs.SetOutput(io.Discard)
+	}
+	err := fs.Parse(args)
+	if err == nil || errors.Is(err, flag.ErrHelp) {
+		return err
+	}
+	return &codedError{exitUsage, err}
+}
+
+// exitCode returns the exit code for err.
+func exitCode(err error) int {
+	var ce *codedError
+	var pe *fs.PathError
+	var le *os.LinkError
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.As(err, &ce):
+		return ce.code
+	case errors.Is(err, zip.ErrFormat), errors.Is(err, zip.ErrAlgorithm), errors.Is(err, gzip.ErrHeader), errors.Is(err, tar.ErrHeader):
+		return exitUnsupported
+	case errors.Is(err, zip.ErrChecksum), errors.Is(err, gzip.ErrChecksum):
+		return exitInvalid
+	case errors.As(err, &pe), errors.As(err, &le):
+		return exitIO
+	}
+	return exitError
+}
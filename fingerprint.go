@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// fingerprintLen is the number of hex digits of the movesHash of a game shown as its fingerprint in listings.
+// As the hash covers only the size and moves, the fingerprint stays the same when chamgo rewrites or renames the record.
+const fingerprintLen = 12
+
+// fingerprint returns the fingerprint of the game with the movesHash hash.
+func fingerprint(hash string) string {
+	return hash[:min(fingerprintLen, len(hash))]
+}
+
+// isFingerprint reports whether the game selector s is a fingerprint, or the beginning of one, rather than an entry name,
+// which always has a directory.
+func isFingerprint(s string) bool {
+	if len(s) < 6 || len(s) > 64 {
+		return false
+	}
+	for _, c := range strings.ToLower(s) {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// readFingerprint returns the name and record of the one local or online game of the archive avxName
+// whose movesHash starts with prefix.
+func readFingerprint(ctx context.Context, avxName, prefix string) (string, []byte, error) {
+	prefix = strings.ToLower(prefix)
+	var found []gameEntry
+	if settingsOf(ctx).useCache() {
+		idx, err := gameIndex(ctx, avxName)
+		if err != nil {
+			return "", nil, err
+		}
+		for _, g := range idx {
+			if strings.HasPrefix(g.Hash, prefix) {
+				found = append(found, gameEntry{Name: g.Name})
+			}
+		}
+		if len(found) == 1 {
+			body, err := readArchiveFile(ctx, avxName, found[0].Name)
+			if err == nil {
+				body, err = settingsOf(ctx).app.record(found[0].Name, body)
+			}
+			if err != nil {
+				return "", nil, err
+			}
+			found[0].Body = body
+		}
+	} else {
+		for _, online := range []bool{false, true} {
+			games, err := listGames(ctx, avxName, online)
+			if err != nil {
+				return "", nil, err
+			}
+			for _, ge := range games {
+				if strings.HasPrefix(movesHash(ge.Body), prefix) {
+					found = append(found, ge)
+				}
+			}
+		}
+	}
+	switch len(found) {
+	case 0:
+		return "", nil, &codedError{exitNoGames, fmt.Errorf("no game of %s has the fingerprint %s", avxName, prefix)}
+	case 1:
+		return found[0].Name, found[0].Body, nil
+	}
+	names := make([]string, len(found))
+	for i, ge := range found {
+		names[i] = ge.Name
+	}
+	return "", nil, fmt.Errorf("several games have fingerprints starting with %s: %s", prefix, strings.Join(names, ", "))
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Fox Weiqi has no documented API. chamgo uses the endpoints of its web and mobile clients, which may change:
+// foxUserURL looks up the id of a user by name, and foxURL lists the games of a user and returns their SGF.
+var (
+	foxURL     = envOr("CHAMGO_FOX_URL", "https://h5.foxwq.com")
+	foxUserURL = envOr("CHAMGO_FOX_USER_URL", "https://newframe.foxwq.com")
+)
+
+// foxGame is a game of the game list of a Fox user.
+type foxGame struct {
+	ID     string `json:"chessid"`
+	Start  string `json:"starttime"`
+	Black  string `json:"blacknick"`
+	White  string `json:"whitenick"`
+	Result int    `json:"winner"` // 1 when black won and 2 when white won
+	Moves  int    `json:"movenum"`
+}
+
+// foxGet gets the JSON of a Fox endpoint into v. Fox reports errors with a non-zero result in a 200 response.
+func foxGet(ctx context.Context, endpoint string, q url.Values, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint+"?"+q.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(io.LimitReader(resp.Body, 8<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("Fox: %s", resp.Status)
+	}
+	var status struct {
+		Result    int    `json:"result"`
+		ResultStr string `json:"resultstr"`
+	}
+	if err := json.Unmarshal(b, &status); err != nil {
+		return fmt.Errorf("Fox: unexpected response: %w", err)
+	}
+	if status.Result != 0 {
+		return fmt.Errorf("Fox: error %d: %s", status.Result, status.ResultStr)
+	}
+	return json.Unmarshal(b, v)
+}
+
+// foxUserID returns the id of the Fox user name.
+func foxUserID(ctx context.Context, name string) (string, error) {
+	var r struct {
+		UID json.Number `json:"uid"`
+	}
+	err := foxGet(ctx, foxUserURL+"/cgi/QueryUserInfoPanel", url.Values{"srcuid": {"0"}, "username": {name}}, &r)
+	if err != nil {
+		return "", err
+	}
+	if r.UID == "" || r.UID == "0" {
+		return "", &codedError{exitNoGames, fmt.Errorf("no Fox user %s", name)}
+	}
+	return r.UID.String(), nil
+}
+
+// foxGames returns the most recent games of the Fox user uid, the latest first.
+func foxGames(ctx context.Context, uid string) ([]foxGame, error) {
+	var r struct {
+		Games []foxGame `json:"chesslist"`
+	}
+	q := url.Values{"srcuid": {uid}, "dstuid": {uid}, "type": {"1"}, "lastcode": {"0"}, "searchkey": {""}, "uin": {uid}}
+	if err := foxGet(ctx, foxURL+"/yehuDiamond/chessbook_local/YHWQFetchChessList", q, &r); err != nil {
+		return nil, err
+	}
+	return r.Games, nil
+}
+
+// foxSGF returns the SGF of the Fox game id.
+func foxSGF(ctx context.Context, id string) (string, error) {
+	var r struct {
+		SGF string `json:"chess"`
+	}
+	if err := foxGet(ctx, foxURL+"/yehuDiamond/chessbook_local/YHWQFetchChess", url.Values{"chessid": {id}}, &r); err != nil {
+		return "", err
+	}
+	if r.SGF == "" {
+		return "", &codedError{exitNoGames, fmt.Errorf("no Fox game %s", id)}
+	}
+	return r.SGF, nil
+}
+
+func foxCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("fox", flag.ContinueOnError)
+	avx := fs.String("a", "", "Champion Go archive")
+	user := fs.String("user", "", "the Fox user name whose games are listed or imported")
+	uid := fs.String("uid", "", "the Fox user id, instead of -user")
+	list := fs.Bool("list", false, "list the recent games of the user instead of importing one")
+	nth := fs.Int("n", 1, "import the nth most recent game of the user")
+	id := fs.String("id", "", "import the Fox game of this id")
+	moves := fs.Int("moves", 0, "keep this many moves, or 0 for the whole game")
+	slot := fs.String("slot", "latest-online", "the online game replaced by the Fox game")
+	p := fs.String("p", "", "the color of the human player, by default the color of the user, or b")
+	level := fs.Int("l", 10, "computer level")
+	sgfOut := fs.Bool("sgf", false, "print the SGF of the game instead of importing it")
+	out := fs.String("o", "-", "output archive")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	usage := fmt.Errorf("usage: chamgo fox [flags] -user name [-list | -n n] | -id id | game.sgf")
+	sources := fs.NArg()
+	if *id != "" {
+		sources++
+	}
+	if *user != "" || *uid != "" {
+		sources++
+	}
+	if sources != 1 || fs.NArg() > 1 || *list && *user == "" && *uid == "" || *nth < 1 || *moves < 0 {
+		return usage
+	}
+	if *level < 1 || *level > 10 {
+		return fmt.Errorf("level must be between 1 and 10")
+	}
+
+	var s string
+	color := *p
+	switch {
+	case fs.NArg() == 1:
+		b, err := ioutil.ReadFile(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		s = string(b)
+	case *id != "":
+		var err error
+		if s, err = foxSGF(ctx, *id); err != nil {
+			return err
+		}
+	default:
+		if *uid == "" {
+			var err error
+			if *uid, err = foxUserID(ctx, *user); err != nil {
+				return err
+			}
+		}
+		games, err := foxGames(ctx, *uid)
+		if err != nil {
+			return err
+		}
+		if *list {
+			output(games, func(w io.Writer) {
+				for _, g := range games {
+					result := map[int]string{1: "B+", 2: "W+"}[g.Result]
+					fmt.Fprintf(w, "%s  %s  %s (B) - %s (W)  %s %d moves\n", g.ID, g.Start, g.Black, g.White, result, g.Moves)
+				}
+			})
+			return nil
+		}
+		if *nth > len(games) {
+			return &codedError{exitNoGames, fmt.Errorf("the Fox user has %d recent games", len(games))}
+		}
+		g := games[*nth-1]
+		if color == "" && *user != "" {
+			switch *user {
+			case g.Black:
+				color = "b"
+			case g.White:
+				color = "w"
+			}
+		}
+		if s, err = foxSGF(ctx, g.ID); err != nil {
+			return err
+		}
+		*id = g.ID
+	}
+	if color == "" {
+		color = "b"
+	}
+	if *sgfOut {
+		output(map[string]string{"sgf": s}, func(w io.Writer) { fmt.Fprintln(w, strings.TrimSpace(s)) })
+		return nil
+	}
+
+	name, base, err := readEntry(ctx, *avx, *slot)
+	if err != nil {
+		return err
+	}
+	if base == nil {
+		return errNoGames
+	}
+	body, err := sgfGame(ctx, s, base)
+	if err != nil {
+		return err
+	}
+	if *moves > 0 {
+		if body, err = applyTransforms(ctx, body, "truncate="+strconv.Itoa(*moves)); err != nil {
+			return err
+		}
+	}
+	g, err := Decode(body)
+	if err != nil {
+		return err
+	}
+	from := fs.Arg(0)
+	if from == "" {
+		from = "Fox game " + *id
+	}
+	note(event{"event": "fox", "from": from, "entry": name, "moves": len(g.Moves)}, "replaced %s with %d moves of %s", name, len(g.Moves), from)
+	if err := setPlayer(body, color); err != nil {
+		return err
+	}
+	setLevel(body, byte(*level))
+	touchDates(body)
+	return writeAvxFile(ctx, *out, *avx, avxEdit{Replace: map[string][]byte{name: body}})
+}
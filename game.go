@@ -0,0 +1,223 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Layout of a saved game record in the current version of the app.
+// All multi-byte fields are little endian.
+const (
+	headerLen = 76
+	moveLen   = 20
+)
+
+// Game is the decoded form of a saved game record.
+// Bytes whose meaning is unknown are kept from the original record when encoding.
+type Game struct {
+	Mode    Mode   `json:"mode"`
+	Size    int32  `json:"size"`
+	Color   int32  `json:"color"`
+	Level   int32  `json:"level"`
+	Started int32  `json:"started"`
+	Saved   int32  `json:"saved"`
+	Moves   []Move `json:"moves"`
+
+	layout *layout
+	raw    []byte
+}
+
+// Mode is the kind of game of a record.
+// Only the values seen in saved games are named; the others are kept as they are and shown as numbers.
+type Mode int32
+
+const (
+	ModeComputer Mode = 0 // against the computer, at the level of the record
+	ModeHuman    Mode = 1 // two players taking turns on the device
+)
+
+var modeNames = map[Mode]string{ModeComputer: "computer", ModeHuman: "human"}
+
+func (m Mode) String() string {
+	if s, ok := modeNames[m]; ok {
+		return s
+	}
+	return strconv.Itoa(int(m))
+}
+
+// Set parses a mode name or number, so that modes can be flags.
+func (m *Mode) Set(s string) error {
+	for v, name := range modeNames {
+		if s == name {
+			*m = v
+			return nil
+		}
+	}
+	// Injected games only have the 5th byte of the record set to the mode, so the number has to fit in it.
+	n, err := strconv.ParseUint(s, 0, 8)
+	if err != nil {
+		return fmt.Errorf("mode must be computer, human or a number from 0 to 255")
+	}
+	*m = Mode(n)
+	return nil
+}
+
+// Move is a single entry of the move list, decoded from all 20 bytes of its record.
+// The coordinates are known for certain; the meaning of the other fields is inferred from the values seen in saved games.
+type Move struct {
+	Color    int32  `json:"color"` // 0 for black and 1 for white
+	X        int32  `json:"x"`
+	Y        int32  `json:"y"`
+	Captures uint16 `json:"captures"` // number of stones captured by the move
+	Flags    uint16 `json:"flags"`
+	Time     int32  `json:"time"` // elapsed time of the move
+}
+
+// newMove returns a move of the stone s at x, y, or a pass if x and y are 0.
+func newMove(s Stone, x, y int32) Move {
+	m := Move{X: x, Y: y}
+	if s == White {
+		m.Color = 1
+	}
+	return m
+}
+
+// Stone returns the color of the stone played by m.
+func (m Move) Stone() Stone {
+	if m.Color == 1 {
+		return White
+	}
+	return Black
+}
+
+// IsPass reports whether m is a pass.
+func (m Move) IsPass() bool { return m.X == 0 && m.Y == 0 }
+
+// Decode decodes a saved game record of the current version of the app, as -strict and -lenient say.
+func Decode(body []byte) (*Game, error) {
+	return decodeLayout(body, currentLayout)
+}
+
+// decode decodes a saved game record of the current version of the app, in the decoding mode of s.
+func (s *settings) decode(body []byte) (*Game, error) {
+	return s.decodeLayout(body, currentLayout)
+}
+
+// decodeMode is how records that do not look like the ones the app writes are decoded, set by -strict and -lenient.
+// In "strict" mode they are rejected, in "lenient" mode they are decoded as well as possible with warnings,
+// and by default only records too short for a header are rejected.
+var decodeMode = "default"
+
+// warned holds the lenient decoding warnings already printed.
+var warned = make(map[string]bool)
+
+func decodeLayout(body []byte, l *layout) (*Game, error) {
+	return flagSettings().decodeLayout(body, l)
+}
+
+func (s *settings) decodeLayout(body []byte, l *layout) (*Game, error) {
+	if len(body) < l.HeaderLen {
+		if s.decodeMode != "lenient" {
+			return nil, invalid(fmt.Errorf("record too short: %d bytes", len(body)))
+		}
+		s.warn(fmt.Sprintf("record too short: %d bytes, decoding the missing header bytes as zero", len(body)))
+		body = append(append([]byte(nil), body...), make([]byte, l.HeaderLen-len(body))...)
+	}
+	get := func(b []byte, off int) int32 { return int32(binary.LittleEndian.Uint32(b[off:])) }
+	g := &Game{
+		Mode:    Mode(get(body, l.Mode)),
+		Size:    get(body, l.Size),
+		Color:   get(body, l.Color),
+		Level:   get(body, l.Level),
+		Started: get(body, l.Started),
+		Saved:   get(body, l.Saved),
+		layout:  l,
+		raw:     append([]byte(nil), body...),
+	}
+	for i := l.HeaderLen; i+l.MoveLen <= len(body); i += l.MoveLen {
+		rec := body[i:]
+		g.Moves = append(g.Moves, Move{
+			Color:    get(rec, l.MoveColor),
+			X:        get(rec, l.MoveX),
+			Y:        get(rec, l.MoveY),
+			Captures: binary.LittleEndian.Uint16(rec[l.MoveCaptures:]),
+			Flags:    binary.LittleEndian.Uint16(rec[l.MoveFlags:]),
+			Time:     get(rec, l.MoveTime),
+		})
+	}
+	if s.decodeMode == "default" {
+		return g, nil
+	}
+	problems := g.problems(len(body))
+	if s.decodeMode == "strict" && len(problems) > 0 {
+		return nil, invalid(fmt.Errorf("strict: %s", strings.Join(problems, "; ")))
+	}
+	for _, p := range problems {
+		s.warn(p)
+	}
+	return g, nil
+}
+
+// problems lists what makes g, decoded from a record of n bytes, unlike the records the app writes.
+func (g *Game) problems(n int) []string {
+	var ps []string
+	if g.Size < 2 || int(g.Size) > len(columns) {
+		ps = append(ps, fmt.Sprintf("board size %d", g.Size))
+	}
+	if extra := (n - g.layout.HeaderLen) % g.layout.MoveLen; extra != 0 {
+		ps = append(ps, fmt.Sprintf("%d trailing bytes", extra))
+	}
+	for i, m := range g.Moves {
+		if m.Color != 0 && m.Color != 1 {
+			ps = append(ps, fmt.Sprintf("move %d: color %d", i+1, m.Color))
+		}
+		if !m.IsPass() && !g.onBoard(m) {
+			ps = append(ps, fmt.Sprintf("move %d: %d,%d is off the board", i+1, m.X, m.Y))
+		}
+		if m.Flags != 0 {
+			ps = append(ps, fmt.Sprintf("move %d: unknown flags %#x", i+1, m.Flags))
+		}
+	}
+	return ps
+}
+
+// Encode encodes g into a saved game record, in the layout it was decoded from.
+func (g *Game) Encode() []byte {
+	if g.layout == nil {
+		return g.encodeLayout(currentLayout)
+	}
+	return g.encodeLayout(g.layout)
+}
+
+// encodeLayout encodes g in the layout l.
+// Unknown header bytes are zero unless l is the layout g was decoded from.
+func (g *Game) encodeLayout(l *layout) []byte {
+	body := make([]byte, l.HeaderLen+len(g.Moves)*l.MoveLen)
+	if g.layout == l && len(g.raw) >= l.HeaderLen {
+		copy(body, g.raw[:l.HeaderLen])
+	}
+	put := func(b []byte, off int, v int32) { binary.LittleEndian.PutUint32(b[off:], uint32(v)) }
+	put(body, l.Mode, int32(g.Mode))
+	put(body, l.Size, g.Size)
+	put(body, l.Color, g.Color)
+	put(body, l.Level, g.Level)
+	put(body, l.Started, g.Started)
+	put(body, l.Saved, g.Saved)
+	for i, m := range g.Moves {
+		rec := body[l.HeaderLen+i*l.MoveLen:][:l.MoveLen]
+		put(rec, l.MoveColor, m.Color)
+		put(rec, l.MoveX, m.X)
+		put(rec, l.MoveY, m.Y)
+		binary.LittleEndian.PutUint16(rec[l.MoveCaptures:], m.Captures)
+		binary.LittleEndian.PutUint16(rec[l.MoveFlags:], m.Flags)
+		put(rec, l.MoveTime, m.Time)
+	}
+	return body
+}
+
+// onBoard reports whether m is a stone on the board of g, rather than a pass or resignation.
+func (g *Game) onBoard(m Move) bool {
+	return m.X >= 1 && m.X <= g.Size && m.Y >= 1 && m.Y <= g.Size
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, l := range layouts {
+		for _, size := range []int{9, 13, 19} {
+			g := synthGame(r, l, size, 60, synthEpoch)
+			body := g.encodeLayout(l)
+			d, err := decodeLayout(body, l)
+			if err != nil {
+				t.Fatalf("%s %dx%d: %v", l.Version, size, size, err)
+			}
+			if d.Mode != g.Mode || d.Size != g.Size || d.Color != g.Color || d.Level != g.Level ||
+				d.Started != g.Started || d.Saved != g.Saved || !reflect.DeepEqual(d.Moves, g.Moves) {
+				t.Errorf("%s %dx%d: decoded %+v, encoded %+v", l.Version, size, size, d, g)
+			}
+			if again := d.encodeLayout(l); !bytes.Equal(again, body) {
+				t.Errorf("%s %dx%d: encoding the decoded game changed the record", l.Version, size, size)
+			}
+		}
+	}
+}
+
+func TestDecodeShort(t *testing.T) {
+	g := synthGame(rand.New(rand.NewSource(1)), currentLayout, 19, 10, synthEpoch)
+	body := g.Encode()
+	for _, n := range []int{0, headerLen - 1} {
+		if _, err := Decode(body[:n]); err == nil {
+			t.Errorf("Decode of %d bytes: no error", n)
+		}
+	}
+}
+
+func FuzzDecode(f *testing.F) {
+	r := rand.New(rand.NewSource(3))
+	for _, size := range []int{9, 19, 25} {
+		f.Add(synthGame(r, currentLayout, size, 20, synthEpoch).Encode())
+	}
+	f.Add([]byte{})
+	f.Fuzz(func(t *testing.T, body []byte) {
+		defer func(mode string) { decodeMode = mode }(decodeMode)
+		for _, decodeMode = range []string{"strict", "default", "lenient"} {
+			g, err := Decode(body)
+			if err != nil {
+				continue
+			}
+			g.problems(len(body))
+			replay(g)
+			d, err := Decode(g.Encode())
+			if err != nil {
+				t.Fatalf("%s: decoding the encoded game: %v", decodeMode, err)
+			}
+			if !reflect.DeepEqual(d.Moves, g.Moves) {
+				t.Fatalf("%s: the moves changed when encoding", decodeMode)
+			}
+		}
+	})
+}
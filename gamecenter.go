@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// gameCenterPathRe matches the entries of the GameKit caches the app keeps of its Game Center matches.
+var gameCenterPathRe = regexp.MustCompile(`(?i)gamekit|gamecenter`)
+
+// forceOnline is set by -force to overwrite online games that Game Center may still sync.
+var forceOnline = false
+
+// plistStrings returns the strings and keys of the property list value v.
+func plistStrings(v interface{}) []string {
+	var s []string
+	switch v := v.(type) {
+	case string:
+		s = append(s, v)
+	case *plistDict:
+		for _, k := range v.Keys {
+			s = append(s, k)
+			s = append(s, plistStrings(v.Values[k])...)
+		}
+	case []interface{}:
+		for _, e := range v {
+			s = append(s, plistStrings(e)...)
+		}
+	}
+	return s
+}
+
+// gameCenterRefs returns the online games of names that the Game Center caches of the archive avxName refer to,
+// with the cache referring to each.
+func gameCenterRefs(ctx context.Context, avxName string, names []string) (map[string]string, error) {
+	return entryRefs(ctx, avxName, names, gameCenterPathRe.MatchString)
+}
+
+// entryRefs returns the games of names that the entries of the archive avxName selected by in refer to,
+// with the entry referring to each. The formats of such entries are mostly not known, so an entry refers to a game
+// when one of its property list strings is the game's file name or ends with its path, as match data naming
+// the game would, or when an entry that is not a property list contains its path.
+func entryRefs(ctx context.Context, avxName string, names []string, in func(name string) bool) (map[string]string, error) {
+	a, err := openArchive(ctx, avxName)
+	if err != nil {
+		return nil, err
+	}
+	defer a.Close()
+	refs := make(map[string]string)
+	for _, f := range a.Files {
+		if f.IsDir || !in(f.Name) {
+			continue
+		}
+		b, err := f.ReadAll()
+		if err != nil {
+			return nil, err
+		}
+		var strs []string
+		if v, err := parsePlist(b); err == nil {
+			strs = plistStrings(v)
+		}
+		for _, name := range names {
+			if _, ok := refs[name]; ok {
+				continue
+			}
+			base, rel := path.Base(name), path.Base(path.Dir(name))+"/"+path.Base(name)
+			found := strs == nil && bytes.Contains(b, []byte(rel))
+			for _, s := range strs {
+				if s == base || strings.HasSuffix(s, "/"+rel) || s == rel {
+					found = true
+					break
+				}
+			}
+			if found {
+				refs[name] = f.Name
+			}
+		}
+	}
+	return refs, nil
+}
+
+// checkGameCenter refuses to replace the online games of e that Game Center caches refer to, unless -force is set:
+// the device would sync them with Game Center again, overwriting the injected game or confusing the match.
+func checkGameCenter(ctx context.Context, avxName string, e avxEdit) error {
+	s := settingsOf(ctx)
+	app := s.app
+	if s.force || !app.hasOnlineGames() {
+		return nil
+	}
+	var online []string
+	for name := range e.Replace {
+		if app.isGameFile(name, true) {
+			online = append(online, name)
+		}
+	}
+	if len(online) == 0 {
+		return nil
+	}
+	refs, err := gameCenterRefs(ctx, avxName, online)
+	if err != nil {
+		return err
+	}
+	var active []string
+	for name, cache := range refs {
+		active = append(active, fmt.Sprintf("%s (in %s)", name, cache))
+	}
+	if len(active) == 0 {
+		return nil
+	}
+	sort.Strings(active)
+	return invalid(fmt.Errorf("the Game Center caches refer to %s, which may be an active match synced again by the device; use -force to replace it anyway, or another online game",
+		strings.Join(active, ", ")))
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"path"
+	"strings"
+)
+
+// globList is a repeatable flag of comma separated globs.
+type globList []string
+
+func (l *globList) String() string { return strings.Join(*l, ",") }
+
+func (l *globList) Set(s string) error {
+	for _, g := range strings.Split(s, ",") {
+		if g = strings.TrimSpace(g); g == "" {
+			continue
+		}
+		if _, err := path.Match(strings.ReplaceAll(g, "**", "*"), ""); err != nil {
+			return err
+		}
+		*l = append(*l, g)
+	}
+	return nil
+}
+
+// includeGlobs and excludeGlobs scope the archive entries that are scanned and copied.
+var includeGlobs, excludeGlobs globList
+
+// stripGlobs are the entries left out by -strip: caches and launch screen snapshots, which the app and iOS rebuild,
+// and the AppleDouble files, .DS_Store files and thumbnails that extraction tools and file browsers leave behind.
+var stripGlobs = []string{
+	"Container/Library/Caches",
+	"Container/Library/SplashBoard",
+	"Container/tmp",
+	"__MACOSX",
+	"**/._*",
+	"**/.DS_Store",
+	"**/Thumbs.db",
+	"**/*[Tt]humbnail*",
+}
+
+// inDir reports whether the slash separated entry name is inside the directory dir.
+// Unlike filepath.HasPrefix, it compares whole path elements and does not depend on the OS path separator.
+func inDir(name, dir string) bool {
+	dir = path.Clean(dir)
+	return dir == "." || strings.HasPrefix(path.Clean(name), dir+"/")
+}
+
+// matchGlob reports whether the entry name matches the glob pattern.
+// Patterns use the syntax of path.Match, where * does not match slashes, with ** matching any number of path elements.
+// A pattern matching a directory matches everything inside it, so "Container/Library" matches all the settings and caches.
+func matchGlob(pattern, name string) bool {
+	name = strings.TrimSuffix(name, "/")
+	pattern = strings.TrimSuffix(pattern, "/")
+	p, n := strings.Split(pattern, "/"), strings.Split(name, "/")
+	return matchElems(p, n)
+}
+
+func matchElems(p, n []string) bool {
+	for len(p) > 0 {
+		if p[0] == "**" {
+			for i := 0; i <= len(n); i++ {
+				if matchElems(p[1:], n[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(n) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(p[0], n[0]); !ok {
+			return false
+		}
+		p, n = p[1:], n[1:]
+	}
+	// The pattern matched a directory containing the rest of the name.
+	return true
+}
+
+// entryIncluded reports whether an archive entry is selected by the include and exclude globs of s.
+// Directories are kept when they may contain included entries.
+func (s *settings) entryIncluded(name string, isDir bool) bool {
+	for _, g := range s.exclude {
+		if matchGlob(g, name) {
+			return false
+		}
+	}
+	if len(s.include) == 0 {
+		return true
+	}
+	for _, g := range s.include {
+		if matchGlob(g, name) || isDir && inDir(strings.TrimSuffix(g, "/"), name) {
+			return true
+		}
+	}
+	return false
+}
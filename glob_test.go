@@ -0,0 +1,95 @@
+package main
+
+import "testing"
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"Container/Documents/game/*", "Container/Documents/game/3", true},
+		{"Container/Documents/game/*", "Container/Documents/game-online/3", false},
+		{"Container/Documents/*", "Container/Documents/game/3", true}, // inside the matched directory
+		{"*", "iTunesMetadata.plist", true},
+		{"*.plist", "Container/Library/Preferences/x.plist", false}, // * does not match slashes
+		{"**/*.plist", "Container/Library/Preferences/x.plist", true},
+		{"**/*.plist", "iTunesMetadata.plist", true}, // ** matches no elements too
+		{"Container/**/x.plist", "Container/x.plist", true},
+		{"Container/**/x.plist", "Container/Library/Preferences/x.plist", true},
+		{"Container/**/x.plist", "Other/Library/x.plist", false},
+		{"Container/Library", "Container/Library/Caches/", true},
+		{"Container/Library/", "Container/Library/Caches", true},
+		{"Container/Library", "Container/LibraryX/y", false}, // whole elements
+		{"Container/Library/Caches", "Container/Library", false},
+		{"**/._*", "__MACOSX/Container/._game", true},
+		{"**/*[Tt]humbnail*", "Container/Documents/thumbnail.png", true},
+		{"**/*[Tt]humbnail*", "Container/Documents/game/1", false},
+		{"Container/Documents/game/?", "Container/Documents/game/12", false},
+	}
+	for _, tt := range tests {
+		if got := matchGlob(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("matchGlob(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestInDir(t *testing.T) {
+	tests := []struct {
+		name, dir string
+		want      bool
+	}{
+		{"Container/Documents/game/1", "Container/Documents/game/", true},
+		{"Container/Documents/game/1", "Container/Documents/game", true},
+		{"Container/Documents/game-online/1", "Container/Documents/game/", false},
+		{"Container/Documents/game", "Container/Documents/game/", false},
+		{"Container/Documents/./game/1", "Container/Documents/game/", true},
+		{"anything", "", true},
+	}
+	for _, tt := range tests {
+		if got := inDir(tt.name, tt.dir); got != tt.want {
+			t.Errorf("inDir(%q, %q) = %v, want %v", tt.name, tt.dir, got, tt.want)
+		}
+	}
+}
+
+func TestEntryIncluded(t *testing.T) {
+	tests := []struct {
+		include, exclude globList
+		name             string
+		isDir            bool
+		want             bool
+	}{
+		{nil, nil, "Container/Documents/game/1", false, true},
+		{globList{"Container/Documents"}, nil, "Container/Documents/game/1", false, true},
+		{globList{"Container/Documents"}, nil, "Container/Library/x.plist", false, false},
+		{globList{"Container/Documents/game"}, nil, "Container/", true, true}, // may contain included entries
+		{globList{"Container/Documents/game"}, nil, "Container/Library/", true, false},
+		{nil, globList{"**/.DS_Store"}, "Container/Documents/.DS_Store", false, false},
+		{globList{"Container"}, globList{"Container/Library"}, "Container/Library/Caches/x", false, false},
+	}
+	for _, tt := range tests {
+		s := &settings{include: tt.include, exclude: tt.exclude}
+		if got := s.entryIncluded(tt.name, tt.isDir); got != tt.want {
+			t.Errorf("include %v, exclude %v: entryIncluded(%q, %v) = %v, want %v", tt.include, tt.exclude, tt.name, tt.isDir, got, tt.want)
+		}
+	}
+}
+
+func TestGlobListSet(t *testing.T) {
+	tests := []struct {
+		s    string
+		want int // the number of globs, or -1 for an error
+	}{
+		{"Container/Documents", 1},
+		{" a/*, **/b ,,c", 3},
+		{"", 0},
+		{"Container/[", -1},
+	}
+	for _, tt := range tests {
+		var l globList
+		err := l.Set(tt.s)
+		if got := len(l); err != nil && tt.want != -1 || err == nil && got != tt.want {
+			t.Errorf("Set(%q): %d globs, %v, want %d", tt.s, got, err, tt.want)
+		}
+	}
+}
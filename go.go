@@ -9,74 +9,213 @@ import (
 	"archive/zip"
 	"bytes"
 	"compress/flate"
+	"context"
 	"encoding/binary"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 )
 
+// The flags of the main command, which only main reads, to fill the Options of inject.
 var inAvx = flag.String("a", "", "input Champion Go archive")
+var outAvx = flag.String("o", "-", "output archive, - for stdout")
 var player = flag.String("p", "b", "the color of the human player")
+var diagram = flag.String("d", "", "use the position of a text board diagram file instead of the latest game")
+var toMove = flag.String("tomove", "b", "the side to move in the diagram")
+var sgfFile = flag.String("sgf", "", "use the main line of an SGF file instead of the latest game")
+var fromClipboard = flag.Bool("from-clipboard", false, "use the SGF in the clipboard instead of the latest game")
+var toClipboard = flag.Bool("to-clipboard", false, "copy the SGF of the injected game to the clipboard")
+var autosave = flag.Bool("autosave", false, "inject into the autosaved game in progress instead of the latest online game")
+var transformChain = flag.String("t", "", "comma separated transforms applied to the game, such as flip180,truncate=30")
 
 func getSavedDate(body []byte) (int32, error) {
+	if len(body) < 64 {
+		return 0, invalid(fmt.Errorf("record too short: %d bytes", len(body)))
+	}
 	b := body[60:64]
 	buf := bytes.NewReader(b)
 	var t int32
 	if err := binary.Read(buf, binary.LittleEndian, &t); err != nil {
-		return 0, fmt.Errorf("parse %v error: %v", b, err)
+		return 0, fmt.Errorf("parse %v error: %w", b, err)
 	}
 	return t, nil
 }
 
-func readAvx(f string, online bool) (string, []byte, error) {
-	r, err := zip.OpenReader(f)
+// gameEntry is a saved game record in an archive.
+type gameEntry struct {
+	Name string
+	Body []byte
+}
+
+// gameDir returns the directory of the local or online games in an archive.
+func gameDir(online bool) string { return currentApp.gameDir(online) }
+
+// listGames returns the local or online games of an archive in archive order.
+func listGames(ctx context.Context, f string, online bool) ([]gameEntry, error) {
+	a, err := openArchive(ctx, f)
 	if err != nil {
-		return "", nil, err
+		return nil, err
 	}
-	defer r.Close()
+	defer a.Close()
+	return archiveGames(ctx, a, f, online)
+}
 
-	prefix := "Container/Documents/game/"
-	if online {
-		prefix = "Container/Documents/game-online/"
+// archiveGames is listGames on the opened archive a of avxName.
+func archiveGames(ctx context.Context, a *archive, avxName string, online bool) ([]gameEntry, error) {
+	app := settingsOf(ctx).app
+	var games []gameEntry
+	for _, f := range a.Files {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if f.IsDir || !app.isGameFile(f.Name, online) {
+			continue
+		}
+		body, err := f.ReadAll()
+		if err != nil {
+			return nil, err
+		}
+		if body, err = app.record(f.Name, body); err != nil {
+			return nil, err
+		}
+		games = append(games, gameEntry{Name: f.Name, Body: body})
 	}
+	recordGames(ctx, avxName, games)
+	return games, nil
+}
 
-	var latest string
-	var latestBody []byte
-	var latestDate int32 = -1
-	for _, f := range r.File {
-		if !filepath.HasPrefix(f.Name, prefix) {
-			continue
+// scanHeaders returns the local or online games of the opened archive a of avxName in archive order, with only
+// the header of their records as Body, from the cached index of the archive if useCache. Otherwise, entries are closed
+// as soon as their header is read, so that only a few bytes of each are inflated.
+func scanHeaders(ctx context.Context, a *archive, avxName string, online bool) ([]gameEntry, error) {
+	if settingsOf(ctx).useCache() {
+		idx, err := indexArchive(ctx, a, avxName, false)
+		if err != nil {
+			return nil, err
+		}
+		var games []gameEntry
+		for _, g := range idx {
+			if g.Online == online {
+				games = append(games, gameEntry{Name: g.Name, Body: g.Header})
+			}
 		}
-		if f.Mode().IsDir() {
+		return games, nil
+	}
+	app := settingsOf(ctx).app
+	var games []gameEntry
+	for _, f := range a.Files {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if f.IsDir || !app.isGameFile(f.Name, online) {
 			continue
 		}
-		body, err := func() ([]byte, error) {
-			rc, err := f.Open()
-			if err != nil {
-				return nil, err
+		if app.format().toRecord != nil {
+			// Other apps' games are converted whole.
+			body, err := f.ReadAll()
+			if err == nil {
+				body, err = app.record(f.Name, body)
 			}
-			defer rc.Close()
-			body, err := ioutil.ReadAll(rc)
 			if err != nil {
 				return nil, err
 			}
-			return body, nil
-		}()
+			games = append(games, gameEntry{Name: f.Name, Body: body})
+			continue
+		}
+		header, err := readHeader(f)
+		if err != nil {
+			return nil, err
+		}
+		games = append(games, gameEntry{Name: f.Name, Body: header})
+	}
+	return games, nil
+}
+
+// readHeader returns the first headerLen bytes of f, or all of it if it is shorter.
+func readHeader(f *archiveFile) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	b := make([]byte, headerLen)
+	n, err := io.ReadFull(rc, b)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = nil
+	}
+	return b[:n], err
+}
+
+func readAvx(ctx context.Context, f string, online bool) (string, []byte, error) {
+	a, err := openArchive(ctx, f)
+	if err != nil {
+		return "", nil, err
+	}
+	defer a.Close()
+	return latestIn(ctx, a, f, online)
+}
+
+// latestIn is readAvx on the opened archive a of avxName.
+func latestIn(ctx context.Context, a *archive, avxName string, online bool) (string, []byte, error) {
+	// The latest game is found from the headers alone, unless all the games are recorded in the games database
+	// or shown to select one.
+	s := settingsOf(ctx)
+	if s.selectMode != "interactive" && !resultsEnabled() {
+		headers, err := scanHeaders(ctx, a, avxName, online)
+		if err != nil {
+			return "", nil, err
+		}
+		name, _, err := latestGame(headers)
+		if err != nil || name == "" {
+			return "", nil, err
+		}
+		body, err := a.file(name).ReadAll()
 		if err != nil {
 			return "", nil, err
 		}
-		savedDate, err := getSavedDate(body)
+		body, err = s.app.record(name, body)
+		return name, body, err
+	}
+	games, err := archiveGames(ctx, a, avxName, online)
+	if err != nil {
+		return "", nil, err
+	}
+	if s.selectMode == "interactive" && len(games) > 1 {
+		what := "local"
+		if online {
+			what = "online"
+		}
+		g, err := selectGame(games, what)
 		if err != nil {
 			return "", nil, err
 		}
+		return g.Name, g.Body, nil
+	}
+	return latestGame(games)
+}
+
+// latestGame returns the name and record of the most recently saved of games, or "" if there are none.
+func latestGame(games []gameEntry) (string, []byte, error) {
+	var latest string
+	var latestBody []byte
+	var latestDate int32 = -1
+	for _, g := range games {
+		savedDate, err := getSavedDate(g.Body)
+		if err != nil {
+			return "", nil, fmt.Errorf("%s: %w", g.Name, err)
+		}
 		if savedDate > latestDate {
-			latest = f.Name
-			latestBody = body
+			latest = g.Name
+			latestBody = g.Body
 			latestDate = savedDate
 		}
 	}
@@ -84,65 +223,184 @@ func readAvx(f string, online bool) (string, []byte, error) {
 	return latest, latestBody, nil
 }
 
-func flipBoard180(body []byte) {
-	// board size
-	bs := body[8]
-
-	for i := 76; i < len(body); i += 20 {
-		body[i+4] = bs - body[i+4] + 1
-		body[i+8] = bs - body[i+8] + 1
+func (s *settings) flipBoard180(body []byte) error {
+	g, err := s.decode(body)
+	if err != nil {
+		return err
 	}
+	symmetryTransform(symmetries["flip180"]).Apply(context.Background(), g)
+	copy(body, g.Encode())
+	return nil
 }
 
-func flipToComputer(body []byte) {
-	// The 5th byte determines that it is a computer game.
-	//body[4] = 0 // computer vs human
-	body[4] = 1 // human vs human
+// setPlayer, setLevel, setMode and touchDates leave records too short for a header as they are, for Decode to reject.
+func setPlayer(body []byte, p string) error { return flagSettings().setPlayer(body, p) }
 
+func (s *settings) setPlayer(body []byte, p string) error {
+	if len(body) < headerLen {
+		return nil
+	}
 	// The 12th byte determines whether the human player is black or white.
 	// If it is 0 then human plays black.
-	if *player == "w" {
+	if p == "w" {
 		body[12] = 1
-		flipBoard180(body)
-	} else {
-		body[12] = 0
+		return s.flipBoard180(body)
 	}
+	body[12] = 0
+	return nil
+}
 
-	// Level 10 computer
-	body[16] = 0x0a
+func setLevel(body []byte, level byte) {
+	if len(body) < headerLen {
+		return
+	}
+	body[16] = level
+}
+
+func setMode(body []byte, m Mode) {
+	if len(body) < headerLen {
+		return
+	}
+	// The 5th byte determines the mode of the game, 0 for a computer game and 1 for human vs human.
+	body[4] = byte(m)
+}
+
+// injectMode is the mode of injected games, set by -mode.
+var injectMode = ModeHuman
+
+// clock returns the time injected games are dated with and outputs are stamped with,
+// the time of -timestamp for reproducible outputs, or else the current time.
+var clock = time.Now
+
+// setTimestamp sets the clock to the time s, in Unix seconds, RFC 3339 or as dates takes it.
+func setTimestamp(s string) error {
+	t, err := time.Parse(time.RFC3339, s)
+	if sec, perr := strconv.ParseInt(s, 10, 64); perr == nil {
+		t, err = time.Unix(sec, 0), nil
+	} else if err != nil {
+		t, err = parseDateTime(s)
+	}
+	if err != nil {
+		return err
+	}
+	clock = func() time.Time { return t }
+	return nil
+}
+
+func touchDates(body []byte) { flagSettings().touchDates(body) }
+
+// touchDates sets the mode of body to the mode of injected games of s, and dates it with the clock of s.
+func (s *settings) touchDates(body []byte) {
+	if len(body) < headerLen {
+		return
+	}
+	setMode(body, s.mode)
 
 	// Update the started and save dates to make it easier to find
 	buf := bytes.NewBuffer(body[56:56])
-	now := int32(time.Now().Unix())
+	now := int32(s.clock().Unix())
 	binary.Write(buf, binary.LittleEndian, now) // started date
 	binary.Write(buf, binary.LittleEndian, now) // saved date
 }
 
-func writeAvx(w io.Writer, avxName string, latestBody []byte, firstOnline string) error {
+// flipToComputer makes the record body a game of player, "b" or "w", against the level 10 computer, dated now.
+func flipToComputer(s *settings, body []byte, player string) error {
+	if err := s.setPlayer(body, player); err != nil {
+		return err
+	}
+
+	// Level 10 computer
+	setLevel(body, 0x0a)
+
+	s.touchDates(body)
+	return nil
+}
+
+// diagramGame returns the record base with its board replaced by the diagram in the file fn.
+func diagramGame(ctx context.Context, fn string, base []byte, toMove string) ([]byte, error) {
+	side := Black
+	if toMove == "w" {
+		side = White
+	}
+	p, err := readDiagram(fn, side)
+	if err != nil {
+		return nil, err
+	}
+	g, err := settingsOf(ctx).decode(base)
+	if err != nil {
+		return nil, err
+	}
+	if err := g.setPosition(p); err != nil {
+		return nil, invalid(fmt.Errorf("%s: %w", fn, err))
+	}
+	return g.Encode(), nil
+}
+
+// avxEdit describes the changes made to an archive when it is copied by writeAvx.
+type avxEdit struct {
+	Replace map[string][]byte // new contents of entries
+	Rename  map[string]string // new names of entries
+	Delete  map[string]bool   // entries left out
+	Add     map[string][]byte // new entries, written after the entries of the archive
+
+	NoJournal bool // do not record the edit for chamgo undo
+}
+
+// writeAvx copies the archive avxName to w, applying the edit e.
+func writeAvx(ctx context.Context, w io.Writer, avxName string, e avxEdit) error {
 	zw := zip.NewWriter(w)
 	zw.RegisterCompressor(zip.Deflate, func(out io.Writer) (io.WriteCloser, error) {
 		return flate.NewWriter(out, flate.NoCompression)
 	})
 
-	r, err := zip.OpenReader(avxName)
+	s := settingsOf(ctx)
+	a, err := openArchive(ctx, avxName)
 	if err != nil {
 		return err
 	}
-	defer r.Close()
-	for _, f := range r.File {
+	defer a.Close()
+	encrypted := false // whether added entries are encrypted like the ones of the archive
+	files := outputFiles(a, e, s.sortEntries)
+	if s.sortEntries {
+		e.Add = nil
+	}
+	for _, f := range files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		encrypted = encrypted || f.Encrypted
+		if e.Delete[f.Name] {
+			continue
+		}
+		name := f.outName(e)
+		if _, ok := e.Replace[f.Name]; !ok && f.zip != nil {
+			if err := copyZipRaw(zw, f.zip, name); err != nil {
+				return err
+			}
+			continue
+		}
 		err := func() error {
 			rc, err := f.Open()
 			if err != nil {
 				return err
 			}
 			defer rc.Close()
-			of, err := zw.Create(f.Name)
+			if f.Encrypted {
+				body, ok := e.Replace[f.Name]
+				if !ok {
+					if body, err = ioutil.ReadAll(rc); err != nil {
+						return err
+					}
+				}
+				return writeZipAES(s, zw, name, body)
+			}
+			of, err := zw.Create(name)
 			if err != nil {
 				return err
 			}
 
-			if f.Name == firstOnline {
-				_, err = of.Write(latestBody)
+			if body, ok := e.Replace[f.Name]; ok {
+				_, err = of.Write(body)
 				if err != nil {
 					return err
 				}
@@ -158,6 +416,21 @@ func writeAvx(w io.Writer, avxName string, latestBody []byte, firstOnline string
 			return err
 		}
 	}
+	for _, name := range sortedKeys(e.Add) {
+		if encrypted {
+			if err := writeZipAES(s, zw, name, e.Add[name]); err != nil {
+				return err
+			}
+			continue
+		}
+		of, err := zw.Create(name)
+		if err != nil {
+			return err
+		}
+		if _, err := of.Write(e.Add[name]); err != nil {
+			return err
+		}
+	}
 
 	if err := zw.Close(); err != nil {
 		return err
@@ -165,20 +438,430 @@ func writeAvx(w io.Writer, avxName string, latestBody []byte, firstOnline string
 	return nil
 }
 
-func main() {
-	flag.Parse()
-	_, latestBody, err := readAvx(*inAvx, false)
+// copyZipRaw copies the zip entry f to zw as name without inflating and deflating it again.
+// Directories are written without data, which some zip tools deflate to a few bytes.
+func copyZipRaw(zw *zip.Writer, f *zip.File, name string) error {
+	h := f.FileHeader
+	h.Name = name
+	if strings.HasSuffix(name, "/") {
+		_, err := zw.CreateHeader(&h)
+		return err
+	}
+	w, err := zw.CreateRaw(&h)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	firstOnline, _, err := readAvx(*inAvx, true)
+	rc, err := f.OpenRaw()
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
+	_, err = io.Copy(w, rc)
+	return err
+}
 
-	flipToComputer(latestBody)
+// writeAvxFile is writeAvx to the file out, or to stdout if out is "-".
+// If out is a directory or ends with a slash, the archive is written as a directory tree instead,
+// and if out ends with .tar, .tar.gz or .tgz, as a tarball.
+func writeAvxFile(ctx context.Context, out, avxName string, e avxEdit) error {
+	if err := checkGameCenter(ctx, avxName, e); err != nil {
+		return err
+	}
+	e, err := keepOriginals(ctx, avxName, e)
+	if err != nil {
+		return err
+	}
+	e, err = appEdit(ctx, avxName, e)
+	if err != nil {
+		return err
+	}
+	if out == "" || out == "-" {
+		if settingsOf(ctx).json {
+			return fmt.Errorf("the archive cannot be written to stdout with -json, use -o")
+		}
+		return writeAvx(ctx, os.Stdout, avxName, e)
+	}
+	unlock, err := lockOutput(ctx, out)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if !(isDir(out) && sameFile(out, avxName)) {
+		if err := checkDiskSpace(ctx, out, avxName, e); err != nil {
+			return err
+		}
+	}
+	rec, err := journalFor(ctx, avxName, e)
+	if err != nil {
+		return err
+	}
+	if err := saveVersion(ctx, out); err != nil {
+		return err
+	}
+	if isDir(out) || strings.HasSuffix(out, "/") || strings.HasSuffix(out, string(filepath.Separator)) {
+		if err := writeDir(ctx, out, avxName, e); err != nil {
+			return err
+		}
+		return appendJournal(out, avxName, rec)
+	}
+	if err := writeArchiveFile(ctx, out, avxName, e); err != nil {
+		return err
+	}
+	return appendJournal(out, avxName, rec)
+}
 
-	if err := writeAvx(os.Stdout, *inAvx, latestBody, firstOnline); err != nil {
-		log.Fatal(err)
+// writeArchiveFile writes the archive avxName with the edit e to the file out through a temporary file
+// in the same directory, renamed to out once complete, so that out may be avxName itself
+// and is left as it was if writing fails or is canceled.
+func writeArchiveFile(ctx context.Context, out, avxName string, e avxEdit) error {
+	mode := os.FileMode(0644)
+	if fi, err := os.Stat(out); err == nil {
+		mode = fi.Mode().Perm()
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(out), ".chamgo-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := archiveWriter(out)(ctx, tmp, avxName, e); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
 	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), out)
+}
+
+// commands are the subcommands of chamgo.
+// Running chamgo without a subcommand performs the original replace-the-latest-online-game flow.
+var commands = map[string]func(ctx context.Context, args []string) error{
+	"run":        runCmd,
+	"script":     scriptCmd,
+	"pos":        posCmd,
+	"sgf":        sgfCmd,
+	"renumber":   renumberCmd,
+	"hexdump":    hexdumpCmd,
+	"patch":      patchCmd,
+	"gtp-serve":  gtpServeCmd,
+	"takeback":   takebackCmd,
+	"prefs":      prefsCmd,
+	"scrub":      scrubCmd,
+	"undo":       undoCmd,
+	"history":    historyCmd,
+	"recover":    recoverCmd,
+	"seed":       seedCmd,
+	"db":         dbCmd,
+	"merge":      mergeCmd,
+	"backup":     backupCmd,
+	"pull":       pullCmd,
+	"push":       pushCmd,
+	"sim":        simCmd,
+	"engine":     engineCmd,
+	"accuracy":   accuracyCmd,
+	"report":     reportCmd,
+	"games":      gamesCmd,
+	"tag":        tagCmd,
+	"anki":       ankiCmd,
+	"train":      trainCmd,
+	"replay":     replayCmd,
+	"latex":      latexCmd,
+	"epub":       epubCmd,
+	"html":       htmlCmd,
+	"ogs":        ogsCmd,
+	"mc-engine":  mcEngineCmd,
+	"check":      checkCmd,
+	"summary":    summaryCmd,
+	"problems":   problemsCmd,
+	"set-level":  setLevelCmd,
+	"ladder":     ladderCmd,
+	"status":     statusCmd,
+	"endgame":    endgameCmd,
+	"apps":       appsCmd,
+	"fox":        foxCmd,
+	"igs":        igsCmd,
+	"completion": completionCmd,
+	"wizard":     wizardCmd,
+	"batch":      batchCmd,
+	"dates":      datesCmd,
+	"synth":      synthCmd,
+	"bench":      benchCmd,
+}
+
+// globalFlags are the flags that may also precede a subcommand, such as "chamgo -json sgf -a backup.imazingapp".
+var globalFlags = []string{"password", "json", "include", "exclude", "strip", "blind", "select", "keep", "strict", "lenient", "rules", "engine", "app", "force", "keep-original", "sort-entries", "timestamp", "no-cache", "cache"}
+
+func init() {
+	// Bad flags are reported like other errors, see parseFlags.
+	flag.CommandLine.Init(os.Args[0], flag.ContinueOnError)
+	flag.StringVar(&zipPassword, "password", zipPassword, "password of AES-encrypted zip archives, also read from $CHAMGO_PASSWORD")
+	flag.BoolVar(&jsonOutput, "json", false, "print a single JSON report on stdout")
+	flag.Var(&includeGlobs, "include", "only use the archive entries matching this glob, may be repeated")
+	flag.Var(&excludeGlobs, "exclude", "leave out the archive entries matching this glob, may be repeated")
+	flag.BoolFunc("strip", "leave out caches, AppleDouble files and thumbnails", func(s string) error {
+		if on, err := strconv.ParseBool(s); err != nil || on {
+			excludeGlobs = append(excludeGlobs, stripGlobs...)
+			return err
+		}
+		return nil
+	})
+	flag.IntVar(&keepVersions, "keep", keepVersions, "keep this many previous versions of overwritten outputs, also read from $CHAMGO_KEEP")
+	flag.BoolFunc("strict", "reject records with unknown flags, off-board moves or trailing bytes", func(s string) error {
+		if on, err := strconv.ParseBool(s); err != nil || on {
+			decodeMode = "strict"
+			return err
+		}
+		return nil
+	})
+	flag.BoolFunc("lenient", "decode damaged records as well as possible, with warnings", func(s string) error {
+		if on, err := strconv.ParseBool(s); err != nil || on {
+			decodeMode = "lenient"
+			return err
+		}
+		return nil
+	})
+	flag.Var(rulesFlag{}, "rules", "rules moves are checked against: "+strings.Join(rulesetNames(), ", "))
+	flag.StringVar(&engineSpec, "engine", engineSpec, "GTP engine: "+strings.Join(engineNames(), ", ")+" with an optional level such as katago:5, or a command line")
+	flag.Var(&injectMode, "mode", "the mode of the injected game: computer, human or the number of another mode")
+	flag.Var(blindFlag{}, "blind", `draw boards for blind go: "none", "hidden" stones or "one-color"`)
+	flag.Var(appFlag{}, "app", "the app of the archives: auto, "+appUsage())
+	flag.Var(selectFlag{}, "select", `how games are picked when there are several: "latest" or "interactive"`)
+	flag.Func("keep-original", `keep the online games replaced as new local games, "local", or SGF files in $CHAMGO_HOME/originals, "sgf"`, setKeepOriginal)
+	flag.Func("timestamp", "date injected games and outputs at this time instead of now, in Unix seconds, RFC 3339 or as 2006-01-02 15:04", setTimestamp)
+	flag.BoolVar(&sortEntries, "sort-entries", false, "write archive entries in a canonical order, directory by directory, for smaller diffs between outputs")
+	flag.BoolVar(&noCache, "no-cache", false, "read every game of the archives instead of their indexes cached in $CHAMGO_HOME/cache")
+	flag.BoolVar(&writeCache, "cache", false, "write the indexes of the archives read to $CHAMGO_HOME/cache, for later commands to read")
+	flag.BoolVar(&forceOnline, "force", false, "replace online games even if Game Center caches refer to them")
+}
+
+// parseGlobalFlags sets the leading global flags of args and returns the remaining arguments.
+func parseGlobalFlags(args []string) ([]string, error) {
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") {
+		name, value, hasValue := strings.Cut(strings.TrimLeft(args[0], "-"), "=")
+		global := false
+		for _, g := range globalFlags {
+			global = global || g == name
+		}
+		if !global {
+			break
+		}
+		f := flag.Lookup(name)
+		if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() && !hasValue {
+			value, hasValue = "true", true
+		}
+		args = args[1:]
+		if !hasValue {
+			if len(args) == 0 {
+				return nil, fmt.Errorf("flag needs an argument: -%s", name)
+			}
+			value, args = args[0], args[1:]
+		}
+		if err := f.Value.Set(value); err != nil {
+			return nil, fmt.Errorf("invalid value %q for flag -%s: %v", value, name, err)
+		}
+	}
+	return args, nil
+}
+
+// jsMain replaces the command line in WebAssembly builds, see wasm/chamgo_js.go.
+var jsMain func()
+
+func main() {
+	if jsMain != nil {
+		jsMain()
+		return
+	}
+	// The first interrupt cancels the running command, which stops at the next entry, game or script
+	// and removes its temporary and partial output files; a second one exits immediately.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	fatal := func(err error) {
+		if errors.Is(err, flag.ErrHelp) {
+			// The usage was asked for with -h and has been printed.
+			if jsonOutput {
+				printReport(nil)
+			}
+			os.Exit(exitOK)
+		}
+		if errors.Is(err, context.Canceled) {
+			err = errors.New("interrupted")
+		}
+		if jsonOutput {
+			printReport(err)
+		} else {
+			log.Print(err)
+		}
+		os.Exit(exitCode(err))
+	}
+	args, err := parseGlobalFlags(os.Args[1:])
+	if err != nil {
+		fatal(&codedError{exitUsage, err})
+	}
+	os.Args = append(os.Args[:1], args...)
+
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			report.Command = os.Args[1]
+			if err := cmd(ctx, os.Args[2:]); err != nil {
+				fatal(err)
+			}
+			if jsonOutput {
+				printReport(nil)
+			}
+			return
+		}
+	}
+
+	if err := parseFlags(flag.CommandLine, os.Args[1:]); err != nil {
+		fatal(err)
+	}
+	o := Options{
+		Archive:       *inAvx,
+		Out:           *outAvx,
+		Player:        *player,
+		Diagram:       *diagram,
+		ToMove:        *toMove,
+		SGF:           *sgfFile,
+		FromClipboard: *fromClipboard,
+		ToClipboard:   *toClipboard,
+		Autosave:      *autosave,
+		Transforms:    *transformChain,
+		Select:        selectMode,
+		Mode:          injectMode,
+		Clock:         clock,
+		DecodeMode:    decodeMode,
+		Rules:         rules.Name,
+		SortEntries:   sortEntries,
+		JSON:          jsonOutput,
+		Password:      zipPassword,
+		Include:       includeGlobs,
+		Exclude:       excludeGlobs,
+		Force:         forceOnline,
+		KeepOriginal:  keepOriginal,
+		NoCache:       noCache,
+		Cache:         writeCache,
+		KeepVersions:  keepVersions,
+	}
+	if appDetected {
+		o.App = currentApp.Name
+	}
+	if err := inject(ctx, o); err != nil {
+		fatal(err)
+	}
+	if jsonOutput {
+		printReport(nil)
+	}
+}
+
+// Options are the choices of an injection, which the flags of the main command fill,
+// so that other programs can inject games without setting flags, and several injections can run at once.
+type Options struct {
+	Archive       string // the input archive
+	Out           string // the output archive, "-" for stdout
+	Player        string // the color of the human player, "b" or "w"
+	Diagram       string // a text board diagram file used instead of the latest game
+	ToMove        string // the side to move in the diagram
+	SGF           string // an SGF file whose main line is used instead of the latest game
+	FromClipboard bool   // use the SGF in the clipboard instead of the latest game
+	ToClipboard   bool   // copy the SGF of the injected game to the clipboard
+	Autosave      bool   // inject into the autosaved game in progress instead of the latest online game
+	Transforms    string // comma separated transforms applied to the game
+
+	// The global flags of chamgo set the rest, which the functions inject calls follow instead of the flags.
+	App         string           // the app of the archive, detected from its iTunesMetadata.plist if ""
+	Select      string           // how games are picked when there are several, "latest" if "" or "interactive"
+	Mode        Mode             // the mode of the injected game; chamgo injects ModeHuman games unless -mode is set
+	Clock       func() time.Time // the time the injected game is dated with and the output stamped with, time.Now if nil
+	DecodeMode  string           // how records unlike the app's are decoded, "default" if "", "strict" or "lenient"
+	Rules       string           // the rules moves are checked against, japanese if ""
+	SortEntries bool             // write the entries of the archive in a canonical order
+	JSON        bool             // record notes in the JSON report instead of printing them, and refuse to write to stdout
+	Password    string           // the password of AES-encrypted zip archives
+	Include     []string         // only use the archive entries matching these globs
+	Exclude     []string         // leave out the archive entries matching these globs
+
+	Force        bool   // replace the online game even if Game Center caches refer to it
+	KeepOriginal string // keep the replaced online game as a new local game with "local", or in an SGF file with "sgf"
+	NoCache      bool   // read every game of the archive instead of its cached index
+	Cache        bool   // write the index of the archive to $CHAMGO_HOME/cache
+	KeepVersions int    // keep this many previous versions of an overwritten Out
+}
+
+// inject replaces the latest online game of o.Archive with its latest local game, or the game o gives instead,
+// as a game of o.Player against the computer, and writes the archive to o.Out.
+func inject(ctx context.Context, o Options) error {
+	sess, err := NewSession(o)
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+	a, err := sess.Open(ctx, o.Archive)
+	if err != nil {
+		return err
+	}
+	s := sess.settings
+	ctx = withSettings(ctx, s)
+	_, latestBody, err := a.Record(ctx, "latest")
+	if err != nil {
+		return err
+	}
+	var firstOnline string
+	var onlineBody []byte
+	switch {
+	case o.Autosave:
+		firstOnline, onlineBody, err = a.Record(ctx, "autosave")
+	case !s.app.hasOnlineGames():
+		// Apps without online games have their latest game replaced instead.
+		firstOnline, onlineBody, err = a.Record(ctx, "latest")
+	default:
+		firstOnline, onlineBody, err = a.Record(ctx, "latest-online")
+	}
+	if err != nil {
+		return err
+	}
+	if onlineBody == nil || (latestBody == nil && o.Diagram == "" && o.SGF == "" && !o.FromClipboard) {
+		return errNoGames
+	}
+	switch {
+	case o.Diagram != "":
+		latestBody, err = diagramGame(ctx, o.Diagram, onlineBody, o.ToMove)
+	case o.SGF != "":
+		var b []byte
+		if b, err = ioutil.ReadFile(o.SGF); err == nil {
+			latestBody, err = sgfGame(ctx, string(b), onlineBody)
+		}
+	case o.FromClipboard:
+		var clip string
+		if clip, err = readClipboard(); err == nil {
+			latestBody, err = sgfGame(ctx, clip, onlineBody)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	latestBody, err = applyTransforms(ctx, latestBody, o.Transforms)
+	if err != nil {
+		return err
+	}
+	if err := flipToComputer(s, latestBody, o.Player); err != nil {
+		return err
+	}
+	if o.ToClipboard {
+		g, err := s.decode(latestBody)
+		if err != nil {
+			return err
+		}
+		if err := writeClipboard(writeSGF(g)); err != nil {
+			return err
+		}
+	}
+
+	a.Replace(firstOnline, latestBody)
+	return a.Write(ctx, o.Out)
 }
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// synthArchive writes a synthetic archive of o to a temporary file and returns its name.
+func synthArchive(t *testing.T, o synthOptions) string {
+	t.Helper()
+	name := filepath.Join(t.TempDir(), "synth.imazingapp")
+	f, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeSynthArchive(f, o); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+// zipEntries returns the entries of the zip archive b by name.
+func zipEntries(t *testing.T, b []byte) map[string][]byte {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := make(map[string][]byte)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries[f.Name] = body
+	}
+	return entries
+}
+
+func TestWriteAvx(t *testing.T) {
+	avx := synthArchive(t, synthOptions{Version: currentLayout.Version, Local: 3, Online: 2, Size: 19, Moves: 30, Seed: 1})
+	b, err := ioutil.ReadFile(avx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := zipEntries(t, b)
+
+	ctx := context.Background()
+	replaced := apps[0].Dirs[0] + "1.game"
+	deleted := apps[0].Dirs[1] + "4.game"
+	added := apps[0].Dirs[0] + "5.game"
+	body, err := applyTransforms(ctx, before[replaced], "flip180")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	e := avxEdit{
+		Replace: map[string][]byte{replaced: body},
+		Delete:  map[string]bool{deleted: true},
+		Add:     map[string][]byte{added: before[replaced]},
+	}
+	if err := writeAvx(ctx, &buf, avx, e); err != nil {
+		t.Fatal(err)
+	}
+	after := zipEntries(t, buf.Bytes())
+
+	if !bytes.Equal(after[replaced], body) {
+		t.Errorf("%s is not replaced", replaced)
+	}
+	if _, ok := after[deleted]; ok {
+		t.Errorf("%s is not deleted", deleted)
+	}
+	if !bytes.Equal(after[added], before[replaced]) {
+		t.Errorf("%s is not added", added)
+	}
+	for name, b := range before {
+		if name == replaced || name == deleted {
+			continue
+		}
+		if !bytes.Equal(after[name], b) {
+			t.Errorf("%s changed", name)
+		}
+	}
+	if len(after) != len(before) {
+		t.Errorf("%d entries written, want %d", len(after), len(before))
+	}
+
+	// Flipping the replaced game again gives back the original record.
+	g, err := Decode(after[replaced])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if flipped, _ := applyTransforms(ctx, g.Encode(), "flip180"); !bytes.Equal(flipped, before[replaced]) {
+		t.Errorf("%s flipped twice is not the original game", replaced)
+	}
+}
+
+// TestWriteAvxFileInPlace writes an archive to itself, which must end up edited rather than truncated,
+// and left as it was when writing fails.
+func TestWriteAvxFileInPlace(t *testing.T) {
+	avx := synthArchive(t, synthOptions{Version: currentLayout.Version, Local: 2, Online: 1, Size: 9, Moves: 10, Seed: 5})
+	b, err := ioutil.ReadFile(avx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := zipEntries(t, b)
+
+	ctx := context.Background()
+	replaced := apps[0].Dirs[0] + "1.game"
+	body, err := applyTransforms(ctx, before[replaced], "flip180")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeAvxFile(ctx, avx, avx, avxEdit{Replace: map[string][]byte{replaced: body}}); err != nil {
+		t.Fatal(err)
+	}
+	if b, err = ioutil.ReadFile(avx); err != nil {
+		t.Fatal(err)
+	}
+	after := zipEntries(t, b)
+	if !bytes.Equal(after[replaced], body) || len(after) != len(before) {
+		t.Errorf("%s is not replaced in place", replaced)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := writeArchiveFile(canceled, avx, avx, avxEdit{Replace: map[string][]byte{replaced: before[replaced]}}); err == nil {
+		t.Error("no error writing with a canceled context")
+	}
+	if b2, err := ioutil.ReadFile(avx); err != nil || !bytes.Equal(b, b2) {
+		t.Errorf("a failed write changed the archive: %v", err)
+	}
+}
+
+// TestInject runs injections with different Options at once, which must not see each other's settings.
+func TestInject(t *testing.T) {
+	avx := synthArchive(t, synthOptions{Version: currentLayout.Version, Local: 2, Online: 2, Size: 19, Moves: 20, Seed: 2})
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	errs := make([]error, 4)
+	outs := make([]string, 4)
+	for i := range outs {
+		outs[i] = filepath.Join(t.TempDir(), "out.imazingapp")
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			at := synthEpoch.Add(time.Duration(i) * 24 * time.Hour)
+			errs[i] = inject(ctx, Options{Archive: avx, Out: outs[i], Player: "b", Mode: Mode(i % 2), Clock: func() time.Time { return at }})
+		}(i)
+	}
+	wg.Wait()
+
+	online := apps[0].Dirs[1] + "3.game"
+	for i, out := range outs {
+		if errs[i] != nil {
+			t.Fatalf("injection %d: %v", i, errs[i])
+		}
+		b, err := ioutil.ReadFile(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		g, err := Decode(zipEntries(t, b)[online])
+		if err != nil {
+			t.Fatal(err)
+		}
+		at := synthEpoch.Add(time.Duration(i) * 24 * time.Hour)
+		if g.Mode != Mode(i%2) || g.Saved != int32(at.Unix()) || g.Level != 10 {
+			t.Errorf("injection %d: mode %v, saved %d, level %d", i, g.Mode, g.Saved, g.Level)
+		}
+	}
+
+	if err := inject(ctx, Options{Archive: avx, Out: "-", JSON: true}); err == nil {
+		t.Error("no error writing to stdout with JSON")
+	}
+}
+
+// TestInjectSettings checks that injections follow the settings of their Options rather than the global flags.
+func TestInjectSettings(t *testing.T) {
+	avx := synthArchive(t, synthOptions{Version: currentLayout.Version, Local: 1, Online: 1, Size: 9, Moves: 10, Seed: 6})
+	ctx := context.Background()
+	out := filepath.Join(t.TempDir(), "out.imazingapp")
+	at := synthEpoch.Add(48 * time.Hour)
+	o := Options{Archive: avx, Out: out, Player: "w", KeepVersions: 1, Clock: func() time.Time { return at }}
+	for i := 0; i < 2; i++ {
+		if err := inject(ctx, o); err != nil {
+			t.Fatal(err)
+		}
+	}
+	versions, err := listVersions(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := at.Format(versionFormat) + ".imazingapp"; len(versions) != 1 || filepath.Base(versions[0]) != want {
+		t.Errorf("versions %v, want %s", versions, want)
+	}
+
+	o.KeepOriginal = "online"
+	if err := inject(ctx, o); err == nil {
+		t.Error("no error keeping originals as online games")
+	}
+
+	// Playing white flips the board, which decodes the record as the settings say.
+	body := make([]byte, headerLen+moveLen)
+	binary.LittleEndian.PutUint32(body[8:], 50)
+	strict := flagSettings()
+	strict.decodeMode = "strict"
+	if err := flipToComputer(strict, body, "w"); err == nil {
+		t.Error("no error flipping a board of size 50 in strict mode")
+	}
+}
+
+func FuzzGetSavedDate(f *testing.F) {
+	f.Add(synthGame(rand.New(rand.NewSource(4)), currentLayout, 19, 5, synthEpoch).Encode())
+	f.Add([]byte{})
+	f.Fuzz(func(t *testing.T, body []byte) {
+		saved, err := getSavedDate(body)
+		if len(body) >= headerLen && (err != nil || saved != int32(binary.LittleEndian.Uint32(body[60:]))) {
+			t.Fatalf("saved date %d, %v", saved, err)
+		}
+	})
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// GoGoD collections name their files by the date of the game, as 1846-09-11a.sgf, in directories of years or periods.
+// Their DT properties date games played over several days as 1846-09-11,12, and games known only to the month or year,
+// or with uncertain dates, with such notes as 1846 (?); the names of some players are in Latin-1.
+var (
+	gogodNameRe = regexp.MustCompile(`^\d{4}(-\d{2}){0,2}[a-z]*\.sgf$`)
+	gogodDateRe = regexp.MustCompile(`^\d{4}(-\d{2}(-\d{2})?)?`)
+)
+
+// isGoGoD reports whether the SGF file fn with the root node root is from a GoGoD collection,
+// by its name or its source and user properties.
+func isGoGoD(fn string, root sgfNode) bool {
+	for _, id := range []string{"SO", "US"} {
+		if v := root[id]; len(v) > 0 && strings.Contains(strings.ToLower(v[0]), "gogod") {
+			return true
+		}
+	}
+	return gogodNameRe.MatchString(strings.ToLower(filepath.Base(fn)))
+}
+
+// gogodEntry applies the conventions of GoGoD to the index entry e of its file fn with the root node root:
+// the date is the first day of the game, or else the date of the file name, the event includes the round,
+// and a jigo is the result 0 as in SGF.
+func gogodEntry(e *indexedGame, fn string, root sgfNode) {
+	e.Source = "GoGoD"
+	e.Date = gogodDateRe.FindString(e.Date)
+	if e.Date == "" {
+		e.Date = gogodDateRe.FindString(filepath.Base(fn))
+	}
+	if ro := root["RO"]; len(ro) > 0 && strings.TrimSpace(ro[0]) != "" {
+		if e.Event == "" {
+			e.Event = strings.TrimSpace(ro[0])
+		} else {
+			e.Event += ", " + strings.TrimSpace(ro[0])
+		}
+	}
+	if strings.EqualFold(e.Result, "jigo") {
+		e.Result = "0"
+	}
+	for _, s := range []*string{&e.Black, &e.White, &e.Event} {
+		*s = latin1(*s)
+	}
+}
+
+// latin1 returns s decoded from Latin-1 if it is not valid UTF-8.
+func latin1(s string) string {
+	if utf8.ValidString(s) {
+		return s
+	}
+	r := make([]rune, len(s))
+	for i := 0; i < len(s); i++ {
+		r[i] = rune(s[i])
+	}
+	return string(r)
+}
+
+// sgfHandicap returns the number of handicap stones of the HA property of root, or 0.
+func sgfHandicap(root sgfNode) int {
+	if v := root["HA"]; len(v) > 0 {
+		n, _ := strconv.Atoi(strings.TrimSpace(v[0]))
+		return n
+	}
+	return 0
+}
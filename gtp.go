@@ -0,0 +1,311 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// gtpServer speaks GTP to a GUI such as Sabaki or GoGui, editing a game of an archive.
+// Moves played in the GUI are appended to the game, and the archive is written back after each of them.
+type gtpServer struct {
+	avx  string // the input archive
+	slot string // the edited game
+	out  string // the output archive
+
+	game   *Game
+	board  *Board
+	engine *gtpEngine // the engine of -engine generating moves, started by the first genmove
+}
+
+var gtpCommands = []string{
+	"protocol_version", "name", "version", "known_command", "list_commands", "quit",
+	"boardsize", "clear_board", "komi", "play", "genmove", "undo", "showboard", "loadavx",
+	"kgs-rules",
+}
+
+// load loads the game slot of the archive avx.
+func (s *gtpServer) load(ctx context.Context, avx, slot string) error {
+	name, body, err := readEntry(ctx, avx, slot)
+	if err != nil {
+		return err
+	}
+	if body == nil {
+		return errNoGames
+	}
+	g, err := Decode(body)
+	if err != nil {
+		return err
+	}
+	b, err := replay(g)
+	if err != nil {
+		return err
+	}
+	s.avx, s.slot, s.game, s.board = avx, name, g, b
+	return nil
+}
+
+// save writes the archive with the edited game.
+func (s *gtpServer) save(ctx context.Context) error {
+	s.game.Saved = int32(clock().Unix())
+	return writeAvxFile(ctx, s.out, s.avx, avxEdit{Replace: map[string][]byte{s.slot: s.game.Encode()}})
+}
+
+func parseVertex(v string, size int) (int, int, error) {
+	v = strings.ToUpper(v)
+	if v == "PASS" {
+		return 0, 0, nil
+	}
+	if len(v) < 2 {
+		return 0, 0, fmt.Errorf("invalid vertex %q", v)
+	}
+	x := strings.IndexByte(columns, v[0]) + 1
+	row, err := strconv.Atoi(v[1:])
+	if x < 1 || x > size || err != nil || row < 1 || row > size {
+		return 0, 0, fmt.Errorf("invalid vertex %q", v)
+	}
+	return x, size - row + 1, nil
+}
+
+func parseGTPColor(c string) (Stone, error) {
+	switch strings.ToLower(c) {
+	case "b", "black":
+		return Black, nil
+	case "w", "white":
+		return White, nil
+	}
+	return Empty, fmt.Errorf("invalid color %q", c)
+}
+
+// handle executes a GTP command and returns its response.
+func (s *gtpServer) handle(ctx context.Context, cmd string, args []string) (string, error) {
+	switch cmd {
+	case "protocol_version":
+		return "2", nil
+	case "name":
+		return "chamgo", nil
+	case "version":
+		return "1", nil
+	case "known_command":
+		if len(args) == 1 {
+			for _, c := range gtpCommands {
+				if c == args[0] {
+					return "true", nil
+				}
+			}
+		}
+		return "false", nil
+	case "list_commands":
+		cs := append([]string(nil), gtpCommands...)
+		sort.Strings(cs)
+		return strings.Join(cs, "\n"), nil
+	case "quit":
+		return "", nil
+	case "loadavx":
+		avx, slot := s.avx, s.slot
+		if len(args) > 0 {
+			avx, slot = args[0], "latest-online"
+		}
+		if len(args) > 1 {
+			slot = args[1]
+		}
+		return "", s.load(ctx, avx, slot)
+	case "kgs-rules":
+		if len(args) != 1 {
+			return "", fmt.Errorf("kgs-rules needs a ruleset")
+		}
+		r, err := lookupRules(args[0])
+		if err != nil {
+			return "", err
+		}
+		rules = r
+		s.closeEngine() // restarted with the new rules by the next genmove
+		if s.game == nil {
+			return "", nil
+		}
+		b, err := replay(s.game)
+		if err != nil {
+			return "", err
+		}
+		s.board = b
+		return "", nil
+	}
+
+	if s.game == nil {
+		return "", fmt.Errorf("no game loaded, use loadavx")
+	}
+	switch cmd {
+	case "boardsize":
+		if len(args) != 1 {
+			return "", fmt.Errorf("boardsize needs a size")
+		}
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 2 || n > len(columns) {
+			return "", fmt.Errorf("unacceptable size")
+		}
+		s.game.Size = int32(n)
+		s.game.Moves = nil
+		s.board = NewBoard(n)
+	case "clear_board":
+		s.game.Moves = nil
+		s.board = NewBoard(int(s.game.Size))
+	case "komi":
+	case "play":
+		if len(args) != 2 {
+			return "", fmt.Errorf("play needs a color and a vertex")
+		}
+		c, err := parseGTPColor(args[0])
+		if err != nil {
+			return "", err
+		}
+		x, y, err := parseVertex(args[1], s.board.Size)
+		if err != nil {
+			return "", err
+		}
+		captured, err := s.board.Play(c, x, y)
+		if err != nil {
+			return "", fmt.Errorf("illegal move: %w", err)
+		}
+		m := newMove(c, int32(x), int32(y))
+		m.Captures = uint16(captured)
+		s.game.Moves = append(s.game.Moves, m)
+		return "", s.save(ctx)
+	case "undo":
+		if len(s.game.Moves) == 0 {
+			return "", fmt.Errorf("cannot undo")
+		}
+		s.game.Moves = s.game.Moves[:len(s.game.Moves)-1]
+		b, err := replay(s.game)
+		if err != nil {
+			return "", err
+		}
+		s.board = b
+		return "", s.save(ctx)
+	case "genmove":
+		if len(args) != 1 {
+			return "", fmt.Errorf("genmove needs a color")
+		}
+		c, err := parseGTPColor(args[0])
+		if err != nil {
+			return "", err
+		}
+		if engineSpec == "" {
+			return "", fmt.Errorf("chamgo does not generate moves, pick an engine with -engine")
+		}
+		if s.engine == nil {
+			if s.engine, err = startEngine(ctx); err != nil {
+				return "", err
+			}
+		}
+		if err := s.engine.setup(s.game); err != nil {
+			return "", err
+		}
+		v, err := s.engine.send("genmove", gtpColor(c))
+		if err != nil {
+			return "", err
+		}
+		if strings.EqualFold(v, "resign") {
+			return v, nil
+		}
+		x, y, err := parseVertex(v, s.board.Size)
+		if err != nil {
+			return "", fmt.Errorf("engine: %w", err)
+		}
+		captured, err := s.board.Play(c, x, y)
+		if err != nil {
+			return "", fmt.Errorf("engine played an illegal move: %w", err)
+		}
+		m := newMove(c, int32(x), int32(y))
+		m.Captures = uint16(captured)
+		s.game.Moves = append(s.game.Moves, m)
+		return v, s.save(ctx)
+	case "showboard":
+		var sb strings.Builder
+		s.board.Print(&sb)
+		return "\n" + strings.TrimRight(sb.String(), "\n"), nil
+	default:
+		return "", fmt.Errorf("unknown command")
+	}
+	return "", nil
+}
+
+func (s *gtpServer) closeEngine() {
+	if s.engine != nil {
+		s.engine.Close()
+		s.engine = nil
+	}
+}
+
+// serve reads GTP commands from r until quit, writing responses to w.
+func (s *gtpServer) serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	defer s.closeEngine()
+	return serveGTP(r, w, func(cmd string, args []string) (string, error) { return s.handle(ctx, cmd, args) })
+}
+
+// serveGTP reads GTP commands from r until quit, writing the responses of handle to w.
+func serveGTP(r io.Reader, w io.Writer, handle func(cmd string, args []string) (string, error)) error {
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := sc.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		var id string
+		if _, err := strconv.Atoi(fields[0]); err == nil {
+			id, fields = fields[0], fields[1:]
+			if len(fields) == 0 {
+				continue
+			}
+		}
+		resp, err := handle(fields[0], fields[1:])
+		switch {
+		case err != nil:
+			fmt.Fprintf(w, "?%s %v\n\n", id, err)
+		case resp == "":
+			fmt.Fprintf(w, "=%s\n\n", id)
+		default:
+			fmt.Fprintf(w, "=%s %s\n\n", id, resp)
+		}
+		if fields[0] == "quit" {
+			return nil
+		}
+	}
+	return sc.Err()
+}
+
+func gtpServeCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("gtp-serve", flag.ContinueOnError)
+	avx := fs.String("a", "", "Champion Go archive")
+	slot := fs.String("g", "latest-online", `the edited game: an entry name, "latest" or "latest-online"`)
+	out := fs.String("o", "", "output archive, written after every move")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *out == "" {
+		return fmt.Errorf("usage: chamgo gtp-serve -a archive -o out [-g game]")
+	}
+	if jsonOutput {
+		return fmt.Errorf("gtp-serve speaks GTP on stdout and does not support -json")
+	}
+	if *out == *avx {
+		return fmt.Errorf("the output archive must differ from the input archive")
+	}
+
+	s := &gtpServer{out: *out}
+	if *avx != "" {
+		if err := s.load(ctx, *avx, *slot); err != nil {
+			return err
+		}
+	}
+	return s.serve(ctx, os.Stdin, os.Stdout)
+}
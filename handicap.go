@@ -0,0 +1,76 @@
+package main
+
+import "fmt"
+
+// compensationStones returns the number of handicap stones given for a difference of ranks between two players,
+// as in the usual handicap tables: none for equal ranks or one rank, where komi compensates instead,
+// one stone a rank above that, up to nine.
+func compensationStones(ranks int) int {
+	if ranks < 2 {
+		return 0
+	}
+	return min(ranks, 9)
+}
+
+// handicapPoints returns the points of n handicap stones on a board of the given size, in the usual order:
+// the corners from the upper right, then the center for odd numbers, then the sides.
+// Boards without a center point have at most four, and boards smaller than 7x7 none.
+func handicapPoints(size, n int) [][2]int {
+	edge := 4
+	switch {
+	case size < 7:
+		return nil
+	case size < 13:
+		edge = 3
+	}
+	lo, hi, mid := edge, size+1-edge, (size+1)/2
+	ps := [][2]int{{hi, lo}, {lo, hi}, {hi, hi}, {lo, lo}}
+	if size%2 == 0 {
+		return ps[:min(n, 4)]
+	}
+	switch {
+	case n <= 4:
+		return ps[:n]
+	case n == 5:
+		return append(ps, [2]int{mid, mid})
+	case n <= 7:
+		ps = append(ps, [2]int{lo, mid}, [2]int{hi, mid})
+	default:
+		ps = append(ps, [2]int{lo, mid}, [2]int{hi, mid}, [2]int{mid, lo}, [2]int{mid, hi})
+	}
+	if n%2 == 1 {
+		ps = append(ps, [2]int{mid, mid})
+	}
+	return ps
+}
+
+// addHandicap gives black the compensation stones for a difference of ranks, played after the moves of g
+// on the free handicap points with white passing in between, so that white moves next as in a handicap game.
+// It returns the number of stones added.
+func addHandicap(g *Game, ranks int, r Rules) (int, error) {
+	b, err := replayRules(g, r)
+	if err != nil {
+		return 0, err
+	}
+	added := 0
+	for _, p := range handicapPoints(int(g.Size), compensationStones(ranks)) {
+		if b.At(p[0], p[1]) != Empty {
+			continue
+		}
+		captured, err := b.Play(Black, p[0], p[1])
+		if err != nil {
+			continue
+		}
+		if n := len(g.Moves); n > 0 && g.Moves[n-1].Stone() == Black {
+			g.Moves = append(g.Moves, newMove(White, 0, 0))
+		}
+		m := newMove(Black, int32(p[0]), int32(p[1]))
+		m.Captures = uint16(captured)
+		g.Moves = append(g.Moves, m)
+		added++
+	}
+	if added == 0 && compensationStones(ranks) > 0 {
+		return 0, fmt.Errorf("no free handicap point")
+	}
+	return added, nil
+}
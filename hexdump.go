@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// recordField is a known field of a saved game record.
+type recordField struct {
+	Off    int
+	Len    int // 2 or 4 bytes
+	Name   string
+	Format func(v int32) string
+}
+
+func formatMoveTime(v int32) string { return fmt.Sprintf("%d (%s)", v, time.Duration(v)*time.Second) }
+
+func formatMode(v int32) string { return fmt.Sprintf("%d (%s)", v, Mode(v)) }
+
+func formatDate(v int32) string { return time.Unix(int64(v), 0).UTC().Format(time.RFC3339) }
+
+// headerFields returns the known fields of the header of a record in the layout l.
+func headerFields(l *layout) []recordField {
+	return []recordField{
+		{l.Mode, 4, "mode", formatMode},
+		{l.Size, 4, "size", nil},
+		{l.Color, 4, "color", nil},
+		{l.Level, 4, "level", nil},
+		{l.Started, 4, "started", formatDate},
+		{l.Saved, 4, "saved", formatDate},
+	}
+}
+
+// moveFields returns the known fields of a move record, relative to its start.
+func moveFields(l *layout) []recordField {
+	return []recordField{
+		{l.MoveColor, 4, "color", nil},
+		{l.MoveX, 4, "x", nil},
+		{l.MoveY, 4, "y", nil},
+		{l.MoveCaptures, 2, "captures", nil},
+		{l.MoveFlags, 2, "flags", nil},
+		{l.MoveTime, 4, "time", formatMoveTime},
+	}
+}
+
+// dumpRow is a line of a hexdump: a section header such as "move 1", a known field, or a run of unknown bytes.
+type dumpRow struct {
+	Offset  int    `json:"offset"`
+	Section string `json:"section,omitempty"`
+	Hex     string `json:"hex,omitempty"`
+	Field   string `json:"field,omitempty"`
+	Value   string `json:"value,omitempty"`
+}
+
+// hexdump returns the rows of a saved game record, naming the known fields and flagging the unknown ones.
+func hexdump(body []byte, l *layout) []dumpRow {
+	rows := dumpFields(body, 0, min(l.HeaderLen, len(body)), headerFields(l))
+	n := 1
+	for i := l.HeaderLen; i+l.MoveLen <= len(body); i += l.MoveLen {
+		rows = append(rows, dumpRow{Offset: i, Section: fmt.Sprintf("move %d", n)})
+		rows = append(rows, dumpFields(body, i, i+l.MoveLen, moveFields(l))...)
+		n++
+	}
+	if len(body) > l.HeaderLen {
+		if tail := l.HeaderLen + (len(body)-l.HeaderLen)/l.MoveLen*l.MoveLen; tail < len(body) {
+			rows = append(rows, dumpRow{Offset: tail, Section: "trailing bytes"})
+			rows = append(rows, dumpFields(body, tail, len(body), nil)...)
+		}
+	}
+	return rows
+}
+
+// dumpFields dumps body[start:end], where fields are at offsets relative to start.
+func dumpFields(body []byte, start, end int, fields []recordField) []dumpRow {
+	var rows []dumpRow
+	unknown := -1 // start of the pending unknown bytes
+	flush := func(i int) {
+		for unknown >= 0 && unknown < i {
+			j := min(unknown+16, i)
+			rows = append(rows, dumpRow{Offset: unknown, Hex: hexBytes(body[unknown:j])})
+			unknown = j
+		}
+		unknown = -1
+	}
+	for i := start; i < end; {
+		var field *recordField
+		for k := range fields {
+			if fields[k].Off == i-start && i+fields[k].Len <= end {
+				field = &fields[k]
+			}
+		}
+		if field == nil {
+			if unknown < 0 {
+				unknown = i
+			}
+			i++
+			continue
+		}
+		flush(i)
+		var v int32
+		if field.Len == 2 {
+			v = int32(binary.LittleEndian.Uint16(body[i:]))
+		} else {
+			v = int32(binary.LittleEndian.Uint32(body[i:]))
+		}
+		s := fmt.Sprint(v)
+		if field.Format != nil {
+			s = field.Format(v)
+		}
+		rows = append(rows, dumpRow{Offset: i, Hex: hexBytes(body[i : i+field.Len]), Field: field.Name, Value: s})
+		i += field.Len
+	}
+	flush(end)
+	return rows
+}
+
+// writeDump writes hexdump rows to w.
+func writeDump(w io.Writer, rows []dumpRow) {
+	for _, r := range rows {
+		switch {
+		case r.Section != "":
+			fmt.Fprintf(w, "%04x  -- %s\n", r.Offset, r.Section)
+		case r.Field == "":
+			fmt.Fprintf(w, "%04x  %-47s  ?? unknown\n", r.Offset, r.Hex)
+		default:
+			fmt.Fprintf(w, "%04x  %-47s  %s = %s\n", r.Offset, r.Hex, r.Field, r.Value)
+		}
+	}
+}
+
+func hexBytes(b []byte) string {
+	var s []string
+	for _, c := range b {
+		s = append(s, fmt.Sprintf("%02x", c))
+	}
+	return strings.Join(s, " ")
+}
+
+func hexdumpCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("hexdump", flag.ContinueOnError)
+	avx := fs.String("a", "", "Champion Go archive")
+	version := fs.String("version", "", "the app version that saved the game, detected from iTunesMetadata.plist by default")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	game := "latest"
+	if fs.NArg() > 0 {
+		game = fs.Arg(0)
+	}
+
+	name, body, err := readEntry(ctx, *avx, game)
+	if err != nil {
+		return err
+	}
+	if body == nil {
+		return errNoGames
+	}
+	if *version == "" {
+		*version = appVersion(ctx, *avx)
+	}
+	l := currentLayout
+	if *version != "" {
+		l = layoutFor(*version)
+	}
+	rows := hexdump(body, l)
+	output(map[string]interface{}{"entry": name, "bytes": len(body), "layout": l.Version, "rows": rows}, func(w io.Writer) {
+		fmt.Fprintf(w, "%s: %d bytes, layout of %s\n", name, len(body), l.Version)
+		writeDump(w, rows)
+	})
+	return nil
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// keepVersions is the number of previous versions of an output archive kept by -keep, or 0 to keep none.
+var keepVersions, _ = strconv.Atoi(os.Getenv("CHAMGO_KEEP"))
+
+// historyDir returns the directory holding the previous versions of the archive out.
+func historyDir(out string) string {
+	return strings.TrimRight(out, `/\`) + ".history"
+}
+
+const versionFormat = "20060102-150405.000"
+
+// saveVersion copies the archive out, if it exists, to its history before it is overwritten,
+// and removes the versions beyond the last keepVersions.
+func saveVersion(ctx context.Context, out string) error {
+	keep := settingsOf(ctx).keepVersions
+	if keep <= 0 {
+		return nil
+	}
+	if err := copyToHistory(ctx, out); err != nil {
+		return err
+	}
+	return pruneVersions(out, keep)
+}
+
+// copyToHistory copies the archive out, if it exists, to its history.
+func copyToHistory(ctx context.Context, out string) error {
+	if _, err := os.Stat(out); os.IsNotExist(err) {
+		return nil
+	}
+	if err := os.MkdirAll(historyDir(out), 0755); err != nil {
+		return err
+	}
+	version := filepath.Join(historyDir(out), settingsOf(ctx).clock().Format(versionFormat))
+	var err error
+	if isDir(out) {
+		err = writeDir(ctx, version+"/", out, avxEdit{NoJournal: true})
+	} else {
+		err = copyFile(version+filepath.Ext(out), out)
+	}
+	if err != nil {
+		return fmt.Errorf("saving the previous version of %s: %w", out, err)
+	}
+	return nil
+}
+
+// pruneVersions removes the versions of out beyond the last keep.
+func pruneVersions(out string, keep int) error {
+	versions, err := listVersions(out)
+	if err != nil {
+		return err
+	}
+	for _, v := range versions[min(keep, len(versions)):] {
+		if err := os.RemoveAll(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listVersions returns the previous versions of the archive out, newest first.
+func listVersions(out string) ([]string, error) {
+	fns, err := filepath.Glob(filepath.Join(historyDir(out), "*"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(fns)))
+	return fns, nil
+}
+
+func historyCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("history", flag.ContinueOnError)
+	avx := fs.String("a", "", "Champion Go archive written by chamgo")
+	restore := fs.String("restore", "", "restore this version, by number in the listing or by timestamp")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	versions, err := listVersions(*avx)
+	if err != nil {
+		return err
+	}
+	if *restore == "" {
+		type version struct {
+			N     int       `json:"n"`
+			Path  string    `json:"path"`
+			Saved time.Time `json:"saved"`
+		}
+		var vs []version
+		for i, v := range versions {
+			fi, err := os.Stat(v)
+			if err != nil {
+				return err
+			}
+			vs = append(vs, version{i + 1, v, fi.ModTime()})
+		}
+		output(vs, func(w io.Writer) {
+			for _, v := range vs {
+				fmt.Fprintf(w, "%2d) %s  %s\n", v.N, filepath.Base(v.Path), v.Saved.Format("2006-01-02 15:04:05"))
+			}
+		})
+		return nil
+	}
+
+	var chosen string
+	if n, err := strconv.Atoi(*restore); err == nil && n >= 1 && n <= len(versions) {
+		chosen = versions[n-1]
+	}
+	for _, v := range versions {
+		if chosen == "" && strings.HasPrefix(filepath.Base(v), *restore) {
+			chosen = v
+		}
+	}
+	if chosen == "" {
+		return fmt.Errorf("no version %s of %s, see chamgo history -a %s", *restore, *avx, *avx)
+	}
+	unlock, err := lockOutput(ctx, *avx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	// Keep the current version, so that the restore can be undone,
+	// and only prune the history once the chosen version is restored.
+	if keepVersions > 0 {
+		if err := copyToHistory(ctx, *avx); err != nil {
+			return err
+		}
+	}
+	note(event{"event": "restore", "archive": *avx, "version": chosen}, "restored %s from %s", *avx, chosen)
+	if isDir(chosen) {
+		if err := os.RemoveAll(*avx); err != nil {
+			return err
+		}
+		err = writeDir(ctx, *avx+"/", chosen, avxEdit{NoJournal: true})
+	} else {
+		err = copyFile(*avx, chosen)
+	}
+	if err != nil {
+		return err
+	}
+	return pruneVersions(*avx, keepVersions)
+}
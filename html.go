@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// viewerMove is a move as the HTML viewer plays it: the color, 0 for black and 1 for white, and the point numbered from 1,
+// or 0, 0 for a pass.
+type viewerMove [3]int32
+
+// viewerPage is the data of a self-contained HTML game viewer.
+type viewerPage struct {
+	Title string
+	Size  int32
+	Moves []viewerMove
+	SGF   string
+	Stars [][2]int
+}
+
+// viewerHTML is a game viewer without external scripts or styles, so that the page can be sent as a single file.
+// The moves are replayed in the page, capturing stones, and the SGF can be copied or downloaded.
+const viewerHTML = `<!DOCTYPE html>
+<html><head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body{font-family:sans-serif;max-width:40em;margin:auto;padding:1em}
+#board{width:100%;max-width:36em;display:block;margin:auto}
+#controls{text-align:center;margin:.5em}
+#controls button{font-size:1.2em;min-width:3em}
+textarea{width:100%;height:6em;font-family:monospace}
+</style></head><body>
+<h1>{{.Title}}</h1>
+<svg id="board" xmlns="http://www.w3.org/2000/svg"></svg>
+<div id="controls"><button id="first">|&lt;</button> <button id="prev10">&lt;&lt;</button> <button id="prev">&lt;</button>
+<span id="status"></span>
+<button id="next">&gt;</button> <button id="next10">&gt;&gt;</button> <button id="last">&gt;|</button></div>
+<p><input id="slider" type="range" min="0" value="0" style="width:100%"></p>
+<h2>SGF</h2>
+<textarea id="sgf" readonly>{{.SGF}}</textarea>
+<p><button id="download">Download SGF</button></p>
+<script>
+const size = {{.Size}}, moves = {{.Moves}}, title = {{.Title}};
+const cell = 20, svg = document.getElementById("board"), ns = "http://www.w3.org/2000/svg";
+const columns = "ABCDEFGHJKLMNOPQRSTUVWXYZ";
+svg.setAttribute("viewBox", "0 0 " + (size + 1) * cell + " " + (size + 1) * cell);
+function el(name, attrs) {
+  const e = document.createElementNS(ns, name);
+  for (const k in attrs) e.setAttribute(k, attrs[k]);
+  svg.appendChild(e);
+}
+// position replays the first n moves, returning the points, row major from the top left, and the prisoners.
+function position(n) {
+  const pts = new Array(size * size).fill(-1), prisoners = [0, 0];
+  const neighbors = p => {
+    const x = p % size, y = Math.floor(p / size), ns = [];
+    if (x > 0) ns.push(p - 1);
+    if (x < size - 1) ns.push(p + 1);
+    if (y > 0) ns.push(p - size);
+    if (y < size - 1) ns.push(p + size);
+    return ns;
+  };
+  const group = p => {
+    const seen = new Set([p]), stack = [p];
+    let libs = 0;
+    while (stack.length) {
+      for (const q of neighbors(stack.pop())) {
+        if (seen.has(q)) continue;
+        if (pts[q] === -1) { seen.add(q); libs++; }
+        else if (pts[q] === pts[p]) { seen.add(q); stack.push(q); }
+      }
+    }
+    return [[...seen].filter(q => pts[q] === pts[p]), libs];
+  };
+  for (const [c, x, y] of moves.slice(0, n)) {
+    if (x === 0 && y === 0) continue;
+    const p = (y - 1) * size + x - 1;
+    pts[p] = c;
+    for (const q of neighbors(p)) {
+      if (pts[q] !== 1 - c) continue;
+      const [stones, libs] = group(q);
+      if (libs === 0) { stones.forEach(s => pts[s] = -1); prisoners[c] += stones.length; }
+    }
+    const [stones, libs] = group(p);
+    if (libs === 0) { stones.forEach(s => pts[s] = -1); prisoners[1 - c] += stones.length; }
+  }
+  return [pts, prisoners];
+}
+let n = moves.length;
+function draw() {
+  svg.innerHTML = "";
+  const at = i => i * cell, total = (size + 1) * cell;
+  el("rect", {width: total, height: total, fill: "#dcb35c"});
+  for (let i = 1; i <= size; i++) {
+    el("line", {x1: at(1), y1: at(i), x2: at(size), y2: at(i), stroke: "#000"});
+    el("line", {x1: at(i), y1: at(1), x2: at(i), y2: at(size), stroke: "#000"});
+  }
+  {{range .Stars}}el("circle", {cx: at({{index . 0}}), cy: at({{index . 1}}), r: 2.5});
+  {{end}}const [pts, prisoners] = position(n);
+  pts.forEach((c, p) => {
+    if (c < 0) return;
+    const x = p % size + 1, y = Math.floor(p / size) + 1;
+    el("circle", {cx: at(x), cy: at(y), r: cell / 2 - 1, fill: c ? "#fff" : "#000", stroke: "#000"});
+  });
+  let last = "";
+  if (n > 0) {
+    const [c, x, y] = moves[n - 1], color = c ? "White" : "Black";
+    if (x === 0 && y === 0) {
+      last = ", " + color + " passes";
+    } else {
+      last = ", " + color + " " + columns[x - 1] + (size - y + 1);
+      el("circle", {cx: at(x), cy: at(y), r: cell / 4, fill: "none", stroke: "#d00", "stroke-width": 2});
+    }
+  }
+  document.getElementById("status").textContent = "move " + n + " of " + moves.length + last + ", prisoners B " + prisoners[0] + " W " + prisoners[1];
+  document.getElementById("slider").value = n;
+}
+function go(m) { n = Math.max(0, Math.min(m, moves.length)); draw(); }
+const slider = document.getElementById("slider");
+slider.max = moves.length;
+slider.oninput = () => go(+slider.value);
+for (const [id, f] of [["first", () => 0], ["prev10", () => n - 10], ["prev", () => n - 1], ["next", () => n + 1], ["next10", () => n + 10], ["last", () => moves.length]]) {
+  document.getElementById(id).onclick = () => go(f());
+}
+document.onkeydown = e => {
+  const f = {ArrowLeft: () => n - 1, ArrowRight: () => n + 1, ArrowUp: () => n - 10, ArrowDown: () => n + 10, Home: () => 0, End: () => moves.length}[e.key];
+  if (f && e.target.tagName !== "TEXTAREA") { e.preventDefault(); go(f()); }
+};
+document.getElementById("download").onclick = () => {
+  const a = document.createElement("a");
+  a.href = URL.createObjectURL(new Blob([document.getElementById("sgf").value], {type: "application/x-go-sgf"}));
+  a.download = title.replace(/[^\w.-]+/g, "_").replace(/\.sgf$/, "") + ".sgf";
+  a.click();
+};
+draw();
+</script>
+</body></html>
+`
+
+var viewerTemplate = template.Must(template.New("viewer").Parse(viewerHTML))
+
+// writeViewer writes the self-contained HTML viewer of g, with the SGF sgf inlined.
+func writeViewer(w io.Writer, title string, g *Game, sgf string) error {
+	var moves []viewerMove
+	for _, m := range g.Moves {
+		if !m.IsPass() && !g.onBoard(m) {
+			continue // resignations
+		}
+		moves = append(moves, viewerMove{m.Color, m.X, m.Y})
+	}
+	return viewerTemplate.Execute(w, viewerPage{Title: title, Size: g.Size, Moves: moves, SGF: sgf, Stars: starPoints(int(g.Size))})
+}
+
+func htmlCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("html", flag.ContinueOnError)
+	avx := fs.String("a", "", "Champion Go archive")
+	slot := fs.String("g", "latest", `the game: an entry name, "latest" or "latest-online"`)
+	out := fs.String("o", "", "the HTML file, by default stdout")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		return fmt.Errorf("usage: chamgo html [-o game.html] [-a archive [-g game] | game.sgf]")
+	}
+	name, g, err := loadGame(ctx, *avx, *slot, fs.Args())
+	if err != nil {
+		return err
+	}
+	sgf, err := sourceSGF(g, fs.Args())
+	if err != nil {
+		return err
+	}
+	title := strings.TrimSuffix(filepath.Base(name), filepath.Ext(name))
+
+	var b strings.Builder
+	if err := writeViewer(&b, title, g, sgf); err != nil {
+		return err
+	}
+	if *out == "" || *out == "-" {
+		output(map[string]string{"html": b.String()}, func(w io.Writer) { io.WriteString(w, b.String()) })
+		return nil
+	}
+	if err := writeFileAtomic(*out, []byte(b.String())); err != nil {
+		return err
+	}
+	output(map[string]string{"file": *out}, func(w io.Writer) { fmt.Fprintf(w, "wrote %s\n", *out) })
+	return nil
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// igsAddr is the IGS (Pandanet) server, which chamgo logs into as a guest.
+// IGS keeps no archive of finished games outside of its own clients, so only the games still on the server,
+// being played or just finished, can be fetched; the SGF files saved by GoPanda are injected with -sgf.
+var igsAddr = envOr("CHAMGO_IGS_ADDR", "igs.joyjoy.net:7777")
+
+// igsConn is a guest session of the IGS protocol in client mode, where every line starts with a message code
+// and every reply ends with a prompt line, code 1.
+type igsConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+var igsPromptRe = regexp.MustCompile(`(^|> )1 \d+\s*$`)
+
+func dialIGS(ctx context.Context) (*igsConn, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", igsAddr)
+	if err != nil {
+		return nil, err
+	}
+	c := &igsConn{conn: conn, r: bufio.NewReader(conn)}
+	conn.SetDeadline(time.Now().Add(30 * time.Second))
+	// The login prompt has no newline.
+	var seen strings.Builder
+	for !strings.HasSuffix(strings.TrimSpace(seen.String()), "Login:") {
+		b, err := c.r.ReadByte()
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("IGS: no login prompt: %w", err)
+		}
+		seen.WriteByte(b)
+	}
+	if _, err := c.command("guest\ntoggle client true"); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// command sends the command line s and returns the lines of its reply, without the prompt.
+func (c *igsConn) command(s string) ([]string, error) {
+	c.conn.SetDeadline(time.Now().Add(30 * time.Second))
+	if _, err := io.WriteString(c.conn, s+"\n"); err != nil {
+		return nil, err
+	}
+	var lines []string
+	for {
+		line, err := c.r.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("IGS: %s: %w", strings.Fields(s)[0], err)
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if igsPromptRe.MatchString(line) {
+			return lines, nil
+		}
+		if strings.HasPrefix(line, "5 ") {
+			return nil, fmt.Errorf("IGS: %s", strings.TrimPrefix(line, "5 "))
+		}
+		lines = append(lines, line)
+	}
+}
+
+func (c *igsConn) Close() error {
+	io.WriteString(c.conn, "exit\n")
+	return c.conn.Close()
+}
+
+// igsGame is a game on the IGS server, from its line in the games list.
+type igsGame struct {
+	Number int     `json:"number"`
+	White  string  `json:"white"`
+	WRank  string  `json:"white_rank"`
+	Black  string  `json:"black"`
+	BRank  string  `json:"black_rank"`
+	Moves  int     `json:"moves"`
+	Size   int     `json:"size"`
+	Komi   float64 `json:"komi"`
+}
+
+// igsGameRe matches the lines of the games command, such as
+// "7 [ 5]       rob [ 2d*] vs.       joe [ 1k*] (123   19  0  6.5 10  I) (  3)".
+var igsGameRe = regexp.MustCompile(`^7 \[\s*(\d+)\]\s+(\S+) \[\s*([^\]]*)\] vs\.\s+(\S+) \[\s*([^\]]*)\] \(\s*(\d+)\s+(\d+)\s+\d+\s+([-\d.]+)`)
+
+func parseIGSGames(lines []string) []igsGame {
+	var games []igsGame
+	for _, l := range lines {
+		m := igsGameRe.FindStringSubmatch(l)
+		if m == nil {
+			continue
+		}
+		g := igsGame{White: m[2], WRank: strings.TrimSpace(m[3]), Black: m[4], BRank: strings.TrimSpace(m[5])}
+		g.Number, _ = strconv.Atoi(m[1])
+		g.Moves, _ = strconv.Atoi(m[6])
+		g.Size, _ = strconv.Atoi(m[7])
+		g.Komi, _ = strconv.ParseFloat(m[8], 64)
+		games = append(games, g)
+	}
+	return games
+}
+
+// igsMoveRe matches the moves of the moves command, such as "15   0(B): Handicap 3" and "15  12(W): Q16".
+var igsMoveRe = regexp.MustCompile(`^15\s+(\d+)\((B|W)\): (.*)$`)
+
+// igsMoves returns the moves of the reply to the moves command of a game on a board of size,
+// with handicap stones played as setup stones are.
+func igsMoves(lines []string, size int) ([]Move, error) {
+	var moves []Move
+	for _, l := range lines {
+		m := igsMoveRe.FindStringSubmatch(l)
+		if m == nil {
+			continue
+		}
+		s := sgfColor(m[2])
+		v := strings.Fields(m[3])
+		switch {
+		case len(v) == 0:
+			continue
+		case v[0] == "Handicap" && len(v) == 2:
+			n, err := strconv.Atoi(v[1])
+			if err != nil {
+				return nil, fmt.Errorf("IGS: bad handicap %q", m[3])
+			}
+			pos := &Position{Size: size, Board: make([]Stone, size*size), ToMove: White}
+			for _, p := range handicapPoints(size, n) {
+				pos.Board[(p[1]-1)*size+p[0]-1] = Black
+			}
+			if moves, err = pos.moves(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		x, y, err := parseVertex(v[0], size)
+		if err != nil {
+			return nil, fmt.Errorf("IGS: move %s: %w", m[1], err)
+		}
+		moves = append(moves, newMove(s, int32(x), int32(y)))
+	}
+	return moves, nil
+}
+
+func igsCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("igs", flag.ContinueOnError)
+	avx := fs.String("a", "", "Champion Go archive")
+	list := fs.Bool("list", false, "list the games on the server instead of fetching one")
+	moves := fs.Int("moves", 0, "keep this many moves, or 0 for the whole game")
+	slot := fs.String("slot", "latest-online", "the online game replaced by the IGS game")
+	p := fs.String("p", "b", "the color of the human player")
+	level := fs.Int("l", 10, "computer level")
+	sgfOut := fs.Bool("sgf", false, "print the SGF of the game instead of injecting it")
+	out := fs.String("o", "-", "output archive")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if *list == (fs.NArg() == 1) || fs.NArg() > 1 || *moves < 0 {
+		return fmt.Errorf("usage: chamgo igs [flags] -list | game-number")
+	}
+	if *level < 1 || *level > 10 {
+		return fmt.Errorf("level must be between 1 and 10")
+	}
+	c, err := dialIGS(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if *list {
+		lines, err := c.command("games")
+		if err != nil {
+			return err
+		}
+		games := parseIGSGames(lines)
+		output(games, func(w io.Writer) {
+			for _, g := range games {
+				fmt.Fprintf(w, "%4d  %s [%s] (W) - %s [%s] (B)  %dx%d, %d moves\n", g.Number, g.White, g.WRank, g.Black, g.BRank, g.Size, g.Size, g.Moves)
+			}
+		})
+		return nil
+	}
+
+	number, err := strconv.Atoi(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("%q is not a game number", fs.Arg(0))
+	}
+	lines, err := c.command("games " + strconv.Itoa(number))
+	if err != nil {
+		return err
+	}
+	found := parseIGSGames(lines)
+	if len(found) != 1 {
+		return &codedError{exitNoGames, fmt.Errorf("no game %d on IGS", number)}
+	}
+	if lines, err = c.command("moves " + strconv.Itoa(number)); err != nil {
+		return err
+	}
+	ms, err := igsMoves(lines, found[0].Size)
+	if err != nil {
+		return err
+	}
+	g := &Game{Size: int32(found[0].Size), Moves: ms}
+	if *moves > 0 {
+		g.Moves = g.Moves[:min(*moves, len(g.Moves))]
+	}
+	if *sgfOut {
+		output(map[string]string{"sgf": writeSGF(g)}, func(w io.Writer) { io.WriteString(w, writeSGF(g)) })
+		return nil
+	}
+
+	name, base, err := readEntry(ctx, *avx, *slot)
+	if err != nil {
+		return err
+	}
+	if base == nil {
+		return errNoGames
+	}
+	bg, err := Decode(base)
+	if err != nil {
+		return err
+	}
+	bg.Size, bg.Moves = g.Size, g.Moves
+	body := bg.Encode()
+	note(event{"event": "igs", "game": number, "entry": name, "moves": len(g.Moves)},
+		"replaced %s with %d moves of IGS game %d, %s (W) - %s (B)", name, len(g.Moves), number, found[0].White, found[0].Black)
+	if err := setPlayer(body, *p); err != nil {
+		return err
+	}
+	setLevel(body, byte(*level))
+	touchDates(body)
+	return writeAvxFile(ctx, *out, *avx, avxEdit{Replace: map[string][]byte{name: body}})
+}
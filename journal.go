@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// journalRecord records the entries an edit changed in an output archive, so that chamgo undo can restore them.
+// Entries are keyed by their names in the output archive.
+type journalRecord struct {
+	Time     time.Time         `json:"time"`
+	Source   string            `json:"source"`             // the archive the edit was applied to
+	Original map[string][]byte `json:"original,omitempty"` // previous contents of replaced entries
+	Renamed  map[string]string `json:"renamed,omitempty"`  // previous names of renamed entries
+	Added    []string          `json:"added,omitempty"`    // entries the edit added
+}
+
+// journalPath returns the journal kept next to the archive out.
+func journalPath(out string) string {
+	return strings.TrimRight(out, `/\`) + ".journal"
+}
+
+// newJournalRecord reads the entries of avxName that the edit e changes. Deleted entries are not recorded;
+// edits whose originals must not be kept, such as scrub's, are NoJournal.
+func newJournalRecord(ctx context.Context, avxName string, e avxEdit) (*journalRecord, error) {
+	rec := &journalRecord{Time: time.Now(), Source: avxName, Original: make(map[string][]byte), Renamed: make(map[string]string), Added: sortedKeys(e.Add)}
+	if len(e.Replace) == 0 && len(e.Rename) == 0 {
+		return rec, nil
+	}
+	a, err := openArchive(ctx, avxName)
+	if err != nil {
+		return nil, err
+	}
+	defer a.Close()
+	for _, f := range a.Files {
+		if e.Delete[f.Name] {
+			continue
+		}
+		name := f.Name
+		if n, ok := e.Rename[f.Name]; ok {
+			name = n
+			rec.Renamed[n] = f.Name
+		}
+		if _, ok := e.Replace[f.Name]; ok {
+			b, err := f.ReadAll()
+			if err != nil {
+				return nil, err
+			}
+			rec.Original[name] = b
+		}
+	}
+	return rec, nil
+}
+
+// journalFor is newJournalRecord, or an empty record if e is not journaled.
+func journalFor(ctx context.Context, avxName string, e avxEdit) (*journalRecord, error) {
+	if e.NoJournal {
+		return &journalRecord{}, nil
+	}
+	return newJournalRecord(ctx, avxName, e)
+}
+
+// appendJournal adds rec to the journal of the archive out, just written from avxName.
+// If avxName is another archive, the journal is started anew, since its records describe entries that are gone.
+func appendJournal(out, avxName string, rec *journalRecord) error {
+	empty := len(rec.Original) == 0 && len(rec.Renamed) == 0 && len(rec.Added) == 0
+	if !sameFile(out, avxName) {
+		if empty {
+			return writeJournal(out, nil)
+		}
+		return writeJournal(out, []*journalRecord{rec})
+	}
+	if empty {
+		return nil
+	}
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(journalPath(out), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// readJournal returns the records of the journal of the archive avxName, oldest first.
+func readJournal(avxName string) ([]*journalRecord, error) {
+	b, err := ioutil.ReadFile(journalPath(avxName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var recs []*journalRecord
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	sc.Buffer(nil, 1<<30)
+	for n := 1; sc.Scan(); n++ {
+		if len(bytes.TrimSpace(sc.Bytes())) == 0 {
+			continue
+		}
+		rec := &journalRecord{}
+		if err := json.Unmarshal(sc.Bytes(), rec); err != nil {
+			return nil, invalid(fmt.Errorf("%s:%d: %w", journalPath(avxName), n, err))
+		}
+		recs = append(recs, rec)
+	}
+	return recs, sc.Err()
+}
+
+// writeJournal replaces the journal of the archive avxName with recs.
+func writeJournal(avxName string, recs []*journalRecord) error {
+	if len(recs) == 0 {
+		err := os.Remove(journalPath(avxName))
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	var b bytes.Buffer
+	for _, rec := range recs {
+		line, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+		b.Write(append(line, '\n'))
+	}
+	tmp := journalPath(avxName) + ".tmp"
+	if err := ioutil.WriteFile(tmp, b.Bytes(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, journalPath(avxName))
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func undoCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("undo", flag.ContinueOnError)
+	avx := fs.String("a", "", "Champion Go archive written by chamgo, restored in place")
+	out := fs.String("o", "", "write the restored archive here instead of restoring in place")
+	list := fs.Bool("l", false, "list the journal instead of undoing")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	recs, err := readJournal(*avx)
+	if err != nil {
+		return err
+	}
+	if *list {
+		type entry struct {
+			Time    time.Time `json:"time"`
+			Source  string    `json:"source"`
+			Entries []string  `json:"entries"`
+		}
+		var entries []entry
+		for _, rec := range recs {
+			names := sortedKeys(rec.Original)
+			for _, to := range sortedKeys(rec.Renamed) {
+				names = append(names, rec.Renamed[to]+" -> "+to)
+			}
+			for _, name := range rec.Added {
+				names = append(names, "+"+name)
+			}
+			entries = append(entries, entry{rec.Time, rec.Source, names})
+		}
+		output(entries, func(w io.Writer) {
+			for i := len(entries) - 1; i >= 0; i-- {
+				fmt.Fprintf(w, "%s from %s: %s\n", entries[i].Time.Format("2006-01-02 15:04:05"), filepath.Base(entries[i].Source), strings.Join(entries[i].Entries, ", "))
+			}
+		})
+		return nil
+	}
+	if len(recs) == 0 {
+		return fmt.Errorf("nothing to undo, %s does not exist", journalPath(*avx))
+	}
+
+	rec := recs[len(recs)-1]
+	e := avxEdit{Replace: rec.Original, Rename: rec.Renamed, Delete: make(map[string]bool), NoJournal: true}
+	for _, name := range rec.Added {
+		e.Delete[name] = true
+		note(event{"event": "remove", "entry": name}, "removed %s", name)
+	}
+	for _, name := range sortedKeys(rec.Original) {
+		note(event{"event": "restore", "entry": name}, "restored %s from %s", name, rec.Time.Format("2006-01-02 15:04:05"))
+	}
+	for _, to := range sortedKeys(rec.Renamed) {
+		note(event{"event": "rename", "from": to, "to": rec.Renamed[to]}, "%s -> %s", to, rec.Renamed[to])
+	}
+	if *out != "" {
+		return writeAvxFile(ctx, *out, *avx, e)
+	}
+	if err := writeAvxInPlace(ctx, *avx, e); err != nil {
+		return err
+	}
+	return writeJournal(*avx, recs[:len(recs)-1])
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAppendJournal(t *testing.T) {
+	edit := &journalRecord{Source: "edit", Original: map[string][]byte{"a": []byte("old")}}
+	tests := []struct {
+		name    string
+		inPlace bool
+		rec     *journalRecord
+		want    []string // the sources of the records of the journal
+	}{
+		{"in place", true, edit, []string{"earlier", "edit"}},
+		{"in place, nothing changed", true, &journalRecord{}, []string{"earlier"}},
+		{"from another archive", false, edit, []string{"edit"}},
+		{"from another archive, nothing changed", false, &journalRecord{}, nil},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		out, other := filepath.Join(dir, "out.zip"), filepath.Join(dir, "other.zip")
+		for _, fn := range []string{out, other} {
+			if err := ioutil.WriteFile(fn, []byte(fn), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := writeJournal(out, []*journalRecord{{Source: "earlier", Added: []string{"b"}}}); err != nil {
+			t.Fatal(err)
+		}
+		src := other
+		if tt.inPlace {
+			src = out
+		}
+		if err := appendJournal(out, src, tt.rec); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		recs, err := readJournal(out)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		var got []string
+		for _, rec := range recs {
+			got = append(got, rec.Source)
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%s: journal of %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestUndo edits an archive in place twice and undoes the edits one at a time.
+func TestUndo(t *testing.T) {
+	avx := synthArchive(t, synthOptions{Version: currentLayout.Version, Local: 3, Online: 1, Size: 9, Moves: 10, Seed: 6})
+	const dir = "Container/Documents/game/"
+	edits := []avxEdit{
+		{Replace: map[string][]byte{dir + "0.game": []byte("replaced")}, Add: map[string][]byte{dir + "9.game": []byte("added")}},
+		{Replace: map[string][]byte{dir + "0.game": []byte("replaced again")}, Rename: map[string]string{dir + "1.game": dir + "8.game"}},
+	}
+	ctx := context.Background()
+	var states []map[string][]byte
+	for _, e := range edits {
+		b, err := ioutil.ReadFile(avx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		states = append(states, zipEntries(t, b))
+		if err := writeAvxInPlace(ctx, avx, e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := len(states) - 1; i >= 0; i-- {
+		if err := undoCmd(ctx, []string{"-a", avx}); err != nil {
+			t.Fatalf("undo %d: %v", i, err)
+		}
+		b, err := ioutil.ReadFile(avx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := zipEntries(t, b); !reflect.DeepEqual(got, states[i]) {
+			t.Errorf("undo %d: entries %v, want %v", i, sortedKeys(got), sortedKeys(states[i]))
+		}
+	}
+	if err := undoCmd(ctx, []string{"-a", avx}); err == nil {
+		t.Error("no error undoing with an empty journal")
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+)
+
+// jsonOutput is set by a leading -json flag.
+// Commands then print a single jsonReport on stdout instead of their text output and stderr messages.
+var jsonOutput bool
+
+// jsonReport is the output of a command in JSON mode.
+type jsonReport struct {
+	Command string      `json:"command"`
+	OK      bool        `json:"ok"`
+	Error   string      `json:"error,omitempty"`
+	Code    int         `json:"code"` // the exit code
+	Notes   []event     `json:"notes,omitempty"`
+	Result  interface{} `json:"result,omitempty"`
+}
+
+var report jsonReport
+
+// event is a structured progress message, such as a renamed or scrubbed entry.
+// Its "event" key names the kind of message.
+type event map[string]interface{}
+
+// note prints a progress message to stderr, or records ev in JSON mode.
+func note(ev event, format string, args ...interface{}) {
+	flagSettings().note(ev, format, args...)
+}
+
+// output prints the result of a command with text, or records v as the result in JSON mode.
+func output(v interface{}, text func(w io.Writer)) {
+	if jsonOutput {
+		report.Result = v
+		return
+	}
+	text(os.Stdout)
+}
+
+// printReport prints the report of a command in JSON mode, with err if it failed.
+func printReport(err error) {
+	report.OK = err == nil
+	if err != nil {
+		report.Error = err.Error()
+	}
+	report.Code = exitCode(err)
+	b, merr := json.MarshalIndent(report, "", "  ")
+	if merr != nil {
+		b, _ = json.Marshal(jsonReport{Command: report.Command, Error: merr.Error()})
+	}
+	os.Stdout.Write(append(b, '\n'))
+}
+
+// plistJSON converts a property list value to one that marshals to plain JSON.
+func plistJSON(v interface{}) interface{} {
+	switch v := v.(type) {
+	case *plistDict:
+		m := make(map[string]interface{})
+		for _, k := range v.Keys {
+			m[k] = plistJSON(v.Values[k])
+		}
+		return m
+	case []interface{}:
+		a := make([]interface{}, len(v))
+		for i, e := range v {
+			a[i] = plistJSON(e)
+		}
+		return a
+	case plistUID:
+		return map[string]interface{}{"uid": uint64(v)}
+	}
+	return v
+}
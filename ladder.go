@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// parseLevels parses a range of computer levels such as 1-10 or 3-6.
+func parseLevels(s string) (from, to int, err error) {
+	a, b, isRange := strings.Cut(s, "-")
+	if from, err = strconv.Atoi(a); err == nil {
+		to = from
+		if isRange {
+			to, err = strconv.Atoi(b)
+		}
+	}
+	if err != nil || from < 1 || to > 10 || from > to {
+		return 0, 0, fmt.Errorf("levels must be a range between 1 and 10, such as 1-10, got %q", s)
+	}
+	return from, to, nil
+}
+
+// ladderCmd adds a game against each computer level from the same position, to find the level matching a player.
+func ladderCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("ladder", flag.ContinueOnError)
+	avx := fs.String("a", "", "Champion Go archive")
+	out := fs.String("o", "-", "output archive")
+	levels := fs.String("levels", "1-10", "the range of computer levels")
+	human := fs.String("p", "b", "the color of the human player")
+	chain := fs.String("t", "", "comma separated transforms applied to the game")
+	sgf := fs.String("sgf", "", "start from the main line of an SGF file instead of the latest game")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	from, to, err := parseLevels(*levels)
+	if err != nil {
+		return err
+	}
+	if *human != "b" && *human != "w" {
+		return fmt.Errorf("-p must be b or w")
+	}
+
+	// The games are added as new local games in computer mode, since the app lists the local games by traversing
+	// their directory, but online games through the Game Center, which would not know of added ones.
+	// They are numbered after the existing local games, and based on the latest of them.
+	local, err := listGames(ctx, *avx, false)
+	if err != nil {
+		return err
+	}
+	_, body, err := latestGame(local)
+	if err != nil {
+		return err
+	}
+	if body == nil {
+		return errNoGames
+	}
+	if *sgf != "" {
+		b, err := ioutil.ReadFile(*sgf)
+		if err != nil {
+			return err
+		}
+		if body, err = sgfGame(ctx, string(b), body); err != nil {
+			return err
+		}
+	}
+	if body, err = applyTransforms(ctx, body, *chain); err != nil {
+		return err
+	}
+
+	next := nextGameNumber(local)
+	ext := path.Ext(local[len(local)-1].Name)
+	e := avxEdit{Add: make(map[string][]byte)}
+	for level := from; level <= to; level++ {
+		b := append([]byte(nil), body...)
+		if err := setPlayer(b, *human); err != nil {
+			return err
+		}
+		setLevel(b, byte(level))
+		touchDates(b)
+		setMode(b, ModeComputer)
+		name := gameDir(false) + strconv.Itoa(next) + ext
+		next++
+		e.Add[name] = b
+		note(event{"event": "add", "level": level, "entry": name}, "level %d: %s", level, name)
+	}
+	return writeAvxFile(ctx, *out, *avx, e)
+}
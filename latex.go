@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// figureStone is a numbered move of a figure.
+type figureStone struct {
+	X, Y  int
+	Color Stone
+	Num   int
+}
+
+// figure is a kifu diagram of the moves From to To of a game: the position before move From,
+// with the moves numbered on it. Moves on points already numbered in the figure or captured and played again
+// are listed in At, as "12 at 5" or "12 at d4", below the diagram as in printed game records.
+type figure struct {
+	From, To int
+	Board    *Board
+	Stones   []figureStone
+	At       []string
+}
+
+// makeFigure returns the figure of the moves from to to of g, numbered from 1.
+func makeFigure(g *Game, from, to int) (*figure, error) {
+	from, to = max(1, from), min(to, len(g.Moves))
+	b, err := replay(&Game{Size: g.Size, Moves: g.Moves[:from-1]})
+	if err != nil {
+		return nil, err
+	}
+	f := &figure{From: from, To: to, Board: b}
+	numbered := make(map[[2]int]int)
+	for i := from; i <= to; i++ {
+		m := g.Moves[i-1]
+		if m.IsPass() || !g.onBoard(m) {
+			continue
+		}
+		p := [2]int{int(m.X), int(m.Y)}
+		switch n, ok := numbered[p]; {
+		case ok:
+			f.At = append(f.At, fmt.Sprintf("%d at %d", i, n))
+		case b.At(p[0], p[1]) != Empty:
+			f.At = append(f.At, fmt.Sprintf("%d at %s", i, strings.ToLower(gtpVertex(p[0], p[1], b.Size))))
+		default:
+			numbered[p] = i
+			f.Stones = append(f.Stones, figureStone{X: p[0], Y: p[1], Color: m.Stone(), Num: i})
+		}
+	}
+	return f, nil
+}
+
+// parseRanges parses comma separated move ranges such as "1-50,51-100,120", where a single number is the position
+// after that move, without numbered moves.
+func parseRanges(s string, total int) ([][2]int, error) {
+	var rs [][2]int
+	for _, r := range strings.Split(s, ",") {
+		a, b, isRange := strings.Cut(strings.TrimSpace(r), "-")
+		from, err := strconv.Atoi(a)
+		if err != nil {
+			return nil, fmt.Errorf("invalid move range %q", r)
+		}
+		to := from
+		if isRange {
+			if to, err = strconv.Atoi(b); err != nil || to < from {
+				return nil, fmt.Errorf("invalid move range %q", r)
+			}
+		} else {
+			from = to + 1 // no numbered moves
+		}
+		if from < 1 || from > total+1 {
+			return nil, fmt.Errorf("move range %q is outside the %d moves of the game", r, total)
+		}
+		rs = append(rs, [2]int{from, to})
+	}
+	return rs, nil
+}
+
+// latexVertex returns the coordinates of a point as the igo package writes them, such as d4.
+func latexVertex(x, y, size int) string { return strings.ToLower(gtpVertex(x, y, size)) }
+
+// writeIgo writes f as a diagram of the igo LaTeX package.
+func writeIgo(w io.Writer, f *figure) {
+	n := f.Board.Size
+	fmt.Fprintf(w, "\\gobansize{%d}\n\\cleargoban\n", n)
+	for _, s := range []Stone{Black, White} {
+		var vs []string
+		for y := 1; y <= n; y++ {
+			for x := 1; x <= n; x++ {
+				if f.Board.At(x, y) == s {
+					vs = append(vs, latexVertex(x, y, n))
+				}
+			}
+		}
+		if len(vs) > 0 {
+			fmt.Fprintf(w, "\\%s{%s}\n", colorName(s), strings.Join(vs, ","))
+		}
+	}
+	for _, s := range f.Stones {
+		fmt.Fprintf(w, "\\%s[%d]{%s}\n", colorName(s.Color), s.Num, latexVertex(s.X, s.Y, n))
+	}
+	fmt.Fprint(w, "\\showfullgoban\n")
+}
+
+// writePsgo writes f as a psgoboard environment of the psgo LaTeX package.
+func writePsgo(w io.Writer, f *figure) {
+	n := f.Board.Size
+	stone := func(c Stone, x, y int, opt string) {
+		v := latexVertex(x, y, n)
+		fmt.Fprintf(w, "\\stone%s{%s}{%s}{%s}\n", opt, colorName(c), v[:1], v[1:])
+	}
+	fmt.Fprintf(w, "\\begin{psgoboard}[%d]\n", n)
+	for y := 1; y <= n; y++ {
+		for x := 1; x <= n; x++ {
+			if c := f.Board.At(x, y); c != Empty {
+				stone(c, x, y, "")
+			}
+		}
+	}
+	for _, s := range f.Stones {
+		stone(s.Color, s.X, s.Y, fmt.Sprintf("[\\marklb{%d}]", s.Num))
+	}
+	fmt.Fprint(w, "\\end{psgoboard}\n")
+}
+
+// writeLatex writes the figures of the ranges of g as a LaTeX fragment for package pkg, igo or psgo,
+// each diagram in a center environment with its caption.
+func writeLatex(w io.Writer, g *Game, ranges [][2]int, pkg string) error {
+	for i, r := range ranges {
+		f, err := makeFigure(g, r[0], r[1])
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprint(w, "\\begin{center}\n")
+		if pkg == "psgo" {
+			writePsgo(w, f)
+		} else {
+			writeIgo(w, f)
+		}
+		caption := fmt.Sprintf("After move %d", f.To)
+		if f.From <= f.To {
+			caption = fmt.Sprintf("Moves %d--%d", f.From, f.To)
+		}
+		if len(f.At) > 0 {
+			caption += ". " + strings.Join(f.At, ", ")
+		}
+		fmt.Fprintf(w, "\\\\ %s.\n\\end{center}\n", caption)
+	}
+	return nil
+}
+
+func latexCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("latex", flag.ContinueOnError)
+	avx := fs.String("a", "", "Champion Go archive")
+	slot := fs.String("g", "latest", `the game: an entry name, "latest" or "latest-online"`)
+	moves := fs.String("moves", "", "comma separated move ranges, such as 1-50,51-100, or moves after which to show the position, by default the whole game")
+	pkg := fs.String("package", "igo", "the LaTeX package of the diagrams: igo or psgo")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		return fmt.Errorf("usage: chamgo latex [-package igo|psgo] [-moves ranges] [-a archive [-g game] | game.sgf]")
+	}
+	if *pkg != "igo" && *pkg != "psgo" {
+		return fmt.Errorf("unknown package %q, want igo or psgo", *pkg)
+	}
+	_, g, err := loadGame(ctx, *avx, *slot, fs.Args())
+	if err != nil {
+		return err
+	}
+	ranges := [][2]int{{1, len(g.Moves)}}
+	if *moves != "" {
+		if ranges, err = parseRanges(*moves, len(g.Moves)); err != nil {
+			return invalid(err)
+		}
+	}
+
+	var sb strings.Builder
+	if err := writeLatex(&sb, g, ranges, *pkg); err != nil {
+		return err
+	}
+	output(sb.String(), func(w io.Writer) { io.WriteString(w, sb.String()) })
+	return nil
+}
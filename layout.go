@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// layout is the position of the known fields of a saved game record in a version of the app.
+// Offsets of move fields are relative to the start of each move.
+type layout struct {
+	Version string // the first app version using this layout
+
+	HeaderLen, MoveLen int
+
+	Mode, Size, Color, Level, Started, Saved int
+
+	MoveColor, MoveX, MoveY, MoveTime int // 32 bit fields
+	MoveCaptures, MoveFlags           int // 16 bit fields
+}
+
+// layouts are the known record layouts, oldest first.
+// Only the layout of 1.1.3 has been reverse engineered so far; older layouts are added here as they are found,
+// and with a second one, a command converting games between them.
+//
+// No field pointing at the current move separately from the move count has been found.
+// Such a pointer would have to be within the unknown header bytes 0-3, 20-55 or 64-75 shown by chamgo hexdump;
+// until it is found, opening a game at an earlier move means removing the later moves with chamgo takeback.
+var layouts = []*layout{
+	{
+		Version:      "1.1.3",
+		HeaderLen:    headerLen,
+		MoveLen:      moveLen,
+		Mode:         4,
+		Size:         8,
+		Color:        12,
+		Level:        16,
+		Started:      56,
+		Saved:        60,
+		MoveColor:    0,
+		MoveX:        4,
+		MoveY:        8,
+		MoveCaptures: 12,
+		MoveFlags:    14,
+		MoveTime:     16,
+	},
+}
+
+var currentLayout = layouts[len(layouts)-1]
+
+// compareVersions compares dotted version numbers such as 1.1.3.
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// layoutFor returns the layout used by an app version.
+// Versions older than the oldest known layout use that layout.
+func layoutFor(version string) *layout {
+	l := layouts[0]
+	for _, ll := range layouts {
+		if compareVersions(ll.Version, version) <= 0 {
+			l = ll
+		}
+	}
+	return l
+}
+
+var bundleVersionRe = regexp.MustCompile(`<key>bundleShortVersionString</key>\s*<string>([^<]*)</string>`)
+
+// appVersion returns the app version recorded in the iTunesMetadata.plist of an archive, or "" if it is unknown.
+func appVersion(ctx context.Context, avxName string) string {
+	b, err := readArchiveFile(ctx, avxName, "iTunesMetadata.plist")
+	if err != nil {
+		return ""
+	}
+	m := bundleVersionRe.FindSubmatch(b)
+	if m == nil {
+		return ""
+	}
+	return string(m[1])
+}
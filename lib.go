@@ -0,0 +1,128 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// The functions of this file work on records and zip archives in memory, for the WebAssembly, C and mobile builds
+// of the codec in wasm, cshared and mobile.
+
+// libGame is a game record of an archive in memory.
+type libGame struct {
+	Name   string `json:"name"`
+	Online bool   `json:"online"`
+	Game   *Game  `json:"game"`
+}
+
+// libGames returns the local and online games of the zip archive b, skipping the records that cannot be decoded.
+func libGames(b []byte) ([]libGame, error) {
+	r, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		return nil, err
+	}
+	gs := []libGame{}
+	for _, f := range r.File {
+		name := normalizeEntryName(f.Name)
+		online := inDir(name, gameDir(true))
+		if f.FileInfo().IsDir() || strings.HasSuffix(name, "/") || !online && !inDir(name, gameDir(false)) {
+			continue
+		}
+		body, err := readZipFile(f)
+		if err != nil {
+			return nil, err
+		}
+		g, err := Decode(body)
+		if err != nil {
+			continue
+		}
+		gs = append(gs, libGame{Name: name, Online: online, Game: g})
+	}
+	return gs, nil
+}
+
+// libRecord returns the entry name of the zip archive b.
+func libRecord(b []byte, name string) ([]byte, error) {
+	r, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range r.File {
+		if normalizeEntryName(f.Name) == name {
+			return readZipFile(f)
+		}
+	}
+	return nil, fmt.Errorf("%s is not in the archive", name)
+}
+
+// libReplace returns the zip archive b with the entries of replace, copying the others as they are.
+func libReplace(b []byte, replace map[string][]byte) ([]byte, error) {
+	r, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	zw := zip.NewWriter(&out)
+	for _, f := range r.File {
+		body, ok := replace[normalizeEntryName(f.Name)]
+		if !ok {
+			if err := zw.Copy(f); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: f.Name, Method: zip.Deflate, Modified: f.Modified})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(body); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// libEncode returns the record of the game in JSON, keeping the unknown bytes of the record base, as for scripts.
+func libEncode(game, base []byte) ([]byte, error) {
+	g, err := Decode(base)
+	if err != nil {
+		return nil, err
+	}
+	g.Moves = nil
+	if err := json.Unmarshal(game, g); err != nil {
+		return nil, invalid(err)
+	}
+	return g.Encode(), nil
+}
+
+// libToComputer returns a copy of record as a game of player, "b" or "w", against level 10, dated now,
+// as chamgo makes the games it injects.
+func libToComputer(record []byte, player string) ([]byte, error) {
+	if _, err := Decode(record); err != nil {
+		return nil, err
+	}
+	if player != "b" && player != "w" {
+		return nil, fmt.Errorf("player must be b or w, not %q", player)
+	}
+	b := append([]byte(nil), record...)
+	if err := flipToComputer(flagSettings(), b, player); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func readZipFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// lockTimeout is how long a write waits for another chamgo writing the same output.
+const lockTimeout = 10 * time.Second
+
+// lockPath returns the lock file of the archive out.
+func lockPath(out string) string {
+	return strings.TrimRight(out, `/\`) + ".lock"
+}
+
+// lockOutput takes an advisory lock on the archive out, so that two chamgo runs cannot interleave their writes,
+// and returns the function releasing it.
+// The lock is a file holding the process ID of its owner, created exclusively next to out;
+// locks left by processes that are no longer running are removed.
+func lockOutput(ctx context.Context, out string) (func(), error) {
+	name := lockPath(out)
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		f, err := os.OpenFile(name, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			fmt.Fprintf(f, "%d\n", os.Getpid())
+			f.Close()
+			return func() { os.Remove(name) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		pid := lockOwner(name)
+		if pid > 0 && !processRunning(pid) {
+			os.Remove(name)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%s is being written by process %d, remove %s if it is no longer running", out, pid, name)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+func lockOwner(name string) int {
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		return 0
+	}
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(b)))
+	return pid
+}
+
+// processRunning reports whether the process pid exists.
+// Where this cannot be checked, such as on Windows, processes are assumed to be running.
+func processRunning(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	err = p.Signal(syscall.Signal(0))
+	return err == nil || !errors.Is(err, os.ErrProcessDone) && !errors.Is(err, syscall.ESRCH)
+}
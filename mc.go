@@ -0,0 +1,631 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// mcEngine is chamgo's built-in engine, for when no other engine is installed. It plays random games to the end,
+// without filling the eyes of either side, and takes the average of their area scores as its estimate:
+// the best move is the one winning the most of its playouts, and stones are dead when the opponent owns
+// their point at the end of most playouts. It is weak, but good enough for rough scores and sanity hints.
+type mcEngine struct {
+	komi     float64
+	playouts int // a move, or for an estimate
+	game     *Game
+	board    *Board
+	seed     int64
+}
+
+var mcCommands = []string{
+	"protocol_version", "name", "version", "known_command", "list_commands", "quit",
+	"boardsize", "clear_board", "komi", "play", "genmove", "reg_genmove", "undo",
+	"final_score", "final_status_list", "showboard",
+}
+
+func newMCEngine(size, playouts int) (*mcEngine, error) {
+	if err := checkSize(int32(size)); err != nil {
+		return nil, err
+	}
+	return &mcEngine{komi: engineKomi[rules.Name], playouts: playouts, game: &Game{Size: int32(size)}, board: NewBoard(size), seed: time.Now().UnixNano()}, nil
+}
+
+// toMove returns the side to move, the opponent of the last move.
+func (e *mcEngine) toMove() Stone {
+	if n := len(e.game.Moves); n > 0 {
+		return e.game.Moves[n-1].Stone().Opponent()
+	}
+	return Black
+}
+
+// mcBoard is the board of a playout, with simple ko and without suicide, faster than Board as it keeps
+// no history and reuses its buffers.
+type mcBoard struct {
+	*Board
+	nbrs  [][]int // the neighbors of each point
+	mark  []int32 // the generation in which each point was last visited
+	gen   int32
+	stack []int
+}
+
+func newMCBoard(b *Board) *mcBoard {
+	pb := &Board{Size: b.Size, Points: append([]Stone(nil), b.Points...), ko: b.ko}
+	nbrs := make([][]int, len(b.Points))
+	for p := range nbrs {
+		nbrs[p] = b.neighbors(p)
+	}
+	return &mcBoard{Board: pb, nbrs: nbrs, mark: make([]int32, len(b.Points))}
+}
+
+// visit calls f for each stone of the group at p until f returns false, and reports whether it went through them all.
+func (b *mcBoard) visit(p int, f func(q int) bool) bool {
+	b.gen++
+	b.mark[p] = b.gen
+	b.stack = append(b.stack[:0], p)
+	for len(b.stack) > 0 {
+		q := b.stack[len(b.stack)-1]
+		b.stack = b.stack[:len(b.stack)-1]
+		if !f(q) {
+			return false
+		}
+		for _, n := range b.nbrs[q] {
+			if b.Points[n] == b.Points[p] && b.mark[n] != b.gen {
+				b.mark[n] = b.gen
+				b.stack = append(b.stack, n)
+			}
+		}
+	}
+	return true
+}
+
+// hasLiberty reports whether the group at p has a liberty.
+func (b *mcBoard) hasLiberty(p int) bool {
+	return !b.visit(p, func(q int) bool {
+		for _, n := range b.nbrs[q] {
+			if b.Points[n] == Empty {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// play plays s at p, and reports whether the move was legal.
+func (b *mcBoard) play(s Stone, p int) bool {
+	if b.Points[p] != Empty || p == b.ko {
+		return false
+	}
+	b.Points[p] = s
+	captured, last := 0, -1
+	for _, n := range b.nbrs[p] {
+		if b.Points[n] != s.Opponent() || b.hasLiberty(n) {
+			continue
+		}
+		var stones []int
+		b.visit(n, func(q int) bool { stones = append(stones, q); return true })
+		for _, q := range stones {
+			b.Points[q] = Empty
+		}
+		captured, last = captured+len(stones), n
+	}
+	if !b.hasLiberty(p) {
+		b.Points[p] = Empty
+		return false
+	}
+	b.ko = -1
+	if captured == 1 {
+		alone, libs := true, 0
+		for _, n := range b.nbrs[p] {
+			switch b.Points[n] {
+			case s:
+				alone = false
+			case Empty:
+				libs++
+			}
+		}
+		if alone && libs == 1 {
+			b.ko = last
+		}
+	}
+	return true
+}
+
+// mcEye reports whether p is an eye of s on b: an empty point surrounded by s,
+// with at most one diagonal point of the opponent, or none on the edge.
+func mcEye(b *Board, s Stone, p int) bool {
+	if b.Points[p] != Empty {
+		return false
+	}
+	for _, n := range b.neighbors(p) {
+		if b.Points[n] != s {
+			return false
+		}
+	}
+	x, y := p%b.Size, p/b.Size
+	bad, edge := 0, false
+	for _, d := range [][2]int{{-1, -1}, {1, -1}, {-1, 1}, {1, 1}} {
+		dx, dy := x+d[0], y+d[1]
+		if dx < 0 || dy < 0 || dx >= b.Size || dy >= b.Size {
+			edge = true
+			continue
+		}
+		if b.Points[dy*b.Size+dx] == s.Opponent() {
+			bad++
+		}
+	}
+	return bad == 0 || bad == 1 && !edge
+}
+
+// selfAtari reports whether s playing at p on b is illegal, or leaves its group with one liberty without capturing.
+func selfAtari(b *Board, s Stone, p int) bool {
+	pb := newMCBoard(b)
+	if !pb.play(s, p) {
+		return true
+	}
+	for q, c := range pb.Points {
+		if c == Empty && b.Points[q] == s.Opponent() {
+			return false // it captured
+		}
+	}
+	libs := make(map[int]bool)
+	pb.visit(p, func(q int) bool {
+		for _, n := range pb.nbrs[q] {
+			if pb.Points[n] == Empty {
+				libs[n] = true
+			}
+		}
+		return len(libs) < 2
+	})
+	return len(libs) < 2
+}
+
+// sekiPoints returns the empty points of b that are shared liberties of black and white groups in seki:
+// points next to both colors that either side filling would put its own group in atari,
+// next to groups whose liberties are all such points or eyes of their own, with fewer than two eyes.
+// Playouts leave them empty, as players would, so that groups in seki live and their shared liberties stay neutral.
+func sekiPoints(b *Board) []bool {
+	shared := make([]bool, len(b.Points))
+	for p, s := range b.Points {
+		if s != Empty {
+			continue
+		}
+		var black, white bool
+		for _, n := range b.neighbors(p) {
+			black = black || b.Points[n] == Black
+			white = white || b.Points[n] == White
+		}
+		shared[p] = black && white && selfAtari(b, Black, p) && selfAtari(b, White, p)
+	}
+	seki := make([]bool, len(b.Points))
+	for _, g := range sekiGroups(b, shared) {
+		for _, p := range g {
+			for _, n := range b.neighbors(p) {
+				if shared[n] {
+					seki[n] = true
+				}
+			}
+		}
+	}
+	return seki
+}
+
+// sekiGroups returns the stones of the groups of b in seki, given the shared liberties that neither side can fill.
+func sekiGroups(b *Board, shared []bool) [][]int {
+	var groups [][]int
+	done := make([]bool, len(b.Points))
+	for p, s := range b.Points {
+		if s == Empty || done[p] {
+			continue
+		}
+		stones, _ := b.group(p)
+		libs := make(map[int]bool)
+		for _, q := range stones {
+			done[q] = true
+			for _, n := range b.neighbors(q) {
+				if b.Points[n] == Empty {
+					libs[n] = true
+				}
+			}
+		}
+		// A liberty that is neither shared nor an eye lets the group still make or lose eyes.
+		sharedLibs, eyes, free := 0, 0, false
+		for l := range libs {
+			switch {
+			case shared[l]:
+				sharedLibs++
+			case mcEye(b, s, l):
+				eyes++
+			default:
+				free = true
+			}
+		}
+		if sharedLibs > 0 && eyes < 2 && !free {
+			groups = append(groups, stones)
+		}
+	}
+	return groups
+}
+
+// mcScore returns the area score of b, black minus white without komi, and the owner of each point:
+// the color of its stone, or of all the neighbors of an empty point.
+func mcScore(b *Board) (float64, []Stone) {
+	owner := make([]Stone, len(b.Points))
+	score := 0.0
+	for p, s := range b.Points {
+		if s == Empty {
+			for i, n := range b.neighbors(p) {
+				if i > 0 && b.Points[n] != s {
+					s = Empty
+					break
+				}
+				s = b.Points[n]
+			}
+		}
+		owner[p] = s
+		switch s {
+		case Black:
+			score++
+		case White:
+			score--
+		}
+	}
+	return score, owner
+}
+
+// playout plays random moves on a copy of b from the side s until both sides pass, leaving the points in seki empty,
+// and returns the area score of the end and the owner of each point.
+func playout(b *Board, s Stone, seki []bool, rng *rand.Rand) (float64, []Stone) {
+	pb := newMCBoard(b)
+	empty := make([]int, 0, len(pb.Points))
+	passes := 0
+	for moves := 0; passes < 2 && moves < 3*len(pb.Points); moves++ {
+		empty = empty[:0]
+		for p, c := range pb.Points {
+			if c == Empty {
+				empty = append(empty, p)
+			}
+		}
+		played := false
+		for i, start := 0, rng.Intn(len(empty)+1); i < len(empty); i++ {
+			if p := empty[(start+i)%len(empty)]; !seki[p] && !mcEye(pb.Board, s, p) && pb.play(s, p) {
+				played = true
+				break
+			}
+		}
+		if played {
+			passes = 0
+		} else {
+			pb.ko = -1
+			passes++
+		}
+		s = s.Opponent()
+	}
+	return mcScore(pb.Board)
+}
+
+// parallel runs n playouts of the boards given by start for each of their indexes, spread over the CPUs,
+// and calls done with the index and the outcome of each, under a lock. The points in seki of the current position
+// are left empty.
+func (e *mcEngine) parallel(starts []*Board, s Stone, n int, done func(i int, score float64, owner []Stone)) {
+	seki := sekiPoints(e.board)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	jobs := make(chan int)
+	for w := 0; w < runtime.NumCPU(); w++ {
+		wg.Add(1)
+		rng := rand.New(rand.NewSource(e.seed + int64(w)))
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				score, owner := playout(starts[i], s, seki, rng)
+				mu.Lock()
+				done(i, score, owner)
+				mu.Unlock()
+			}
+		}()
+	}
+	for k := 0; k < n; k++ {
+		jobs <- k % len(starts)
+	}
+	close(jobs)
+	wg.Wait()
+	e.seed += int64(runtime.NumCPU())
+}
+
+// estimate returns the mean score of the playouts of the current position, black minus white with komi,
+// and the ownership of each point, from -1 if white owns it in every playout to 1 if black does.
+func (e *mcEngine) estimate() (float64, []float64) {
+	own := make([]float64, len(e.board.Points))
+	total := 0.0
+	e.parallel([]*Board{e.board}, e.toMove(), e.playouts, func(_ int, score float64, owner []Stone) {
+		total += score - e.komi
+		for p, s := range owner {
+			switch s {
+			case Black:
+				own[p]++
+			case White:
+				own[p]--
+			}
+		}
+	})
+	for p := range own {
+		own[p] /= float64(e.playouts)
+	}
+	return total / float64(e.playouts), own
+}
+
+// mcGroup is a group of stones with its status at the end of the game.
+type mcGroup struct {
+	Color     string   `json:"color"`
+	Stones    []string `json:"stones"`
+	Liberties int      `json:"liberties"`
+	Status    string   `json:"status"`    // alive, dead or seki
+	Ownership float64  `json:"ownership"` // the mean ownership of its stones, from -1 if the opponent owns them to 1
+}
+
+// groups returns the groups of the current position with their status: seki for the groups in seki,
+// and otherwise dead when the opponent owns their stones in most playouts and alive when not.
+func (e *mcEngine) groups() []mcGroup {
+	_, own := e.estimate()
+	b := e.board
+	inSeki := make(map[int]bool)
+	for _, g := range sekiGroups(b, sekiPoints(b)) {
+		for _, p := range g {
+			inSeki[p] = true
+		}
+	}
+	var gs []mcGroup
+	done := make([]bool, len(b.Points))
+	for p, s := range b.Points {
+		if s == Empty || done[p] {
+			continue
+		}
+		stones, libs := b.group(p)
+		sort.Ints(stones)
+		g := mcGroup{Color: colorName(s), Liberties: libs, Status: "alive"}
+		for _, q := range stones {
+			done[q] = true
+			g.Stones = append(g.Stones, gtpVertex(q%b.Size+1, q/b.Size+1, b.Size))
+			g.Ownership += own[q]
+		}
+		g.Ownership /= float64(len(stones))
+		if s == White {
+			g.Ownership = -g.Ownership
+		}
+		switch {
+		case inSeki[p]:
+			g.Status = "seki"
+		case g.Ownership < 0:
+			g.Status = "dead"
+		}
+		gs = append(gs, g)
+	}
+	return gs
+}
+
+// genmove returns the move of s winning the most playouts, or a pass if s has only eyes left to fill.
+func (e *mcEngine) genmove(s Stone) (int, int) {
+	var moves [][2]int
+	var starts []*Board
+	for p := range e.board.Points {
+		if mcEye(e.board, s, p) {
+			continue
+		}
+		b := e.board.clone()
+		x, y := p%b.Size+1, p/b.Size+1
+		if _, err := b.Play(s, x, y); err == nil {
+			moves = append(moves, [2]int{x, y})
+			starts = append(starts, b)
+		}
+	}
+	if len(moves) == 0 {
+		return 0, 0
+	}
+	wins := make([]float64, len(moves))
+	scores := make([]float64, len(moves))
+	e.parallel(starts, s.Opponent(), max(e.playouts, len(moves)), func(i int, score float64, _ []Stone) {
+		score -= e.komi
+		if s == White {
+			score = -score
+		}
+		scores[i] += score
+		if score > 0 {
+			wins[i]++
+		}
+	})
+	best := 0
+	for i := range moves {
+		if wins[i] > wins[best] || wins[i] == wins[best] && scores[i] > scores[best] {
+			best = i
+		}
+	}
+	return moves[best][0], moves[best][1]
+}
+
+// play plays s at x, y, or passes at 0, 0.
+func (e *mcEngine) play(s Stone, x, y int) error {
+	captured, err := e.board.Play(s, x, y)
+	if err != nil {
+		return fmt.Errorf("illegal move: %w", err)
+	}
+	m := newMove(s, int32(x), int32(y))
+	m.Captures = uint16(captured)
+	e.game.Moves = append(e.game.Moves, m)
+	return nil
+}
+
+// handle executes a GTP command and returns its response.
+func (e *mcEngine) handle(cmd string, args []string) (string, error) {
+	switch cmd {
+	case "protocol_version":
+		return "2", nil
+	case "name":
+		return "chamgo-mc", nil
+	case "version":
+		return "1", nil
+	case "known_command":
+		for _, c := range mcCommands {
+			if len(args) == 1 && c == args[0] {
+				return "true", nil
+			}
+		}
+		return "false", nil
+	case "list_commands":
+		cs := append([]string(nil), mcCommands...)
+		sort.Strings(cs)
+		return strings.Join(cs, "\n"), nil
+	case "quit":
+		return "", nil
+	case "boardsize":
+		if len(args) != 1 {
+			return "", fmt.Errorf("boardsize needs a size")
+		}
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 2 || n > len(columns) {
+			return "", fmt.Errorf("unacceptable size")
+		}
+		e.game, e.board = &Game{Size: int32(n)}, NewBoard(n)
+	case "clear_board":
+		e.game.Moves, e.board = nil, NewBoard(int(e.game.Size))
+	case "komi":
+		if len(args) != 1 {
+			return "", fmt.Errorf("komi needs a value")
+		}
+		k, err := strconv.ParseFloat(args[0], 64)
+		if err != nil {
+			return "", fmt.Errorf("syntax error")
+		}
+		e.komi = k
+	case "play":
+		if len(args) != 2 {
+			return "", fmt.Errorf("play needs a color and a vertex")
+		}
+		c, err := parseGTPColor(args[0])
+		if err != nil {
+			return "", err
+		}
+		x, y, err := parseVertex(args[1], e.board.Size)
+		if err != nil {
+			return "", err
+		}
+		return "", e.play(c, x, y)
+	case "genmove", "reg_genmove":
+		if len(args) != 1 {
+			return "", fmt.Errorf("%s needs a color", cmd)
+		}
+		c, err := parseGTPColor(args[0])
+		if err != nil {
+			return "", err
+		}
+		x, y := e.genmove(c)
+		if cmd == "genmove" {
+			if err := e.play(c, x, y); err != nil {
+				return "", err
+			}
+		}
+		return gtpVertex(x, y, e.board.Size), nil
+	case "undo":
+		if len(e.game.Moves) == 0 {
+			return "", fmt.Errorf("cannot undo")
+		}
+		e.game.Moves = e.game.Moves[:len(e.game.Moves)-1]
+		b, err := replay(e.game)
+		if err != nil {
+			return "", err
+		}
+		e.board = b
+	case "final_score":
+		score, _ := e.estimate()
+		switch {
+		case score > 0:
+			return fmt.Sprintf("B+%.1f", score), nil
+		case score < 0:
+			return fmt.Sprintf("W+%.1f", -score), nil
+		}
+		return "0", nil
+	case "final_status_list":
+		if len(args) != 1 || args[0] != "dead" && args[0] != "alive" && args[0] != "seki" {
+			return "", fmt.Errorf("final_status_list needs dead, alive or seki")
+		}
+		var vs []string
+		for _, g := range e.groups() {
+			if g.Status == args[0] {
+				vs = append(vs, g.Stones...)
+			}
+		}
+		return strings.Join(vs, " "), nil
+	case "showboard":
+		var sb strings.Builder
+		e.board.Print(&sb)
+		return "\n" + strings.TrimRight(sb.String(), "\n"), nil
+	default:
+		return "", fmt.Errorf("unknown command")
+	}
+	return "", nil
+}
+
+// mcEngineCmd runs the built-in engine as a GTP engine on stdin and stdout, as the mc engine profile launches it.
+func mcEngineCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("mc-engine", flag.ContinueOnError)
+	playouts := fs.Int("playouts", 1000, "the playouts of each move and estimate")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *playouts < 1 {
+		return fmt.Errorf("-playouts must be positive")
+	}
+	e, err := newMCEngine(19, *playouts)
+	if err != nil {
+		return err
+	}
+	return serveGTP(os.Stdin, os.Stdout, e.handle)
+}
+
+// statusCmd prints the groups of the end of a game with their status as estimated by the built-in engine.
+func statusCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("status", flag.ContinueOnError)
+	avx := fs.String("a", "", "Champion Go archive")
+	slot := fs.String("g", "latest", `the game: an entry name, "latest" or "latest-online"`)
+	playouts := fs.Int("playouts", 1000, "the playouts of the estimate")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 || *playouts < 1 {
+		return fmt.Errorf("usage: chamgo status [-playouts n] [-a archive [-g game] | game.sgf]")
+	}
+	name, g, err := loadGame(ctx, *avx, *slot, fs.Args())
+	if err != nil {
+		return err
+	}
+	e, err := newMCEngine(int(g.Size), *playouts)
+	if err != nil {
+		return err
+	}
+	for i, m := range g.Moves {
+		if !m.IsPass() && !g.onBoard(m) {
+			continue
+		}
+		if err := e.play(m.Stone(), int(m.X), int(m.Y)); err != nil {
+			return invalid(fmt.Errorf("move %d: %w", i+1, err))
+		}
+	}
+	score, _ := e.estimate()
+	gs := e.groups()
+	output(map[string]interface{}{"game": name, "score": score, "groups": gs}, func(w io.Writer) {
+		fmt.Fprintf(w, "%s, %d moves, estimated score %+.1f for black with komi %.1f\n", name, len(g.Moves), score, e.komi)
+		for _, g := range gs {
+			fmt.Fprintf(w, "%-5s %-5s %3d stones, %d liberties, ownership %+.2f: %s\n", g.Status, g.Color, len(g.Stones), g.Liberties, g.Ownership, strings.Join(g.Stones, " "))
+		}
+	})
+	return nil
+}
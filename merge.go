@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// movesHash identifies a game by its board size and moves, so that the same game saved in two backups
+// is recognized even if its dates, settings or move times differ.
+// Records that cannot be decoded are identified by their bytes.
+func movesHash(body []byte) string {
+	g, err := Decode(body)
+	if err != nil {
+		h := sha256.New()
+		h.Write([]byte("raw"))
+		h.Write(body)
+		return hex.EncodeToString(h.Sum(nil))
+	}
+	return g.movesHash()
+}
+
+// movesHash returns the movesHash of g, such as of a game read from an SGF file.
+func (g *Game) movesHash() string {
+	h := sha256.New()
+	binary.Write(h, binary.LittleEndian, g.Size)
+	for _, m := range g.Moves {
+		binary.Write(h, binary.LittleEndian, [3]int32{m.Color, m.X, m.Y})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// nextGameNumber returns the number following the largest numbered game name of games.
+func nextGameNumber(games []gameEntry) int {
+	next := 0
+	for _, g := range games {
+		base := path.Base(g.Name)
+		if n, err := strconv.Atoi(strings.TrimSuffix(base, path.Ext(base))); err == nil && n >= next {
+			next = n + 1
+		}
+	}
+	return next
+}
+
+func mergeCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("merge", flag.ContinueOnError)
+	out := fs.String("o", "-", "output archive")
+	dryRun := fs.Bool("n", false, "only print the games that would be added")
+	// Archives and flags may be mixed, as in chamgo merge old.imazingapp new.imazingapp -o combined.imazingapp.
+	var archives []string
+	for rest := args; ; rest = fs.Args()[1:] {
+		if err := parseFlags(fs, rest); err != nil {
+			return err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		archives = append(archives, fs.Arg(0))
+	}
+	if len(archives) < 2 {
+		return fmt.Errorf("usage: chamgo merge [-o combined] old-archive... new-archive")
+	}
+
+	// The last archive is the newest backup, whose settings and other files are kept.
+	base := archives[len(archives)-1]
+	games, err := listGames(ctx, base, false)
+	if err != nil {
+		return err
+	}
+	seen := make(map[string]string)
+	for _, g := range games {
+		seen[movesHash(g.Body)] = g.Name
+	}
+	next := nextGameNumber(games)
+	e := avxEdit{Add: make(map[string][]byte)}
+	for _, avx := range archives[:len(archives)-1] {
+		old, err := listGames(ctx, avx, false)
+		if err != nil {
+			return err
+		}
+		for _, g := range old {
+			h := movesHash(g.Body)
+			if dup, ok := seen[h]; ok {
+				note(event{"event": "duplicate", "archive": avx, "entry": g.Name, "same_as": dup}, "%s: %s is %s", avx, g.Name, dup)
+				continue
+			}
+			name := gameDir(false) + strconv.Itoa(next) + path.Ext(g.Name)
+			next++
+			seen[h] = name
+			e.Add[name] = g.Body
+			note(event{"event": "add", "archive": avx, "entry": g.Name, "to": name}, "%s: %s -> %s", avx, g.Name, name)
+		}
+	}
+	note(event{"event": "summary", "games": len(games) + len(e.Add), "added": len(e.Add)},
+		"%d games, %d added to the %d of %s", len(games)+len(e.Add), len(e.Add), len(games), base)
+	if *dryRun {
+		return nil
+	}
+	return writeAvxFile(ctx, *out, base, e)
+}
@@ -0,0 +1,22 @@
+#!/bin/sh
+# Builds the record codec of chamgo with gomobile: ./build.sh android writes chamgo.aar,
+# and ./build.sh ios Chamgo.xcframework. gomobile must be installed and initialized, see golang.org/x/mobile.
+set -e
+cd "$(dirname "$0")"
+case "$1" in
+android) out=$PWD/chamgo.aar ;;
+ios) out=$PWD/Chamgo.xcframework ;;
+*) echo "usage: $0 android|ios" >&2; exit 2 ;;
+esac
+tmp=$(mktemp -d)
+trap 'rm -rf "$tmp"' EXIT
+mkdir "$tmp/chamgo"
+for f in ../*.go; do
+	sed 's/^package main$/package chamgo/' "$f" > "$tmp/chamgo/$(basename "$f")"
+done
+sed '/^\/\/go:build ignore$/d' chamgo_mobile.go > "$tmp/chamgo/chamgo_mobile.go"
+cp ../demo.imazingapp "$tmp/chamgo"
+cd "$tmp"
+go mod init chamgo >/dev/null 2>&1
+go get golang.org/x/mobile/bind
+gomobile bind -target="$1" -o "$out" ./chamgo
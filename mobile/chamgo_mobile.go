@@ -0,0 +1,71 @@
+//go:build ignore
+
+package chamgo
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// This file is built by build.sh with the sources of chamgo, copied into a package chamgo as gomobile binds
+// packages rather than commands, without the ignore build constraint that keeps go build ./... from building
+// this directory alone. Its functions only use the types gomobile supports, with games as JSON.
+
+// DecodeJSON returns the game of a record as JSON.
+func DecodeJSON(record []byte) (string, error) {
+	g, err := Decode(record)
+	if err != nil {
+		return "", err
+	}
+	b, err := json.Marshal(g)
+	return string(b), err
+}
+
+// EncodeJSON returns the record of a game in JSON, keeping the unknown bytes of the record base.
+func EncodeJSON(game string, base []byte) ([]byte, error) {
+	return libEncode([]byte(game), base)
+}
+
+// ApplyTransforms applies a chain of transforms such as "flip180,truncate=30" to a record.
+func ApplyTransforms(record []byte, chain string) ([]byte, error) {
+	return applyTransforms(context.Background(), record, chain)
+}
+
+// ToSGF returns the SGF of a record.
+func ToSGF(record []byte) (string, error) {
+	g, err := Decode(record)
+	if err != nil {
+		return "", err
+	}
+	return writeSGF(g), nil
+}
+
+// FromSGF returns the record base with the main line of an SGF game.
+func FromSGF(sgf string, base []byte) ([]byte, error) {
+	return sgfGame(context.Background(), sgf, base)
+}
+
+// ToComputer returns the record as a game of player, "b" or "w", against level 10, dated now.
+func ToComputer(record []byte, player string) ([]byte, error) {
+	return libToComputer(record, player)
+}
+
+// ArchiveGames returns the local and online games of a zip archive as JSON: a list of name, online and game.
+func ArchiveGames(archive []byte) (string, error) {
+	gs, err := libGames(archive)
+	if err != nil {
+		return "", err
+	}
+	b, err := json.Marshal(gs)
+	return string(b), err
+}
+
+// ArchiveRecord returns the record of the entry name of a zip archive.
+func ArchiveRecord(archive []byte, name string) ([]byte, error) {
+	return libRecord(archive, name)
+}
+
+// ReplaceRecord returns the zip archive with the entry name replaced by record.
+func ReplaceRecord(archive []byte, name string, record []byte) ([]byte, error) {
+	return libReplace(archive, map[string][]byte{name: record})
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ogsURL is the OGS server, which may be set to https://beta.online-go.com for trying things out.
+var ogsURL = envOr("CHAMGO_OGS_URL", "https://online-go.com")
+
+// envOr returns the environment variable key, or def if it is not set.
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// ogsUpload uploads sgf to the SGF library of the OGS account of token, which OGS opens as a demo board,
+// and returns the URL of the board.
+func ogsUpload(ctx context.Context, token, name, sgf string) (string, error) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile("file", name)
+	if err != nil {
+		return "", err
+	}
+	io.WriteString(fw, sgf)
+	if err := mw.Close(); err != nil {
+		return "", err
+	}
+	// Collection 0 is the top of the library.
+	req, err := http.NewRequestWithContext(ctx, "POST", ogsURL+"/api/v1/me/games/sgf/0", &body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return "", fmt.Errorf("OGS rejected the token: %s", resp.Status)
+	}
+	if resp.StatusCode/100 != 2 {
+		return "", fmt.Errorf("OGS upload failed: %s: %s", resp.Status, strings.TrimSpace(string(b)))
+	}
+	id, err := ogsReviewID(b)
+	if err != nil {
+		return "", fmt.Errorf("OGS upload: %w: %s", err, strings.TrimSpace(string(b)))
+	}
+	return fmt.Sprintf("%s/review/%d", ogsURL, id), nil
+}
+
+// ogsReviewID returns the id of the demo board in the response of an SGF upload,
+// which is an object or a list of one object per uploaded file.
+func ogsReviewID(b []byte) (int64, error) {
+	type entry struct {
+		ID     int64 `json:"id"`
+		Review int64 `json:"review_id"`
+	}
+	var es []entry
+	if err := json.Unmarshal(b, &es); err != nil {
+		var e entry
+		if err := json.Unmarshal(b, &e); err != nil {
+			return 0, fmt.Errorf("unexpected response")
+		}
+		es = []entry{e}
+	}
+	for _, e := range es {
+		if e.Review != 0 {
+			return e.Review, nil
+		}
+		if e.ID != 0 {
+			return e.ID, nil
+		}
+	}
+	return 0, fmt.Errorf("no demo board in the response")
+}
+
+func ogsCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("ogs", flag.ContinueOnError)
+	avx := fs.String("a", "", "Champion Go archive")
+	slot := fs.String("g", "latest", `the game: an entry name, "latest" or "latest-online"`)
+	token := fs.String("token", os.Getenv("CHAMGO_OGS_TOKEN"), "the OAuth access token of the OGS account, by default $CHAMGO_OGS_TOKEN")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		return fmt.Errorf("usage: chamgo ogs [-token token] [-a archive [-g game] | game.sgf]")
+	}
+	if *token == "" {
+		return fmt.Errorf("no OGS token, set $CHAMGO_OGS_TOKEN or -token")
+	}
+	name, g, err := loadGame(ctx, *avx, *slot, fs.Args())
+	if err != nil {
+		return err
+	}
+	sgf, err := sourceSGF(g, fs.Args())
+	if err != nil {
+		return err
+	}
+	base := strings.TrimSuffix(filepath.Base(name), filepath.Ext(name)) + ".sgf"
+	url, err := ogsUpload(ctx, *token, base, sgf)
+	if err != nil {
+		return err
+	}
+	output(map[string]string{"url": url}, func(w io.Writer) { fmt.Fprintln(w, url) })
+	return nil
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// openingMoves is how many moves of a game are looked at to classify its opening.
+const openingMoves = 12
+
+// pointClass returns the name of the opening point x, y of a board of size n, as counted from the nearest edges,
+// or "" for other points.
+func pointClass(n, x, y int) string {
+	if n%2 == 1 && x == (n+1)/2 && y == x {
+		return "tengen"
+	}
+	a, b := min(x, n+1-x), min(y, n+1-y)
+	if a > b {
+		a, b = b, a
+	}
+	if 2*b >= n+1 {
+		return ""
+	}
+	switch [2]int{a, b} {
+	case [2]int{3, 3}:
+		return "san-san"
+	case [2]int{3, 4}:
+		return "komoku"
+	case [2]int{4, 4}:
+		return "hoshi"
+	case [2]int{3, 5}:
+		return "mokuhazushi"
+	case [2]int{4, 5}:
+		return "takamoku"
+	}
+	return ""
+}
+
+// corner returns 0 to 3 for the corner of the board of size n that x, y is in.
+func corner(n, x, y int) int {
+	c := 0
+	if 2*x > n+1 {
+		c |= 1
+	}
+	if 2*y > n+1 {
+		c |= 2
+	}
+	return c
+}
+
+// fromEdge returns the distance of x, y from the edge e, 0 to 3 for left, right, top and bottom, counting the edge as 1,
+// and its position along the edge.
+func fromEdge(n, x, y, e int) (d, along int) {
+	switch e {
+	case 0:
+		return x, y
+	case 1:
+		return n + 1 - x, y
+	case 2:
+		return y, x
+	}
+	return n + 1 - y, x
+}
+
+// namedFuseki returns the name of the whole-board opening of the first black stones s on a 19x19 board, or "".
+func namedFuseki(s [][2]int) string {
+	const n = 19
+	class := func(i int) string { return pointClass(n, s[i][0], s[i][1]) }
+	if len(s) >= 3 {
+		for e := 0; e < 4; e++ {
+			var d, along [3]int
+			for i := range d {
+				d[i], along[i] = fromEdge(n, s[i][0], s[i][1], e)
+			}
+			sorted := along
+			sort.Ints(sorted[:])
+			if d == [3]int{4, 4, 4} && sorted == [3]int{4, 10, 16} {
+				return "sanrensei"
+			}
+			// Chinese: a star point and a komoku on one side, and a stone on the third or fourth line between them.
+			for _, p := range [][3]int{{0, 1, 2}, {1, 0, 2}, {0, 2, 1}, {2, 0, 1}, {1, 2, 0}, {2, 1, 0}} {
+				h, k, side := p[0], p[1], p[2]
+				if class(h) != "hoshi" || class(k) != "komoku" || d[h] != 4 || d[k] > 4 ||
+					corner(n, s[h][0], s[h][1]) == corner(n, s[k][0], s[k][1]) || along[side] < 9 || along[side] > 11 {
+					continue
+				}
+				switch d[side] {
+				case 3:
+					return "chinese"
+				case 4:
+					return "high chinese"
+				}
+			}
+		}
+		corners := map[int]bool{}
+		komoku := 0
+		for i := 0; i < 3; i++ {
+			corners[corner(n, s[i][0], s[i][1])] = true
+			if class(i) == "komoku" {
+				komoku++
+			}
+		}
+		if komoku == 3 && len(corners) == 3 {
+			return "shusaku"
+		}
+	}
+	if len(s) >= 2 && class(0) == "hoshi" && class(1) == "hoshi" && corner(n, s[0][0], s[0][1]) != corner(n, s[1][0], s[1][1]) {
+		for e := 0; e < 4; e++ {
+			d0, _ := fromEdge(n, s[0][0], s[0][1], e)
+			d1, _ := fromEdge(n, s[1][0], s[1][1], e)
+			if d0 == 4 && d1 == 4 {
+				return "nirensei"
+			}
+		}
+	}
+	return ""
+}
+
+// openingOf classifies the opening of g by black's first stones: the name of a common whole-board opening on 19x19
+// boards, as sanrensei or chinese, or else the points of black's first two corner stones, as hoshi or hoshi-komoku.
+// Games without a black stone in a corner in their first moves are "other".
+func openingOf(g *Game) string {
+	n := int(g.Size)
+	var black [][2]int
+	for _, m := range g.Moves[:min(openingMoves, len(g.Moves))] {
+		if m.Stone() == Black && !m.IsPass() && g.onBoard(m) {
+			black = append(black, [2]int{int(m.X), int(m.Y)})
+		}
+	}
+	if n == 19 {
+		if name := namedFuseki(black); name != "" {
+			return name
+		}
+	}
+	if len(black) > 0 && pointClass(n, black[0][0], black[0][1]) == "tengen" {
+		return "tengen"
+	}
+	var classes []string
+	for _, p := range black {
+		if c := pointClass(n, p[0], p[1]); c != "" && c != "tengen" && len(classes) < 2 {
+			classes = append(classes, c)
+		}
+	}
+	switch {
+	case len(classes) == 0:
+		return "other"
+	case len(classes) == 2 && classes[0] == classes[1]:
+		return classes[0]
+	}
+	return strings.Join(classes, "-")
+}
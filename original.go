@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// keepOriginal is set by -keep-original: "local" keeps the online games an edit replaces as new local games,
+// and "sgf" exports them to SGF files in $CHAMGO_HOME/originals.
+var keepOriginal = ""
+
+func setKeepOriginal(s string) error {
+	if err := checkKeepOriginal(s); err != nil {
+		return err
+	}
+	keepOriginal = s
+	return nil
+}
+
+func checkKeepOriginal(s string) error {
+	if s != "" && s != "local" && s != "sgf" {
+		return fmt.Errorf(`must be "local" or "sgf"`)
+	}
+	return nil
+}
+
+// keepOriginals returns e with the originals of the online games it replaces kept as -keep-original says,
+// the games of apps without online games counting as online. Unchanged games, and with "local" games
+// already kept as local games, are left out.
+func keepOriginals(ctx context.Context, avxName string, e avxEdit) (avxEdit, error) {
+	s := settingsOf(ctx)
+	if s.keepOriginal == "" || len(e.Replace) == 0 {
+		return e, nil
+	}
+	local, err := listGames(ctx, avxName, false)
+	if err != nil {
+		return e, err
+	}
+	seen := make(map[string]bool)
+	for _, g := range local {
+		if _, ok := e.Replace[g.Name]; !ok {
+			seen[movesHash(g.Body)] = true
+		}
+	}
+	added := append([]gameEntry(nil), local...)
+	for name := range e.Add {
+		added = append(added, gameEntry{Name: name})
+	}
+	next := nextGameNumber(added)
+
+	for _, name := range sortedKeys(e.Replace) {
+		if !s.app.isGameFile(name, true) && (s.app.hasOnlineGames() || !s.app.isGameFile(name, false)) {
+			continue
+		}
+		_, orig, err := readEntry(ctx, avxName, name)
+		if err != nil {
+			return e, err
+		}
+		if bytes.Equal(orig, e.Replace[name]) {
+			continue
+		}
+		switch s.keepOriginal {
+		case "local":
+			h := movesHash(orig)
+			if seen[h] {
+				continue
+			}
+			seen[h] = true
+			kept := s.app.gameDir(false) + strconv.Itoa(next) + path.Ext(name)
+			next++
+			if e.Add == nil {
+				e.Add = make(map[string][]byte)
+			}
+			e.Add[kept] = orig
+			s.note(event{"event": "keep-original", "entry": name, "to": kept}, "kept the original %s as %s", name, kept)
+		case "sgf":
+			g, err := s.decode(orig)
+			if err != nil {
+				return e, err
+			}
+			dir, err := chamgoDir("originals")
+			if err != nil {
+				return e, err
+			}
+			fn := filepath.Join(dir, strings.TrimSuffix(path.Base(name), path.Ext(name))+"-"+fingerprint(g.movesHash())+".sgf")
+			if err := ioutil.WriteFile(fn, []byte(writeSGF(g)), 0644); err != nil {
+				return e, err
+			}
+			s.note(event{"event": "keep-original", "entry": name, "to": fn}, "kept the original %s as %s", name, fn)
+		}
+	}
+	return e, nil
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// patchBytes returns the little endian encoding of the value v of bits bits.
+func patchBytes(v string, bits int) ([]byte, error) {
+	n, err := strconv.ParseInt(v, 0, 64)
+	if err != nil {
+		u, uerr := strconv.ParseUint(v, 0, bits)
+		if uerr != nil {
+			return nil, fmt.Errorf("bad value %q", v)
+		}
+		n = int64(u)
+	}
+	if n < -(1<<(bits-1)) || n >= 1<<bits {
+		return nil, fmt.Errorf("value %s does not fit in %d bits", v, bits)
+	}
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, uint64(n))
+	return b[:bits/8], nil
+}
+
+// copyFile copies the file src to dst.
+func copyFile(dst, src string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// writeAvxInPlace rewrites the archive avxName with the edit e, as writeArchiveFile does.
+func writeAvxInPlace(ctx context.Context, avxName string, e avxEdit) error {
+	unlock, err := lockOutput(ctx, avxName)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if !isDir(avxName) {
+		if err := checkDiskSpace(ctx, avxName, avxName, e); err != nil {
+			return err
+		}
+	}
+	rec, err := journalFor(ctx, avxName, e)
+	if err != nil {
+		return err
+	}
+	if err := saveVersion(ctx, avxName); err != nil {
+		return err
+	}
+	if isDir(avxName) {
+		if err := writeDir(ctx, avxName, avxName, e); err != nil {
+			return err
+		}
+		return appendJournal(avxName, avxName, rec)
+	}
+	if err := writeArchiveFile(ctx, avxName, avxName, e); err != nil {
+		return err
+	}
+	return appendJournal(avxName, avxName, rec)
+}
+
+// recordProblems returns what makes the record body unlike the records the app writes: the problems of its game,
+// and the first move that cannot be played.
+func recordProblems(body []byte) []string {
+	g, err := Decode(body)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	ps := g.problems(len(body))
+	if checkSize(g.Size) != nil {
+		return ps
+	}
+	if _, err := replay(g); err != nil {
+		ps = append(ps, err.Error())
+	}
+	return ps
+}
+
+func patchCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("patch", flag.ContinueOnError)
+	avx := fs.String("a", "", "Champion Go archive, patched in place after being backed up")
+	game := fs.String("g", "latest", `the game to patch: an entry name, "latest" or "latest-online"`)
+	out := fs.String("o", "", "write the patched archive here instead of patching in place")
+	offset := fs.Int("offset", -1, "offset of the patched bytes in the game record")
+	u8 := fs.String("u8", "", "patch a byte")
+	u16 := fs.String("u16", "", "patch a little endian 16 bit value")
+	u32 := fs.String("u32", "", "patch a little endian 32 bit value")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	var patch []byte
+	var err error
+	switch {
+	case *u8 != "":
+		patch, err = patchBytes(*u8, 8)
+	case *u16 != "":
+		patch, err = patchBytes(*u16, 16)
+	case *u32 != "":
+		patch, err = patchBytes(*u32, 32)
+	default:
+		return fmt.Errorf("one of -u8, -u16 or -u32 is required")
+	}
+	if err != nil {
+		return err
+	}
+
+	name, body, err := readEntry(ctx, *avx, *game)
+	if err != nil {
+		return err
+	}
+	if body == nil {
+		return errNoGames
+	}
+	if currentApp.format().toRecord != nil {
+		return unsupported(fmt.Errorf("the games of %s are not records and cannot be patched", currentApp.Name))
+	}
+	if *offset < 0 || *offset+len(patch) > len(body) {
+		return fmt.Errorf("offset %d and %d bytes are outside of %s, which has %d bytes", *offset, len(patch), name, len(body))
+	}
+	before := make(map[string]bool)
+	for _, p := range recordProblems(body) {
+		before[p] = true
+	}
+	orig := append([]byte(nil), body...)
+	copy(body[*offset:], patch)
+	// A patch may leave problems the record already had, but not add any.
+	var added []string
+	for _, p := range recordProblems(body) {
+		if !before[p] {
+			added = append(added, p)
+		}
+	}
+	if len(added) > 0 {
+		return invalid(fmt.Errorf("the patched %s would not be a valid record: %s", name, strings.Join(added, "; ")))
+	}
+	note(event{"event": "patch", "entry": name, "offset": *offset, "old": hexBytes(orig[*offset : *offset+len(patch)]), "new": hexBytes(patch)},
+		"%s@%d: % x -> % x", name, *offset, orig[*offset:*offset+len(patch)], patch)
+
+	dst := *out
+	if dst == "" {
+		dst = *avx
+		backup := fmt.Sprintf("%s.%s.bak", strings.TrimRight(*avx, `/\`), time.Now().Format("20060102-150405"))
+		if isDir(*avx) {
+			err = writeDir(ctx, backup+"/", *avx, avxEdit{})
+		} else {
+			err = copyFile(backup, *avx)
+		}
+		if err != nil {
+			return fmt.Errorf("backup: %w", err)
+		}
+		note(event{"event": "backup", "archive": *avx, "backup": backup}, "backed up %s to %s", *avx, backup)
+		err = writeAvxInPlace(ctx, *avx, avxEdit{Replace: map[string][]byte{name: body}})
+	} else {
+		err = writeAvxFile(ctx, dst, *avx, avxEdit{Replace: map[string][]byte{name: body}})
+	}
+	if err != nil {
+		return err
+	}
+	if dst == "-" {
+		// The archive went to stdout, where it cannot be read back; the record was checked before it was written.
+		return nil
+	}
+
+	// Check that the patched archive and game can still be read.
+	got, err := readArchiveFile(ctx, dst, name)
+	if err != nil {
+		return invalid(fmt.Errorf("patched archive %s is unreadable: %w", dst, err))
+	}
+	if !bytes.Equal(got, body) {
+		return invalid(fmt.Errorf("patched archive %s has unexpected contents in %s", dst, name))
+	}
+	if ps := recordProblems(got); len(ps) > len(before) {
+		return invalid(fmt.Errorf("patched game %s is not a valid record: %s", name, strings.Join(ps, "; ")))
+	}
+	return nil
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// A pipeline is a reproducible sequence of operations read from a YAML file such as:
+//
+//	input: Champion Go 1.1.3.imazingapp
+//	steps:
+//	  - select: latest          # the latest local game, or an entry name
+//	  - transform: flip180,truncate=30   # see transforms
+//	  - player: w
+//	  - set-level: 10
+//	  - script: ./truncate.py 30   # see runScript
+//	  - inject: latest-online   # the latest online game, or an entry name
+//	  - write: out.imazingapp   # "-" writes to stdout
+//
+// Only the subset of YAML above is understood: top-level scalars and a "steps" list of single-key maps.
+type pipeline struct {
+	Input string
+	Steps []pipelineStep
+}
+
+type pipelineStep struct {
+	Op   string
+	Arg  string
+	Line int
+}
+
+func parsePipeline(r io.Reader) (*pipeline, error) {
+	p := &pipeline{}
+	var inSteps bool
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := stripYAMLComment(sc.Text())
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		if item := strings.TrimSpace(line); strings.HasPrefix(item, "- ") || item == "-" {
+			if !inSteps {
+				return nil, fmt.Errorf("line %d: list item outside of steps", n)
+			}
+			k, v, err := splitYAMLPair(strings.TrimPrefix(item, "-"))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", n, err)
+			}
+			p.Steps = append(p.Steps, pipelineStep{Op: k, Arg: v, Line: n})
+			continue
+		}
+
+		if line[0] == ' ' || line[0] == '\t' {
+			return nil, fmt.Errorf("line %d: unexpected indentation", n)
+		}
+		k, v, err := splitYAMLPair(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		inSteps = false
+		switch k {
+		case "input":
+			p.Input = v
+		case "steps":
+			if v != "" {
+				return nil, fmt.Errorf("line %d: steps must be a list", n)
+			}
+			inSteps = true
+		default:
+			return nil, fmt.Errorf("line %d: unknown key %q", n, k)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func stripYAMLComment(line string) string {
+	var quote rune
+	for i, c := range line {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+func splitYAMLPair(s string) (string, string, error) {
+	i := strings.Index(s, ":")
+	if i < 0 {
+		return "", "", fmt.Errorf("expected \"key: value\", got %q", strings.TrimSpace(s))
+	}
+	k := strings.TrimSpace(s[:i])
+	v := strings.TrimSpace(s[i+1:])
+	if len(v) >= 2 && (v[0] == '"' || v[0] == '\'') && v[len(v)-1] == v[0] {
+		if v[0] == '"' {
+			uq, err := strconv.Unquote(v)
+			if err != nil {
+				return "", "", fmt.Errorf("bad string %s: %w", v, err)
+			}
+			v = uq
+		} else {
+			v = strings.ReplaceAll(v[1:len(v)-1], "''", "'")
+		}
+	}
+	return k, v, nil
+}
+
+// readEntry returns the body of the entry name in the archive avxName.
+// The special names "latest" and "latest-online" select the most recently saved local and online games,
+// "autosave" the autosaved game in progress, and a fingerprint, or the beginning of one, the game with that fingerprint.
+func readEntry(ctx context.Context, avxName, name string) (string, []byte, error) {
+	switch name {
+	case "", "latest":
+		return readAvx(ctx, avxName, false)
+	case "latest-online":
+		return readAvx(ctx, avxName, true)
+	case "autosave":
+		return readAutosave(ctx, avxName)
+	}
+	if isFingerprint(name) {
+		return readFingerprint(ctx, avxName, name)
+	}
+	body, err := readArchiveFile(ctx, avxName, name)
+	if err != nil {
+		return "", nil, err
+	}
+	if app := settingsOf(ctx).app; app.isGameFile(name, false) || app.isGameFile(name, true) {
+		if body, err = app.record(name, body); err != nil {
+			return "", nil, err
+		}
+	}
+	return name, body, nil
+}
+
+func (p *pipeline) run(ctx context.Context) error {
+	if p.Input == "" {
+		return fmt.Errorf("pipeline has no input")
+	}
+
+	var body []byte
+	replace := make(map[string][]byte)
+	for _, s := range p.Steps {
+		if s.Op != "select" && s.Op != "write" && body == nil {
+			return fmt.Errorf("line %d: %s before select", s.Line, s.Op)
+		}
+		if err := p.runStep(ctx, s, &body, replace); err != nil {
+			return fmt.Errorf("line %d: %s: %w", s.Line, s.Op, err)
+		}
+	}
+	return nil
+}
+
+func (p *pipeline) runStep(ctx context.Context, s pipelineStep, body *[]byte, replace map[string][]byte) error {
+	switch s.Op {
+	case "select":
+		_, b, err := readEntry(ctx, p.Input, s.Arg)
+		if err != nil {
+			return err
+		}
+		if b == nil {
+			return errNoGames
+		}
+		*body = b
+	case "transform":
+		b, err := applyTransforms(ctx, *body, s.Arg)
+		if err != nil {
+			return err
+		}
+		*body = b
+	case "player":
+		if s.Arg != "b" && s.Arg != "w" {
+			return fmt.Errorf("player must be b or w")
+		}
+		return setPlayer(*body, s.Arg)
+	case "set-level":
+		level, err := strconv.ParseUint(s.Arg, 10, 8)
+		if err != nil || level < 1 || level > 10 {
+			return fmt.Errorf("level must be between 1 and 10")
+		}
+		setLevel(*body, byte(level))
+	case "inject":
+		slot, _, err := readEntry(ctx, p.Input, s.Arg)
+		if err != nil {
+			return err
+		}
+		if slot == "" {
+			return fmt.Errorf("no slot found")
+		}
+		b := append([]byte(nil), *body...)
+		touchDates(b)
+		replace[slot] = b
+	case "script":
+		b, err := runScript(ctx, *body, s.Arg)
+		if err != nil {
+			return err
+		}
+		*body = b
+	case "write":
+		return writeAvxFile(ctx, s.Arg, p.Input, avxEdit{Replace: replace})
+	default:
+		return fmt.Errorf("unknown operation")
+	}
+	return nil
+}
+
+func runCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	input := fs.String("a", "", "input Champion Go archive, overriding the pipeline's input")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: chamgo run [-a archive] pipeline.yaml")
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	p, err := parsePipeline(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", fs.Arg(0), err)
+	}
+	if *input != "" {
+		p.Input = *input
+	}
+	return p.run(ctx)
+}
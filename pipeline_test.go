@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParsePipeline(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want *pipeline
+		err  string
+	}{
+		{"full", `# a pipeline
+input: Champion Go 1.1.3.imazingapp
+steps:
+  - select: latest          # a comment
+  - transform: flip180,truncate=30
+  - player: w
+  - inject: "latest-online"
+  - write: 'it''s #1.imazingapp'
+`, &pipeline{Input: "Champion Go 1.1.3.imazingapp", Steps: []pipelineStep{
+			{"select", "latest", 4}, {"transform", "flip180,truncate=30", 5}, {"player", "w", 6},
+			{"inject", "latest-online", 7}, {"write", "it's #1.imazingapp", 8},
+		}}, ""},
+		{"no steps", "input: a.imazingapp\n", &pipeline{Input: "a.imazingapp"}, ""},
+		{"item outside steps", "input: a\n- select: latest\n", nil, "line 2: list item outside of steps"},
+		{"item after input", "steps:\ninput: a\n  - select: latest\n", nil, "line 3: list item outside of steps"},
+		{"indentation", "input: a\n  steps:\n", nil, "line 2: unexpected indentation"},
+		{"unknown key", "output: a\n", nil, `line 1: unknown key "output"`},
+		{"scalar steps", "steps: select\n", nil, "line 1: steps must be a list"},
+		{"no colon", "steps:\n  - select\n", nil, "line 2: expected"},
+		{"bad string", "input: \"a\\q\"\n", nil, "line 1: bad string"},
+	}
+	for _, tt := range tests {
+		p, err := parsePipeline(strings.NewReader(tt.src))
+		switch {
+		case tt.err != "":
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: %v, want %s", tt.name, err, tt.err)
+			}
+		case err != nil:
+			t.Errorf("%s: %v", tt.name, err)
+		case !reflect.DeepEqual(p, tt.want):
+			t.Errorf("%s: %+v, want %+v", tt.name, p, tt.want)
+		}
+	}
+}
+
+func TestPipelineRun(t *testing.T) {
+	avx := synthArchive(t, synthOptions{Version: currentLayout.Version, Local: 2, Online: 2, Size: 9, Moves: 10, Seed: 5})
+	ctx := context.Background()
+	latest, _, err := readAvx(ctx, avx, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		steps string
+		level int32 // the level of the latest online game written
+		err   string
+	}{
+		{"set level", "select: latest\nset-level: 7\ninject: latest-online\nwrite: OUT", 7, ""},
+		{"player and transform", "select: latest\nplayer: w\ntransform: flip180\nset-level: 3\ninject: latest-online\nwrite: OUT", 3, ""},
+		{"before select", "set-level: 7", 0, "line 3: set-level before select"},
+		{"bad level", "select: latest\nset-level: 11", 0, "line 4: set-level: level must be between 1 and 10"},
+		{"bad player", "select: latest\nplayer: x", 0, "line 4: player: player must be b or w"},
+		{"unknown operation", "select: latest\nundo: 1", 0, "line 4: undo: unknown operation"},
+		{"missing entry", "select: Container/Documents/game/9", 0, "line 3: select:"},
+	}
+	for _, tt := range tests {
+		out := filepath.Join(t.TempDir(), "out.imazingapp")
+		src := "input: " + avx + "\nsteps:\n  - " + strings.ReplaceAll(strings.ReplaceAll(tt.steps, "OUT", out), "\n", "\n  - ")
+		p, err := parsePipeline(strings.NewReader(src))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		err = p.run(ctx)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: %v, want %s", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		b, err := ioutil.ReadFile(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		g, err := Decode(zipEntries(t, b)[latest])
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		} else if g.Level != tt.level {
+			t.Errorf("%s: level %d, want %d", tt.name, g.Level, tt.level)
+		}
+	}
+	if err := (&pipeline{}).run(ctx); err == nil {
+		t.Error("no error running a pipeline without input")
+	}
+}
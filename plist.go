@@ -0,0 +1,608 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// Property list values are represented by
+// string, int64, float64, bool, time.Time, []byte, []interface{}, *plistDict and plistUID.
+
+// plistDict is a property list dictionary that keeps the order of its keys.
+type plistDict struct {
+	Keys   []string
+	Values map[string]interface{}
+}
+
+func newPlistDict() *plistDict {
+	return &plistDict{Values: make(map[string]interface{})}
+}
+
+func (d *plistDict) Get(k string) (interface{}, bool) {
+	v, ok := d.Values[k]
+	return v, ok
+}
+
+func (d *plistDict) Set(k string, v interface{}) {
+	if _, ok := d.Values[k]; !ok {
+		d.Keys = append(d.Keys, k)
+	}
+	d.Values[k] = v
+}
+
+func (d *plistDict) Delete(k string) {
+	if _, ok := d.Values[k]; !ok {
+		return
+	}
+	delete(d.Values, k)
+	for i, kk := range d.Keys {
+		if kk == k {
+			d.Keys = append(d.Keys[:i], d.Keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// plistUID is a reference used by keyed archives, which only exists in binary property lists.
+type plistUID uint64
+
+// plistEpoch is the reference date of property list dates.
+var plistEpoch = time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// isBinaryPlist reports whether b is a binary property list.
+func isBinaryPlist(b []byte) bool { return bytes.HasPrefix(b, []byte("bplist00")) }
+
+// parsePlist parses an XML or binary property list.
+func parsePlist(b []byte) (interface{}, error) {
+	parse := parseXMLPlist
+	if isBinaryPlist(b) {
+		parse = parseBinaryPlist
+	}
+	v, err := parse(b)
+	if err != nil {
+		return nil, unsupported(err)
+	}
+	return v, nil
+}
+
+// parseXMLPlist parses an XML property list.
+func parseXMLPlist(b []byte) (interface{}, error) {
+	d := xml.NewDecoder(bytes.NewReader(b))
+	d.Strict = false
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return nil, fmt.Errorf("plist: %w", err)
+		}
+		if se, ok := t.(xml.StartElement); ok {
+			if se.Name.Local == "plist" {
+				continue
+			}
+			return parseXMLValue(d, se)
+		}
+	}
+}
+
+func parseXMLValue(d *xml.Decoder, se xml.StartElement) (interface{}, error) {
+	text := func() (string, error) {
+		var s string
+		err := d.DecodeElement(&s, &se)
+		return s, err
+	}
+	switch se.Name.Local {
+	case "string":
+		return text()
+	case "integer":
+		s, err := text()
+		if err != nil {
+			return nil, err
+		}
+		s = strings.TrimSpace(s)
+		if n, err := strconv.ParseInt(s, 0, 64); err == nil {
+			return n, nil
+		}
+		n, err := strconv.ParseUint(s, 0, 64)
+		return int64(n), err
+	case "real":
+		s, err := text()
+		if err != nil {
+			return nil, err
+		}
+		return strconv.ParseFloat(strings.TrimSpace(s), 64)
+	case "true", "false":
+		if err := d.Skip(); err != nil {
+			return nil, err
+		}
+		return se.Name.Local == "true", nil
+	case "date":
+		s, err := text()
+		if err != nil {
+			return nil, err
+		}
+		return time.Parse(time.RFC3339, strings.TrimSpace(s))
+	case "data":
+		s, err := text()
+		if err != nil {
+			return nil, err
+		}
+		return base64.StdEncoding.DecodeString(strings.Join(strings.Fields(s), ""))
+	case "array":
+		a := []interface{}{}
+		for {
+			t, err := d.Token()
+			if err != nil {
+				return nil, err
+			}
+			switch t := t.(type) {
+			case xml.StartElement:
+				v, err := parseXMLValue(d, t)
+				if err != nil {
+					return nil, err
+				}
+				a = append(a, v)
+			case xml.EndElement:
+				return a, nil
+			}
+		}
+	case "dict":
+		dict := newPlistDict()
+		var key string
+		var haveKey bool
+		for {
+			t, err := d.Token()
+			if err != nil {
+				return nil, err
+			}
+			switch t := t.(type) {
+			case xml.StartElement:
+				if t.Name.Local == "key" {
+					if err := d.DecodeElement(&key, &t); err != nil {
+						return nil, err
+					}
+					haveKey = true
+					continue
+				}
+				if !haveKey {
+					return nil, fmt.Errorf("plist: dict value without a key")
+				}
+				v, err := parseXMLValue(d, t)
+				if err != nil {
+					return nil, err
+				}
+				dict.Set(key, v)
+				haveKey = false
+			case xml.EndElement:
+				return dict, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("plist: unknown element %s", se.Name.Local)
+}
+
+// writeXMLPlist writes v as an XML property list.
+func writeXMLPlist(w io.Writer, v interface{}) error {
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+	b.WriteString(`<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">` + "\n")
+	b.WriteString(`<plist version="1.0">` + "\n")
+	if err := writeXMLValue(&b, v, 0); err != nil {
+		return err
+	}
+	b.WriteString("</plist>\n")
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+func writeXMLValue(b *bytes.Buffer, v interface{}, depth int) error {
+	indent := strings.Repeat("\t", depth)
+	esc := func(s string) string {
+		var e bytes.Buffer
+		xml.EscapeText(&e, []byte(s))
+		return e.String()
+	}
+	switch v := v.(type) {
+	case string:
+		fmt.Fprintf(b, "%s<string>%s</string>\n", indent, esc(v))
+	case int64:
+		fmt.Fprintf(b, "%s<integer>%d</integer>\n", indent, v)
+	case float64:
+		fmt.Fprintf(b, "%s<real>%s</real>\n", indent, strconv.FormatFloat(v, 'g', -1, 64))
+	case bool:
+		fmt.Fprintf(b, "%s<%t/>\n", indent, v)
+	case time.Time:
+		fmt.Fprintf(b, "%s<date>%s</date>\n", indent, v.UTC().Format(time.RFC3339))
+	case []byte:
+		fmt.Fprintf(b, "%s<data>%s</data>\n", indent, base64.StdEncoding.EncodeToString(v))
+	case []interface{}:
+		fmt.Fprintf(b, "%s<array>\n", indent)
+		for _, e := range v {
+			if err := writeXMLValue(b, e, depth+1); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintf(b, "%s</array>\n", indent)
+	case *plistDict:
+		fmt.Fprintf(b, "%s<dict>\n", indent)
+		for _, k := range v.Keys {
+			fmt.Fprintf(b, "%s\t<key>%s</key>\n", indent, esc(k))
+			if err := writeXMLValue(b, v.Values[k], depth+1); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintf(b, "%s</dict>\n", indent)
+	case plistUID:
+		return fmt.Errorf("plist: UIDs cannot be written in XML")
+	default:
+		return fmt.Errorf("plist: cannot write %T", v)
+	}
+	return nil
+}
+
+// parseBinaryPlist parses a bplist00 property list.
+func parseBinaryPlist(b []byte) (interface{}, error) {
+	if len(b) < 8+32 {
+		return nil, fmt.Errorf("plist: too short")
+	}
+	t := b[len(b)-32:]
+	offSize, refSize := int(t[6]), int(t[7])
+	numObjects := binary.BigEndian.Uint64(t[8:])
+	top := binary.BigEndian.Uint64(t[16:])
+	tableOff := binary.BigEndian.Uint64(t[24:])
+	if offSize < 1 || offSize > 8 || refSize < 1 || refSize > 8 || tableOff > uint64(len(b)) ||
+		numObjects > (uint64(len(b))-tableOff)/uint64(offSize) || top >= numObjects {
+		return nil, fmt.Errorf("plist: bad trailer")
+	}
+	p := &bplistReader{b: b, refSize: refSize, offsets: make([]uint64, numObjects), visiting: make(map[uint64]bool)}
+	for i := range p.offsets {
+		p.offsets[i] = readBEUint(b[tableOff+uint64(i*offSize):], offSize)
+	}
+	return p.object(top)
+}
+
+type bplistReader struct {
+	b        []byte
+	refSize  int
+	offsets  []uint64
+	visiting map[uint64]bool
+}
+
+func readBEUint(b []byte, n int) uint64 {
+	var v uint64
+	for i := 0; i < n; i++ {
+		v = v<<8 | uint64(b[i])
+	}
+	return v
+}
+
+func (p *bplistReader) object(ref uint64) (interface{}, error) {
+	if ref >= uint64(len(p.offsets)) {
+		return nil, fmt.Errorf("plist: bad object reference %d", ref)
+	}
+	if p.visiting[ref] {
+		return nil, fmt.Errorf("plist: cyclic object %d", ref)
+	}
+	p.visiting[ref] = true
+	defer delete(p.visiting, ref)
+
+	off := p.offsets[ref]
+	if off >= uint64(len(p.b)) {
+		return nil, fmt.Errorf("plist: bad object offset %d", off)
+	}
+	marker := p.b[off]
+	kind, info := marker>>4, int(marker&0xf)
+	pos := off + 1
+	need := func(n uint64) error {
+		if pos+n > uint64(len(p.b)) || pos+n < pos {
+			return fmt.Errorf("plist: object %d is truncated", ref)
+		}
+		return nil
+	}
+	// count reads the element count of data, strings and collections.
+	count := func() (uint64, error) {
+		if info != 0xf {
+			return uint64(info), nil
+		}
+		if err := need(1); err != nil {
+			return 0, err
+		}
+		m := p.b[pos]
+		if m>>4 != 1 {
+			return 0, fmt.Errorf("plist: bad count marker")
+		}
+		n := uint64(1) << (m & 0xf)
+		pos++
+		if n > 8 {
+			return 0, fmt.Errorf("plist: count too large")
+		}
+		if err := need(n); err != nil {
+			return 0, err
+		}
+		c := readBEUint(p.b[pos:], int(n))
+		pos += n
+		return c, nil
+	}
+
+	switch kind {
+	case 0x0:
+		switch marker {
+		case 0x08:
+			return false, nil
+		case 0x09:
+			return true, nil
+		}
+		return nil, nil
+	case 0x1:
+		n := uint64(1) << info
+		if n > 16 {
+			return nil, fmt.Errorf("plist: integer too large")
+		}
+		if err := need(n); err != nil {
+			return nil, err
+		}
+		if n == 16 {
+			return int64(readBEUint(p.b[pos+8:], 8)), nil
+		}
+		return int64(readBEUint(p.b[pos:], int(n))), nil
+	case 0x2:
+		n := uint64(1) << info
+		if err := need(n); err != nil {
+			return nil, err
+		}
+		switch n {
+		case 4:
+			return float64(math.Float32frombits(uint32(readBEUint(p.b[pos:], 4)))), nil
+		case 8:
+			return math.Float64frombits(readBEUint(p.b[pos:], 8)), nil
+		}
+		return nil, fmt.Errorf("plist: bad real size %d", n)
+	case 0x3:
+		if err := need(8); err != nil {
+			return nil, err
+		}
+		secs := math.Float64frombits(readBEUint(p.b[pos:], 8))
+		return plistEpoch.Add(time.Duration(secs * float64(time.Second))), nil
+	case 0x4, 0x5, 0x6:
+		n, err := count()
+		if err != nil {
+			return nil, err
+		}
+		size := n
+		if kind == 0x6 {
+			// UTF-16 strings count 2-byte units, whose size would overflow for a crafted count.
+			if n > uint64(len(p.b))/2 {
+				return nil, fmt.Errorf("plist: object %d is truncated", ref)
+			}
+			size = 2 * n
+		}
+		if err := need(size); err != nil {
+			return nil, err
+		}
+		data := p.b[pos : pos+size]
+		switch kind {
+		case 0x4:
+			return append([]byte(nil), data...), nil
+		case 0x5:
+			return string(data), nil
+		}
+		u := make([]uint16, n)
+		for i := range u {
+			u[i] = binary.BigEndian.Uint16(data[2*i:])
+		}
+		return string(utf16.Decode(u)), nil
+	case 0x8:
+		if err := need(uint64(info + 1)); err != nil {
+			return nil, err
+		}
+		return plistUID(readBEUint(p.b[pos:], info+1)), nil
+	case 0xa, 0xd:
+		n, err := count()
+		if err != nil {
+			return nil, err
+		}
+		refs := n
+		if kind == 0xd {
+			refs = 2 * n
+		}
+		if refs > uint64(len(p.b)) {
+			return nil, fmt.Errorf("plist: object %d is truncated", ref)
+		}
+		if err := need(refs * uint64(p.refSize)); err != nil {
+			return nil, err
+		}
+		refAt := func(i uint64) uint64 { return readBEUint(p.b[pos+i*uint64(p.refSize):], p.refSize) }
+		if kind == 0xa {
+			a := make([]interface{}, 0, n)
+			for i := uint64(0); i < n; i++ {
+				v, err := p.object(refAt(i))
+				if err != nil {
+					return nil, err
+				}
+				a = append(a, v)
+			}
+			return a, nil
+		}
+		d := newPlistDict()
+		for i := uint64(0); i < n; i++ {
+			k, err := p.object(refAt(i))
+			if err != nil {
+				return nil, err
+			}
+			ks, ok := k.(string)
+			if !ok {
+				return nil, fmt.Errorf("plist: dict key is %T", k)
+			}
+			v, err := p.object(refAt(n + i))
+			if err != nil {
+				return nil, err
+			}
+			d.Set(ks, v)
+		}
+		return d, nil
+	}
+	return nil, fmt.Errorf("plist: unknown object marker %#x", marker)
+}
+
+// writeBinaryPlist writes v as a bplist00 property list.
+func writeBinaryPlist(w io.Writer, v interface{}) error {
+	// Flatten the objects so that each one has a reference.
+	var objs []interface{}
+	var flatten func(v interface{}) int
+	type array struct{ refs []int }
+	type dict struct{ keys, values []int }
+	flatten = func(v interface{}) int {
+		i := len(objs)
+		objs = append(objs, nil)
+		switch v := v.(type) {
+		case []interface{}:
+			a := array{}
+			for _, e := range v {
+				a.refs = append(a.refs, flatten(e))
+			}
+			objs[i] = a
+		case *plistDict:
+			d := dict{}
+			for _, k := range v.Keys {
+				d.keys = append(d.keys, flatten(k))
+			}
+			for _, k := range v.Keys {
+				d.values = append(d.values, flatten(v.Values[k]))
+			}
+			objs[i] = d
+		default:
+			objs[i] = v
+		}
+		return i
+	}
+	flatten(v)
+
+	refSize := 1
+	for ; refSize < 8 && len(objs) >= 1<<(8*refSize); refSize *= 2 {
+	}
+	var b bytes.Buffer
+	b.WriteString("bplist00")
+	putUint := func(v uint64, n int) {
+		for i := n - 1; i >= 0; i-- {
+			b.WriteByte(byte(v >> (8 * i)))
+		}
+	}
+	putInt := func(n int64) {
+		switch {
+		case n >= 0 && n < 1<<8:
+			b.WriteByte(0x10)
+			putUint(uint64(n), 1)
+		case n >= 0 && n < 1<<16:
+			b.WriteByte(0x11)
+			putUint(uint64(n), 2)
+		case n >= 0 && n < 1<<32:
+			b.WriteByte(0x12)
+			putUint(uint64(n), 4)
+		default:
+			b.WriteByte(0x13)
+			putUint(uint64(n), 8)
+		}
+	}
+	putMarker := func(kind byte, n int) {
+		if n < 0xf {
+			b.WriteByte(kind<<4 | byte(n))
+			return
+		}
+		b.WriteByte(kind<<4 | 0xf)
+		putInt(int64(n))
+	}
+	putRefs := func(refs []int) {
+		for _, r := range refs {
+			putUint(uint64(r), refSize)
+		}
+	}
+
+	offsets := make([]uint64, len(objs))
+	for i, o := range objs {
+		offsets[i] = uint64(b.Len())
+		switch o := o.(type) {
+		case nil:
+			b.WriteByte(0x00)
+		case bool:
+			if o {
+				b.WriteByte(0x09)
+			} else {
+				b.WriteByte(0x08)
+			}
+		case int64:
+			putInt(o)
+		case float64:
+			b.WriteByte(0x23)
+			putUint(math.Float64bits(o), 8)
+		case time.Time:
+			b.WriteByte(0x33)
+			putUint(math.Float64bits(o.Sub(plistEpoch).Seconds()), 8)
+		case []byte:
+			putMarker(0x4, len(o))
+			b.Write(o)
+		case string:
+			ascii := true
+			for i := 0; i < len(o); i++ {
+				if o[i] >= 0x80 {
+					ascii = false
+				}
+			}
+			if ascii {
+				putMarker(0x5, len(o))
+				b.WriteString(o)
+				break
+			}
+			u := utf16.Encode([]rune(o))
+			putMarker(0x6, len(u))
+			for _, c := range u {
+				putUint(uint64(c), 2)
+			}
+		case plistUID:
+			n := 1
+			for ; n < 8 && uint64(o) >= 1<<(8*n); n *= 2 {
+			}
+			b.WriteByte(0x80 | byte(n-1))
+			putUint(uint64(o), n)
+		case array:
+			putMarker(0xa, len(o.refs))
+			putRefs(o.refs)
+		case dict:
+			putMarker(0xd, len(o.keys))
+			putRefs(o.keys)
+			putRefs(o.values)
+		default:
+			return fmt.Errorf("plist: cannot write %T", o)
+		}
+	}
+
+	tableOff := uint64(b.Len())
+	offSize := 1
+	for ; offSize < 8 && tableOff >= 1<<(8*offSize); offSize *= 2 {
+	}
+	for _, off := range offsets {
+		putUint(off, offSize)
+	}
+	b.Write(make([]byte, 6))
+	b.WriteByte(byte(offSize))
+	b.WriteByte(byte(refSize))
+	putUint(uint64(len(objs)), 8)
+	putUint(0, 8)
+	putUint(tableOff, 8)
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// writePlist writes v in the same format as the property list orig.
+func writePlist(w io.Writer, v interface{}, orig []byte) error {
+	if isBinaryPlist(orig) {
+		return writeBinaryPlist(w, v)
+	}
+	return writeXMLPlist(w, v)
+}
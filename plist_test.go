@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func FuzzPlist(f *testing.F) {
+	f.Add([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<plist version="1.0">
+<dict>
+	<key>bundleShortVersionString</key>
+	<string>1.1.3</string>
+	<key>levels</key>
+	<array><integer>3</integer><real>1.5</real><true/><date>2024-01-01T09:00:00Z</date><data>AAE=</data></array>
+</dict>
+</plist>
+`))
+	d := newPlistDict()
+	d.Set("name", "Champion Go")
+	d.Set("jp", "囲碁")
+	d.Set("level", int64(7))
+	d.Set("saved", synthEpoch.Add(time.Hour))
+	d.Set("games", []interface{}{[]byte{1, 2}, 2.5, false})
+	var buf bytes.Buffer
+	if err := writeBinaryPlist(&buf, d); err != nil {
+		f.Fatal(err)
+	}
+	f.Add(buf.Bytes())
+	f.Fuzz(func(t *testing.T, b []byte) {
+		v, err := parsePlist(b)
+		if err != nil {
+			return
+		}
+		var buf bytes.Buffer
+		if err := writeBinaryPlist(&buf, v); err != nil {
+			return
+		}
+		if _, err := parsePlist(buf.Bytes()); err != nil {
+			t.Fatalf("parsing the written plist: %v", err)
+		}
+	})
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// chamgoDir returns the directory sub of chamgo's local store, creating it if necessary.
+func chamgoDir(sub string) (string, error) {
+	dir, err := chamgoPath(sub)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// chamgoPath returns the directory sub of chamgo's local store, which may not exist.
+// The store lives in $CHAMGO_HOME, or in the user's config directory if it is not set.
+func chamgoPath(sub string) (string, error) {
+	home := os.Getenv("CHAMGO_HOME")
+	if home == "" {
+		cfg, err := os.UserConfigDir()
+		if err != nil {
+			return "", err
+		}
+		home = filepath.Join(cfg, "chamgo")
+	}
+	return filepath.Join(home, sub), nil
+}
+
+func positionPath(name string) (string, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return "", fmt.Errorf("bad position name %q", name)
+	}
+	dir, err := chamgoDir("positions")
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name+".game"), nil
+}
+
+func posCmd(ctx context.Context, args []string) error {
+	usage := fmt.Errorf("usage: chamgo pos save|inject|list|rm [flags] [name]")
+	if len(args) == 0 {
+		return usage
+	}
+	fs := flag.NewFlagSet("pos "+args[0], flag.ContinueOnError)
+	avx := fs.String("a", "", "Champion Go archive")
+	game := fs.String("g", "latest", `the game to save: an entry name, "latest" or "latest-online"`)
+	slot := fs.String("slot", "latest-online", "the online game replaced by inject")
+	p := fs.String("p", "b", "the color of the human player")
+	level := fs.Int("l", 10, "computer level")
+	out := fs.String("o", "-", "output archive")
+	if err := parseFlags(fs, args[1:]); err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "save":
+		if fs.NArg() != 1 {
+			return usage
+		}
+		fn, err := positionPath(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		_, body, err := readEntry(ctx, *avx, *game)
+		if err != nil {
+			return err
+		}
+		if body == nil {
+			return errNoGames
+		}
+		return ioutil.WriteFile(fn, body, 0644)
+	case "inject":
+		if fs.NArg() != 1 {
+			return usage
+		}
+		if *level < 1 || *level > 10 {
+			return fmt.Errorf("level must be between 1 and 10")
+		}
+		fn, err := positionPath(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		body, err := ioutil.ReadFile(fn)
+		if err != nil {
+			return err
+		}
+		name, _, err := readEntry(ctx, *avx, *slot)
+		if err != nil {
+			return err
+		}
+		if name == "" {
+			return fmt.Errorf("no slot found")
+		}
+		if err := setPlayer(body, *p); err != nil {
+			return err
+		}
+		setLevel(body, byte(*level))
+		touchDates(body)
+		return writeAvxFile(ctx, *out, *avx, avxEdit{Replace: map[string][]byte{name: body}})
+	case "list":
+		dir, err := chamgoDir("positions")
+		if err != nil {
+			return err
+		}
+		fns, err := filepath.Glob(filepath.Join(dir, "*.game"))
+		if err != nil {
+			return err
+		}
+		sort.Strings(fns)
+		names := []string{}
+		for _, fn := range fns {
+			names = append(names, strings.TrimSuffix(filepath.Base(fn), ".game"))
+		}
+		output(names, func(w io.Writer) {
+			for _, n := range names {
+				fmt.Fprintln(w, n)
+			}
+		})
+		return nil
+	case "rm":
+		if fs.NArg() != 1 {
+			return usage
+		}
+		fn, err := positionPath(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		return os.Remove(fn)
+	}
+	return usage
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Stone is the content of a board point.
+type Stone int8
+
+const (
+	Empty Stone = iota
+	Black
+	White
+)
+
+// Position is a board position with the side to move.
+// Points are numbered from 1 to Size, with x increasing to the right and y increasing downwards.
+type Position struct {
+	Size   int
+	Board  []Stone // row major
+	ToMove Stone
+}
+
+func (p *Position) At(x, y int) Stone { return p.Board[(y-1)*p.Size+x-1] }
+
+// toBoard returns a board with the stones of p.
+func (p *Position) toBoard() *Board {
+	b := NewBoard(p.Size)
+	copy(b.Points, p.Board)
+	return b
+}
+
+// parseDiagram parses a plain text board diagram such as
+//
+//	. . X O
+//	. X O .
+//	. X O .
+//	. . . .
+//
+// where X or # is black, O is white, and . or + is empty.
+// Spaces between points are optional.
+func parseDiagram(r io.Reader, toMove Stone) (*Position, error) {
+	p := &Position{ToMove: toMove}
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		var row []Stone
+		for _, c := range line {
+			switch c {
+			case ' ', '\t':
+			case '.', '+':
+				row = append(row, Empty)
+			case 'X', 'x', '#':
+				row = append(row, Black)
+			case 'O', 'o':
+				row = append(row, White)
+			default:
+				return nil, fmt.Errorf("line %d: unknown point %q", n, c)
+			}
+		}
+		if p.Size == 0 {
+			p.Size = len(row)
+		}
+		if len(row) != p.Size {
+			return nil, fmt.Errorf("line %d: %d points, expected %d", n, len(row), p.Size)
+		}
+		p.Board = append(p.Board, row...)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if p.Size == 0 || len(p.Board) != p.Size*p.Size {
+		return nil, fmt.Errorf("diagram is not square")
+	}
+	if err := checkSize(int32(p.Size)); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// readDiagram parses the board diagram in the file fn.
+func readDiagram(fn string, toMove Stone) (*Position, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	p, err := parseDiagram(f, toMove)
+	if err != nil {
+		return nil, invalid(fmt.Errorf("%s: %w", fn, err))
+	}
+	return p, nil
+}
+
+// moves returns a move sequence starting with black that reproduces p,
+// alternating black and white stones and padding with passes when one side has more stones.
+// Records have no known region for setup stones, so this is how positions and SGF setup stones are injected.
+//
+// Each group is played outwards from a stone next to one of its liberties, so every group on the board
+// keeps a liberty it has in p and no move captures, is suicide or repeats a position, under any rules.
+// Positions with a group without liberties cannot be reached and are rejected.
+func (p *Position) moves() ([]Move, error) {
+	b := p.toBoard()
+	var stones [3][]Move
+	played := make([]bool, len(b.Points))
+	for i, s := range b.Points {
+		if s == Empty || played[i] {
+			continue
+		}
+		group, libs := b.group(i)
+		if libs == 0 {
+			return nil, fmt.Errorf("the group at %d,%d has no liberties", i%p.Size+1, i/p.Size+1)
+		}
+		root := group[0]
+		for _, q := range group {
+			if b.hasLiberty(q) {
+				root = q
+				break
+			}
+		}
+		played[root] = true
+		for queue := []int{root}; len(queue) > 0; queue = queue[1:] {
+			q := queue[0]
+			stones[s] = append(stones[s], newMove(s, int32(q%p.Size+1), int32(q/p.Size+1)))
+			for _, n := range b.neighbors(q) {
+				if b.Points[n] == s && !played[n] {
+					played[n] = true
+					queue = append(queue, n)
+				}
+			}
+		}
+	}
+
+	var ms []Move
+	for i := 0; i < len(stones[Black]) || i < len(stones[White]); i++ {
+		for _, s := range []Stone{Black, White} {
+			if i < len(stones[s]) {
+				ms = append(ms, stones[s][i])
+			} else {
+				ms = append(ms, newMove(s, 0, 0))
+			}
+		}
+	}
+	// Drop a trailing white pass, and pass once more if the wrong side is to move.
+	if len(ms) > 0 && ms[len(ms)-1].IsPass() {
+		ms = ms[:len(ms)-1]
+	}
+	next := Black
+	if len(ms)%2 == 1 {
+		next = White
+	}
+	if p.ToMove != Empty && p.ToMove != next {
+		ms = append(ms, newMove(next, 0, 0))
+	}
+	return ms, nil
+}
+
+// setPosition replaces the board of g with p.
+func (g *Game) setPosition(p *Position) error {
+	ms, err := p.moves()
+	if err != nil {
+		return err
+	}
+	g.Size = int32(p.Size)
+	g.Moves = ms
+	return nil
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// prefsDir is the directory of the app's settings, as saved by NSUserDefaults.
+const prefsDir = "Container/Library/Preferences/"
+
+// readPrefs returns the settings files of an archive, keyed by entry name.
+func readPrefs(ctx context.Context, avxName string) (map[string][]byte, error) {
+	a, err := openArchive(ctx, avxName)
+	if err != nil {
+		return nil, err
+	}
+	defer a.Close()
+
+	files := make(map[string][]byte)
+	for _, f := range a.Files {
+		if !strings.HasPrefix(f.Name, prefsDir) || path.Ext(f.Name) != ".plist" {
+			continue
+		}
+		b, err := f.ReadAll()
+		if err != nil {
+			return nil, err
+		}
+		files[f.Name] = b
+	}
+	return files, nil
+}
+
+// formatPlistValue formats a property list value on a single line.
+func formatPlistValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return strconv.Quote(v)
+	case []byte:
+		return fmt.Sprintf("<%d bytes>", len(v))
+	case []interface{}:
+		return fmt.Sprintf("<array of %d>", len(v))
+	case *plistDict:
+		return fmt.Sprintf("<dict of %d>", len(v.Keys))
+	}
+	return fmt.Sprint(v)
+}
+
+// parseLike parses s as a value of the same type as old.
+func parseLike(s string, old interface{}) (interface{}, error) {
+	switch old.(type) {
+	case int64:
+		return strconv.ParseInt(s, 0, 64)
+	case float64:
+		return strconv.ParseFloat(s, 64)
+	case bool:
+		return strconv.ParseBool(s)
+	case string, nil:
+		return s, nil
+	}
+	return nil, fmt.Errorf("cannot set a value of type %T", old)
+}
+
+// levelKeyRe matches the settings keys that may hold level progression. The app's actual key names are not known,
+// and keys such as a sound level match as well, so unlock-levels and reset-levels only list the keys it matches
+// and change those given with -key.
+var levelKeyRe = regexp.MustCompile(`(?i)level`)
+
+func prefsCmd(ctx context.Context, args []string) error {
+	usage := fmt.Errorf("usage: chamgo prefs list|set|unlock-levels|reset-levels -a archive [flags] [key value]")
+	if len(args) == 0 {
+		return usage
+	}
+	fs := flag.NewFlagSet("prefs "+args[0], flag.ContinueOnError)
+	avx := fs.String("a", "", "Champion Go archive")
+	file := fs.String("f", "", "the settings file to change, by default the only one or the one containing the key")
+	out := fs.String("o", "-", "output archive")
+	dryRun := fs.Bool("n", false, "only print the changes")
+	var keys stringList
+	fs.Var(&keys, "key", "with unlock-levels and reset-levels, a setting to change, may be repeated; without it, the candidate settings are listed")
+	if err := parseFlags(fs, args[1:]); err != nil {
+		return err
+	}
+
+	files, err := readPrefs(ctx, *avx)
+	if err != nil {
+		return err
+	}
+	var names []string
+	for n := range files {
+		if *file == "" || n == *file || path.Base(n) == *file {
+			names = append(names, n)
+		}
+	}
+	sort.Strings(names)
+	if len(names) == 0 {
+		return fmt.Errorf("no settings files found in %s", prefsDir)
+	}
+	dicts := make(map[string]*plistDict)
+	for _, n := range names {
+		v, err := parsePlist(files[n])
+		if err != nil {
+			return fmt.Errorf("%s: %w", n, err)
+		}
+		d, ok := v.(*plistDict)
+		if !ok {
+			return fmt.Errorf("%s: not a dictionary", n)
+		}
+		dicts[n] = d
+	}
+
+	changed := make(map[string]bool)
+	set := func(n, k string, v interface{}) {
+		old, _ := dicts[n].Get(k)
+		note(event{"event": "set", "file": n, "key": k, "old": plistJSON(old), "new": plistJSON(v)},
+			"%s: %s = %s -> %s", path.Base(n), k, formatPlistValue(old), formatPlistValue(v))
+		dicts[n].Set(k, v)
+		changed[n] = true
+	}
+	switch args[0] {
+	case "list":
+		result := make(map[string]interface{})
+		for _, n := range names {
+			result[n] = plistJSON(dicts[n])
+		}
+		output(result, func(w io.Writer) {
+			for _, n := range names {
+				fmt.Fprintln(w, n)
+				for _, k := range dicts[n].Keys {
+					fmt.Fprintf(w, "\t%s = %s\n", k, formatPlistValue(dicts[n].Values[k]))
+				}
+			}
+		})
+		return nil
+	case "set":
+		if fs.NArg() != 2 {
+			return usage
+		}
+		k := fs.Arg(0)
+		target := names[0]
+		for _, n := range names {
+			if _, ok := dicts[n].Get(k); ok {
+				target = n
+				break
+			}
+		}
+		if len(names) > 1 && *file == "" {
+			if _, ok := dicts[target].Get(k); !ok {
+				return fmt.Errorf("key %s not found, use -f to choose a settings file", k)
+			}
+		}
+		old, _ := dicts[target].Get(k)
+		v, err := parseLike(fs.Arg(1), old)
+		if err != nil {
+			return err
+		}
+		set(target, k, v)
+	case "unlock-levels", "reset-levels":
+		level := int64(10)
+		if args[0] == "reset-levels" {
+			level = 1
+		}
+		if len(keys) == 0 {
+			var candidates []string
+			for _, n := range names {
+				for _, k := range dicts[n].Keys {
+					if _, ok := dicts[n].Values[k].(int64); ok && levelKeyRe.MatchString(k) {
+						note(event{"event": "candidate", "file": n, "key": k, "value": plistJSON(dicts[n].Values[k])},
+							"%s: %s = %s", path.Base(n), k, formatPlistValue(dicts[n].Values[k]))
+						candidates = append(candidates, k)
+					}
+				}
+			}
+			if len(candidates) == 0 {
+				return fmt.Errorf("no level settings found, see chamgo prefs list")
+			}
+			return fmt.Errorf("the level settings are not known; give the ones to change with -key, as in -key %s", candidates[0])
+		}
+		for _, k := range keys {
+			found := false
+			for _, n := range names {
+				if old, ok := dicts[n].Get(k); ok {
+					if _, ok := old.(int64); !ok {
+						return fmt.Errorf("%s: %s is not an integer", path.Base(n), k)
+					}
+					set(n, k, level)
+					found = true
+				}
+			}
+			if !found {
+				return fmt.Errorf("key %s not found, see chamgo prefs list", k)
+			}
+		}
+	default:
+		return usage
+	}
+
+	if *dryRun {
+		return nil
+	}
+	replace := make(map[string][]byte)
+	for n := range changed {
+		var b bytes.Buffer
+		if err := writePlist(&b, dicts[n], files[n]); err != nil {
+			return fmt.Errorf("%s: %w", n, err)
+		}
+		replace[n] = b.Bytes()
+	}
+	return writeAvxFile(ctx, *out, *avx, avxEdit{Replace: replace})
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strconv"
+)
+
+// The format of the app's built-in problems is not known yet: chamgo problems list shows what the problem files of an
+// archive look like, and chamgo problems add only writes problems into a directory whose files are SGF or game records,
+// the two formats chamgo can write.
+
+// problemFile is a file of the app's problems.
+type problemFile struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	Format string `json:"format"` // sgf, record, plist, sqlite or unknown
+	Board  int    `json:"board,omitempty"`
+	Stones int    `json:"stones,omitempty"`
+}
+
+// sniffProblem returns the problem file name with the format of its contents b.
+func sniffProblem(name string, b []byte) problemFile {
+	p := problemFile{Name: name, Size: int64(len(b)), Format: "unknown"}
+	switch {
+	case bytes.HasPrefix(bytes.TrimSpace(b), []byte("(;")):
+		p.Format = "sgf"
+		if nodes, err := parseSGF(string(b)); err == nil {
+			if size, moves, err := sgfMoves(nodes[:1]); err == nil {
+				p.Board, p.Stones = size, countStones(moves)
+			}
+		}
+	case isBinaryPlist(b) || bytes.Contains(b[:min(len(b), 256)], []byte("<plist")):
+		p.Format = "plist"
+	case bytes.HasPrefix(b, []byte("SQLite format 3\x00")):
+		p.Format = "sqlite"
+	default:
+		if g, err := Decode(b); err == nil && len(g.problems(len(b))) == 0 {
+			p.Format, p.Board, p.Stones = "record", int(g.Size), countStones(g.Moves)
+		}
+	}
+	return p
+}
+
+func countStones(moves []Move) int {
+	n := 0
+	for _, m := range moves {
+		if !m.IsPass() {
+			n++
+		}
+	}
+	return n
+}
+
+// readProblems returns the problem files of the archive avxName with their contents.
+func readProblems(ctx context.Context, avxName string) ([]problemFile, []gameEntry, error) {
+	a, err := openArchive(ctx, avxName)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer a.Close()
+	var ps []problemFile
+	var entries []gameEntry
+	for _, f := range a.Files {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+		if f.IsDir || entryKind(f.Name) != "problems" {
+			continue
+		}
+		b, err := f.ReadAll()
+		if err != nil {
+			return nil, nil, err
+		}
+		ps = append(ps, sniffProblem(f.Name, b))
+		entries = append(entries, gameEntry{Name: f.Name, Body: b})
+	}
+	return ps, entries, nil
+}
+
+// problemRecord returns the record base with the position of the SGF problem s, without its solution.
+func problemRecord(s string, base []byte) ([]byte, error) {
+	nodes, err := parseSGF(s)
+	if err != nil {
+		return nil, invalid(err)
+	}
+	if len(nodes) == 0 {
+		return nil, invalid(fmt.Errorf("sgf: empty game"))
+	}
+	root := make(sgfNode)
+	for k, v := range nodes[0] {
+		root[k] = v
+	}
+	if len(root["PL"]) == 0 {
+		// The side to move is the player of the first move of the solution.
+		for _, n := range nodes[1:] {
+			if len(n["B"]) > 0 {
+				root["PL"] = []string{"B"}
+				break
+			}
+			if len(n["W"]) > 0 {
+				root["PL"] = []string{"W"}
+				break
+			}
+		}
+	}
+	size, moves, err := sgfMoves([]sgfNode{root})
+	if err != nil {
+		return nil, err
+	}
+	g, err := Decode(base)
+	if err != nil {
+		return nil, err
+	}
+	g.Size, g.Moves = int32(size), moves
+	return g.Encode(), nil
+}
+
+func problemsCmd(ctx context.Context, args []string) error {
+	usage := fmt.Errorf("usage: chamgo problems list|add -a archive [-dir directory] [-o out] [problems.sgf...]")
+	if len(args) == 0 {
+		return usage
+	}
+	fs := flag.NewFlagSet("problems "+args[0], flag.ContinueOnError)
+	avx := fs.String("a", "", "Champion Go archive")
+	out := fs.String("o", "-", "output archive")
+	dir := fs.String("dir", "", "the directory of problems to add to, when the archive has several")
+	if err := parseFlags(fs, args[1:]); err != nil {
+		return err
+	}
+
+	ps, entries, err := readProblems(ctx, *avx)
+	if err != nil {
+		return err
+	}
+	switch args[0] {
+	case "list":
+		output(ps, func(w io.Writer) {
+			for _, p := range ps {
+				fmt.Fprintf(w, "%s  %d bytes  %s", p.Name, p.Size, p.Format)
+				if p.Board > 0 {
+					fmt.Fprintf(w, "  %dx%d, %d stones", p.Board, p.Board, p.Stones)
+				}
+				fmt.Fprintln(w)
+			}
+		})
+		return nil
+	case "add":
+	default:
+		return usage
+	}
+	if fs.NArg() == 0 {
+		return usage
+	}
+
+	// Problems are added next to the existing ones, in their format.
+	dirs := make(map[string]bool)
+	for _, p := range ps {
+		dirs[path.Dir(p.Name)] = true
+	}
+	if *dir == "" {
+		if len(dirs) != 1 {
+			return fmt.Errorf("%d problem directories, choose one with -dir", len(dirs))
+		}
+		for d := range dirs {
+			*dir = d
+		}
+	}
+	*dir = path.Clean(*dir)
+	var format string
+	var base []byte
+	var siblings []gameEntry
+	for i, p := range ps {
+		if path.Dir(p.Name) != *dir {
+			continue
+		}
+		if format != "" && p.Format != format {
+			return unsupported(fmt.Errorf("%s holds problems in several formats", *dir))
+		}
+		format, base = p.Format, entries[i].Body
+		siblings = append(siblings, entries[i])
+	}
+	if format != "sgf" && format != "record" {
+		if format == "" {
+			return fmt.Errorf("%s has no problems", *dir)
+		}
+		return unsupported(fmt.Errorf("the problems of %s are stored as %s, which chamgo cannot write yet", *dir, format))
+	}
+	sort.Slice(siblings, func(i, j int) bool { return siblings[i].Name < siblings[j].Name })
+	ext := path.Ext(siblings[len(siblings)-1].Name)
+	next := nextGameNumber(siblings)
+
+	e := avxEdit{Add: make(map[string][]byte)}
+	for _, fn := range fs.Args() {
+		b, err := ioutil.ReadFile(fn)
+		if err != nil {
+			return err
+		}
+		games, err := splitSGF(string(b))
+		if err != nil {
+			return invalid(fmt.Errorf("%s: %w", fn, err))
+		}
+		for i, s := range games {
+			body := []byte(s)
+			if format == "record" {
+				if body, err = problemRecord(s, base); err != nil {
+					return fmt.Errorf("%s: problem %d: %w", fn, i+1, err)
+				}
+			}
+			name := *dir + "/" + strconv.Itoa(next) + ext
+			next++
+			e.Add[name] = body
+			note(event{"event": "add", "file": fn, "problem": i + 1, "to": name}, "%s: problem %d -> %s", fn, i+1, name)
+		}
+	}
+	return writeAvxFile(ctx, *out, *avx, e)
+}
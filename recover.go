@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+)
+
+// zipLocalHeader is the signature of the local file header in front of each entry of a zip file.
+var zipLocalHeader = []byte("PK\x03\x04")
+
+// salvaged is an entry found by scanning a damaged zip file.
+type salvaged struct {
+	Name string
+	Body []byte
+	Err  error // why the entry could not be read, if it could not
+}
+
+// scanZip finds the entries of a zip file by their local file headers, without using the central directory
+// at the end of the file, which is what goes missing when a transfer is interrupted.
+func scanZip(ctx context.Context, b []byte) ([]salvaged, error) {
+	var found []salvaged
+	for off := 0; ; {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		i := bytes.Index(b[off:], zipLocalHeader)
+		if i < 0 {
+			return found, nil
+		}
+		off += i
+		h := b[off:]
+		if len(h) < 30 {
+			return found, nil
+		}
+		flags := binary.LittleEndian.Uint16(h[6:])
+		method := binary.LittleEndian.Uint16(h[8:])
+		crc := binary.LittleEndian.Uint32(h[14:])
+		csize := int(binary.LittleEndian.Uint32(h[18:]))
+		usize := int(binary.LittleEndian.Uint32(h[22:]))
+		nlen := int(binary.LittleEndian.Uint16(h[26:]))
+		elen := int(binary.LittleEndian.Uint16(h[28:]))
+		start := 30 + nlen + elen
+		if start > len(h) {
+			return found, nil
+		}
+		s := salvaged{Name: string(h[30 : 30+nlen])}
+		data := h[start:]
+		sized := flags&0x8 == 0 // sizes are in the header rather than in a data descriptor after the data
+		if sized && csize <= len(data) {
+			data = data[:csize]
+		}
+		switch {
+		case strings.HasSuffix(s.Name, "/"):
+		case flags&0x1 != 0:
+			s.Err = fmt.Errorf("encrypted")
+		case method == 0 && sized:
+			if csize > len(data) {
+				s.Err = fmt.Errorf("truncated")
+			}
+			s.Body = data
+		case method == 0:
+			s.Err = fmt.Errorf("stored without sizes")
+		case method == 8:
+			// Deflate streams end by themselves, so entries can be read even without sizes.
+			fr := flate.NewReader(bytes.NewReader(data))
+			s.Body, s.Err = ioutil.ReadAll(fr)
+			fr.Close()
+		default:
+			s.Err = fmt.Errorf("unsupported compression method %d", method)
+		}
+		if s.Err == nil && sized && !strings.HasSuffix(s.Name, "/") {
+			if len(s.Body) != usize || crc32.ChecksumIEEE(s.Body) != crc {
+				s.Err = fmt.Errorf("checksum mismatch")
+			}
+		}
+		if s.Err != nil {
+			s.Body = nil
+		}
+		found = append(found, s)
+		if s.Err == nil && sized {
+			off += start + csize
+		} else {
+			off += len(zipLocalHeader)
+		}
+	}
+}
+
+func recoverCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("recover", flag.ContinueOnError)
+	avx := fs.String("a", "", "damaged Champion Go zip archive")
+	out := fs.String("o", "-", "output archive of the salvaged entries")
+	dryRun := fs.Bool("n", false, "only report what can be recovered")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	b, err := ioutil.ReadFile(*avx)
+	if err != nil {
+		return err
+	}
+	found, err := scanZip(ctx, b)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempDir("", "chamgo-recover-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	type result struct {
+		Entry string `json:"entry"`
+		Game  bool   `json:"game"`
+		Error string `json:"error,omitempty"`
+	}
+	var results []result
+	games, salvagedGames := 0, 0
+	for _, s := range found {
+		if strings.HasSuffix(s.Name, "/") {
+			continue
+		}
+		isGame := (inDir(s.Name, gameDir(false)) || inDir(s.Name, gameDir(true))) && path.Ext(s.Name) == ".game"
+		if isGame {
+			games++
+			if s.Err == nil {
+				if _, err := Decode(s.Body); err != nil {
+					s.Err = err
+				}
+			}
+		}
+		r := result{Entry: s.Name, Game: isGame}
+		if s.Err != nil {
+			r.Error = s.Err.Error()
+			note(event{"event": "lost", "entry": s.Name, "game": isGame, "error": r.Error}, "lost %s: %v", s.Name, s.Err)
+		} else {
+			if isGame {
+				salvagedGames++
+				note(event{"event": "recovered", "entry": s.Name, "game": true}, "recovered %s", s.Name)
+			}
+			dst, err := safeJoin(tmp, s.Name)
+			if err != nil {
+				return err
+			}
+			if err := writeFileAll(dst, s.Body); err != nil {
+				return err
+			}
+		}
+		results = append(results, r)
+	}
+	output(results, func(w io.Writer) {})
+	note(event{"event": "summary", "entries": len(results), "games": games, "recovered": salvagedGames},
+		"%d entries found, %d of %d games recovered", len(results), salvagedGames, games)
+	if salvagedGames == 0 {
+		return errNoGames
+	}
+	if *dryRun {
+		return nil
+	}
+	return writeAvxFile(ctx, *out, tmp, avxEdit{NoJournal: true})
+}
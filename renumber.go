@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// renumberGames returns new names for the local games of an archive,
+// numbering them 0, 1, 2... in the order they were saved, keeping their extensions.
+// Online games are not renumbered, since the app finds them through the Game Center rather than by listing their directory.
+func renumberGames(ctx context.Context, avxName string) (map[string]string, error) {
+	games, err := listGames(ctx, avxName, false)
+	if err != nil {
+		return nil, err
+	}
+	type dated struct {
+		name  string
+		saved int32
+	}
+	var ds []dated
+	for _, g := range games {
+		saved, err := getSavedDate(g.Body)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", g.Name, err)
+		}
+		ds = append(ds, dated{g.Name, saved})
+	}
+	sort.SliceStable(ds, func(i, j int) bool { return ds[i].saved < ds[j].saved })
+
+	rename := make(map[string]string)
+	for i, d := range ds {
+		n := gameDir(false) + strconv.Itoa(i) + path.Ext(d.name)
+		if n != d.name {
+			rename[d.name] = n
+		}
+	}
+	return rename, nil
+}
+
+// checkRenames refuses the renames of the games of the archive avxName onto entries that are not renamed themselves,
+// and of games that other entries refer to, such as a settings file naming the last game played, which would be left
+// pointing at another game or at nothing.
+func checkRenames(ctx context.Context, avxName string, rename map[string]string) error {
+	if len(rename) == 0 {
+		return nil
+	}
+	a, err := openArchive(ctx, avxName)
+	if err != nil {
+		return err
+	}
+	var taken []string
+	for _, f := range a.Files {
+		if _, renamed := rename[f.Name]; !renamed {
+			for _, to := range rename {
+				if f.Name == to {
+					taken = append(taken, fmt.Sprintf("%s (by %s)", to, f.Name))
+				}
+			}
+		}
+	}
+	a.Close()
+	if len(taken) > 0 {
+		sort.Strings(taken)
+		return invalid(fmt.Errorf("the new names are already taken: %s", strings.Join(taken, ", ")))
+	}
+
+	refs, err := entryRefs(ctx, avxName, sortedKeys(rename), func(name string) bool { return !isGameFile(name, false) && !isGameFile(name, true) })
+	if err != nil {
+		return err
+	}
+	var referred []string
+	for _, name := range sortedKeys(refs) {
+		referred = append(referred, fmt.Sprintf("%s (in %s)", name, refs[name]))
+	}
+	if len(referred) > 0 {
+		return invalid(fmt.Errorf("other entries refer to %s, which would not follow the renames", strings.Join(referred, ", ")))
+	}
+	return nil
+}
+
+func renumberCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("renumber", flag.ContinueOnError)
+	avx := fs.String("a", "", "Champion Go archive")
+	out := fs.String("o", "-", "output archive")
+	dryRun := fs.Bool("n", false, "only print the new names")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	rename, err := renumberGames(ctx, *avx)
+	if err != nil {
+		return err
+	}
+	if err := checkRenames(ctx, *avx, rename); err != nil {
+		return err
+	}
+	var names []string
+	for n := range rename {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		note(event{"event": "rename", "from": n, "to": rename[n]}, "%s -> %s", n, rename[n])
+	}
+	if *dryRun {
+		return nil
+	}
+	return writeAvxFile(ctx, *out, *avx, avxEdit{Rename: rename})
+}
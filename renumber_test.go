@@ -0,0 +1,82 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRenumberGames(t *testing.T) {
+	const dir = "Container/Documents/game/"
+	pref := "Container/Library/Preferences/com.example.plist"
+	prefs := func(game string) string {
+		return `<?xml version="1.0" encoding="UTF-8"?>
+<plist version="1.0"><dict><key>lastGame</key><string>` + game + `</string></dict></plist>`
+	}
+	tests := []struct {
+		name  string
+		saved map[string]int32 // the saved dates of the games
+		other map[string]string
+		want  map[string]string
+		err   string
+	}{
+		{"numbered", map[string]int32{"0": 10, "1": 20, "2": 30}, nil, map[string]string{}, ""},
+		{"saved order", map[string]int32{"7": 30, "3": 10, "12": 20}, nil,
+			map[string]string{dir + "3": dir + "0", dir + "12": dir + "1", dir + "7": dir + "2"}, ""},
+		{"gap", map[string]int32{"0": 10, "2": 20}, nil, map[string]string{dir + "2": dir + "1"}, ""},
+		{"swap", map[string]int32{"0": 20, "1": 10}, nil, map[string]string{dir + "0": dir + "1", dir + "1": dir + "0"}, ""},
+		{"extensions", map[string]int32{"b.game": 20, "a.game": 10}, nil,
+			map[string]string{dir + "a.game": dir + "0.game", dir + "b.game": dir + "1.game"}, ""},
+		{"online games stay", map[string]int32{"1": 10}, map[string]string{"Container/Documents/game-online/5": ""},
+			map[string]string{dir + "1": dir + "0"}, ""},
+		{"referred to", map[string]int32{"4": 10}, map[string]string{pref: prefs("game/4")},
+			nil, "other entries refer to " + dir + "4 (in " + pref + ")"},
+		{"not referred to", map[string]int32{"4": 10}, map[string]string{pref: prefs("game/0")},
+			map[string]string{dir + "4": dir + "0"}, ""},
+	}
+	ctx := context.Background()
+	for _, tt := range tests {
+		avx := filepath.Join(t.TempDir(), "in.imazingapp")
+		f, err := os.Create(avx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		zw := zip.NewWriter(f)
+		r := rand.New(rand.NewSource(1))
+		for name, saved := range tt.saved {
+			g := synthGame(r, currentLayout, 9, 4, synthEpoch)
+			g.Saved = saved
+			w, _ := zw.Create(dir + name)
+			w.Write(g.Encode())
+		}
+		for name, body := range tt.other {
+			if body == "" {
+				body = string(synthGame(r, currentLayout, 9, 4, synthEpoch).Encode())
+			}
+			w, _ := zw.Create(name)
+			w.Write([]byte(body))
+		}
+		zw.Close()
+		f.Close()
+
+		rename, err := renumberGames(ctx, avx)
+		if err == nil {
+			err = checkRenames(ctx, avx, rename)
+		}
+		switch {
+		case tt.err != "":
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: %v, want %s", tt.name, err, tt.err)
+			}
+		case err != nil:
+			t.Errorf("%s: %v", tt.name, err)
+		case !reflect.DeepEqual(rename, tt.want):
+			t.Errorf("%s: %v, want %v", tt.name, rename, tt.want)
+		}
+	}
+}
@@ -0,0 +1,488 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// progressReport summarizes the games saved in a period.
+type progressReport struct {
+	Since    time.Time      `json:"since"`
+	Until    time.Time      `json:"until"`
+	Games    int            `json:"games"`
+	Moves    int            `json:"moves"`
+	Analyzed int            `json:"analyzed"` // games with a cached analysis
+	Levels   []levelStats   `json:"levels"`
+	Openings []openingStats `json:"openings"`
+	Trend    []trendStats   `json:"trend"`
+	Blunders []blunder      `json:"blunders"`
+}
+
+// levelStats are the games against one level of the computer, or between two humans for level "human".
+type levelStats struct {
+	Level string `json:"level"`
+	periodStats
+}
+
+// openingStats are the games of one opening, as classified by openingOf.
+type openingStats struct {
+	Opening string `json:"opening"`
+	periodStats
+}
+
+// trendStats are the games of a day, or of a week in reports longer than two weeks.
+type trendStats struct {
+	Start time.Time `json:"start"`
+	periodStats
+}
+
+type periodStats struct {
+	Games     int      `json:"games"`
+	Moves     int      `json:"moves"`
+	MatchRate *float64 `json:"match_rate,omitempty"`
+	MeanLoss  *float64 `json:"mean_loss,omitempty"`
+
+	analysis gameAnalysis // the analyzed moves of the games, for the rates
+}
+
+func (s *periodStats) add(g *Game, a *gameAnalysis) {
+	s.Games++
+	s.Moves += len(g.Moves)
+	if a != nil {
+		s.analysis.Moves = append(s.analysis.Moves, a.Moves...)
+	}
+}
+
+func (s *periodStats) finish() {
+	if len(s.analysis.Moves) == 0 {
+		return
+	}
+	r := s.analysis.matchRate()
+	s.MatchRate = &r
+	if l, ok := s.analysis.meanLoss(); ok {
+		s.MeanLoss = &l
+	}
+}
+
+// blunder is a move that lost many points by the engine's estimate.
+type blunder struct {
+	Game  string    `json:"game"`
+	Saved time.Time `json:"saved"`
+	moveAnalysis
+}
+
+// findAnalysis returns the cached analysis of the game with moves hash by the engine of -engine,
+// or by any engine if -engine is not given.
+func findAnalysis(hash string) *gameAnalysis {
+	if engineSpec != "" {
+		return cachedAnalysis(hash, engineSpec)
+	}
+	fn, err := analysisPath(hash, "")
+	if err != nil {
+		return nil
+	}
+	fns, _ := filepath.Glob(strings.TrimSuffix(fn, "-.json") + "-*.json")
+	sort.Strings(fns)
+	for _, fn := range fns {
+		b, err := ioutil.ReadFile(fn)
+		if err != nil {
+			continue
+		}
+		var a gameAnalysis
+		if json.Unmarshal(b, &a) == nil {
+			return &a
+		}
+	}
+	return nil
+}
+
+// trendStart returns the start of the day, or of the week from Monday if weekly, holding t.
+func trendStart(t time.Time, weekly bool) time.Time {
+	t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	if weekly {
+		t = t.AddDate(0, 0, -(int(t.Weekday())+6)%7)
+	}
+	return t
+}
+
+func buildReport(ctx context.Context, avx string, since, until time.Time, opening string, nblunders int) (*progressReport, error) {
+	r := &progressReport{Since: since, Until: until}
+	levels := make(map[string]*levelStats)
+	openings := make(map[string]*openingStats)
+	trend := make(map[time.Time]*trendStats)
+	weekly := until.Sub(since) > 14*24*time.Hour
+	for _, online := range []bool{false, true} {
+		games, err := listGames(ctx, avx, online)
+		if err != nil {
+			return nil, err
+		}
+		for _, ge := range games {
+			g, err := Decode(ge.Body)
+			if err != nil {
+				note(event{"event": "skip", "entry": ge.Name, "error": err.Error()}, "skipping %s: %v", ge.Name, err)
+				continue
+			}
+			saved := time.Unix(int64(g.Saved), 0).Local()
+			if saved.Before(since) || !saved.Before(until) {
+				continue
+			}
+			o := openingOf(g)
+			if opening != "" && !strings.EqualFold(o, opening) {
+				continue
+			}
+			a := findAnalysis(movesHash(ge.Body))
+			r.Games++
+			r.Moves += len(g.Moves)
+			if a != nil {
+				r.Analyzed++
+				for _, m := range a.Moves {
+					if m.Loss != nil && *m.Loss > 0 {
+						r.Blunders = append(r.Blunders, blunder{Game: ge.Name, Saved: saved, moveAnalysis: m})
+					}
+				}
+			}
+
+			level := "human"
+			if g.Mode == ModeComputer {
+				level = fmt.Sprint(g.Level)
+			}
+			if levels[level] == nil {
+				levels[level] = &levelStats{Level: level}
+			}
+			levels[level].add(g, a)
+			if openings[o] == nil {
+				openings[o] = &openingStats{Opening: o}
+			}
+			openings[o].add(g, a)
+			start := trendStart(saved, weekly)
+			if trend[start] == nil {
+				trend[start] = &trendStats{Start: start}
+			}
+			trend[start].add(g, a)
+		}
+	}
+
+	for _, l := range levels {
+		l.finish()
+		r.Levels = append(r.Levels, *l)
+	}
+	sort.Slice(r.Levels, func(i, j int) bool {
+		a, b := r.Levels[i].Level, r.Levels[j].Level
+		return len(a) < len(b) || len(a) == len(b) && a < b
+	})
+	for _, o := range openings {
+		o.finish()
+		r.Openings = append(r.Openings, *o)
+	}
+	sort.Slice(r.Openings, func(i, j int) bool {
+		a, b := r.Openings[i], r.Openings[j]
+		return a.Games > b.Games || a.Games == b.Games && a.Opening < b.Opening
+	})
+	for _, s := range trend {
+		s.finish()
+		r.Trend = append(r.Trend, *s)
+	}
+	sort.Slice(r.Trend, func(i, j int) bool { return r.Trend[i].Start.Before(r.Trend[j].Start) })
+	sort.SliceStable(r.Blunders, func(i, j int) bool { return *r.Blunders[i].Loss > *r.Blunders[j].Loss })
+	r.Blunders = r.Blunders[:min(nblunders, len(r.Blunders))]
+	return r, nil
+}
+
+var reportFuncs = map[string]interface{}{
+	"date":    func(t time.Time) string { return t.Format("2006-01-02") },
+	"last":    func(t time.Time) string { return t.AddDate(0, 0, -1).Format("2006-01-02") }, // of periods ending before t
+	"percent": func(f *float64) string { return fmt.Sprintf("%.1f%%", 100**f) },
+	"points":  func(f *float64) string { return fmt.Sprintf("%.2f", *f) },
+}
+
+const reportMarkdown = `# Champion Go, {{date .Since}} to {{last .Until}}
+
+{{.Games}} games, {{.Moves}} moves. {{.Analyzed}} games are analyzed, by chamgo accuracy.
+Game records do not say who won, so no results are given.
+
+## By level
+
+| Level | Games | Moves | Engine match | Points lost a move |
+|---|---|---|---|---|
+{{range .Levels}}| {{.Level}} | {{.Games}} | {{.Moves}} | {{with .MatchRate}}{{percent .}}{{end}} | {{with .MeanLoss}}{{points .}}{{end}} |
+{{end}}
+## By opening
+
+| Opening | Games | Moves | Engine match | Points lost a move |
+|---|---|---|---|---|
+{{range .Openings}}| {{.Opening}} | {{.Games}} | {{.Moves}} | {{with .MatchRate}}{{percent .}}{{end}} | {{with .MeanLoss}}{{points .}}{{end}} |
+{{end}}
+## Trend
+
+| From | Games | Moves | Engine match | Points lost a move |
+|---|---|---|---|---|
+{{range .Trend}}| {{date .Start}} | {{.Games}} | {{.Moves}} | {{with .MatchRate}}{{percent .}}{{end}} | {{with .MeanLoss}}{{points .}}{{end}} |
+{{end}}{{if .Blunders}}
+## Blunders
+
+| Game | Saved | Move | Played | Engine | Points lost |
+|---|---|---|---|---|---|
+{{range .Blunders}}| {{.Game}} | {{date .Saved}} | {{.Move}} | {{.Played}} | {{.Best}} | {{points .Loss}} |
+{{end}}{{end}}`
+
+const reportHTML = `<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>Champion Go, {{date .Since}} to {{last .Until}}</title>
+<style>body{font-family:sans-serif;max-width:50em;margin:auto}table{border-collapse:collapse}td,th{border:1px solid #ccc;padding:.2em .6em;text-align:right}</style>
+</head><body>
+<h1>Champion Go, {{date .Since}} to {{last .Until}}</h1>
+<p>{{.Games}} games, {{.Moves}} moves. {{.Analyzed}} games are analyzed, by chamgo accuracy.
+Game records do not say who won, so no results are given.</p>
+<h2>By level</h2>
+<table><tr><th>Level</th><th>Games</th><th>Moves</th><th>Engine match</th><th>Points lost a move</th></tr>
+{{range .Levels}}<tr><td>{{.Level}}</td><td>{{.Games}}</td><td>{{.Moves}}</td><td>{{with .MatchRate}}{{percent .}}{{end}}</td><td>{{with .MeanLoss}}{{points .}}{{end}}</td></tr>
+{{end}}</table>
+<h2>By opening</h2>
+<table><tr><th>Opening</th><th>Games</th><th>Moves</th><th>Engine match</th><th>Points lost a move</th></tr>
+{{range .Openings}}<tr><td>{{.Opening}}</td><td>{{.Games}}</td><td>{{.Moves}}</td><td>{{with .MatchRate}}{{percent .}}{{end}}</td><td>{{with .MeanLoss}}{{points .}}{{end}}</td></tr>
+{{end}}</table>
+<h2>Trend</h2>
+<table><tr><th>From</th><th>Games</th><th>Moves</th><th>Engine match</th><th>Points lost a move</th></tr>
+{{range .Trend}}<tr><td>{{date .Start}}</td><td>{{.Games}}</td><td>{{.Moves}}</td><td>{{with .MatchRate}}{{percent .}}{{end}}</td><td>{{with .MeanLoss}}{{points .}}{{end}}</td></tr>
+{{end}}</table>
+{{if .Blunders}}<h2>Blunders</h2>
+<table><tr><th>Game</th><th>Saved</th><th>Move</th><th>Played</th><th>Engine</th><th>Points lost</th></tr>
+{{range .Blunders}}<tr><td>{{.Game}}</td><td>{{date .Saved}}</td><td>{{.Move}}</td><td>{{.Played}}</td><td>{{.Best}}</td><td>{{points .Loss}}</td></tr>
+{{end}}</table>
+{{end}}</body></html>
+`
+
+// gameReport is the report of a single game, written as Markdown with its diagrams as SVG files beside it.
+type gameReport struct {
+	Name     string        `json:"name"`
+	Size     int32         `json:"size"`
+	Level    string        `json:"level,omitempty"`
+	Started  time.Time     `json:"started,omitempty"`
+	Saved    time.Time     `json:"saved,omitempty"`
+	Moves    int           `json:"moves"`
+	Analysis *gameAnalysis `json:"analysis,omitempty"`
+	Figures  []reportImage `json:"figures"`
+	Blunders []reportImage `json:"blunders"`
+	Files    []string      `json:"files"` // the files written
+}
+
+// reportImage is a diagram of a game report and the file of its image.
+type reportImage struct {
+	Title string `json:"title"`
+	Image string `json:"image"`
+	Note  string `json:"note,omitempty"`
+
+	svg []byte
+}
+
+// buildGameReport returns the report of g, with a diagram every figureMoves moves and of its nblunders worst moves
+// if chamgo accuracy analyzed it. The images are named after base.
+func buildGameReport(name, base string, g *Game, figureMoves, nblunders int) (*gameReport, error) {
+	r := &gameReport{Name: name, Size: g.Size, Level: "human", Moves: len(g.Moves), Analysis: findAnalysis(g.movesHash())}
+	if g.Mode == ModeComputer {
+		r.Level = fmt.Sprint(g.Level)
+	}
+	if g.Started > 0 {
+		r.Started = time.Unix(int64(g.Started), 0).Local()
+	}
+	if g.Saved > 0 {
+		r.Saved = time.Unix(int64(g.Saved), 0).Local()
+	}
+	for from := 1; from <= len(g.Moves); from += figureMoves {
+		f, err := makeFigure(g, from, from+figureMoves-1)
+		if err != nil {
+			return nil, err
+		}
+		var b bytes.Buffer
+		f.SVG(&b)
+		r.Figures = append(r.Figures, reportImage{
+			Title: fmt.Sprintf("Moves %d–%d", f.From, f.To),
+			Image: fmt.Sprintf("%s-%d-%d.svg", base, f.From, f.To),
+			Note:  strings.Join(f.At, ", "),
+			svg:   b.Bytes(),
+		})
+	}
+	if r.Analysis == nil {
+		return r, nil
+	}
+	var worst []moveAnalysis
+	for _, m := range r.Analysis.Moves {
+		if m.Loss != nil && *m.Loss > 0 && m.Move >= 1 && m.Move <= len(g.Moves) {
+			worst = append(worst, m)
+		}
+	}
+	sort.SliceStable(worst, func(i, j int) bool { return *worst[i].Loss > *worst[j].Loss })
+	for _, m := range worst[:min(nblunders, len(worst))] {
+		b, err := replay(&Game{Size: g.Size, Moves: g.Moves[:m.Move-1]})
+		if err != nil {
+			return nil, err
+		}
+		var marks []svgMark
+		if x, y, err := parseVertex(m.Best, b.Size); err == nil {
+			marks = append(marks, svgMark{X: x, Y: y, Color: "#080", Label: "A"})
+		}
+		if x, y, err := parseVertex(m.Played, b.Size); err == nil {
+			marks = append(marks, svgMark{X: x, Y: y, Color: "#d00", Label: "X"})
+		}
+		var svg bytes.Buffer
+		b.SVG(&svg, marks...)
+		r.Blunders = append(r.Blunders, reportImage{
+			Title: fmt.Sprintf("Move %d: %s, %.1f points lost", m.Move, m.Played, *m.Loss),
+			Image: fmt.Sprintf("%s-move-%d.svg", base, m.Move),
+			Note:  fmt.Sprintf("A: the engine's %s. X: the played %s.", m.Best, m.Played),
+			svg:   svg.Bytes(),
+		})
+	}
+	return r, nil
+}
+
+const gameReportMarkdown = `# {{.Name}}
+
+{{.Size}}×{{.Size}}{{if eq .Level "human"}}, between two humans{{else if .Level}}, against level {{.Level}}{{end}}, {{.Moves}} moves.
+{{- if not .Started.IsZero}} Started {{date .Started}}.{{end}}{{if not .Saved.IsZero}} Saved {{date .Saved}}.{{end}}
+{{with .Analysis}}
+By {{.Engine}}, the player matched {{percent (matchRate .)}} of the engine's moves{{with meanLoss .}}, losing {{points .}} points a move{{end}}.
+{{end}}
+## Game
+{{range .Figures}}
+### {{.Title}}
+
+![{{.Title}}]({{.Image}})
+{{with .Note}}
+{{.}}
+{{end}}{{end}}{{if .Blunders}}
+## Blunders
+{{range .Blunders}}
+### {{.Title}}
+
+![{{.Title}}]({{.Image}})
+
+{{.Note}}
+{{end}}{{end}}`
+
+// writeGameReport writes the report of the game of slot in avx, or of the SGF file of args, as Markdown in dir,
+// with its diagrams as SVG files.
+func writeGameReport(ctx context.Context, avx, slot string, args []string, dir string, figureMoves, nblunders int) error {
+	name, g, err := loadGame(ctx, avx, slot, args)
+	if err != nil {
+		return err
+	}
+	base := strings.TrimSuffix(filepath.Base(name), filepath.Ext(name))
+	r, err := buildGameReport(name, base, g, figureMoves, nblunders)
+	if err != nil {
+		return err
+	}
+	if len(args) > 0 {
+		r.Level = "" // SGF files have no level
+	}
+	funcs := map[string]interface{}{
+		"matchRate": func(a *gameAnalysis) *float64 { f := a.matchRate(); return &f },
+		"meanLoss": func(a *gameAnalysis) *float64 {
+			if f, ok := a.meanLoss(); ok {
+				return &f
+			}
+			return nil
+		},
+	}
+	for k, v := range reportFuncs {
+		funcs[k] = v
+	}
+	var md bytes.Buffer
+	if err := template.Must(template.New("game").Funcs(funcs).Parse(gameReportMarkdown)).Execute(&md, r); err != nil {
+		return err
+	}
+	for _, im := range append(r.Figures, r.Blunders...) {
+		fn := filepath.Join(dir, im.Image)
+		if err := writeFileAll(fn, im.svg); err != nil {
+			return err
+		}
+		r.Files = append(r.Files, fn)
+	}
+	fn := filepath.Join(dir, base+".md")
+	if err := writeFileAll(fn, md.Bytes()); err != nil {
+		return err
+	}
+	r.Files = append(r.Files, fn)
+	output(r, func(w io.Writer) { fmt.Fprintf(w, "wrote %s and %d images\n", fn, len(r.Files)-1) })
+	return nil
+}
+
+func reportCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("report", flag.ContinueOnError)
+	avx := fs.String("a", "", "Champion Go archive")
+	period := fs.String("period", "week", "the period reported on, week or month, ending with -until")
+	sinceFlag := fs.String("since", "", "the first day reported on, as 2006-01-02, instead of -period")
+	untilFlag := fs.String("until", "", "the day after the period, as 2006-01-02, by default tomorrow")
+	format := fs.String("format", "md", "md for Markdown or html")
+	nblunders := fs.Int("blunders", 5, "the number of blunders listed")
+	opening := fs.String("opening", "", "report only on the games with this opening, as hoshi, komoku or sanrensei")
+	slot := fs.String("g", "", `report on a single game instead: an entry name, "latest" or "latest-online"`)
+	dir := fs.String("o", ".", "the directory of the Markdown file and images of a game report")
+	figureMoves := fs.Int("figure", 50, "the moves of each diagram of a game report")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *slot != "" || fs.NArg() > 0 {
+		if *format != "md" {
+			return fmt.Errorf("game reports are only written as Markdown")
+		}
+		if *figureMoves < 1 {
+			return fmt.Errorf("-figure must be positive")
+		}
+		return writeGameReport(ctx, *avx, *slot, fs.Args(), *dir, *figureMoves, *nblunders)
+	}
+
+	until := trendStart(time.Now(), false).AddDate(0, 0, 1)
+	if *untilFlag != "" {
+		t, err := time.ParseInLocation("2006-01-02", *untilFlag, time.Local)
+		if err != nil {
+			return fmt.Errorf("-until: %w", err)
+		}
+		until = t
+	}
+	var since time.Time
+	switch {
+	case *sinceFlag != "":
+		t, err := time.ParseInLocation("2006-01-02", *sinceFlag, time.Local)
+		if err != nil {
+			return fmt.Errorf("-since: %w", err)
+		}
+		since = t
+	case *period == "week":
+		since = until.AddDate(0, 0, -7)
+	case *period == "month":
+		since = until.AddDate(0, -1, 0)
+	default:
+		return fmt.Errorf("-period must be week or month")
+	}
+
+	var render func(w io.Writer, r *progressReport) error
+	switch *format {
+	case "md":
+		t := template.Must(template.New("report").Funcs(reportFuncs).Parse(reportMarkdown))
+		render = func(w io.Writer, r *progressReport) error { return t.Execute(w, r) }
+	case "html":
+		t := htmltemplate.Must(htmltemplate.New("report").Funcs(reportFuncs).Parse(reportHTML))
+		render = func(w io.Writer, r *progressReport) error { return t.Execute(w, r) }
+	default:
+		return fmt.Errorf("-format must be md or html")
+	}
+
+	r, err := buildReport(ctx, *avx, since, until, *opening, *nblunders)
+	if err != nil {
+		return err
+	}
+	var rerr error
+	output(r, func(w io.Writer) { rerr = render(w, r) })
+	return rerr
+}
@@ -0,0 +1,389 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// resultsName is the SQLite database in $CHAMGO_HOME of the games database, which chamgo only keeps once it exists.
+const resultsName = "games.db"
+
+// seenGame is the entry of a game in the games database, keyed by movesHash,
+// so that a game still being played gets a new entry each time it is seen with more moves.
+type seenGame struct {
+	Hash      string           `json:"hash"`
+	FirstSeen time.Time        `json:"first_seen"`
+	LastSeen  time.Time        `json:"last_seen"`
+	Archive   string           `json:"archive"` // where it was last seen
+	Entry     string           `json:"entry"`
+	Mode      Mode             `json:"mode"`
+	Size      int32            `json:"size"`
+	Color     int32            `json:"color"`
+	Level     int32            `json:"level"`
+	Started   time.Time        `json:"started"`
+	Saved     time.Time        `json:"saved"`
+	Moves     int              `json:"moves"`
+	Opening   string           `json:"opening,omitempty"` // as classified by openingOf
+	Result    string           `json:"result,omitempty"`  // as in SGF RE, set with chamgo games result as records do not store it
+	Analysis  *analysisSummary `json:"analysis,omitempty"`
+}
+
+// analysisSummary is the outcome of chamgo accuracy for a game.
+type analysisSummary struct {
+	Engine    string    `json:"engine"`
+	Analyzed  time.Time `json:"analyzed"`
+	Moves     int       `json:"moves"`
+	MatchRate float64   `json:"match_rate"`
+	MeanLoss  *float64  `json:"mean_loss,omitempty"`
+}
+
+// resultsColumns are the columns of the games table of the games database, a row for each seenGame.
+// Times are in Unix seconds, and the analysis columns are NULL for games not analyzed.
+var resultsColumns = []string{"hash TEXT NOT NULL", "first_seen INTEGER", "last_seen INTEGER", "archive TEXT", "entry TEXT",
+	"mode INTEGER", "size INTEGER", "color INTEGER", "level INTEGER", "started INTEGER", "saved INTEGER", "moves INTEGER",
+	"opening TEXT", "result TEXT", "engine TEXT", "analyzed INTEGER", "analyzed_moves INTEGER", "match_rate REAL", "mean_loss REAL"}
+
+// resultsIndexes look games up by the beginning of their hash and by when they were saved.
+var resultsIndexes = []sqliteIndex{
+	{Name: "games_hash", Unique: true, Columns: []int{0}},
+	{Name: "games_saved", Columns: []int{10}},
+}
+
+func sqlTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t.Unix()
+}
+
+func (g *seenGame) row() []interface{} {
+	r := []interface{}{g.Hash, sqlTime(g.FirstSeen), sqlTime(g.LastSeen), sqlNull(g.Archive), sqlNull(g.Entry),
+		int64(g.Mode), int64(g.Size), int64(g.Color), int64(g.Level), sqlTime(g.Started), sqlTime(g.Saved), g.Moves,
+		sqlNull(g.Opening), sqlNull(g.Result), nil, nil, nil, nil, nil}
+	if a := g.Analysis; a != nil {
+		r[14], r[15], r[16], r[17] = a.Engine, sqlTime(a.Analyzed), a.Moves, a.MatchRate
+		if a.MeanLoss != nil {
+			r[18] = *a.MeanLoss
+		}
+	}
+	return r
+}
+
+func seenGameOf(r sqliteRow) *seenGame {
+	times := func(i int) time.Time {
+		if i >= len(r.Values) || r.Values[i] == nil {
+			return time.Time{}
+		}
+		return time.Unix(r.int(i), 0).UTC()
+	}
+	g := &seenGame{Hash: r.str(0), FirstSeen: times(1), LastSeen: times(2), Archive: r.str(3), Entry: r.str(4),
+		Mode: Mode(r.int(5)), Size: int32(r.int(6)), Color: int32(r.int(7)), Level: int32(r.int(8)),
+		Started: times(9), Saved: times(10), Moves: int(r.int(11)), Opening: r.str(12), Result: r.str(13)}
+	if analyzed := times(15); !analyzed.IsZero() {
+		g.Analysis = &analysisSummary{Engine: r.str(14), Analyzed: analyzed, Moves: int(r.int(16))}
+		g.Analysis.MatchRate, _ = r.float(17)
+		if l, ok := r.float(18); ok {
+			g.Analysis.MeanLoss = &l
+		}
+	}
+	return g
+}
+
+func resultsPath() (string, error) {
+	home, err := chamgoPath("")
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, resultsName), nil
+}
+
+// resultsEnabled reports whether the games database was created with chamgo games enable.
+func resultsEnabled() bool {
+	fn, err := resultsPath()
+	return err == nil && exists(fn)
+}
+
+func readResults(fn string) ([]*seenGame, error) {
+	db, err := openSQLiteFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	rows, err := db.table("games")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fn, err)
+	}
+	var gs []*seenGame
+	for _, r := range rows {
+		gs = append(gs, seenGameOf(r))
+	}
+	return gs, nil
+}
+
+// lookupResults returns the games of the games database fn whose entries in index are from from
+// and before to, in the order of the index.
+func lookupResults(fn, index string, from, to interface{}) ([]*seenGame, error) {
+	db, err := openSQLiteFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	root, err := db.root("table", "games")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fn, err)
+	}
+	iroot, err := db.root("index", index)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fn, err)
+	}
+	entries, err := db.indexRange(iroot, from, to)
+	if err != nil {
+		return nil, err
+	}
+	var gs []*seenGame
+	for _, e := range entries {
+		r, ok, err := db.row(root, e.RowID)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			gs = append(gs, seenGameOf(r))
+		}
+	}
+	return gs, nil
+}
+
+// writeResults replaces the games database fn at once, so that readers see either the old or the new one.
+func writeResults(fn string, gs []*seenGame) error {
+	t := sqliteTable{Name: "games", Columns: resultsColumns, Indexes: resultsIndexes}
+	for _, g := range gs {
+		t.Rows = append(t.Rows, g.row())
+	}
+	return writeSQLite(fn, []sqliteTable{t})
+}
+
+// updateResults applies update to the entries of the games database under its lock, doing nothing if it is not enabled.
+func updateResults(ctx context.Context, update func(gs []*seenGame) ([]*seenGame, error)) error {
+	if !resultsEnabled() {
+		return nil
+	}
+	fn, err := resultsPath()
+	if err != nil {
+		return err
+	}
+	unlock, err := lockOutput(ctx, fn)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	gs, err := readResults(fn)
+	if err != nil {
+		return err
+	}
+	if gs, err = update(gs); err != nil {
+		return err
+	}
+	return writeResults(fn, gs)
+}
+
+// recordGames adds the games of the archive avx to the games database, if it is enabled.
+func recordGames(ctx context.Context, avx string, games []gameEntry) {
+	if len(games) == 0 {
+		return
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	if abs, err := filepath.Abs(avx); err == nil {
+		avx = abs
+	}
+	settings := settingsOf(ctx)
+	err := updateResults(ctx, func(gs []*seenGame) ([]*seenGame, error) {
+		byHash := make(map[string]*seenGame)
+		for _, g := range gs {
+			byHash[g.Hash] = g
+		}
+		for _, ge := range games {
+			g, err := settings.decode(ge.Body)
+			if err != nil {
+				continue
+			}
+			h := movesHash(ge.Body)
+			s := byHash[h]
+			if s == nil {
+				s = &seenGame{Hash: h, FirstSeen: now}
+				byHash[h] = s
+				gs = append(gs, s)
+			}
+			s.LastSeen, s.Archive, s.Entry = now, avx, ge.Name
+			s.Mode, s.Size, s.Color, s.Level, s.Moves = g.Mode, g.Size, g.Color, g.Level, len(g.Moves)
+			s.Opening = openingOf(g)
+			s.Started, s.Saved = time.Unix(int64(g.Started), 0).UTC(), time.Unix(int64(g.Saved), 0).UTC()
+		}
+		return gs, nil
+	})
+	if err != nil {
+		note(event{"event": "games-database", "error": err.Error()}, "not recording the games: %v", err)
+	}
+}
+
+// recordAnalysis keeps the summary of the analysis a in the games database, if it is enabled.
+func recordAnalysis(ctx context.Context, a *gameAnalysis) {
+	sum := &analysisSummary{Engine: a.Engine, Analyzed: a.Analyzed, Moves: len(a.Moves), MatchRate: a.matchRate()}
+	if l, ok := a.meanLoss(); ok {
+		sum.MeanLoss = &l
+	}
+	err := updateResults(ctx, func(gs []*seenGame) ([]*seenGame, error) {
+		for _, g := range gs {
+			if g.Hash == a.Hash {
+				g.Analysis = sum
+			}
+		}
+		return gs, nil
+	})
+	if err != nil {
+		note(event{"event": "games-database", "error": err.Error()}, "not recording the analysis: %v", err)
+	}
+}
+
+// findSeenGame returns the index of the one game whose hash starts with prefix.
+func findSeenGame(gs []*seenGame, prefix string) (int, error) {
+	found := -1
+	for i, g := range gs {
+		if strings.HasPrefix(g.Hash, strings.ToLower(prefix)) {
+			if found >= 0 {
+				return 0, fmt.Errorf("several games have hashes starting with %s", prefix)
+			}
+			found = i
+		}
+	}
+	if found < 0 {
+		return 0, fmt.Errorf("no game has a hash starting with %s", prefix)
+	}
+	return found, nil
+}
+
+func gamesCmd(ctx context.Context, args []string) error {
+	usage := fmt.Errorf("usage: chamgo games enable|disable|add|list|result [flags]")
+	if len(args) == 0 {
+		return usage
+	}
+	fs := flag.NewFlagSet("games "+args[0], flag.ContinueOnError)
+	since := fs.String("since", "", "list the games saved since the day, as 2006-01-02")
+	var filter tagFilter
+	fs.Var(&filter, "tag", "list only the games with this tag, may be repeated")
+	opening := fs.String("opening", "", "list only the games with this opening, as hoshi, komoku or sanrensei")
+	if err := parseFlags(fs, args[1:]); err != nil {
+		return err
+	}
+	fn, err := resultsPath()
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "enable":
+		if exists(fn) {
+			return nil
+		}
+		if _, err := chamgoDir(""); err != nil {
+			return err
+		}
+		return writeResults(fn, nil)
+	case "disable":
+		if err := os.Remove(fn); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	case "add":
+		if !resultsEnabled() {
+			return fmt.Errorf("the games database is not enabled, run chamgo games enable")
+		}
+		for _, avx := range fs.Args() {
+			for _, online := range []bool{false, true} {
+				// listGames records the games it reads.
+				if _, err := listGames(ctx, avx, online); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	case "list":
+		var gs []*seenGame
+		if *since != "" {
+			var t time.Time
+			if t, err = time.ParseInLocation("2006-01-02", *since, time.Local); err != nil {
+				return fmt.Errorf("-since: %w", err)
+			}
+			gs, err = lookupResults(fn, "games_saved", t.Unix(), nil)
+		} else {
+			gs, err = readResults(fn)
+		}
+		if os.IsNotExist(err) {
+			return fmt.Errorf("the games database is not enabled, run chamgo games enable")
+		}
+		if err != nil {
+			return err
+		}
+		if len(filter) > 0 {
+			tags, err := readTags()
+			if err != nil {
+				return err
+			}
+			var kept []*seenGame
+			for _, g := range gs {
+				if filter.match(tags, g.Hash) {
+					kept = append(kept, g)
+				}
+			}
+			gs = kept
+		}
+		if *opening != "" {
+			var kept []*seenGame
+			for _, g := range gs {
+				if strings.EqualFold(g.Opening, *opening) {
+					kept = append(kept, g)
+				}
+			}
+			gs = kept
+		}
+		sort.SliceStable(gs, func(i, j int) bool { return gs[i].Saved.Before(gs[j].Saved) })
+		output(gs, func(w io.Writer) {
+			for _, g := range gs {
+				fmt.Fprintf(w, "%s  %s  %dx%d level %d, %d moves", fingerprint(g.Hash), g.Saved.Local().Format("2006-01-02 15:04"), g.Size, g.Size, g.Level, g.Moves)
+				if g.Opening != "" {
+					fmt.Fprintf(w, ", %s", g.Opening)
+				}
+				if g.Result != "" {
+					fmt.Fprintf(w, ", %s", g.Result)
+				}
+				if a := g.Analysis; a != nil {
+					fmt.Fprintf(w, ", %.1f%% match", 100*a.MatchRate)
+				}
+				fmt.Fprintf(w, "\n    %s %s\n", g.Archive, g.Entry)
+			}
+		})
+		return nil
+	case "result":
+		if fs.NArg() != 2 {
+			return fmt.Errorf("usage: chamgo games result hash result")
+		}
+		if !resultsEnabled() {
+			return fmt.Errorf("the games database is not enabled, run chamgo games enable")
+		}
+		return updateResults(ctx, func(gs []*seenGame) ([]*seenGame, error) {
+			i, err := findSeenGame(gs, fs.Arg(0))
+			if err != nil {
+				return nil, err
+			}
+			gs[i].Result = fs.Arg(1)
+			return gs, nil
+		})
+	}
+	return usage
+}
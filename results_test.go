@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGamesDatabase(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("CHAMGO_HOME", home)
+	avx := synthArchive(t, synthOptions{Version: currentLayout.Version, Local: 3, Online: 1, Size: 9, Moves: 10, Seed: 11})
+	ctx := context.Background()
+
+	// Nothing is recorded until the database is enabled.
+	if _, err := listGames(ctx, avx, false); err != nil {
+		t.Fatal(err)
+	}
+	if fis, _ := os.ReadDir(home); len(fis) != 0 {
+		t.Errorf("%d files in $CHAMGO_HOME before enabling the database", len(fis))
+	}
+	if err := gamesCmd(ctx, []string{"enable"}); err != nil {
+		t.Fatal(err)
+	}
+	fn := filepath.Join(home, resultsName)
+	if gs, err := readResults(fn); err != nil || len(gs) != 0 {
+		t.Fatalf("enabled database: %d games, %v", len(gs), err)
+	}
+	if err := gamesCmd(ctx, []string{"add", avx}); err != nil {
+		t.Fatal(err)
+	}
+	gs, err := readResults(fn)
+	if err != nil || len(gs) != 4 {
+		t.Fatalf("%d games, %v, want 4", len(gs), err)
+	}
+	g := gs[0]
+	if g.Size != 9 || g.Moves != 10 || g.FirstSeen.IsZero() || g.Saved.IsZero() || g.Archive == "" || g.Analysis != nil {
+		t.Errorf("recorded %+v", g)
+	}
+
+	loss := 1.5
+	recordAnalysis(ctx, &gameAnalysis{Hash: g.Hash, Engine: "katago", Analyzed: time.Unix(1700000000, 0).UTC(),
+		Moves: []moveAnalysis{{Match: true, Loss: &loss}, {Loss: &loss}}})
+	if err := gamesCmd(ctx, []string{"result", g.Hash[:10], "B+R"}); err != nil {
+		t.Fatal(err)
+	}
+	again, err := readResults(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := *g
+	want.Result = "B+R"
+	want.Analysis = &analysisSummary{Engine: "katago", Analyzed: time.Unix(1700000000, 0).UTC(), Moves: 2, MatchRate: 0.5, MeanLoss: &loss}
+	if !reflect.DeepEqual(again[0], &want) {
+		t.Errorf("updated %+v %+v, want %+v %+v", again[0], again[0].Analysis, want, want.Analysis)
+	}
+
+	// The indexes find games by the beginning of their hash and by when they were saved.
+	byHash, err := lookupResults(fn, "games_hash", g.Hash[:6], sqlitePrefixEnd(g.Hash[:6]))
+	if err != nil || len(byHash) != 1 || byHash[0].Hash != g.Hash {
+		t.Errorf("games with hashes starting with %s: %d, %v", g.Hash[:6], len(byHash), err)
+	}
+	if h, err := resolveHash(nil, g.Hash[:8]); err != nil || h != g.Hash {
+		t.Errorf("resolved %s, %v, want %s", h, err, g.Hash)
+	}
+	latest := gs[0]
+	for _, g := range gs {
+		if g.Saved.After(latest.Saved) {
+			latest = g
+		}
+	}
+	since, err := lookupResults(fn, "games_saved", latest.Saved.Unix(), nil)
+	if err != nil || len(since) == 0 || since[len(since)-1].Hash != latest.Hash {
+		t.Errorf("games saved since %v: %d, %v", latest.Saved, len(since), err)
+	}
+
+	if err := gamesCmd(ctx, []string{"disable"}); err != nil || exists(fn) {
+		t.Errorf("disabled: %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Rules are the parts of a ruleset that decide which moves are legal.
+type Rules struct {
+	Name    string
+	Suicide bool   // whether a move may capture its own group
+	Superko string // "positional" or "situational" if a move may not repeat an earlier board, or "" for simple ko only
+}
+
+// rulesets are the rulesets selectable with -rules and kgs-rules.
+var rulesets = map[string]Rules{
+	"japanese":     {Name: "japanese"},
+	"chinese":      {Name: "chinese", Superko: "positional"},
+	"aga":          {Name: "aga", Superko: "situational"},
+	"new-zealand":  {Name: "new-zealand", Suicide: true, Superko: "situational"},
+	"tromp-taylor": {Name: "tromp-taylor", Suicide: true, Superko: "positional"},
+}
+
+// rules is the ruleset moves are checked against, set by -rules.
+// Japanese rules, with simple ko and no suicide, are what the app plays by default.
+// No rules field has been found in the records, so -rules does not change the ruleset of the games chamgo writes,
+// which the app plays under its own setting; see the format notes of the README.
+var rules = rulesets["japanese"]
+
+// lookupRules returns the ruleset called name, accepting the spellings used by GTP controllers such as new_zealand.
+func lookupRules(name string) (Rules, error) {
+	r, ok := rulesets[strings.ReplaceAll(strings.ToLower(name), "_", "-")]
+	if !ok {
+		return Rules{}, fmt.Errorf("unknown rules %q, must be one of %s", name, strings.Join(rulesetNames(), ", "))
+	}
+	return r, nil
+}
+
+func rulesetNames() []string {
+	var names []string
+	for name := range rulesets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+type rulesFlag struct{}
+
+func (rulesFlag) String() string { return rules.Name }
+
+func (rulesFlag) Set(s string) error {
+	r, err := lookupRules(s)
+	if err != nil {
+		return err
+	}
+	rules = r
+	return nil
+}
+
+// positionKey identifies the board for superko, including the side that just moved under situational superko.
+func (b *Board) positionKey(moved Stone) string {
+	key := make([]byte, len(b.Points), len(b.Points)+1)
+	for i, s := range b.Points {
+		key[i] = byte(s)
+	}
+	if b.rules.Superko == "situational" {
+		key = append(key, byte(moved))
+	}
+	return string(key)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// runScript transforms body with an external script.
+// The script receives the decoded Game as JSON on stdin and writes the modified Game as JSON to stdout,
+// so that one-off transformations can be written in any language, for example:
+//
+//	#!/usr/bin/env python3
+//	import json, sys
+//	g = json.load(sys.stdin)
+//	g["moves"] = g["moves"][:30]
+//	json.dump(g, sys.stdout)
+//
+// The script is a command line split on spaces, and is killed when ctx is done.
+//
+// Scripts are external programs rather than Starlark or Lua run by chamgo, since an interpreter would be
+// the one dependency outside the standard library.
+func runScript(ctx context.Context, body []byte, script string) ([]byte, error) {
+	g, err := Decode(body)
+	if err != nil {
+		return nil, err
+	}
+	in, err := json.Marshal(g)
+	if err != nil {
+		return nil, err
+	}
+
+	argv := strings.Fields(script)
+	if len(argv) == 0 {
+		return nil, fmt.Errorf("empty script")
+	}
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Stdin = bytes.NewReader(in)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", script, err)
+	}
+
+	// Decode into g so that the unknown bytes of the original record are kept.
+	g.Moves = nil
+	if err := json.Unmarshal(out, g); err != nil {
+		return nil, fmt.Errorf("%s: bad output: %w", script, err)
+	}
+	return g.Encode(), nil
+}
+
+func scriptCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("script", flag.ContinueOnError)
+	avx := fs.String("a", "", "input Champion Go archive")
+	game := fs.String("g", "latest", `the game to transform: an entry name, "latest" or "latest-online"`)
+	out := fs.String("o", "-", "output archive")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("usage: chamgo script -a archive [-g game] [-o out] script [args...]")
+	}
+
+	name, body, err := readEntry(ctx, *avx, *game)
+	if err != nil {
+		return err
+	}
+	if body == nil {
+		return errNoGames
+	}
+	body, err = runScript(ctx, body, strings.Join(fs.Args(), " "))
+	if err != nil {
+		return err
+	}
+	return writeAvxFile(ctx, *out, *avx, avxEdit{Replace: map[string][]byte{name: body}})
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// scrubKeyRe matches the property list keys that hold personal or device-identifying data,
+// such as the Apple ID and name in iTunesMetadata.plist and Game Center player identifiers in the app's settings.
+var scrubKeyRe = regexp.MustCompile(`(?i)apple.?id|account|person|user.?name|first.?name|last.?name|player|alias|e.?mail|gamecenter|gkplayer|device|udid|serial|phone`)
+
+// scrubPathRe matches the entries dropped from scrubbed archives, which are caches that may hold Game Center data.
+var scrubPathRe = regexp.MustCompile(`(?i)gamekit|gamecenter|^Container/Library/(Caches|Cookies)/`)
+
+// scrubPlist redacts the values of identifying keys in v, returning the redacted key paths.
+func scrubPlist(v interface{}, keyPath string) []string {
+	var scrubbed []string
+	switch v := v.(type) {
+	case *plistDict:
+		for _, k := range v.Keys {
+			p := k
+			if keyPath != "" {
+				p = keyPath + "." + k
+			}
+			if scrubKeyRe.MatchString(k) {
+				switch v.Values[k].(type) {
+				case string:
+					v.Values[k] = "scrubbed"
+					scrubbed = append(scrubbed, p)
+					continue
+				case int64:
+					v.Values[k] = int64(0)
+					scrubbed = append(scrubbed, p)
+					continue
+				case []byte:
+					v.Values[k] = []byte{}
+					scrubbed = append(scrubbed, p)
+					continue
+				}
+			}
+			scrubbed = append(scrubbed, scrubPlist(v.Values[k], p)...)
+		}
+	case []interface{}:
+		for i, e := range v {
+			scrubbed = append(scrubbed, scrubPlist(e, fmt.Sprintf("%s[%d]", keyPath, i))...)
+		}
+	}
+	return scrubbed
+}
+
+func scrubCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("scrub", flag.ContinueOnError)
+	avx := fs.String("a", "", "Champion Go archive")
+	out := fs.String("o", "-", "output archive")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	a, err := openArchive(ctx, *avx)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	// The scrubbed data is not journaled for chamgo undo.
+	e := avxEdit{Replace: make(map[string][]byte), Delete: make(map[string]bool), NoJournal: true}
+	for _, f := range a.Files {
+		if scrubPathRe.MatchString(f.Name) {
+			note(event{"event": "drop", "entry": f.Name}, "dropped %s", f.Name)
+			e.Delete[f.Name] = true
+			continue
+		}
+		if path.Ext(f.Name) != ".plist" {
+			continue
+		}
+		b, err := f.ReadAll()
+		if err != nil {
+			return err
+		}
+		v, err := parsePlist(b)
+		if err != nil {
+			note(event{"event": "skip", "entry": f.Name, "error": err.Error()}, "skipped %s: %v", f.Name, err)
+			continue
+		}
+		scrubbed := scrubPlist(v, "")
+		if len(scrubbed) == 0 {
+			continue
+		}
+		note(event{"event": "scrub", "entry": f.Name, "keys": scrubbed}, "scrubbed %s: %s", f.Name, strings.Join(scrubbed, ", "))
+		var nb bytes.Buffer
+		if err := writePlist(&nb, v, b); err != nil {
+			return fmt.Errorf("%s: %w", f.Name, err)
+		}
+		e.Replace[f.Name] = nb.Bytes()
+	}
+	// The journal of an archive scrubbed in place is dropped too, since the originals of its earlier edits may hold it.
+	if err := writeAvxFile(ctx, *out, *avx, e); err != nil || *out == "" || *out == "-" {
+		return err
+	}
+	return writeJournal(*out, nil)
+}
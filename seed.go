@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// sgfFiles returns the SGF files in dir and its subdirectories, sorted.
+func sgfFiles(ctx context.Context, dir string) ([]string, error) {
+	var fns []string
+	err := filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !fi.IsDir() && strings.EqualFold(filepath.Ext(p), ".sgf") {
+			fns = append(fns, p)
+		}
+		return nil
+	})
+	sort.Strings(fns)
+	return fns, err
+}
+
+func seedCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("seed", flag.ContinueOnError)
+	avx := fs.String("a", "", "Champion Go archive")
+	db := fs.String("db", "", "directory of SGF games, searched recursively, by default $CHAMGO_HOME/sgf")
+	moves := fs.Int("moves", 30, "keep this many moves of the opening, or 0 for the whole game")
+	random := fs.Bool("random", false, "pick a random game of the directory instead of the named one")
+	player := fs.String("player", "", "pick a random game of the player whose name contains this, from the index of the directory")
+	position := fs.String("position", "", "pick a random game reaching the position of this board diagram, from the index of the directory")
+	eventFlag := fs.String("event", "", "pick a random game of the events whose names contain this, from the index of the directory")
+	slot := fs.String("slot", "latest-online", "the online game replaced by the opening")
+	p := fs.String("p", "b", "the color of the human player")
+	level := fs.Int("l", 10, "computer level")
+	out := fs.String("o", "-", "output archive")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	usage := fmt.Errorf("usage: chamgo seed [flags] -random | game.sgf")
+	if *random == (fs.NArg() == 1) || fs.NArg() > 1 {
+		return usage
+	}
+	if *moves < 0 {
+		return fmt.Errorf("moves must not be negative")
+	}
+	if *level < 1 || *level > 10 {
+		return fmt.Errorf("level must be between 1 and 10")
+	}
+	var err error
+	dir := *db
+	if dir == "" {
+		if dir, err = chamgoDir("sgf"); err != nil {
+			return err
+		}
+	}
+
+	fn := fs.Arg(0)
+	if *random {
+		var fns []string
+		if *player != "" || *position != "" || *eventFlag != "" {
+			q := indexQuery{Player: *player, Event: *eventFlag}
+			if *position != "" {
+				h, err := diagramHash(*position)
+				if err != nil {
+					return err
+				}
+				q.Hash = h
+			}
+			found, err := queryIndex(dir, q)
+			if err != nil {
+				return err
+			}
+			for _, e := range found {
+				fns = append(fns, filepath.Join(dir, filepath.FromSlash(e.Path)))
+			}
+		} else if fns, err = sgfFiles(ctx, dir); err != nil {
+			return err
+		}
+		if len(fns) == 0 {
+			return fmt.Errorf("no matching SGF files in %s", dir)
+		}
+		fn = fns[rand.IntN(len(fns))]
+	} else if _, err := os.Stat(fn); os.IsNotExist(err) && !filepath.IsAbs(fn) {
+		fn = filepath.Join(dir, fn)
+	}
+	s, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return err
+	}
+
+	name, base, err := readEntry(ctx, *avx, *slot)
+	if err != nil {
+		return err
+	}
+	if base == nil {
+		return errNoGames
+	}
+	body, err := sgfGame(ctx, string(s), base)
+	if err != nil {
+		return fmt.Errorf("%s: %w", fn, err)
+	}
+	if *moves > 0 {
+		if body, err = applyTransforms(ctx, body, fmt.Sprintf("truncate=%d", *moves)); err != nil {
+			return err
+		}
+	}
+	g, err := Decode(body)
+	if err != nil {
+		return err
+	}
+	note(event{"event": "seed", "sgf": fn, "moves": len(g.Moves)}, "seeded %s with %d moves of %s", name, len(g.Moves), fn)
+	if err := setPlayer(body, *p); err != nil {
+		return err
+	}
+	setLevel(body, byte(*level))
+	touchDates(body)
+	return writeAvxFile(ctx, *out, *avx, avxEdit{Replace: map[string][]byte{name: body}})
+}
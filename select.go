@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// selectMode is set by -select: "latest" picks the most recently saved game,
+// and "interactive" asks which one to use when there are several.
+var selectMode = "latest"
+
+// selectInput is where interactive selections are read from.
+var selectInput io.Reader = os.Stdin
+
+type selectFlag struct{}
+
+func (selectFlag) String() string { return selectMode }
+
+func (selectFlag) Set(s string) error {
+	if s != "latest" && s != "interactive" {
+		return fmt.Errorf(`must be "latest" or "interactive"`)
+	}
+	selectMode = s
+	return nil
+}
+
+// miniBoard draws the final position of a game in a few lines, one character per point.
+func miniBoard(g *Game) []string {
+	b, err := replay(g)
+	if err != nil && b == nil {
+		return []string{fmt.Sprintf("(no preview: %v)", err)}
+	}
+	var lines []string
+	for y := 1; y <= b.Size; y++ {
+		var row strings.Builder
+		for x := 1; x <= b.Size; x++ {
+			switch b.At(x, y) {
+			case Black:
+				row.WriteByte('X')
+			case White:
+				row.WriteByte('O')
+			default:
+				row.WriteByte('.')
+			}
+		}
+		lines = append(lines, row.String())
+	}
+	return lines
+}
+
+// selectGame asks on stderr which of the games to use, listing the most recently saved first.
+func selectGame(games []gameEntry, what string) (gameEntry, error) {
+	sorted := append([]gameEntry(nil), games...)
+	saved := func(e gameEntry) int32 {
+		if g, err := Decode(e.Body); err == nil {
+			return g.Saved
+		}
+		return -1
+	}
+	sort.SliceStable(sorted, func(i, j int) bool { return saved(sorted[i]) > saved(sorted[j]) })
+
+	fmt.Fprintf(os.Stderr, "%d %s games:\n", len(sorted), what)
+	for i, e := range sorted {
+		g, err := Decode(e.Body)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%2d) %s: %v\n", i+1, path.Base(e.Name), err)
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "%2d) %s  %s  saved %s  %dx%d  %d moves  %s\n", i+1, path.Base(e.Name), fingerprint(g.movesHash()),
+			time.Unix(int64(g.Saved), 0).Format("2006-01-02 15:04"), g.Size, g.Size, len(g.Moves), openingOf(g))
+		for _, l := range miniBoard(g) {
+			fmt.Fprintf(os.Stderr, "      %s\n", l)
+		}
+	}
+
+	in := bufio.NewReader(selectInput)
+	for {
+		fmt.Fprintf(os.Stderr, "use which %s game [1-%d, default 1]? ", what, len(sorted))
+		line, err := in.ReadString('\n')
+		line = strings.TrimSpace(line)
+		if line == "" && err == nil {
+			return sorted[0], nil
+		}
+		if n, perr := strconv.Atoi(line); perr == nil && n >= 1 && n <= len(sorted) {
+			return sorted[n-1], nil
+		}
+		if err != nil {
+			return gameEntry{}, fmt.Errorf("no %s game selected: %w", what, err)
+		}
+		fmt.Fprintf(os.Stderr, "%q is not a game number\n", line)
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"sync"
+)
+
+// Session keeps the archives that inject, or a long running program, opens once and shares between its goroutines.
+// A session reads and writes them with the settings of its Options rather than the global flags,
+// so that sessions with different Options can run at once. As for the commands, the app of the first archive opened
+// is the app of them all, unless Options.App names one.
+type Session struct {
+	mu       sync.Mutex // guards archives and the detection of the app of settings
+	settings *settings
+	archives map[string]*Archive
+}
+
+// Archive is an archive of a Session, which goroutines may read and edit at the same time.
+// Edits are kept in memory until Write.
+type Archive struct {
+	Name string
+
+	s *settings // of the session
+
+	// mu guards a and edit: reads share the opened archive, whose zip entries can be read concurrently,
+	// while edits and writes wait for them.
+	mu   sync.RWMutex
+	a    *archive
+	edit avxEdit
+}
+
+// NewSession returns a session with the settings of o. Its Archive and Out are not used.
+func NewSession(o Options) (*Session, error) {
+	s, err := o.settings()
+	if err != nil {
+		return nil, err
+	}
+	return &Session{settings: s, archives: make(map[string]*Archive)}, nil
+}
+
+// Open returns the archive name, opening it the first time.
+func (s *Session) Open(ctx context.Context, name string) (*Archive, error) {
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if a, ok := s.archives[abs]; ok {
+		return a, nil
+	}
+	// openArchive detects the app into the settings if it is not known yet.
+	a, err := openArchive(withSettings(ctx, s.settings), name)
+	if err != nil {
+		return nil, err
+	}
+	if s.settings.app == nil {
+		s.settings.app = apps[0]
+	}
+	s.archives[abs] = &Archive{Name: name, s: s.settings, a: a}
+	return s.archives[abs], nil
+}
+
+// Close closes the archives of the session, dropping their unwritten edits.
+func (s *Session) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var first error
+	for k, a := range s.archives {
+		a.mu.Lock()
+		if err := a.a.Close(); err != nil && first == nil {
+			first = err
+		}
+		a.mu.Unlock()
+		delete(s.archives, k)
+	}
+	return first
+}
+
+// Games returns the local or online games of the archive in archive order, with the edits not written yet.
+func (a *Archive) Games(ctx context.Context, online bool) ([]gameEntry, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.games(ctx, online)
+}
+
+// games is Games with a.mu held.
+func (a *Archive) games(ctx context.Context, online bool) ([]gameEntry, error) {
+	app := a.s.app
+	var games []gameEntry
+	for _, f := range a.a.Files {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if f.IsDir || !app.isGameFile(f.Name, online) || a.edit.Delete[f.Name] {
+			continue
+		}
+		body, ok := a.edit.Replace[f.Name]
+		if !ok {
+			b, err := f.ReadAll()
+			if err != nil {
+				return nil, err
+			}
+			if body, err = app.record(f.Name, b); err != nil {
+				return nil, err
+			}
+		}
+		games = append(games, gameEntry{Name: f.Name, Body: body})
+	}
+	for _, name := range sortedKeys(a.edit.Add) {
+		if app.isGameFile(name, online) {
+			games = append(games, gameEntry{Name: name, Body: a.edit.Add[name]})
+		}
+	}
+	return games, nil
+}
+
+// Record returns the entry name of the archive, "latest", "latest-online" or "autosave", as readEntry does,
+// and its record if it is a game. The latest games of an archive without edits are selected as -select says;
+// the autosaved game is the one of the archive as opened.
+func (a *Archive) Record(ctx context.Context, name string) (string, []byte, error) {
+	ctx = withSettings(ctx, a.s)
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	switch name {
+	case "", "latest", "latest-online":
+		online := name == "latest-online"
+		if a.edit.Replace == nil && a.edit.Delete == nil && a.edit.Add == nil {
+			return latestIn(ctx, a.a, a.Name, online)
+		}
+		games, err := a.games(ctx, online)
+		if err != nil {
+			return "", nil, err
+		}
+		return latestGame(games)
+	case "autosave":
+		return autosaveIn(ctx, a.a, a.Name)
+	}
+	if a.edit.Delete[name] {
+		return "", nil, fmt.Errorf("%s: %s is deleted", a.Name, name)
+	}
+	if body, ok := a.edit.Replace[name]; ok {
+		return name, body, nil
+	}
+	if body, ok := a.edit.Add[name]; ok {
+		return name, body, nil
+	}
+	f := a.a.file(name)
+	if f == nil {
+		return "", nil, fmt.Errorf("%s: %s not found", a.Name, name)
+	}
+	body, err := f.ReadAll()
+	if err != nil {
+		return "", nil, err
+	}
+	if a.s.app.isGameFile(name, false) || a.s.app.isGameFile(name, true) {
+		body, err = a.s.app.record(name, body)
+	}
+	return name, body, err
+}
+
+// Replace replaces the entry name of the archive with body, or adds it if the archive has no such entry.
+func (a *Archive) Replace(name string, body []byte) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	body = append([]byte(nil), body...)
+	if a.a.file(name) == nil {
+		if a.edit.Add == nil {
+			a.edit.Add = make(map[string][]byte)
+		}
+		a.edit.Add[name] = body
+		return
+	}
+	if a.edit.Replace == nil {
+		a.edit.Replace = make(map[string][]byte)
+	}
+	a.edit.Replace[name] = body
+	delete(a.edit.Delete, name)
+}
+
+// Delete deletes the entry name of the archive.
+func (a *Archive) Delete(name string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, ok := a.edit.Add[name]; ok {
+		delete(a.edit.Add, name)
+		return
+	}
+	if a.edit.Delete == nil {
+		a.edit.Delete = make(map[string]bool)
+	}
+	a.edit.Delete[name] = true
+	delete(a.edit.Replace, name)
+}
+
+// Write writes the archive with its edits to out, as writeAvxFile does. A directory archive written to itself
+// is opened again without the edits, now written; other archives cannot be written to themselves.
+func (a *Archive) Write(ctx context.Context, out string) error {
+	ctx = withSettings(ctx, a.s)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	inPlace := out != "" && out != "-" && sameFile(out, a.Name)
+	if inPlace && !isDir(a.Name) {
+		return fmt.Errorf("%s cannot be written to itself, write it to another file", a.Name)
+	}
+	// writeAvxFile may add to the edit, such as the originals kept by -keep-original, which are not edits of the session.
+	e := avxEdit{Replace: maps.Clone(a.edit.Replace), Delete: maps.Clone(a.edit.Delete), Add: maps.Clone(a.edit.Add)}
+	if err := writeAvxFile(ctx, out, a.Name, e); err != nil {
+		return err
+	}
+	if !inPlace {
+		return nil
+	}
+	reopened, err := openArchive(ctx, a.Name)
+	if err != nil {
+		return err
+	}
+	a.a.Close()
+	a.a, a.edit = reopened, avxEdit{}
+	return nil
+}
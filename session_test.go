@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"math/rand"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestSession(t *testing.T) {
+	avx := synthArchive(t, synthOptions{Version: currentLayout.Version, Local: 4, Online: 2, Size: 19, Moves: 20, Seed: 3})
+	bodies := make([][]byte, 4)
+	for i := range bodies {
+		bodies[i] = synthGame(rand.New(rand.NewSource(int64(10+i))), currentLayout, 9, 10, synthEpoch).Encode()
+	}
+	sess, err := NewSession(Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	errs := make([]error, 8)
+	archives := make([]*Archive, 8)
+	names := make([]string, 8)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			a, err := sess.Open(ctx, avx)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			archives[i] = a
+			games, err := a.Games(ctx, false)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			if len(games) != 4 {
+				t.Errorf("goroutine %d: %d local games", i, len(games))
+				return
+			}
+			if _, _, err := a.Record(ctx, "latest-online"); err != nil {
+				errs[i] = err
+				return
+			}
+			names[i] = games[i%4].Name
+			a.Replace(names[i], bodies[i%4])
+			_, body, err := a.Record(ctx, names[i])
+			if err == nil && !bytes.Equal(body, bodies[i%4]) {
+				t.Errorf("goroutine %d: %s not replaced", i, names[i])
+			}
+			errs[i] = err
+		}(i)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("goroutine %d: %v", i, err)
+		}
+		if archives[i] != archives[0] {
+			t.Fatalf("goroutine %d opened the archive again", i)
+		}
+	}
+
+	out := filepath.Join(t.TempDir(), "out.imazingapp")
+	if err := archives[0].Write(ctx, out); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := zipEntries(t, b)
+	for i, name := range names {
+		if !bytes.Equal(entries[name], bodies[i%4]) {
+			t.Errorf("%s not written", name)
+		}
+	}
+	if err := archives[0].Write(ctx, avx); err == nil {
+		t.Error("no error writing an archive to itself")
+	}
+}
+
+// TestSessionApps opens one archive in sessions of different apps, which must not follow currentApp or each other.
+func TestSessionApps(t *testing.T) {
+	avx := synthArchive(t, synthOptions{Version: currentLayout.Version, Local: 3, Online: 1, Size: 9, Moves: 10, Seed: 4})
+	saved := currentApp
+	defer func() { currentApp = saved }()
+	currentApp = apps[len(apps)-1]
+
+	tests := []struct {
+		app   string
+		local int
+	}{
+		{apps[0].Name, 3},
+		{"smartgo", 0},
+		{"", 3}, // detected from the archive
+	}
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	errs := make([]error, len(tests))
+	got := make([]int, len(tests))
+	for i, tt := range tests {
+		wg.Add(1)
+		go func(i int, app string) {
+			defer wg.Done()
+			sess, err := NewSession(Options{App: app})
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			defer sess.Close()
+			a, err := sess.Open(ctx, avx)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			games, err := a.Games(ctx, false)
+			for _, g := range games {
+				if _, err := Decode(g.Body); err != nil {
+					errs[i] = err
+					return
+				}
+			}
+			got[i], errs[i] = len(games), err
+		}(i, tt.app)
+	}
+	wg.Wait()
+	for i, tt := range tests {
+		if errs[i] != nil {
+			t.Errorf("app %q: %v", tt.app, errs[i])
+		} else if got[i] != tt.local {
+			t.Errorf("app %q: %d local games, want %d", tt.app, got[i], tt.local)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// modeList is a flag of game modes, which may be repeated.
+type modeList []Mode
+
+func (l *modeList) String() string {
+	var s []string
+	for _, m := range *l {
+		s = append(s, m.String())
+	}
+	return strings.Join(s, ",")
+}
+
+func (l *modeList) Set(s string) error {
+	var m Mode
+	if err := m.Set(s); err != nil {
+		return err
+	}
+	*l = append(*l, m)
+	return nil
+}
+
+func (l modeList) has(m Mode) bool {
+	for _, v := range l {
+		if v == m {
+			return true
+		}
+	}
+	return false
+}
+
+func setLevelCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("set-level", flag.ContinueOnError)
+	avx := fs.String("a", "", "Champion Go archive")
+	out := fs.String("o", "-", "output archive")
+	slot := fs.String("g", "latest", `the game: an entry name, "latest", "latest-online" or "autosave"`)
+	all := fs.Bool("all", false, "set the level of every game against the computer, local and online, of the modes of -mode")
+	var modes modeList
+	fs.Var(&modes, "mode", "with -all, a mode of the games to change, may be repeated; by default computer games and those of the mode chamgo injects games with, human unless the leading -mode is given")
+	// The level and flags may be mixed, as in chamgo set-level --all 7 -a backup.imazingapp.
+	var rest []string
+	for r := args; ; r = fs.Args()[1:] {
+		if err := parseFlags(fs, r); err != nil {
+			return err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		rest = append(rest, fs.Arg(0))
+	}
+	if len(rest) != 1 {
+		return fmt.Errorf("usage: chamgo set-level [-all | -g game] -a archive [-o out] level")
+	}
+	level, err := strconv.ParseUint(rest[0], 10, 8)
+	if err != nil || level < 1 || level > 10 {
+		return fmt.Errorf("level must be between 1 and 10")
+	}
+
+	e := avxEdit{Replace: make(map[string][]byte)}
+	if !*all {
+		name, body, err := readEntry(ctx, *avx, *slot)
+		if err != nil {
+			return err
+		}
+		if name == "" {
+			return errNoGames
+		}
+		setLevel(body, byte(level))
+		e.Replace[name] = body
+		return writeAvxFile(ctx, *out, *avx, e)
+	}
+	// The games chamgo injected have the mode of -mode, human by default, and are changed along with the computer games.
+	if len(modes) == 0 {
+		modes = modeList{ModeComputer, settingsOf(ctx).mode}
+	}
+	for _, online := range []bool{false, true} {
+		games, err := listGames(ctx, *avx, online)
+		if err != nil {
+			return err
+		}
+		for _, ge := range games {
+			g, err := Decode(ge.Body)
+			if err != nil || !modes.has(g.Mode) || g.Level == int32(level) {
+				continue
+			}
+			note(event{"event": "set-level", "entry": ge.Name, "from": g.Level, "to": level}, "%s: level %d -> %d", ge.Name, g.Level, level)
+			setLevel(ge.Body, byte(level))
+			e.Replace[ge.Name] = ge.Body
+		}
+	}
+	note(event{"event": "summary", "changed": len(e.Replace)}, "%d games changed", len(e.Replace))
+	return writeAvxFile(ctx, *out, *avx, e)
+}
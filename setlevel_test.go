@@ -0,0 +1,79 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSetLevelAll(t *testing.T) {
+	// The games of each mode, whose level is 3.
+	games := map[string]Mode{
+		"Container/Documents/game/0":        ModeComputer,
+		"Container/Documents/game/1":        ModeHuman,
+		"Container/Documents/game/2":        Mode(2),
+		"Container/Documents/game-online/3": ModeHuman,
+	}
+	avx := filepath.Join(t.TempDir(), "in.imazingapp")
+	f, err := os.Create(avx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	r := rand.New(rand.NewSource(8))
+	for name, m := range games {
+		g := synthGame(r, currentLayout, 9, 6, synthEpoch)
+		g.Mode, g.Level = m, 3
+		w, _ := zw.Create(name)
+		w.Write(g.Encode())
+	}
+	zw.Close()
+	f.Close()
+
+	tests := []struct {
+		name    string
+		args    []string
+		inject  Mode   // the mode of the leading -mode flag
+		changed []Mode // the modes of the games set to level 7
+	}{
+		{"default", nil, ModeHuman, []Mode{ModeComputer, ModeHuman}},
+		{"injected mode", nil, Mode(2), []Mode{ModeComputer, Mode(2)}},
+		{"computer", []string{"-mode", "computer"}, ModeHuman, []Mode{ModeComputer}},
+		{"repeated", []string{"-mode", "2", "-mode", "human"}, ModeHuman, []Mode{ModeHuman, Mode(2)}},
+	}
+	for _, tt := range tests {
+		out := filepath.Join(t.TempDir(), "out.imazingapp")
+		s := flagSettings()
+		s.mode = tt.inject
+		args := append([]string{"-all", "-a", avx, "-o", out}, tt.args...)
+		if err := setLevelCmd(withSettings(context.Background(), s), append(args, "7")); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		b, err := ioutil.ReadFile(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries := zipEntries(t, b)
+		for name, m := range games {
+			g, err := Decode(entries[name])
+			if err != nil {
+				t.Fatalf("%s: %s: %v", tt.name, name, err)
+			}
+			want := int32(3)
+			if modeList(tt.changed).has(m) {
+				want = 7
+			}
+			if g.Level != want {
+				t.Errorf("%s: %s of mode %s at level %d, want %d", tt.name, name, m, g.Level, want)
+			}
+		}
+	}
+	var l modeList
+	if err := l.Set("none"); err == nil {
+		t.Error("no error setting an unknown mode")
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// settings are what the global flags set for the functions reading and writing archives and records.
+// The commands take them from the flags, and inject from its Options through the context of its Session,
+// so that injections with different Options can run at once.
+type settings struct {
+	app         *goApp
+	selectMode  string
+	mode        Mode // of injected games
+	clock       func() time.Time
+	decodeMode  string
+	rules       Rules
+	sortEntries bool
+	json        bool
+	password    string
+	include     globList
+	exclude     globList
+
+	force        bool   // replace online games Game Center may still sync
+	keepOriginal string // "", "local" or "sgf", as -keep-original says
+	noCache      bool
+	writeCache   bool
+	keepVersions int
+}
+
+// settingsKey is the context key of the settings of an injection.
+type settingsKey struct{}
+
+// withSettings returns ctx carrying s, which the functions called with it follow instead of the global flags.
+func withSettings(ctx context.Context, s *settings) context.Context {
+	return context.WithValue(ctx, settingsKey{}, s)
+}
+
+// flagSettings returns the settings of the global flags.
+func flagSettings() *settings {
+	return &settings{
+		app:         currentApp,
+		selectMode:  selectMode,
+		mode:        injectMode,
+		clock:       clock,
+		decodeMode:  decodeMode,
+		rules:       rules,
+		sortEntries: sortEntries,
+		json:        jsonOutput,
+		password:    zipPassword,
+		include:     includeGlobs,
+		exclude:     excludeGlobs,
+
+		force:        forceOnline,
+		keepOriginal: keepOriginal,
+		noCache:      noCache,
+		writeCache:   writeCache,
+		keepVersions: keepVersions,
+	}
+}
+
+// settingsOf returns the settings ctx carries, or else the ones of the global flags.
+func settingsOf(ctx context.Context) *settings {
+	if s, ok := ctx.Value(settingsKey{}).(*settings); ok {
+		return s
+	}
+	return flagSettings()
+}
+
+// settings returns the settings of o. An empty o.App leaves the app nil, for the Session of o to detect.
+func (o *Options) settings() (*settings, error) {
+	s := &settings{
+		selectMode:  o.Select,
+		mode:        o.Mode,
+		clock:       o.Clock,
+		decodeMode:  o.DecodeMode,
+		sortEntries: o.SortEntries,
+		json:        o.JSON,
+		password:    o.Password,
+		include:     o.Include,
+		exclude:     o.Exclude,
+
+		force:        o.Force,
+		keepOriginal: o.KeepOriginal,
+		noCache:      o.NoCache,
+		writeCache:   o.Cache,
+		keepVersions: o.KeepVersions,
+	}
+	if err := checkKeepOriginal(o.KeepOriginal); err != nil {
+		return nil, fmt.Errorf("keep original %w", err)
+	}
+	if s.selectMode == "" {
+		s.selectMode = "latest"
+	}
+	if s.clock == nil {
+		s.clock = time.Now
+	}
+	if s.decodeMode == "" {
+		s.decodeMode = "default"
+	}
+	s.rules = rulesets["japanese"]
+	if o.Rules != "" {
+		var err error
+		if s.rules, err = lookupRules(o.Rules); err != nil {
+			return nil, err
+		}
+	}
+	if o.App != "" {
+		known, err := knownApps()
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range known {
+			if a.Name == o.App {
+				s.app = a
+			}
+		}
+		if s.app == nil {
+			return nil, fmt.Errorf("unknown app %q", o.App)
+		}
+	}
+	return s, nil
+}
+
+// reportMu guards report, which injections running at once may add notes to.
+var reportMu sync.Mutex
+
+// note prints a progress message to stderr, or records ev in the JSON report in JSON mode.
+func (s *settings) note(ev event, format string, args ...interface{}) {
+	if s.json {
+		reportMu.Lock()
+		report.Notes = append(report.Notes, ev)
+		reportMu.Unlock()
+		return
+	}
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+}
+
+// warnedMu guards warned.
+var warnedMu sync.Mutex
+
+// warn notes a lenient decoding warning, once.
+func (s *settings) warn(msg string) {
+	warnedMu.Lock()
+	seen := warned[msg]
+	warned[msg] = true
+	warnedMu.Unlock()
+	if !seen {
+		s.note(event{"event": "warning", "warning": msg}, "warning: %s", msg)
+	}
+}
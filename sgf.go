@@ -0,0 +1,350 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// sgfNode is a node of an SGF game tree, mapping property identifiers to their values.
+type sgfNode map[string][]string
+
+// parseSGF returns the nodes of the main line of the first game in s.
+func parseSGF(s string) ([]sgfNode, error) {
+	p := &sgfParser{s: s}
+	p.skipSpace()
+	if p.i >= len(p.s) || p.s[p.i] != '(' {
+		return nil, fmt.Errorf("sgf: expected '('")
+	}
+	return p.tree()
+}
+
+// splitSGF returns the text of each game of the SGF collection s, such as a file of problems.
+func splitSGF(s string) ([]string, error) {
+	p := &sgfParser{s: s}
+	var games []string
+	for {
+		p.skipSpace()
+		if p.i >= len(p.s) {
+			break
+		}
+		if p.s[p.i] != '(' {
+			return nil, fmt.Errorf("sgf: expected '('")
+		}
+		start := p.i
+		if _, err := p.tree(); err != nil {
+			return nil, err
+		}
+		games = append(games, s[start:p.i])
+	}
+	if len(games) == 0 {
+		return nil, fmt.Errorf("sgf: empty collection")
+	}
+	return games, nil
+}
+
+type sgfParser struct {
+	s string
+	i int
+}
+
+func (p *sgfParser) skipSpace() {
+	for p.i < len(p.s) && strings.IndexByte(" \t\r\n", p.s[p.i]) >= 0 {
+		p.i++
+	}
+}
+
+// tree parses a game tree starting at '(' and returns its main line.
+func (p *sgfParser) tree() ([]sgfNode, error) {
+	p.i++ // '('
+	var nodes []sgfNode
+	var mainDone bool
+	for {
+		p.skipSpace()
+		if p.i >= len(p.s) {
+			return nil, fmt.Errorf("sgf: unexpected end")
+		}
+		switch p.s[p.i] {
+		case ';':
+			p.i++
+			n, err := p.node()
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, n)
+		case '(':
+			variation, err := p.tree()
+			if err != nil {
+				return nil, err
+			}
+			if !mainDone {
+				nodes = append(nodes, variation...)
+				mainDone = true
+			}
+		case ')':
+			p.i++
+			return nodes, nil
+		default:
+			return nil, fmt.Errorf("sgf: unexpected %q at %d", p.s[p.i], p.i)
+		}
+	}
+}
+
+func (p *sgfParser) node() (sgfNode, error) {
+	n := make(sgfNode)
+	for {
+		p.skipSpace()
+		start := p.i
+		for p.i < len(p.s) && p.s[p.i] >= 'A' && p.s[p.i] <= 'Z' {
+			p.i++
+		}
+		id := p.s[start:p.i]
+		if id == "" {
+			return n, nil
+		}
+		p.skipSpace()
+		if p.i >= len(p.s) || p.s[p.i] != '[' {
+			return nil, fmt.Errorf("sgf: property %s has no value", id)
+		}
+		for p.i < len(p.s) && p.s[p.i] == '[' {
+			p.i++
+			var v strings.Builder
+			for ; p.i < len(p.s) && p.s[p.i] != ']'; p.i++ {
+				if p.s[p.i] == '\\' && p.i+1 < len(p.s) {
+					p.i++
+				}
+				v.WriteByte(p.s[p.i])
+			}
+			if p.i >= len(p.s) {
+				return nil, fmt.Errorf("sgf: unterminated value of %s", id)
+			}
+			p.i++ // ']'
+			n[id] = append(n[id], v.String())
+			p.skipSpace()
+		}
+	}
+}
+
+// sgfPoint parses an SGF point of the stone s; empty values and "tt" on boards up to 19x19 are passes.
+func sgfPoint(v string, s Stone, size int) (Move, error) {
+	if v == "" || (v == "tt" && size <= 19) {
+		return newMove(s, 0, 0), nil
+	}
+	if len(v) != 2 {
+		return Move{}, fmt.Errorf("sgf: bad point %q", v)
+	}
+	x, y := int(v[0]-'a')+1, int(v[1]-'a')+1
+	if x < 1 || x > size || y < 1 || y > size {
+		return Move{}, fmt.Errorf("sgf: point %q outside of the board", v)
+	}
+	return newMove(s, int32(x), int32(y)), nil
+}
+
+// sgfGame replaces the board of the record base with the main line of an SGF game.
+func sgfGame(ctx context.Context, s string, base []byte) ([]byte, error) {
+	nodes, err := parseSGF(s)
+	if err != nil {
+		return nil, invalid(err)
+	}
+	size, moves, err := sgfMoves(nodes)
+	if err != nil {
+		return nil, err
+	}
+	g, err := settingsOf(ctx).decode(base)
+	if err != nil {
+		return nil, err
+	}
+	g.Size = int32(size)
+	g.Moves = moves
+	return g.Encode(), nil
+}
+
+// sgfMoves returns the board size and the moves of the main line of an SGF game.
+// Setup stones are played as in Position.moves, and passes are inserted where the SGF moves do not alternate.
+// Move times are taken from the BL and WL time left properties when they are present.
+func sgfMoves(nodes []sgfNode) (int, []Move, error) {
+	if len(nodes) == 0 {
+		return 0, nil, fmt.Errorf("sgf: empty game")
+	}
+	var err error
+	size := 19
+	if sz := nodes[0]["SZ"]; len(sz) > 0 {
+		size, err = strconv.Atoi(strings.SplitN(sz[0], ":", 2)[0])
+		if err != nil || size < 2 || size > 25 {
+			return 0, nil, invalid(fmt.Errorf("sgf: bad board size %q", sz[0]))
+		}
+	}
+
+	pos := &Position{Size: size, Board: make([]Stone, size*size)}
+	var moves []Move
+	next := Empty
+	timeLeft := make(map[string]float64) // the last BL and WL values
+	for _, n := range nodes {
+		for _, setup := range []struct {
+			id    string
+			stone Stone
+		}{{"AB", Black}, {"AW", White}, {"AE", Empty}} {
+			for _, v := range n[setup.id] {
+				if moves != nil {
+					return 0, nil, unsupported(fmt.Errorf("sgf: setup stones after the first move are not supported"))
+				}
+				m, err := sgfPoint(v, setup.stone, size)
+				if err != nil {
+					return 0, nil, err
+				}
+				if !m.IsPass() {
+					pos.Board[(m.Y-1)*int32(size)+m.X-1] = setup.stone
+				}
+			}
+		}
+		if pl := n["PL"]; len(pl) > 0 && moves == nil {
+			pos.ToMove = sgfColor(pl[0])
+		}
+		for _, id := range []string{"B", "W"} {
+			for _, v := range n[id] {
+				if moves == nil {
+					if pos.ToMove == Empty {
+						pos.ToMove = sgfColor(id)
+					}
+					if moves, err = pos.moves(); err != nil {
+						return 0, nil, invalid(fmt.Errorf("sgf: %w", err))
+					}
+					next = pos.ToMove
+				}
+				m, err := sgfPoint(v, sgfColor(id), size)
+				if err != nil {
+					return 0, nil, err
+				}
+				if sgfColor(id) != next {
+					moves = append(moves, newMove(next, 0, 0))
+				}
+				// The elapsed time of a move is the decrease of its player's time left.
+				if tl := n[id+"L"]; len(tl) > 0 {
+					if left, err := strconv.ParseFloat(tl[0], 64); err == nil {
+						if prev, ok := timeLeft[id]; ok && prev >= left {
+							m.Time = int32(prev - left + 0.5)
+						}
+						timeLeft[id] = left
+					}
+				}
+				moves = append(moves, m)
+				next = sgfColor(id).Opponent()
+			}
+		}
+	}
+	if moves == nil {
+		if moves, err = pos.moves(); err != nil {
+			return 0, nil, invalid(fmt.Errorf("sgf: %w", err))
+		}
+	}
+	return size, moves, nil
+}
+
+func sgfColor(v string) Stone {
+	if strings.ToUpper(v) == "W" {
+		return White
+	}
+	return Black
+}
+
+// Opponent returns the other color.
+func (s Stone) Opponent() Stone {
+	switch s {
+	case Black:
+		return White
+	case White:
+		return Black
+	}
+	return Empty
+}
+
+// writeSGF returns the SGF of the moves of g.
+func writeSGF(g *Game) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "(;GM[1]FF[4]CA[UTF-8]AP[chamgo]SZ[%d]", g.Size)
+	for _, m := range g.Moves {
+		b.WriteString(g.sgfMove(m))
+	}
+	b.WriteString(")\n")
+	return b.String()
+}
+
+// sgfMove returns the SGF node of the move m of g.
+func (g *Game) sgfMove(m Move) string {
+	color := "B"
+	if m.Stone() == White {
+		color = "W"
+	}
+	if !g.onBoard(m) {
+		return ";" + color + "[]"
+	}
+	return ";" + color + "[" + string(rune('a'+m.X-1)) + string(rune('a'+m.Y-1)) + "]"
+}
+
+// sgfFirst are the properties written first in nodes, in this order, before the others sorted.
+var sgfFirst = []string{"GM", "FF", "CA", "AP", "SZ", "B", "W"}
+
+// writeSGFNode writes the node n to b, with its values escaped.
+func writeSGFNode(b *strings.Builder, n sgfNode) {
+	b.WriteByte(';')
+	var ids []string
+	for _, id := range sgfFirst {
+		if _, ok := n[id]; ok {
+			ids = append(ids, id)
+		}
+	}
+	for _, id := range sortedKeys(n) {
+		if !slices.Contains(sgfFirst, id) {
+			ids = append(ids, id)
+		}
+	}
+	for _, id := range ids {
+		b.WriteString(id)
+		for _, v := range n[id] {
+			b.WriteString("[" + strings.NewReplacer(`\`, `\\`, "]", `\]`).Replace(v) + "]")
+		}
+	}
+}
+
+// sourceSGF returns the SGF of a game read by loadGame: the SGF file of args itself, keeping its players,
+// comments and variations, or else the SGF of g.
+func sourceSGF(g *Game, args []string) (string, error) {
+	if len(args) == 0 {
+		return writeSGF(g), nil
+	}
+	b, err := ioutil.ReadFile(args[0])
+	return string(b), err
+}
+
+func sgfCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("sgf", flag.ContinueOnError)
+	avx := fs.String("a", "", "Champion Go archive")
+	game := fs.String("g", "latest", `the game to export: an entry name, "latest" or "latest-online"`)
+	clip := fs.Bool("to-clipboard", false, "copy the SGF to the clipboard instead of printing it")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	_, body, err := readEntry(ctx, *avx, *game)
+	if err != nil {
+		return err
+	}
+	if body == nil {
+		return errNoGames
+	}
+	g, err := Decode(body)
+	if err != nil {
+		return err
+	}
+	if *clip {
+		return writeClipboard(writeSGF(g))
+	}
+	s := writeSGF(g)
+	output(map[string]string{"sgf": s}, func(w io.Writer) { fmt.Fprint(w, s) })
+	return nil
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+)
+
+func FuzzSGF(f *testing.F) {
+	f.Add("(;GM[1]SZ[19];B[pd];W[dp];B[pp](;W[dd])(;W[dc]))")
+	f.Add("(;SZ[9]AB[cc][gg]AW[cg]PL[W];W[ee])(;SZ[13];B[])")
+	f.Add("(;C[a \\] comment];B[tt])")
+	base := synthGame(rand.New(rand.NewSource(5)), currentLayout, 19, 5, synthEpoch).Encode()
+	f.Fuzz(func(t *testing.T, s string) {
+		splitSGF(s)
+		if nodes, err := parseSGF(s); err == nil {
+			sgfMoves(nodes)
+		}
+		if body, err := sgfGame(context.Background(), s, base); err == nil {
+			if _, err := Decode(body); err != nil {
+				t.Fatalf("decoding the record of the SGF: %v", err)
+			}
+		}
+		problemRecord(s, base)
+	})
+}
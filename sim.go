@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// simContainerMetadata is the file in each app data container of the iOS Simulator naming the app of the container.
+const simContainerMetadata = ".com.apple.mobile_container_manager.metadata.plist"
+
+// simContainer is the data container of an app installed in a simulated device.
+type simContainer struct {
+	Device   string    `json:"device"` // the UDID of the simulated device
+	Name     string    `json:"name"`   // the name of the simulated device
+	Runtime  string    `json:"runtime,omitempty"`
+	Bundle   string    `json:"bundle"`
+	Dir      string    `json:"dir"`
+	Games    int       `json:"games"`
+	Modified time.Time `json:"modified"`
+}
+
+// simDevicesDir returns the directory of the simulated devices of Xcode.
+func simDevicesDir() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, "Library", "Developer", "CoreSimulator", "Devices")
+}
+
+// plistString returns the string at key of the property list file fn, or "" if it has none.
+func plistString(fn, key string) string {
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return ""
+	}
+	v, err := parsePlist(b)
+	if err != nil {
+		return ""
+	}
+	d, ok := v.(*plistDict)
+	if !ok {
+		return ""
+	}
+	s, _ := d.Get(key)
+	str, _ := s.(string)
+	return str
+}
+
+// simContainers returns the app data containers of the simulated devices in root, most recently modified first.
+// Only the containers with saved games are returned, unless bundle names the app.
+func simContainers(root, bundle string) ([]simContainer, error) {
+	fns, err := filepath.Glob(filepath.Join(root, "*", "data", "Containers", "Data", "Application", "*", simContainerMetadata))
+	if err != nil {
+		return nil, err
+	}
+	var cs []simContainer
+	for _, fn := range fns {
+		dir := filepath.Dir(fn)
+		device := filepath.Dir(filepath.Dir(filepath.Dir(filepath.Dir(filepath.Dir(dir))))) // up from data/Containers/Data/Application/UUID
+		c := simContainer{
+			Device:  filepath.Base(device),
+			Name:    plistString(filepath.Join(device, "device.plist"), "name"),
+			Runtime: strings.TrimPrefix(plistString(filepath.Join(device, "device.plist"), "runtime"), "com.apple.CoreSimulator.SimRuntime."),
+			Bundle:  plistString(fn, "MCMMetadataIdentifier"),
+			Dir:     dir,
+		}
+		for _, online := range []bool{false, true} {
+			games, _ := filepath.Glob(filepath.Join(dir, filepath.FromSlash(strings.TrimPrefix(gameDir(online), "Container/")), "*"))
+			c.Games += len(games)
+		}
+		if bundle != "" && c.Bundle != bundle || bundle == "" && c.Games == 0 {
+			continue
+		}
+		if fi, err := os.Stat(dir); err == nil {
+			c.Modified = fi.ModTime()
+		}
+		cs = append(cs, c)
+	}
+	sort.Slice(cs, func(i, j int) bool { return cs[i].Modified.After(cs[j].Modified) })
+	return cs, nil
+}
+
+// findSimContainer returns the container of the app in the simulated device whose UDID starts with device,
+// or in the only simulated device with the app.
+func findSimContainer(root, device, bundle string) (simContainer, error) {
+	cs, err := simContainers(root, bundle)
+	if err != nil {
+		return simContainer{}, err
+	}
+	var found []simContainer
+	for _, c := range cs {
+		if strings.HasPrefix(c.Device, device) {
+			found = append(found, c)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return simContainer{}, fmt.Errorf("no simulated device in %s has the app with saved games: %w", root, errNoGames)
+	case 1:
+		return found[0], nil
+	}
+	var ds []string
+	for _, c := range found {
+		ds = append(ds, fmt.Sprintf("%s (%s)", c.Device, c.Name))
+	}
+	return simContainer{}, fmt.Errorf("several simulated devices have the app, pick one with -device: %s", strings.Join(ds, ", "))
+}
+
+// simctl runs xcrun simctl, for restarting the app after its files were changed.
+func simctl(ctx context.Context, args ...string) error {
+	cmd := exec.CommandContext(ctx, "xcrun", append([]string{"simctl"}, args...)...)
+	cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+	if err := cmd.Run(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return unsupported(fmt.Errorf("xcrun not found, install Xcode"))
+		}
+		return fmt.Errorf("simctl %s: %w", args[0], err)
+	}
+	return nil
+}
+
+func simCmd(ctx context.Context, args []string) error {
+	usage := fmt.Errorf("usage: chamgo sim list|pull|push [flags]")
+	if len(args) == 0 {
+		return usage
+	}
+	fs := flag.NewFlagSet("sim "+args[0], flag.ContinueOnError)
+	root := fs.String("root", simDevicesDir(), "directory of the simulated devices")
+	device := fs.String("device", "", "UDID, or its beginning, of the simulated device")
+	bundle := fs.String("bundle", "", "bundle ID of the app, by default the app with saved games")
+	avx := fs.String("a", "", "archive written to the app by push")
+	out := fs.String("o", "-", "output archive of pull")
+	relaunch := fs.Bool("relaunch", false, "restart the app after push, so that it reads the new files")
+	if err := parseFlags(fs, args[1:]); err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "list":
+		cs, err := simContainers(*root, *bundle)
+		if err != nil {
+			return err
+		}
+		output(cs, func(w io.Writer) {
+			for _, c := range cs {
+				fmt.Fprintf(w, "%s  %s %s  %s  %d games\n    %s\n", c.Device, c.Name, c.Runtime, c.Bundle, c.Games, c.Dir)
+			}
+		})
+		return nil
+	case "pull":
+		c, err := findSimContainer(*root, *device, *bundle)
+		if err != nil {
+			return err
+		}
+		tmp, err := ioutil.TempDir("", "chamgo-sim-")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(tmp)
+		// The container holds what the Container directory of .imazingapp archives does.
+		n := 0
+		err = filepath.Walk(c.Dir, func(p string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(c.Dir, p)
+			if err != nil || fi.IsDir() || rel == simContainerMetadata {
+				return err
+			}
+			b, err := ioutil.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			n++
+			return writeFileAll(filepath.Join(tmp, "Container", rel), b)
+		})
+		if err != nil {
+			return err
+		}
+		note(event{"event": "pull", "device": c.Device, "container": c.Dir, "files": n}, "%d files from %s on %s", n, c.Bundle, c.Name)
+		return writeAvxFile(ctx, *out, tmp, avxEdit{NoJournal: true})
+	case "push":
+		c, err := findSimContainer(*root, *device, *bundle)
+		if err != nil {
+			return err
+		}
+		unlock, err := lockOutput(ctx, c.Dir)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+		a, err := openArchive(ctx, *avx)
+		if err != nil {
+			return err
+		}
+		defer a.Close()
+		keep := make(map[string]bool)
+		for _, f := range a.Files {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if f.IsDir || !inDir(f.Name, "Container/") {
+				continue
+			}
+			rel := strings.TrimPrefix(f.Name, "Container/")
+			dst, err := safeJoin(c.Dir, rel)
+			if err != nil {
+				return err
+			}
+			keep[dst] = true
+			body, err := f.ReadAll()
+			if err != nil {
+				return err
+			}
+			if old, err := ioutil.ReadFile(dst); err == nil && bytes.Equal(old, body) {
+				continue
+			}
+			if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+				return err
+			}
+			if err := writeFileAtomic(dst, body); err != nil {
+				return err
+			}
+			note(event{"event": "update", "entry": f.Name}, "updated %s", f.Name)
+		}
+		// Games renamed or removed in the archive are removed from the app as well.
+		for _, online := range []bool{false, true} {
+			games, _ := filepath.Glob(filepath.Join(c.Dir, filepath.FromSlash(strings.TrimPrefix(gameDir(online), "Container/")), "*"))
+			for _, g := range games {
+				if keep[g] {
+					continue
+				}
+				if err := os.Remove(g); err != nil {
+					return err
+				}
+				note(event{"event": "remove", "file": g}, "removed %s", g)
+			}
+		}
+		if !*relaunch {
+			return nil
+		}
+		simctl(ctx, "terminate", c.Device, c.Bundle) // fails if the app is not running
+		return simctl(ctx, "launch", c.Device, c.Bundle)
+	}
+	return usage
+}
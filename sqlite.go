@@ -0,0 +1,560 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+)
+
+// sqliteDB reads the tables and indexes of an SQLite database file, such as the Manifest.db of device backups
+// and the databases chamgo writes with writeSQLite. WAL files are not read.
+type sqliteDB struct {
+	r        io.ReaderAt
+	size     int64
+	pageSize int
+	usable   int // the page size less the bytes reserved at the end of each page
+	close    func() error
+}
+
+// sqliteRow is a row of an SQLite table, or an entry of an index, whose values are the indexed columns and the rowid.
+type sqliteRow struct {
+	RowID   int64
+	Values  []interface{} // nil, int64, float64, string or []byte
+	Offsets []int         // the offsets of the values in the file, or -1 for values spilling onto overflow pages
+}
+
+// str returns the text value i of r, or "" if it is NULL or missing, as in rows written before a column was added.
+func (r sqliteRow) str(i int) string {
+	if i < len(r.Values) {
+		s, _ := r.Values[i].(string)
+		return s
+	}
+	return ""
+}
+
+// int returns the integer value i of r, or 0 if it is NULL or missing.
+func (r sqliteRow) int(i int) int64 {
+	if i < len(r.Values) {
+		switch v := r.Values[i].(type) {
+		case int64:
+			return v
+		case float64:
+			return int64(v)
+		}
+	}
+	return 0
+}
+
+// float returns the numeric value i of r, and false if it is NULL or missing.
+func (r sqliteRow) float(i int) (float64, bool) {
+	if i < len(r.Values) {
+		switch v := r.Values[i].(type) {
+		case int64:
+			return float64(v), true
+		case float64:
+			return v, true
+		}
+	}
+	return 0, false
+}
+
+var sqliteMagic = []byte("SQLite format 3\x00")
+
+func openSQLite(b []byte) (*sqliteDB, error) {
+	return newSQLiteDB(bytes.NewReader(b), int64(len(b)))
+}
+
+// openSQLiteFile opens the database file fn. A database replaced while it is open,
+// as writeSQLite replaces them, is read as it was when it was opened.
+func openSQLiteFile(fn string) (*sqliteDB, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	db, err := newSQLiteDB(f, fi.Size())
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", fn, err)
+	}
+	db.close = f.Close
+	return db, nil
+}
+
+func newSQLiteDB(r io.ReaderAt, size int64) (*sqliteDB, error) {
+	hdr := make([]byte, 100)
+	if _, err := r.ReadAt(hdr, 0); err != nil || !bytes.HasPrefix(hdr, sqliteMagic) {
+		return nil, invalid(fmt.Errorf("not an SQLite database"))
+	}
+	db := &sqliteDB{r: r, size: size, pageSize: int(binary.BigEndian.Uint16(hdr[16:]))}
+	if db.pageSize == 1 {
+		db.pageSize = 65536
+	}
+	db.usable = db.pageSize - int(hdr[20])
+	if db.pageSize < 512 || db.usable < 480 {
+		return nil, invalid(fmt.Errorf("sqlite: bad page size %d", db.pageSize))
+	}
+	return db, nil
+}
+
+func (db *sqliteDB) Close() error {
+	if db.close == nil {
+		return nil
+	}
+	return db.close()
+}
+
+// page returns page n, numbered from 1, and the offset of its b-tree header, which follows the file header on page 1.
+func (db *sqliteDB) page(n uint32) ([]byte, int, error) {
+	start := int64(n-1) * int64(db.pageSize)
+	if n == 0 || start+int64(db.pageSize) > db.size {
+		return nil, 0, invalid(fmt.Errorf("sqlite: page %d is outside of the file", n))
+	}
+	p := make([]byte, db.pageSize)
+	if _, err := db.r.ReadAt(p, start); err != nil {
+		return nil, 0, err
+	}
+	hdr := 0
+	if n == 1 {
+		hdr = 100
+	}
+	return p, hdr, nil
+}
+
+// B-tree page kinds.
+const (
+	sqliteIndexInterior = 0x02
+	sqliteTableInterior = 0x05
+	sqliteIndexLeaf     = 0x0a
+	sqliteTableLeaf     = 0x0d
+)
+
+// btreePage is a b-tree page: its kind, the offsets of its cells and, for interior pages, its right-most child.
+type btreePage struct {
+	n     uint32
+	p     []byte
+	kind  byte
+	cells []int
+	right uint32
+}
+
+func (db *sqliteDB) btree(n uint32) (*btreePage, error) {
+	p, hdr, err := db.page(n)
+	if err != nil {
+		return nil, err
+	}
+	pg := &btreePage{n: n, p: p, kind: p[hdr]}
+	ptrs := hdr + 8
+	switch pg.kind {
+	case sqliteIndexInterior, sqliteTableInterior:
+		ptrs = hdr + 12
+		pg.right = binary.BigEndian.Uint32(p[hdr+8:])
+	case sqliteIndexLeaf, sqliteTableLeaf:
+	default:
+		return nil, invalid(fmt.Errorf("sqlite: page %d is not a b-tree page", n))
+	}
+	ncells := int(binary.BigEndian.Uint16(p[hdr+3:]))
+	if ptrs+2*ncells > len(p) {
+		return nil, invalid(fmt.Errorf("sqlite: page %d has too many cells", n))
+	}
+	for i := 0; i < ncells; i++ {
+		off := int(binary.BigEndian.Uint16(p[ptrs+2*i:]))
+		if off >= len(p) || pg.kind != sqliteTableLeaf && pg.kind != sqliteIndexLeaf && off+4 > len(p) {
+			return nil, invalid(fmt.Errorf("sqlite: bad cell offset on page %d", n))
+		}
+		pg.cells = append(pg.cells, off)
+	}
+	return pg, nil
+}
+
+// child returns the left child of the interior page cell i.
+func (pg *btreePage) child(i int) uint32 { return binary.BigEndian.Uint32(pg.p[pg.cells[i]:]) }
+
+func sqliteVarint(b []byte) (int64, int) {
+	var v uint64
+	for i := 0; i < 9 && i < len(b); i++ {
+		if i == 8 {
+			return int64(v<<8 | uint64(b[i])), 9
+		}
+		v = v<<7 | uint64(b[i]&0x7f)
+		if b[i]&0x80 == 0 {
+			return int64(v), i + 1
+		}
+	}
+	return int64(v), len(b)
+}
+
+// maxLocal returns how many bytes of a payload the cells of table leaf pages, or of index pages, keep on the page.
+func sqliteMaxLocal(usable int, index bool) int {
+	if index {
+		return (usable-12)*64/255 - 23
+	}
+	return usable - 35
+}
+
+// sqliteLocal returns how many bytes of a payload of size are kept on the page, the rest going to overflow pages.
+func sqliteLocal(usable, size int, index bool) int {
+	x := sqliteMaxLocal(usable, index)
+	if size <= x {
+		return size
+	}
+	m := (usable-12)*32/255 - 23
+	local := m + (size-m)%(usable-4)
+	if local > x {
+		local = m
+	}
+	return local
+}
+
+// rows returns the rows of the table b-tree rooted at page root.
+func (db *sqliteDB) rows(root uint32) ([]sqliteRow, error) {
+	var rows []sqliteRow
+	var walk func(n uint32, depth int) error
+	walk = func(n uint32, depth int) error {
+		if depth > 64 {
+			return invalid(fmt.Errorf("sqlite: b-tree too deep"))
+		}
+		pg, err := db.btree(n)
+		if err != nil {
+			return err
+		}
+		switch pg.kind {
+		case sqliteTableInterior:
+			for i := range pg.cells {
+				if err := walk(pg.child(i), depth+1); err != nil {
+					return err
+				}
+			}
+			return walk(pg.right, depth+1)
+		case sqliteTableLeaf:
+			for _, off := range pg.cells {
+				row, err := db.cell(pg, off)
+				if err != nil {
+					return err
+				}
+				rows = append(rows, row)
+			}
+			return nil
+		}
+		return invalid(fmt.Errorf("sqlite: page %d is not a table page", n))
+	}
+	return rows, walk(root, 0)
+}
+
+// row returns the row rowid of the table b-tree rooted at page root, and false if there is none.
+func (db *sqliteDB) row(root uint32, rowid int64) (sqliteRow, bool, error) {
+	n := root
+	for depth := 0; depth <= 64; depth++ {
+		pg, err := db.btree(n)
+		if err != nil {
+			return sqliteRow{}, false, err
+		}
+		switch pg.kind {
+		case sqliteTableInterior:
+			// The key of a cell is the largest rowid of its left child.
+			n = pg.right
+			for i, off := range pg.cells {
+				if key, _ := sqliteVarint(pg.p[off+4:]); rowid <= key {
+					n = pg.child(i)
+					break
+				}
+			}
+		case sqliteTableLeaf:
+			for _, off := range pg.cells {
+				_, k := sqliteVarint(pg.p[off:])
+				if id, _ := sqliteVarint(pg.p[off+k:]); id == rowid {
+					row, err := db.cell(pg, off)
+					return row, err == nil, err
+				}
+			}
+			return sqliteRow{}, false, nil
+		default:
+			return sqliteRow{}, false, invalid(fmt.Errorf("sqlite: page %d is not a table page", n))
+		}
+	}
+	return sqliteRow{}, false, invalid(fmt.Errorf("sqlite: b-tree too deep"))
+}
+
+// indexRange returns the entries of the index b-tree rooted at page root whose first column is at least from
+// and less than to, or unbounded if to is nil, in index order.
+func (db *sqliteDB) indexRange(root uint32, from, to interface{}) ([]sqliteRow, error) {
+	var found []sqliteRow
+	inRange := func(e sqliteRow) (bool, bool) {
+		if len(e.Values) == 0 {
+			return false, false
+		}
+		if to != nil && compareSQLite(e.Values[0], to) >= 0 {
+			return false, true
+		}
+		return compareSQLite(e.Values[0], from) >= 0, false
+	}
+	// walk reports whether the entries past the range were reached.
+	var walk func(n uint32, depth int) (bool, error)
+	walk = func(n uint32, depth int) (bool, error) {
+		if depth > 64 {
+			return false, invalid(fmt.Errorf("sqlite: b-tree too deep"))
+		}
+		pg, err := db.btree(n)
+		if err != nil {
+			return false, err
+		}
+		if pg.kind != sqliteIndexInterior && pg.kind != sqliteIndexLeaf {
+			return false, invalid(fmt.Errorf("sqlite: page %d is not an index page", n))
+		}
+		for i, off := range pg.cells {
+			e, err := db.indexCell(pg, off)
+			if err != nil {
+				return false, err
+			}
+			// The entries of the left child of a cell sort before it.
+			if pg.kind == sqliteIndexInterior && len(e.Values) > 0 && compareSQLite(e.Values[0], from) >= 0 {
+				if past, err := walk(pg.child(i), depth+1); past || err != nil {
+					return past, err
+				}
+			}
+			ok, past := inRange(e)
+			if past {
+				return true, nil
+			}
+			if ok {
+				found = append(found, e)
+			}
+		}
+		if pg.kind == sqliteIndexInterior {
+			return walk(pg.right, depth+1)
+		}
+		return false, nil
+	}
+	_, err := walk(root, 0)
+	return found, err
+}
+
+// payload returns the payload of size of the cell at off of pg, whose first local bytes are at off
+// and the rest on a chain of overflow pages.
+func (db *sqliteDB) payload(pg *btreePage, off int, size int64, local int) ([]byte, error) {
+	if size < 0 || size > db.size {
+		return nil, invalid(fmt.Errorf("sqlite: bad payload size on page %d", pg.n))
+	}
+	if off+local > len(pg.p) {
+		return nil, invalid(fmt.Errorf("sqlite: cell overflows page %d", pg.n))
+	}
+	payload := append([]byte(nil), pg.p[off:off+local]...)
+	if local < int(size) {
+		if off+local+4 > len(pg.p) {
+			return nil, invalid(fmt.Errorf("sqlite: cell overflows page %d", pg.n))
+		}
+		next := binary.BigEndian.Uint32(pg.p[off+local:])
+		for len(payload) < int(size) {
+			op, _, err := db.page(next)
+			if err != nil {
+				return nil, err
+			}
+			payload = append(payload, op[4:min(db.usable, 4+int(size)-len(payload))]...)
+			next = binary.BigEndian.Uint32(op)
+		}
+	}
+	return payload, nil
+}
+
+// cell decodes the leaf table cell at off of pg.
+func (db *sqliteDB) cell(pg *btreePage, off int) (sqliteRow, error) {
+	size, k := sqliteVarint(pg.p[off:])
+	off += k
+	rowid, k := sqliteVarint(pg.p[off:])
+	off += k
+	local := sqliteLocal(db.usable, int(min(size, db.size)), false)
+	payload, err := db.payload(pg, off, size, local)
+	if err != nil {
+		return sqliteRow{}, err
+	}
+	row := sqliteRow{RowID: rowid}
+	base := int(pg.n-1)*db.pageSize + off // the offset of the payload in the file
+	row.Values, row.Offsets, err = sqliteRecord(payload, base, local)
+	if err != nil {
+		return sqliteRow{}, fmt.Errorf("%w on page %d", err, pg.n)
+	}
+	return row, nil
+}
+
+// indexCell decodes the index cell at off of pg, whose last value is the rowid of the table row it indexes.
+func (db *sqliteDB) indexCell(pg *btreePage, off int) (sqliteRow, error) {
+	if pg.kind == sqliteIndexInterior {
+		off += 4
+	}
+	size, k := sqliteVarint(pg.p[off:])
+	off += k
+	local := sqliteLocal(db.usable, int(min(size, db.size)), true)
+	payload, err := db.payload(pg, off, size, local)
+	if err != nil {
+		return sqliteRow{}, err
+	}
+	var e sqliteRow
+	e.Values, e.Offsets, err = sqliteRecord(payload, int(pg.n-1)*db.pageSize+off, local)
+	if err != nil {
+		return sqliteRow{}, fmt.Errorf("%w on page %d", err, pg.n)
+	}
+	if len(e.Values) > 0 {
+		e.RowID, _ = e.Values[len(e.Values)-1].(int64)
+	}
+	return e, nil
+}
+
+// sqliteRecord decodes the record payload, whose first local bytes are at base in the file.
+func sqliteRecord(payload []byte, base, local int) ([]interface{}, []int, error) {
+	var values []interface{}
+	var offsets []int
+	hlen, k := sqliteVarint(payload)
+	if hlen > int64(len(payload)) {
+		return nil, nil, invalid(fmt.Errorf("sqlite: bad record header"))
+	}
+	data := int(hlen)
+	for h := k; h < int(hlen); {
+		t, k := sqliteVarint(payload[h:])
+		h += k
+		var v interface{}
+		var l int
+		switch {
+		case t == 0:
+		case t >= 1 && t <= 6:
+			l = []int{0, 1, 2, 3, 4, 6, 8}[t]
+			if data+l > len(payload) {
+				return nil, nil, invalid(fmt.Errorf("sqlite: truncated record"))
+			}
+			var x int64
+			for _, c := range payload[data : data+l] {
+				x = x<<8 | int64(c)
+			}
+			if shift := 64 - 8*uint(l); payload[data]&0x80 != 0 {
+				x = x << shift >> shift // sign extend
+			}
+			v = x
+		case t == 7:
+			l = 8
+			if data+l > len(payload) {
+				return nil, nil, invalid(fmt.Errorf("sqlite: truncated record"))
+			}
+			v = math.Float64frombits(binary.BigEndian.Uint64(payload[data:]))
+		case t == 8 || t == 9:
+			v = t - 8
+		case t >= 12:
+			l = int(t-12) / 2
+			if data+l > len(payload) {
+				return nil, nil, invalid(fmt.Errorf("sqlite: truncated record"))
+			}
+			if t%2 == 0 {
+				v = append([]byte(nil), payload[data:data+l]...)
+			} else {
+				v = string(payload[data : data+l])
+			}
+		default:
+			return nil, nil, invalid(fmt.Errorf("sqlite: bad serial type %d", t))
+		}
+		o := -1
+		if data+l <= local {
+			o = base + data
+		}
+		values = append(values, v)
+		offsets = append(offsets, o)
+		data += l
+	}
+	return values, offsets, nil
+}
+
+// compareSQLite compares two values as SQLite orders them in indexes, with the binary collation:
+// NULL first, then numbers, text and blobs.
+func compareSQLite(a, b interface{}) int {
+	rank := func(v interface{}) int {
+		switch v.(type) {
+		case nil:
+			return 0
+		case int64, float64:
+			return 1
+		case string:
+			return 2
+		}
+		return 3
+	}
+	if ra, rb := rank(a), rank(b); ra != rb {
+		return ra - rb
+	}
+	switch a := a.(type) {
+	case int64:
+		if b, ok := b.(int64); ok {
+			return cmp.Compare(a, b)
+		}
+		return cmp.Compare(float64(a), b.(float64))
+	case float64:
+		if b, ok := b.(int64); ok {
+			return cmp.Compare(a, float64(b))
+		}
+		return cmp.Compare(a, b.(float64))
+	case string:
+		return strings.Compare(a, b.(string))
+	case []byte:
+		return bytes.Compare(a, b.([]byte))
+	}
+	return 0
+}
+
+// compareSQLiteRecords compares index entries column by column.
+func compareSQLiteRecords(a, b []interface{}) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := compareSQLite(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+	return len(a) - len(b)
+}
+
+// sqlitePrefixEnd returns the smallest text greater than every text starting with prefix, for indexRange,
+// or nil if there is none.
+func sqlitePrefixEnd(prefix string) interface{} {
+	b := []byte(prefix)
+	for i := len(b) - 1; i >= 0; i-- {
+		if b[i] < 0xff {
+			b[i]++
+			return string(b[:i+1])
+		}
+	}
+	return nil
+}
+
+// root returns the root page of the table or index, as kind says, called name.
+func (db *sqliteDB) root(kind, name string) (uint32, error) {
+	schema, err := db.rows(1)
+	if err != nil {
+		return 0, err
+	}
+	for _, r := range schema {
+		if len(r.Values) < 4 || r.Values[0] != kind {
+			continue
+		}
+		if n, _ := r.Values[1].(string); !strings.EqualFold(n, name) {
+			continue
+		}
+		root, ok := r.Values[3].(int64)
+		if !ok || root < 1 || root > math.MaxUint32 {
+			return 0, invalid(fmt.Errorf("sqlite: bad root page of %s %s", kind, name))
+		}
+		return uint32(root), nil
+	}
+	return 0, invalid(fmt.Errorf("sqlite: no %s %s", kind, name))
+}
+
+// table returns the rows of the table called name.
+func (db *sqliteDB) table(name string) ([]sqliteRow, error) {
+	root, err := db.root("table", name)
+	if err != nil {
+		return nil, err
+	}
+	return db.rows(root)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestSQLite writes databases with writeSQLite and reads them back, through the tables and the indexes.
+func TestSQLite(t *testing.T) {
+	tests := []struct {
+		name string
+		rows int
+		text int // the length of the names, which spill onto overflow pages past a few thousand bytes
+	}{
+		{"empty", 0, 0},
+		{"one page", 10, 8},
+		{"several levels", 30000, 20},
+		{"overflow", 300, 5000},
+		{"index overflow", 2000, 1500},
+	}
+	for _, tt := range tests {
+		fn := filepath.Join(t.TempDir(), "test.db")
+		var rows [][]interface{}
+		for i := 0; i < tt.rows; i++ {
+			text := fmt.Sprintf("%05d", (i*7919)%tt.rows) + strings.Repeat("x", tt.text)
+			var f interface{}
+			if i%3 != 0 {
+				f = float64(i) / 4
+			}
+			rows = append(rows, []interface{}{text, int64(i - 100), f, []byte{byte(i)}, i % 50})
+		}
+		tb := sqliteTable{Name: "things", Columns: []string{"name TEXT", "n INTEGER", "f REAL", "b BLOB", "g INTEGER"}, Rows: rows,
+			Indexes: []sqliteIndex{{Name: "things_name", Unique: true, Columns: []int{0}}, {Name: "things_g", Columns: []int{4, 1}}}}
+		if err := writeSQLite(fn, []sqliteTable{tb, {Name: "other", Columns: []string{"x"}}}); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		db, err := openSQLiteFile(fn)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		got, err := db.table("things")
+		if err != nil || len(got) != len(rows) {
+			t.Fatalf("%s: %d rows, %v, want %d", tt.name, len(got), err, len(rows))
+		}
+		for i, r := range got {
+			want := append([]interface{}(nil), rows[i]...)
+			want[4] = int64(i % 50)
+			if r.RowID != int64(i+1) || !reflect.DeepEqual(r.Values, want) {
+				t.Errorf("%s: row %d: %d %.40v, want %.40v", tt.name, i, r.RowID, r.Values, want)
+				break
+			}
+		}
+		for _, id := range []int64{1, int64(tt.rows / 2), int64(tt.rows), int64(tt.rows + 1)} {
+			r, ok, err := db.row(mustRoot(t, db, "table", "things"), id)
+			if want := id >= 1 && id <= int64(tt.rows); ok != want || err != nil || ok && r.RowID != id {
+				t.Errorf("%s: row %d: %v, %v, %v", tt.name, id, r.RowID, ok, err)
+			}
+		}
+		if tt.rows > 0 {
+			// Each name starting with 000 and the rows of group 7.
+			names, err := db.indexRange(mustRoot(t, db, "index", "things_name"), "000", sqlitePrefixEnd("000"))
+			if want := min(tt.rows, 100); err != nil || len(names) != want {
+				t.Errorf("%s: %d names, %v, want %d", tt.name, len(names), err, want)
+			}
+			for i, e := range names {
+				if want := fmt.Sprintf("%05d", i); !strings.HasPrefix(e.str(0), want) || e.str(0) != rows[e.RowID-1][0] {
+					t.Errorf("%s: name %d is %.10q of row %d, want %s", tt.name, i, e.str(0), e.RowID, want)
+					break
+				}
+			}
+			group, err := db.indexRange(mustRoot(t, db, "index", "things_g"), int64(7), int64(8))
+			if want := (tt.rows + 42) / 50; err != nil || len(group) != want {
+				t.Errorf("%s: %d rows in group 7, %v, want %d", tt.name, len(group), err, want)
+			}
+			for i, e := range group {
+				if e.int(0) != 7 || i > 0 && e.int(1) <= group[i-1].int(1) || e.RowID != e.int(1)+101 {
+					t.Errorf("%s: entry %v of group 7 out of order", tt.name, e.Values)
+					break
+				}
+			}
+		}
+		if rows, err := db.table("other"); len(rows) != 0 || err != nil {
+			t.Errorf("%s: other table: %d rows, %v", tt.name, len(rows), err)
+		}
+		db.Close()
+	}
+}
+
+func TestSQLiteDuplicate(t *testing.T) {
+	tb := sqliteTable{Name: "t", Columns: []string{"a", "b"}, Rows: [][]interface{}{{"x", 1}, {"y", 2}, {"x", 3}},
+		Indexes: []sqliteIndex{{Name: "t_a", Unique: true, Columns: []int{0}}}}
+	if err := writeSQLite(filepath.Join(t.TempDir(), "t.db"), []sqliteTable{tb}); err == nil {
+		t.Error("no error for duplicate values of a unique index")
+	}
+}
+
+func TestSQLiteEncode(t *testing.T) {
+	values := []interface{}{nil, int64(0), int64(1), int64(-1), int64(200), int64(-40000), int64(1 << 23), int64(-1 << 31),
+		int64(1 << 40), int64(-1 << 62), 2.5, "", "text", []byte{1, 2}, strings.Repeat("y", 200)}
+	got, _, err := sqliteRecord(sqliteEncode(values), 0, 0)
+	if err != nil || !reflect.DeepEqual(got, values) {
+		t.Errorf("record %v, %v, want %v", got, err, values)
+	}
+	for _, v := range []int64{0, 127, 128, 1 << 20, 1 << 56, -1} {
+		if got, n := sqliteVarint(sqlitePutVarint(v)); got != v || n != len(sqlitePutVarint(v)) {
+			t.Errorf("varint %d read as %d of %d bytes", v, got, n)
+		}
+	}
+}
+
+func mustRoot(t *testing.T, db *sqliteDB, kind, name string) uint32 {
+	t.Helper()
+	root, err := db.root(kind, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
@@ -0,0 +1,453 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+)
+
+// sqliteTable is a table of a database written by writeSQLite. Its rows are numbered from 1 in order,
+// and their values are nil, int, int64, float64, string or []byte.
+type sqliteTable struct {
+	Name    string
+	Columns []string // the column definitions, such as "path TEXT NOT NULL"
+	Rows    [][]interface{}
+	Indexes []sqliteIndex
+}
+
+// sqliteIndex is an index of a sqliteTable on some of its columns, given by position.
+type sqliteIndex struct {
+	Name    string
+	Unique  bool
+	Columns []int
+}
+
+// sqlitePageSize is the page size of the databases written by writeSQLite.
+const sqlitePageSize = 4096
+
+// writeSQLite writes a new SQLite database fn holding the tables, which the sqlite3 shell and other SQLite
+// libraries can read and query through the indexes. The database replaces fn at once, so that readers see
+// either the old or the new one; callers writing concurrently must hold lockOutput.
+func writeSQLite(fn string, tables []sqliteTable) error {
+	tmp := fn + ".chamgo-tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := &sqliteWriter{f: f, pages: 1} // page 1 is written last, with the schema
+	err = w.write(tables)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, fn)
+}
+
+// sqlNull returns s as a value of a text column, NULL if it is empty.
+func sqlNull(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+type sqliteWriter struct {
+	f     *os.File
+	pages uint32 // the number of pages allocated
+}
+
+func (w *sqliteWriter) alloc() uint32 {
+	w.pages++
+	return w.pages
+}
+
+func (w *sqliteWriter) put(n uint32, p []byte) error {
+	_, err := w.f.WriteAt(p, int64(n-1)*sqlitePageSize)
+	return err
+}
+
+func (w *sqliteWriter) write(tables []sqliteTable) error {
+	var schema [][]interface{}
+	for _, t := range tables {
+		root, err := w.table(t.Rows)
+		if err != nil {
+			return fmt.Errorf("%s: %w", t.Name, err)
+		}
+		sql := fmt.Sprintf("CREATE TABLE %s (%s)", t.Name, strings.Join(t.Columns, ", "))
+		schema = append(schema, []interface{}{"table", t.Name, t.Name, int64(root), sql})
+		for _, idx := range t.Indexes {
+			root, err := w.index(t, idx)
+			if err != nil {
+				return fmt.Errorf("%s: %w", idx.Name, err)
+			}
+			var cols []string
+			for _, c := range idx.Columns {
+				cols = append(cols, strings.Fields(t.Columns[c])[0])
+			}
+			create := "CREATE INDEX"
+			if idx.Unique {
+				create = "CREATE UNIQUE INDEX"
+			}
+			sql := fmt.Sprintf("%s %s ON %s (%s)", create, idx.Name, t.Name, strings.Join(cols, ", "))
+			schema = append(schema, []interface{}{"index", idx.Name, t.Name, int64(root), sql})
+		}
+	}
+
+	// Page 1 holds the file header and the schema, which must fit in it.
+	var cells [][]byte
+	for i, r := range schema {
+		payload := sqliteEncode(r)
+		cells = append(cells, append(append(sqlitePutVarint(int64(len(payload))), sqlitePutVarint(int64(i+1))...), payload...))
+	}
+	if sqliteUsed(cells, 100+8) > sqlitePageSize {
+		return fmt.Errorf("sqlite: schema does not fit in a page")
+	}
+	p := sqlitePage(sqliteTableLeaf, cells, 0, 100)
+	copy(p, sqliteMagic)
+	binary.BigEndian.PutUint16(p[16:], sqlitePageSize)
+	p[18], p[19] = 1, 1 // the legacy journal, not WAL
+	p[21], p[22], p[23] = 64, 32, 32
+	binary.BigEndian.PutUint32(p[24:], 1) // the change counter
+	binary.BigEndian.PutUint32(p[28:], w.pages)
+	binary.BigEndian.PutUint32(p[40:], 1) // the schema cookie
+	binary.BigEndian.PutUint32(p[44:], 4) // the schema format
+	binary.BigEndian.PutUint32(p[56:], 1) // UTF-8
+	binary.BigEndian.PutUint32(p[92:], 1) // the change counter the size is valid for
+	binary.BigEndian.PutUint32(p[96:], 3046000)
+	return w.put(1, p)
+}
+
+// sqliteUsed returns how many bytes of a page with a b-tree header of hdr bytes the cells take.
+func sqliteUsed(cells [][]byte, hdr int) int {
+	for _, c := range cells {
+		hdr += 2 + len(c)
+	}
+	return hdr
+}
+
+// sqlitePage lays out a b-tree page of kind with the cells, whose b-tree header is at off.
+func sqlitePage(kind byte, cells [][]byte, right uint32, off int) []byte {
+	p := make([]byte, sqlitePageSize)
+	p[off] = kind
+	binary.BigEndian.PutUint16(p[off+3:], uint16(len(cells)))
+	ptrs := off + 8
+	if kind == sqliteIndexInterior || kind == sqliteTableInterior {
+		binary.BigEndian.PutUint32(p[off+8:], right)
+		ptrs = off + 12
+	}
+	end := len(p)
+	for i, c := range cells {
+		end -= len(c)
+		copy(p[end:], c)
+		binary.BigEndian.PutUint16(p[ptrs+2*i:], uint16(end))
+	}
+	binary.BigEndian.PutUint16(p[off+5:], uint16(end)) // 65536 wraps to 0, as it should
+	return p
+}
+
+// cellLen returns the size of the part of a payload of size kept in a cell, with the overflow page pointer.
+func cellLen(size int, index bool) int {
+	if local := sqliteLocal(sqlitePageSize, size, index); local < size {
+		return local + 4
+	}
+	return size
+}
+
+// local returns the part of payload kept in a cell, writing the rest to a chain of overflow pages.
+func (w *sqliteWriter) local(payload []byte, index bool) ([]byte, error) {
+	local := sqliteLocal(sqlitePageSize, len(payload), index)
+	if local == len(payload) {
+		return payload, nil
+	}
+	cell := binary.BigEndian.AppendUint32(payload[:local:local], w.pages+1)
+	for rest := payload[local:]; len(rest) > 0; {
+		n := w.alloc()
+		p := make([]byte, sqlitePageSize)
+		rest = rest[copy(p[4:], rest):]
+		if len(rest) > 0 {
+			binary.BigEndian.PutUint32(p, n+1)
+		}
+		if err := w.put(n, p); err != nil {
+			return nil, err
+		}
+	}
+	return cell, nil
+}
+
+// table writes the table b-tree of rows, returning its root page.
+func (w *sqliteWriter) table(rows [][]interface{}) (uint32, error) {
+	type child struct {
+		page uint32
+		max  int64 // the largest rowid under page
+	}
+	var level []child
+	payloads := make([][]byte, len(rows))
+	for i, r := range rows {
+		payloads[i] = sqliteEncode(r)
+	}
+	leafCell := func(i int) int {
+		return len(sqlitePutVarint(int64(len(payloads[i])))) + len(sqlitePutVarint(int64(i+1))) + cellLen(len(payloads[i]), false)
+	}
+	capacity := sqlitePageSize - 8
+	// Leaves hold rows from one start to the next; there is no item going up between them.
+	var starts []int
+	for i := 0; i < len(rows); {
+		starts = append(starts, i)
+		used := 0
+		for i < len(rows) && (used == 0 || used+leafCell(i)+2 <= capacity) {
+			used += leafCell(i) + 2
+			i++
+		}
+	}
+	if len(starts) == 0 {
+		starts = []int{0}
+	}
+	for k, start := range starts {
+		end := len(rows)
+		if k+1 < len(starts) {
+			end = starts[k+1]
+		}
+		var cells [][]byte
+		for i := start; i < end; i++ {
+			local, err := w.local(payloads[i], false)
+			if err != nil {
+				return 0, err
+			}
+			cell := append(sqlitePutVarint(int64(len(payloads[i]))), sqlitePutVarint(int64(i+1))...)
+			cells = append(cells, append(cell, local...))
+		}
+		n := w.alloc()
+		if err := w.put(n, sqlitePage(sqliteTableLeaf, cells, 0, 0)); err != nil {
+			return 0, err
+		}
+		level = append(level, child{n, int64(end)})
+	}
+	for len(level) > 1 {
+		var up []child
+		cur := level
+		cellOf := func(c child) []byte {
+			return append(binary.BigEndian.AppendUint32(nil, c.page), sqlitePutVarint(c.max)...)
+		}
+		for i := 0; i < len(cur); {
+			// The page takes children i to j, j being the right-most one.
+			used, j := 12, i
+			for j+1 < len(cur) && used+len(cellOf(cur[j]))+2 <= sqlitePageSize {
+				used += len(cellOf(cur[j])) + 2
+				j++
+			}
+			if j == len(cur)-2 && j-i >= 2 {
+				j-- // leave the next page a cell besides its right-most child
+			}
+			var cells [][]byte
+			for _, c := range cur[i:j] {
+				cells = append(cells, cellOf(c))
+			}
+			n := w.alloc()
+			if err := w.put(n, sqlitePage(sqliteTableInterior, cells, cur[j].page, 0)); err != nil {
+				return 0, err
+			}
+			up = append(up, child{n, cur[j].max})
+			i = j + 1
+		}
+		level = up
+	}
+	return level[0].page, nil
+}
+
+// index writes the b-tree of the index idx of t, returning its root page.
+func (w *sqliteWriter) index(t sqliteTable, idx sqliteIndex) (uint32, error) {
+	entries := make([][]interface{}, len(t.Rows))
+	for i, r := range t.Rows {
+		e := make([]interface{}, 0, len(idx.Columns)+1)
+		for _, c := range idx.Columns {
+			var v interface{}
+			if c < len(r) {
+				v = r[c]
+			}
+			e = append(e, sqliteValue(v))
+		}
+		entries[i] = append(e, int64(i+1))
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return compareSQLiteRecords(entries[i], entries[j]) < 0 })
+	if idx.Unique {
+		for i := 1; i < len(entries); i++ {
+			a, b := entries[i-1], entries[i]
+			if compareSQLiteRecords(a[:len(a)-1], b[:len(b)-1]) == 0 && a[0] != nil {
+				return 0, fmt.Errorf("sqlite: duplicate %v", a[:len(a)-1])
+			}
+		}
+	}
+	payloads := make([][]byte, len(entries))
+	for i, e := range entries {
+		payloads[i] = sqliteEncode(e)
+	}
+	// Each entry is kept once, either on a leaf or between two pages on the interior page above them.
+	type item struct {
+		page    uint32
+		payload []byte // the entry after page, going up to the parent
+	}
+	cell := func(child uint32, payload []byte) ([]byte, error) {
+		local, err := w.local(payload, true)
+		if err != nil {
+			return nil, err
+		}
+		c := sqlitePutVarint(int64(len(payload)))
+		if child != 0 {
+			c = append(binary.BigEndian.AppendUint32(nil, child), c...)
+		}
+		return append(c, local...), nil
+	}
+	cellSize := func(child bool, payload []byte) int {
+		n := len(sqlitePutVarint(int64(len(payload)))) + cellLen(len(payload), true)
+		if child {
+			n += 4
+		}
+		return n
+	}
+
+	var level []item
+	for i := 0; i < len(payloads) || i == 0; {
+		used, j := 8, i
+		for j < len(payloads) && (j == i || used+cellSize(false, payloads[j])+2 <= sqlitePageSize) {
+			used += cellSize(false, payloads[j]) + 2
+			j++
+		}
+		if j == len(payloads)-1 && j-i >= 2 {
+			j-- // leave the next leaf an entry
+		}
+		var cells [][]byte
+		for _, p := range payloads[i:j] {
+			c, err := cell(0, p)
+			if err != nil {
+				return 0, err
+			}
+			cells = append(cells, c)
+		}
+		n := w.alloc()
+		if err := w.put(n, sqlitePage(sqliteIndexLeaf, cells, 0, 0)); err != nil {
+			return 0, err
+		}
+		it := item{page: n}
+		if j < len(payloads) {
+			it.payload = payloads[j]
+		}
+		level = append(level, it)
+		i = j + 1
+	}
+	for len(level) > 1 {
+		var up []item
+		cur := level
+		for i := 0; i < len(cur); {
+			// The page takes children i to j, j being the right-most one, with the entries between them.
+			used, j := 12, i
+			for j+1 < len(cur) && (j == i || used+cellSize(true, cur[j].payload)+2 <= sqlitePageSize) {
+				used += cellSize(true, cur[j].payload) + 2
+				j++
+			}
+			if j == len(cur)-2 && j-i >= 2 {
+				j--
+			}
+			var cells [][]byte
+			for _, it := range cur[i:j] {
+				c, err := cell(it.page, it.payload)
+				if err != nil {
+					return 0, err
+				}
+				cells = append(cells, c)
+			}
+			n := w.alloc()
+			if err := w.put(n, sqlitePage(sqliteIndexInterior, cells, cur[j].page, 0)); err != nil {
+				return 0, err
+			}
+			up = append(up, item{n, cur[j].payload})
+			i = j + 1
+		}
+		level = up
+	}
+	return level[0].page, nil
+}
+
+// sqliteValue converts the ints of rows to int64.
+func sqliteValue(v interface{}) interface{} {
+	if i, ok := v.(int); ok {
+		return int64(i)
+	}
+	return v
+}
+
+// sqliteEncode encodes values as a record, with the smallest serial type for each.
+func sqliteEncode(values []interface{}) []byte {
+	var types, data []byte
+	for _, v := range values {
+		var t int64
+		switch v := sqliteValue(v).(type) {
+		case nil:
+		case int64:
+			switch {
+			case v == 0 || v == 1:
+				t = 8 + v
+			case v >= math.MinInt8 && v <= math.MaxInt8:
+				t, data = 1, append(data, byte(v))
+			case v >= math.MinInt16 && v <= math.MaxInt16:
+				t, data = 2, binary.BigEndian.AppendUint16(data, uint16(v))
+			case v >= -1<<23 && v < 1<<23:
+				t, data = 3, append(data, byte(v>>16), byte(v>>8), byte(v))
+			case v >= math.MinInt32 && v <= math.MaxInt32:
+				t, data = 4, binary.BigEndian.AppendUint32(data, uint32(v))
+			case v >= -1<<47 && v < 1<<47:
+				t, data = 5, append(binary.BigEndian.AppendUint16(data, uint16(v>>32)), byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+			default:
+				t, data = 6, binary.BigEndian.AppendUint64(data, uint64(v))
+			}
+		case float64:
+			t, data = 7, binary.BigEndian.AppendUint64(data, math.Float64bits(v))
+		case string:
+			t, data = int64(2*len(v)+13), append(data, v...)
+		case []byte:
+			t, data = int64(2*len(v)+12), append(data, v...)
+		default:
+			panic(fmt.Sprintf("sqlite: cannot store %T", v))
+		}
+		types = append(types, sqlitePutVarint(t)...)
+	}
+	// The header size counts its own varint.
+	hlen := len(types) + 1
+	for len(sqlitePutVarint(int64(hlen))) != hlen-len(types) {
+		hlen++
+	}
+	return append(append(sqlitePutVarint(int64(hlen)), types...), data...)
+}
+
+func sqlitePutVarint(v int64) []byte {
+	u := uint64(v)
+	if u>>56 != 0 {
+		b := make([]byte, 9)
+		b[8] = byte(u)
+		u >>= 8
+		for i := 7; i >= 0; i-- {
+			b[i] = byte(u&0x7f) | 0x80
+			u >>= 7
+		}
+		return b
+	}
+	var groups []byte
+	for {
+		groups = append(groups, byte(u&0x7f))
+		u >>= 7
+		if u == 0 {
+			break
+		}
+	}
+	b := make([]byte, len(groups))
+	for i, g := range groups {
+		b[len(b)-1-i] = g | 0x80
+	}
+	b[len(b)-1] &= 0x7f
+	return b
+}
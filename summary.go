@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+)
+
+// problemsRe matches the entries of the app's bundled or downloaded problems, whose format is not known yet.
+var problemsRe = regexp.MustCompile(`(?i)problem|tsumego`)
+
+// entryKind returns what the archive entry name holds: "games", "online games", "autosave", "problems", "settings", "caches" or "other".
+func entryKind(name string) string {
+	switch {
+	case inDir(name, gameDir(false)):
+		return "games"
+	case inDir(name, gameDir(true)):
+		return "online games"
+	case autosaveRe.MatchString(path.Base(name)):
+		return "autosave"
+	case problemsRe.MatchString(name):
+		return "problems"
+	case inDir(name, prefsDir):
+		return "settings"
+	case scrubPathRe.MatchString(name):
+		return "caches"
+	}
+	return "other"
+}
+
+// dirSummary is the number and uncompressed size of the files directly in a directory of an archive.
+type dirSummary struct {
+	Dir   string `json:"dir"`
+	Kind  string `json:"kind"`
+	Files int    `json:"files"`
+	Size  int64  `json:"size"`
+}
+
+// summarize returns the summaries of the directories of the archive name by directory, and by kind.
+func summarize(ctx context.Context, name string) (dirs, kinds []*dirSummary, err error) {
+	a, err := openArchive(ctx, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer a.Close()
+	byDir := make(map[string]*dirSummary)
+	byKind := make(map[string]*dirSummary)
+	for _, f := range a.Files {
+		if f.IsDir {
+			continue
+		}
+		kind := entryKind(f.Name)
+		d := byDir[path.Dir(f.Name)]
+		if d == nil {
+			d = &dirSummary{Dir: path.Dir(f.Name), Kind: kind}
+			byDir[d.Dir] = d
+			dirs = append(dirs, d)
+		}
+		k := byKind[kind]
+		if k == nil {
+			k = &dirSummary{Kind: kind}
+			byKind[kind] = k
+			kinds = append(kinds, k)
+		}
+		d.Files++
+		d.Size += f.Size
+		k.Files++
+		k.Size += f.Size
+	}
+	sort.Slice(dirs, func(i, j int) bool { return dirs[i].Dir < dirs[j].Dir })
+	sort.SliceStable(kinds, func(i, j int) bool { return kinds[i].Size > kinds[j].Size })
+	return dirs, kinds, nil
+}
+
+func summaryCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("summary", flag.ContinueOnError)
+	avx := fs.String("a", "", "Champion Go archive")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	dirs, kinds, err := summarize(ctx, *avx)
+	if err != nil {
+		return err
+	}
+	output(map[string]interface{}{"dirs": dirs, "kinds": kinds}, func(w io.Writer) {
+		width := 0
+		for _, d := range dirs {
+			width = max(width, len(d.Dir))
+		}
+		for _, d := range dirs {
+			fmt.Fprintf(w, "%-*s  %-12s  %5d files  %10s\n", width, d.Dir, d.Kind, d.Files, formatBytes(d.Size))
+		}
+		fmt.Fprintln(w)
+		for _, k := range kinds {
+			fmt.Fprintf(w, "%-12s  %5d files  %10s\n", k.Kind, k.Files, formatBytes(k.Size))
+		}
+	})
+	return nil
+}
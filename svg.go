@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"io"
+)
+
+// svgMark is a mark drawn on a point of an SVG board: a circle of Color, with Label inside if it is not empty.
+type svgMark struct {
+	X, Y  int
+	Color string
+	Label string
+}
+
+// svgCell is the distance between two lines of SVG boards.
+const svgCell = 20
+
+// SVG draws b as an SVG image with the marks, without the XML declaration so that it can be inlined in HTML.
+func (b *Board) SVG(w io.Writer, marks ...svgMark) {
+	b = b.shown()
+	n := b.Size
+	size := (n + 1) * svgCell
+	at := func(i int) int { return i * svgCell } // the coordinate of line i, numbered from 1
+	fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" width="%d" height="%d" font-family="sans-serif">`, size, size, size, size)
+	fmt.Fprintf(w, `<rect width="%d" height="%d" fill="#dcb35c"/>`, size, size)
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(w, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#000"/>`, at(1), at(i), at(n), at(i))
+		fmt.Fprintf(w, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#000"/>`, at(i), at(1), at(i), at(n))
+	}
+	for _, p := range starPoints(n) {
+		fmt.Fprintf(w, `<circle cx="%d" cy="%d" r="2.5"/>`, at(p[0]), at(p[1]))
+	}
+	for y := 1; y <= n; y++ {
+		for x := 1; x <= n; x++ {
+			switch b.At(x, y) {
+			case Black:
+				fmt.Fprintf(w, `<circle cx="%d" cy="%d" r="%d" fill="#000"/>`, at(x), at(y), svgCell/2-1)
+			case White:
+				fmt.Fprintf(w, `<circle cx="%d" cy="%d" r="%d" fill="#fff" stroke="#000"/>`, at(x), at(y), svgCell/2-1)
+			}
+		}
+	}
+	for _, m := range marks {
+		if m.X < 1 || m.X > n || m.Y < 1 || m.Y > n {
+			continue
+		}
+		if m.Label == "" {
+			fmt.Fprintf(w, `<circle cx="%d" cy="%d" r="%d" fill="none" stroke="%s" stroke-width="2"/>`, at(m.X), at(m.Y), svgCell/4, html.EscapeString(m.Color))
+			continue
+		}
+		if b.At(m.X, m.Y) == Empty {
+			fmt.Fprintf(w, `<circle cx="%d" cy="%d" r="%d" fill="%s"/>`, at(m.X), at(m.Y), svgCell/2-2, html.EscapeString(m.Color))
+		}
+		fill := "#fff"
+		if b.At(m.X, m.Y) == White {
+			fill = "#000"
+		}
+		fmt.Fprintf(w, `<text x="%d" y="%d" font-size="%d" text-anchor="middle" dominant-baseline="central" fill="%s">%s</text>`, at(m.X), at(m.Y), svgCell*3/5, fill, html.EscapeString(m.Label))
+	}
+	fmt.Fprint(w, "</svg>")
+}
+
+// starPoints returns the star points of a board of size n, as drawn on boards of the common sizes.
+func starPoints(n int) [][2]int {
+	var edge int
+	switch {
+	case n >= 13:
+		edge = 4
+	case n >= 7:
+		edge = 3
+	default:
+		return nil
+	}
+	lines := []int{edge, n + 1 - edge}
+	if n%2 == 1 && n >= 9 {
+		lines = []int{edge, (n + 1) / 2, n + 1 - edge}
+	}
+	var ps [][2]int
+	for _, x := range lines {
+		for _, y := range lines {
+			if n < 13 && n%2 == 1 && (x == (n+1)/2) != (y == (n+1)/2) {
+				continue // only the center and the corners on small boards
+			}
+			ps = append(ps, [2]int{x, y})
+		}
+	}
+	return ps
+}
+
+// SVG draws f as an SVG image, with the numbers of its moves on their stones.
+func (f *figure) SVG(w io.Writer) {
+	b := &Board{Size: f.Board.Size, Points: append([]Stone(nil), f.Board.Points...)}
+	var marks []svgMark
+	for _, s := range f.Stones {
+		b.Points[(s.Y-1)*b.Size+s.X-1] = s.Color
+		marks = append(marks, svgMark{X: s.X, Y: s.Y, Label: fmt.Sprint(s.Num)})
+	}
+	b.SVG(w, marks...)
+}
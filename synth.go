@@ -0,0 +1,151 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"strconv"
+	"time"
+)
+
+// synthEpoch is the date of the first synthetic game, and the modification time of every entry of synthetic archives,
+// so that the same options always give the same archive, byte for byte.
+var synthEpoch = time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+
+// synthOptions describe a synthetic archive.
+type synthOptions struct {
+	Version  string // the app version, whose layout the records use
+	Local    int    // the number of local games
+	Online   int    // the number of online games
+	Size     int
+	Moves    int
+	Autosave bool // whether the archive has an autosaved game in progress
+	Seed     int64
+}
+
+// synthGame returns a game of n random legal moves, passing when no move is found, on a board of size
+// and in the layout l, started at t: a record like the ones the app writes, without coming from anyone's backup.
+func synthGame(r *rand.Rand, l *layout, size, n int, t time.Time) *Game {
+	g := &Game{
+		Mode:    ModeComputer,
+		Size:    int32(size),
+		Color:   int32(r.Intn(2)),
+		Level:   int32(1 + r.Intn(10)),
+		Started: int32(t.Unix()),
+		layout:  l,
+	}
+	b := NewBoard(size)
+	s := Black
+	elapsed := 0
+	for i := 0; i < n; i++ {
+		m := newMove(s, 0, 0)
+		for try := 0; try < 20; try++ {
+			x, y := 1+r.Intn(size), 1+r.Intn(size)
+			if captured, err := b.Play(s, x, y); err == nil {
+				m = newMove(s, int32(x), int32(y))
+				m.Captures = uint16(captured)
+				break
+			}
+		}
+		if m.IsPass() {
+			b.Play(s, 0, 0)
+		}
+		m.Time = int32(1 + r.Intn(30))
+		elapsed += int(m.Time)
+		g.Moves = append(g.Moves, m)
+		s = s.Opponent()
+	}
+	g.Saved = g.Started + int32(elapsed)
+	return g
+}
+
+// writeSynthArchive writes a synthetic Champion Go archive as an .imazingapp zip file:
+// its iTunesMetadata.plist, local and online games, and with o.Autosave an autosaved game.
+func writeSynthArchive(w io.Writer, o synthOptions) error {
+	r := rand.New(rand.NewSource(o.Seed))
+	l := layoutFor(o.Version)
+	zw := zip.NewWriter(w)
+	add := func(name string, b []byte) error {
+		f, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: synthEpoch})
+		if err != nil {
+			return err
+		}
+		_, err = f.Write(b)
+		return err
+	}
+	plist := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>bundleShortVersionString</key>
+	<string>%s</string>
+	<key>itemName</key>
+	<string>Champion Go</string>
+</dict>
+</plist>
+`, o.Version)
+	if err := add("iTunesMetadata.plist", []byte(plist)); err != nil {
+		return err
+	}
+	t := synthEpoch
+	for i := 0; i < o.Local+o.Online; i++ {
+		dir := apps[0].Dirs[0]
+		if i >= o.Local {
+			dir = apps[0].Dirs[1]
+		}
+		g := synthGame(r, l, o.Size, o.Moves, t)
+		if err := add(dir+strconv.Itoa(i)+".game", g.Encode()); err != nil {
+			return err
+		}
+		t = t.Add(time.Hour)
+	}
+	if o.Autosave {
+		g := synthGame(r, l, o.Size, o.Moves/2, t)
+		if err := add("Container/Documents/autosave.game", g.Encode()); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func synthCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("synth", flag.ContinueOnError)
+	var o synthOptions
+	fs.StringVar(&o.Version, "version", currentLayout.Version, "the app version whose record layout the games use")
+	fs.IntVar(&o.Local, "local", 3, "the number of local games")
+	fs.IntVar(&o.Online, "online", 2, "the number of online games")
+	fs.IntVar(&o.Size, "size", 19, "board size")
+	fs.IntVar(&o.Moves, "moves", 40, "the number of moves of each game")
+	fs.BoolVar(&o.Autosave, "autosave", false, "add an autosaved game in progress")
+	fs.Int64Var(&o.Seed, "seed", 1, "the seed of the random moves; the same options give the same archive")
+	out := fs.String("o", "-", "output archive")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("usage: chamgo synth [flags]")
+	}
+	if o.Size < 2 || o.Size > len(columns) || o.Moves < 0 || o.Local < 0 || o.Online < 0 {
+		return fmt.Errorf("the size must be between 2 and %d, and the counts not negative", len(columns))
+	}
+	if *out == "" || *out == "-" {
+		if jsonOutput {
+			return fmt.Errorf("the archive cannot be written to stdout with -json, use -o")
+		}
+		return writeSynthArchive(os.Stdout, o)
+	}
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	if err := writeSynthArchive(f, o); err != nil {
+		f.Close()
+		os.Remove(*out)
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// tagsName is the file in $CHAMGO_HOME keeping the tags and notes of games, by movesHash,
+// since the records of the app have no room for them.
+const tagsName = "tags.json"
+
+// gameTags are the tags and notes of a game.
+type gameTags struct {
+	Tags  []string `json:"tags,omitempty"`
+	Notes string   `json:"notes,omitempty"`
+}
+
+func (t gameTags) has(tag string) bool {
+	for _, s := range t.Tags {
+		if s == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func tagsPath() (string, error) {
+	home, err := chamgoPath("")
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, tagsName), nil
+}
+
+// readTags returns the tags of all games, which are none if no game was tagged yet.
+func readTags() (map[string]gameTags, error) {
+	tags := make(map[string]gameTags)
+	fn, err := tagsPath()
+	if err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadFile(fn)
+	if os.IsNotExist(err) {
+		return tags, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &tags); err != nil {
+		return nil, fmt.Errorf("%s: %w", fn, err)
+	}
+	return tags, nil
+}
+
+// updateTags applies update to the tags of all games under the lock of the tags file.
+func updateTags(ctx context.Context, update func(tags map[string]gameTags) error) error {
+	fn, err := tagsPath()
+	if err == nil {
+		_, err = chamgoDir("")
+	}
+	if err != nil {
+		return err
+	}
+	unlock, err := lockOutput(ctx, fn)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	tags, err := readTags()
+	if err != nil {
+		return err
+	}
+	if err := update(tags); err != nil {
+		return err
+	}
+	for h, t := range tags {
+		if len(t.Tags) == 0 && t.Notes == "" {
+			delete(tags, h)
+		}
+	}
+	b, err := json.MarshalIndent(tags, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(fn, append(b, '\n'))
+}
+
+// tagFilter is a repeatable -tag flag selecting the games with all of the tags.
+type tagFilter []string
+
+func (f *tagFilter) String() string { return strings.Join(*f, ",") }
+
+func (f *tagFilter) Set(s string) error {
+	*f = append(*f, s)
+	return nil
+}
+
+// match reports whether the game with moves hash has all the tags of f.
+func (f tagFilter) match(tags map[string]gameTags, hash string) bool {
+	for _, tag := range f {
+		if !tags[hash].has(tag) {
+			return false
+		}
+	}
+	return true
+}
+
+// resolveHash returns the full hash of the game with the hash starting with prefix,
+// among the tagged games and the games database.
+func resolveHash(tags map[string]gameTags, prefix string) (string, error) {
+	prefix = strings.ToLower(prefix)
+	found := make(map[string]bool)
+	for h := range tags {
+		if strings.HasPrefix(h, prefix) {
+			found[h] = true
+		}
+	}
+	if fn, err := resultsPath(); err == nil && exists(fn) {
+		gs, err := lookupResults(fn, "games_hash", prefix, sqlitePrefixEnd(prefix))
+		if err != nil {
+			return "", err
+		}
+		for _, g := range gs {
+			found[g.Hash] = true
+		}
+	}
+	switch len(found) {
+	case 0:
+		return "", fmt.Errorf("no tagged or recorded game has a hash starting with %s", prefix)
+	case 1:
+		return sortedKeys(found)[0], nil
+	}
+	return "", fmt.Errorf("several games have hashes starting with %s", prefix)
+}
+
+func tagCmd(ctx context.Context, args []string) error {
+	usage := fmt.Errorf("usage: chamgo tag add|rm|note|list [-a archive [-g game] | -hash hash] [tag... | note]")
+	if len(args) == 0 {
+		return usage
+	}
+	fs := flag.NewFlagSet("tag "+args[0], flag.ContinueOnError)
+	avx := fs.String("a", "", "Champion Go archive")
+	slot := fs.String("g", "latest", `the game: an entry name, "latest" or "latest-online"`)
+	hashFlag := fs.String("hash", "", "the game with this hash, or beginning of it, instead of a game of -a")
+	var filter tagFilter
+	fs.Var(&filter, "tag", "list only the games with this tag, may be repeated")
+	if err := parseFlags(fs, args[1:]); err != nil {
+		return err
+	}
+
+	tags, err := readTags()
+	if err != nil {
+		return err
+	}
+	if args[0] == "list" {
+		type tagged struct {
+			Hash  string `json:"hash"`
+			Entry string `json:"entry,omitempty"`
+			gameTags
+		}
+		var ts []tagged
+		switch {
+		case *avx != "" && settingsOf(ctx).useCache():
+			idx, err := gameIndex(ctx, *avx)
+			if err != nil {
+				return err
+			}
+			// The index is in archive order, while the games are listed local ones first.
+			for _, online := range []bool{false, true} {
+				for _, g := range idx {
+					if g.Online == online && filter.match(tags, g.Hash) {
+						ts = append(ts, tagged{Hash: g.Hash, Entry: g.Name, gameTags: tags[g.Hash]})
+					}
+				}
+			}
+		case *avx != "":
+			for _, online := range []bool{false, true} {
+				games, err := listGames(ctx, *avx, online)
+				if err != nil {
+					return err
+				}
+				for _, ge := range games {
+					if h := movesHash(ge.Body); filter.match(tags, h) {
+						ts = append(ts, tagged{Hash: h, Entry: ge.Name, gameTags: tags[h]})
+					}
+				}
+			}
+		default:
+			for _, h := range sortedKeys(tags) {
+				if filter.match(tags, h) {
+					ts = append(ts, tagged{Hash: h, gameTags: tags[h]})
+				}
+			}
+		}
+		output(ts, func(w io.Writer) {
+			for _, t := range ts {
+				fmt.Fprintf(w, "%s %s", fingerprint(t.Hash), t.Entry)
+				if len(t.Tags) > 0 {
+					fmt.Fprintf(w, " [%s]", strings.Join(t.Tags, ", "))
+				}
+				fmt.Fprintln(w)
+				if t.Notes != "" {
+					fmt.Fprintf(w, "    %s\n", strings.ReplaceAll(t.Notes, "\n", "\n    "))
+				}
+			}
+		})
+		return nil
+	}
+
+	var hash string
+	switch {
+	case *hashFlag != "":
+		if hash, err = resolveHash(tags, *hashFlag); err != nil {
+			return err
+		}
+	case *avx != "":
+		_, body, err := readEntry(ctx, *avx, *slot)
+		if err != nil {
+			return err
+		}
+		if body == nil {
+			return errNoGames
+		}
+		hash = movesHash(body)
+	default:
+		return usage
+	}
+
+	switch args[0] {
+	case "add", "rm":
+		if fs.NArg() == 0 {
+			return usage
+		}
+		return updateTags(ctx, func(tags map[string]gameTags) error {
+			t := tags[hash]
+			for _, tag := range fs.Args() {
+				if args[0] == "add" && !t.has(tag) {
+					t.Tags = append(t.Tags, tag)
+				}
+				if args[0] == "rm" {
+					var kept []string
+					for _, s := range t.Tags {
+						if s != tag {
+							kept = append(kept, s)
+						}
+					}
+					t.Tags = kept
+				}
+			}
+			sort.Strings(t.Tags)
+			tags[hash] = t
+			return nil
+		})
+	case "note":
+		return updateTags(ctx, func(tags map[string]gameTags) error {
+			t := tags[hash]
+			t.Notes = strings.Join(fs.Args(), " ") // no note removes the notes
+			tags[hash] = t
+			return nil
+		})
+	}
+	return usage
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+)
+
+func takebackCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("takeback", flag.ContinueOnError)
+	avx := fs.String("a", "", "Champion Go archive")
+	game := fs.String("g", "latest-online", `the game to take moves back from: an entry name, "latest" or "latest-online"`)
+	n := fs.Int("n", 2, "the number of moves to take back")
+	out := fs.String("o", "-", "output archive")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	name, body, err := readEntry(ctx, *avx, *game)
+	if err != nil {
+		return err
+	}
+	if body == nil {
+		return errNoGames
+	}
+	g, err := Decode(body)
+	if err != nil {
+		return err
+	}
+	if *n < 0 || *n > len(g.Moves) {
+		return fmt.Errorf("cannot take back %d moves, %s has %d", *n, name, len(g.Moves))
+	}
+	g.Moves = g.Moves[:len(g.Moves)-*n]
+	note(event{"event": "takeback", "entry": name, "moves": *n, "left": len(g.Moves)}, "%s: took back %d moves, %d left", name, *n, len(g.Moves))
+	return writeAvxFile(ctx, *out, *avx, avxEdit{Replace: map[string][]byte{name: g.Encode()}})
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+)
+
+// isTarName reports whether name is a tarball, judging by its extension.
+func isTarName(name string) bool {
+	for _, ext := range []string{".tar", ".tar.gz", ".tgz"} {
+		if strings.HasSuffix(strings.ToLower(name), ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// archiveWriter returns the function writing archives of the format chosen by the name out:
+// a tarball if it ends with .tar, .tar.gz or .tgz, and a zip file otherwise.
+func archiveWriter(out string) func(ctx context.Context, w io.Writer, avxName string, e avxEdit) error {
+	if !isTarName(out) {
+		return writeAvx
+	}
+	gz := !strings.HasSuffix(strings.ToLower(out), ".tar")
+	return func(ctx context.Context, w io.Writer, avxName string, e avxEdit) error {
+		return writeTar(ctx, w, avxName, e, gz)
+	}
+}
+
+// sniffTar reports whether the file name is a tarball, possibly gzipped.
+func sniffTar(name string) (bool, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(f, head)
+	head = head[:n]
+	if bytes.HasPrefix(head, []byte{0x1f, 0x8b}) {
+		return true, nil
+	}
+	return n >= 262 && bytes.HasPrefix(head[257:], []byte("ustar")), nil
+}
+
+// openTarArchive reads a tarball, possibly gzipped, into memory, since tar files can only be read sequentially.
+func openTarArchive(name string) (*archive, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	br := bufio.NewReader(f)
+	var r io.Reader = br
+	if magic, _ := br.Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+	}
+
+	a := &archive{}
+	tr := tar.NewReader(r)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		name := strings.TrimPrefix(path.Clean(h.Name), "./")
+		if name == "." {
+			continue
+		}
+		switch h.Typeflag {
+		case tar.TypeDir:
+			a.Files = append(a.Files, &archiveFile{Name: name + "/", IsDir: true, open: memOpener(nil)})
+		case tar.TypeReg:
+			body, err := ioutil.ReadAll(tr)
+			if err != nil {
+				return nil, err
+			}
+			a.Files = append(a.Files, &archiveFile{Name: name, Size: int64(len(body)), open: memOpener(body), crc: crc32.ChecksumIEEE(body), hasCRC: true})
+		}
+	}
+	return a, nil
+}
+
+func memOpener(body []byte) func() (io.ReadCloser, error) {
+	return func() (io.ReadCloser, error) { return ioutil.NopCloser(bytes.NewReader(body)), nil }
+}
+
+// writeTar copies the archive avxName to w as a tarball, gzipped if gz is set, applying the edit e.
+func writeTar(ctx context.Context, w io.Writer, avxName string, e avxEdit, gz bool) error {
+	a, err := openArchive(ctx, avxName)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	var zw *gzip.Writer
+	if gz {
+		zw = gzip.NewWriter(w)
+		w = zw
+	}
+	tw := tar.NewWriter(w)
+	s := settingsOf(ctx)
+	now := s.clock()
+	files := outputFiles(a, e, s.sortEntries)
+	if s.sortEntries {
+		e.Add = nil
+	}
+	for _, f := range files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if e.Delete[f.Name] {
+			continue
+		}
+		name := f.outName(e)
+		if f.IsDir {
+			if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: name, Mode: 0755, ModTime: now}); err != nil {
+				return err
+			}
+			continue
+		}
+		body, ok := e.Replace[f.Name]
+		if !ok {
+			if body, err = f.ReadAll(); err != nil {
+				return err
+			}
+		}
+		if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0644, Size: int64(len(body)), ModTime: now}); err != nil {
+			return err
+		}
+		if _, err := tw.Write(body); err != nil {
+			return err
+		}
+	}
+	for _, name := range sortedKeys(e.Add) {
+		body := e.Add[name]
+		if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0644, Size: int64(len(body)), ModTime: now}); err != nil {
+			return err
+		}
+		if _, err := tw.Write(body); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if zw != nil {
+		return zw.Close()
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIsTarName(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"backup.tar", true},
+		{"backup.TAR.GZ", true},
+		{"backup.tgz", true},
+		{"backup.imazingapp", false},
+		{"backup.zip", false},
+		{"tar", false},
+	}
+	for _, tt := range tests {
+		if got := isTarName(tt.name); got != tt.want {
+			t.Errorf("isTarName(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// tarball returns a tarball of the headers, whose regular files hold their names, gzipped if gz.
+func tarball(t *testing.T, gz bool, headers ...*tar.Header) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var zw *gzip.Writer
+	tw := tar.NewWriter(&buf)
+	if gz {
+		zw = gzip.NewWriter(&buf)
+		tw = tar.NewWriter(zw)
+	}
+	for _, h := range headers {
+		if h.Typeflag == tar.TypeReg {
+			h.Size = int64(len(h.Name))
+		}
+		if err := tw.WriteHeader(h); err != nil {
+			t.Fatal(err)
+		}
+		if h.Typeflag == tar.TypeReg {
+			tw.Write([]byte(h.Name))
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if zw != nil {
+		zw.Close()
+	}
+	return buf.Bytes()
+}
+
+func TestOpenTarArchive(t *testing.T) {
+	headers := []*tar.Header{
+		{Typeflag: tar.TypeDir, Name: "./"},
+		{Typeflag: tar.TypeDir, Name: "./Container/Documents/game/"},
+		{Typeflag: tar.TypeReg, Name: "./Container/Documents/game/1"},
+		{Typeflag: tar.TypeSymlink, Name: "Container/link", Linkname: "Documents"},
+		{Typeflag: tar.TypeReg, Name: "iTunesMetadata.plist"},
+	}
+	want := "Container/Documents/game/, Container/Documents/game/1, iTunesMetadata.plist"
+	tests := []struct {
+		name string
+		b    []byte
+		tar  bool
+	}{
+		{"backup.tar", tarball(t, false, headers...), true},
+		{"backup.tar.gz", tarball(t, true, headers...), true},
+		{"backup.tgz", tarball(t, true, headers...), true},
+		{"backup.imazingapp", []byte("PK\x03\x04 not a tarball"), false},
+	}
+	for _, tt := range tests {
+		fn := filepath.Join(t.TempDir(), tt.name)
+		if err := ioutil.WriteFile(fn, tt.b, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if ok, err := sniffTar(fn); ok != tt.tar || err != nil {
+			t.Errorf("%s: sniffTar = %v, %v, want %v", tt.name, ok, err, tt.tar)
+		}
+		if !tt.tar {
+			continue
+		}
+		a, err := openTarArchive(fn)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		var names []string
+		for _, f := range a.Files {
+			names = append(names, f.Name)
+			if !f.IsDir {
+				if b, err := f.ReadAll(); err != nil || !strings.HasSuffix(string(b), f.Name) {
+					t.Errorf("%s: %s holds %q, %v", tt.name, f.Name, b, err)
+				}
+			}
+		}
+		if got := strings.Join(names, ", "); got != want {
+			t.Errorf("%s: entries %s, want %s", tt.name, got, want)
+		}
+	}
+}
+
+func TestWriteTar(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.tar")
+	b := tarball(t, false,
+		&tar.Header{Typeflag: tar.TypeDir, Name: "Container/"},
+		&tar.Header{Typeflag: tar.TypeReg, Name: "Container/a"},
+		&tar.Header{Typeflag: tar.TypeReg, Name: "Container/b"},
+		&tar.Header{Typeflag: tar.TypeReg, Name: "Container/c"})
+	if err := ioutil.WriteFile(in, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	e := avxEdit{
+		Replace: map[string][]byte{"Container/a": []byte("replaced")},
+		Delete:  map[string]bool{"Container/b": true},
+		Rename:  map[string]string{"Container/c": "Container/renamed"},
+		Add:     map[string][]byte{"Container/d": []byte("added")},
+	}
+	tests := []struct {
+		out string
+		gz  bool
+	}{
+		{"out.tar", false},
+		{"out.tar.gz", true},
+		{"out.tgz", true},
+	}
+	ctx := context.Background()
+	for _, tt := range tests {
+		out := filepath.Join(dir, tt.out)
+		if err := writeAvxFile(ctx, out, in, e); err != nil {
+			t.Fatalf("%s: %v", tt.out, err)
+		}
+		raw, err := ioutil.ReadFile(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if gz := bytes.HasPrefix(raw, []byte{0x1f, 0x8b}); gz != tt.gz {
+			t.Errorf("%s: gzipped %v, want %v", tt.out, gz, tt.gz)
+		}
+		a, err := openTarArchive(out)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.out, err)
+		}
+		got := make(map[string]string)
+		for _, f := range a.Files {
+			b, _ := f.ReadAll()
+			got[f.Name] = string(b)
+		}
+		want := map[string]string{"Container/": "", "Container/a": "replaced", "Container/renamed": "Container/c", "Container/d": "added"}
+		if len(got) != len(want) {
+			t.Errorf("%s: entries %v, want %v", tt.out, got, want)
+		}
+		for name, body := range want {
+			if got[name] != body {
+				t.Errorf("%s: %s holds %q, want %q", tt.out, name, got[name], body)
+			}
+		}
+	}
+}
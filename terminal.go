@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// replayFrame draws the board of g after its first n moves, with the last of them and the prisoners.
+func replayFrame(name string, g *Game, n int) string {
+	b, err := replay(&Game{Size: g.Size, Moves: g.Moves[:n]})
+	if b == nil {
+		return fmt.Sprintf("%s: %v\n", name, err)
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s, move %d of %d", name, n, len(g.Moves))
+	if n > 0 {
+		m := g.Moves[n-1]
+		switch {
+		case m.IsPass():
+			fmt.Fprintf(&sb, ": %s passes", colorName(m.Stone()))
+		case !g.onBoard(m):
+			fmt.Fprintf(&sb, ": %s resigns", colorName(m.Stone()))
+		default:
+			fmt.Fprintf(&sb, ": %s %s", colorName(m.Stone()), strings.ToLower(gtpVertex(int(m.X), int(m.Y), int(g.Size))))
+			if m.Captures > 0 {
+				fmt.Fprintf(&sb, ", capturing %d", m.Captures)
+			}
+		}
+	}
+	sb.WriteString("\n\n")
+	b.shown().Print(&sb)
+	fmt.Fprintf(&sb, "\nprisoners: black %d, white %d\n", b.Prisoners[Black], b.Prisoners[White])
+	return sb.String()
+}
+
+// rawTerminal puts the terminal of stdin in raw mode with stty, so that single key presses can be read,
+// and returns the function restoring it. It fails if stdin is not a terminal or stty is missing, as on Windows.
+func rawTerminal() (func(), error) {
+	if runtime.GOOS == "windows" {
+		return nil, unsupported(fmt.Errorf("raw terminal mode needs stty"))
+	}
+	stty := func(args ...string) ([]byte, error) {
+		cmd := exec.Command("stty", args...)
+		cmd.Stdin = os.Stdin
+		return cmd.Output()
+	}
+	state, err := stty("-g")
+	if err != nil {
+		return nil, fmt.Errorf("stdin is not a terminal: %w", err)
+	}
+	if _, err := stty("raw", "-echo"); err != nil {
+		return nil, err
+	}
+	return func() { stty(strings.TrimSpace(string(state))) }, nil
+}
+
+// replay keys, as read in raw mode.
+const (
+	keyQuit = iota
+	keyNext
+	keyPrev
+	keyNext10
+	keyPrev10
+	keyFirst
+	keyLast
+	keyOther
+)
+
+// readKey reads a key press, decoding the escape sequences of the arrow, page and home and end keys.
+func readKey(r *bufio.Reader) (int, error) {
+	c, err := r.ReadByte()
+	if err != nil {
+		return keyQuit, err
+	}
+	switch c {
+	case 'q', 3, 4: // q, ^C, ^D
+		return keyQuit, nil
+	case ' ', 'n', 'l', '\r':
+		return keyNext, nil
+	case 'p', 'h', 127:
+		return keyPrev, nil
+	case '\x1b':
+	default:
+		return keyOther, nil
+	}
+	if c, err = r.ReadByte(); err != nil || c != '[' && c != 'O' {
+		return keyQuit, err // a single escape
+	}
+	seq := ""
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			return keyQuit, err
+		}
+		seq += string(c)
+		if c >= 0x40 && c <= 0x7e {
+			break
+		}
+	}
+	switch seq {
+	case "C":
+		return keyNext, nil
+	case "D":
+		return keyPrev, nil
+	case "A", "5~":
+		return keyPrev10, nil
+	case "B", "6~":
+		return keyNext10, nil
+	case "H", "1~":
+		return keyFirst, nil
+	case "F", "4~":
+		return keyLast, nil
+	}
+	return keyOther, nil
+}
+
+// step returns the move number after pressing key at move n of a game of total moves.
+func step(key, n, total int) int {
+	switch key {
+	case keyNext:
+		n++
+	case keyPrev:
+		n--
+	case keyNext10:
+		n += 10
+	case keyPrev10:
+		n -= 10
+	case keyFirst:
+		n = 0
+	case keyLast:
+		n = total
+	}
+	return max(0, min(n, total))
+}
+
+// writeCast writes the frames of g from move 0 to its last move as an asciinema cast of format version 2,
+// a frame every delay seconds. The terminal size of the header fits the largest frame.
+func writeCast(w io.Writer, name string, g *Game, delay float64) error {
+	var frames []string
+	width, height := 0, 0
+	for n := 0; n <= len(g.Moves); n++ {
+		f := replayFrame(name, g, n)
+		lines := strings.Split(strings.TrimSuffix(f, "\n"), "\n")
+		height = max(height, len(lines))
+		for _, l := range lines {
+			width = max(width, utf8.RuneCountInString(l))
+		}
+		frames = append(frames, f)
+	}
+	enc := json.NewEncoder(w)
+	header := map[string]interface{}{"version": 2, "width": width, "height": height, "title": name}
+	if err := enc.Encode(header); err != nil {
+		return err
+	}
+	for i, f := range frames {
+		data := "\x1b[H\x1b[2J" + strings.ReplaceAll(f, "\n", "\r\n")
+		if err := enc.Encode([]interface{}{float64(i) * delay, "o", data}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeANSI writes the frames of g from move 0 to its last move, each clearing the screen before it, as raw ANSI output.
+// It has no timing of its own: a pager or a rate limiter such as pv -qL sets the pace.
+func writeANSI(w io.Writer, name string, g *Game) error {
+	for n := 0; n <= len(g.Moves); n++ {
+		if _, err := io.WriteString(w, "\x1b[H\x1b[2J"+replayFrame(name, g, n)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func replayCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	avx := fs.String("a", "", "Champion Go archive")
+	slot := fs.String("g", "latest", `the game: an entry name, "latest" or "latest-online"`)
+	at := fs.Int("at", 0, "the move shown first, by default the last")
+	export := fs.String("export", "", `write the whole game to stdout instead: "cast" for asciinema or "ansi"`)
+	delay := fs.Float64("delay", 1, "the seconds between the moves of a cast")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		return fmt.Errorf("usage: chamgo replay [-export cast|ansi] [-a archive [-g game] | game.sgf]")
+	}
+	if jsonOutput {
+		return fmt.Errorf("replay reads keys on the terminal and does not support -json")
+	}
+	if *export != "" && *export != "cast" && *export != "ansi" {
+		return fmt.Errorf("unknown export %q, want cast or ansi", *export)
+	}
+	name, g, err := loadGame(ctx, *avx, *slot, fs.Args())
+	if err != nil {
+		return err
+	}
+	switch *export {
+	case "cast":
+		return writeCast(os.Stdout, name, g, *delay)
+	case "ansi":
+		return writeANSI(os.Stdout, name, g)
+	}
+	n := len(g.Moves)
+	if *at > 0 {
+		n = min(*at, n)
+	}
+
+	restore, err := rawTerminal()
+	if err != nil {
+		// Without raw mode, keys are read as lines.
+		in := bufio.NewScanner(os.Stdin)
+		for {
+			fmt.Print(replayFrame(name, g, n))
+			fmt.Print("n or return: next, p: previous, a move number, q: quit: ")
+			if !in.Scan() {
+				fmt.Println()
+				return in.Err()
+			}
+			switch s := strings.TrimSpace(in.Text()); s {
+			case "q":
+				return nil
+			case "", "n":
+				n = step(keyNext, n, len(g.Moves))
+			case "p":
+				n = step(keyPrev, n, len(g.Moves))
+			default:
+				if i, err := strconv.Atoi(s); err == nil {
+					n = max(0, min(i, len(g.Moves)))
+				}
+			}
+		}
+	}
+	defer restore()
+	const help = "→ next  ← previous  ↓↑ ten moves  home end  q quit"
+	in := bufio.NewReader(os.Stdin)
+	for {
+		frame := "\x1b[H\x1b[2J" + replayFrame(name, g, n) + "\n" + help
+		io.WriteString(os.Stdout, strings.ReplaceAll(frame, "\n", "\r\n")) // raw mode does not return the carriage
+		key, err := readKey(in)
+		if key == keyQuit || err != nil {
+			io.WriteString(os.Stdout, "\r\n")
+			return err
+		}
+		n = step(key, n, len(g.Moves))
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// loadGame returns the game of the SGF file of args, if there is one, or else the game slot of the archive avx.
+func loadGame(ctx context.Context, avx, slot string, args []string) (string, *Game, error) {
+	if len(args) > 0 {
+		s, err := ioutil.ReadFile(args[0])
+		if err != nil {
+			return "", nil, err
+		}
+		nodes, err := parseSGF(string(s))
+		if err != nil {
+			return "", nil, invalid(err)
+		}
+		size, moves, err := sgfMoves(nodes)
+		if err != nil {
+			return "", nil, err
+		}
+		return args[0], &Game{Size: int32(size), Moves: moves}, nil
+	}
+	name, body, err := readEntry(ctx, avx, slot)
+	if err != nil {
+		return "", nil, err
+	}
+	if body == nil {
+		return "", nil, errNoGames
+	}
+	g, err := Decode(body)
+	if err != nil {
+		return "", nil, err
+	}
+	// Records may have any size, and the commands loading them draw and replay boards of it.
+	if err := checkSize(g.Size); err != nil {
+		return "", nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return name, g, nil
+}
+
+func colorName(s Stone) string {
+	if s == White {
+		return "white"
+	}
+	return "black"
+}
+
+// train replays g on w, asking on r for the moves of the color guess, or of both colors if guess is Empty,
+// from the move numbered from, and returns the number of guesses and of matching guesses.
+func train(ctx context.Context, r io.Reader, w io.Writer, g *Game, guess Stone, from int) (int, int, error) {
+	if err := checkSize(g.Size); err != nil {
+		return 0, 0, err
+	}
+	in := bufio.NewScanner(r)
+	b := NewBoard(int(g.Size))
+	asked, matched := 0, 0
+	for i, m := range g.Moves {
+		if err := ctx.Err(); err != nil {
+			return asked, matched, err
+		}
+		if !m.IsPass() && !g.onBoard(m) {
+			continue
+		}
+		v := gtpVertex(int(m.X), int(m.Y), int(g.Size))
+		if i+1 >= from && (guess == Empty || m.Stone() == guess) {
+			fmt.Fprintln(w)
+			b.shown().Print(w)
+			answer, err := askMove(in, w, fmt.Sprintf("move %d, %s to play", i+1, colorName(m.Stone())), b.Size)
+			if err != nil {
+				return asked, matched, err
+			}
+			switch answer {
+			case "QUIT":
+				return asked, matched, nil
+			case "SKIP":
+				fmt.Fprintf(w, "%s played %s\n", colorName(m.Stone()), strings.ToLower(v))
+			default:
+				asked++
+				if answer == strings.ToUpper(v) {
+					matched++
+					fmt.Fprintf(w, "right, %d of %d\n", matched, asked)
+				} else {
+					fmt.Fprintf(w, "%s played %s, %d of %d\n", colorName(m.Stone()), strings.ToLower(v), matched, asked)
+				}
+			}
+		} else if i+1 >= from {
+			fmt.Fprintf(w, "move %d, %s plays %s\n", i+1, colorName(m.Stone()), strings.ToLower(v))
+		}
+		if _, err := b.Play(m.Stone(), int(m.X), int(m.Y)); err != nil {
+			return asked, matched, invalid(fmt.Errorf("move %d: %w", i+1, err))
+		}
+	}
+	fmt.Fprintln(w)
+	b.Print(w)
+	fmt.Fprintln(w, "end of the game")
+	return asked, matched, nil
+}
+
+// askMove asks on w for a vertex, pass, skip or quit until r gives one of them, returning it in upper case.
+// An empty answer is a skip, and the end of r a quit.
+func askMove(in *bufio.Scanner, w io.Writer, prompt string, size int) (string, error) {
+	for {
+		fmt.Fprintf(w, "%s (a vertex, pass, skip or quit): ", prompt)
+		if !in.Scan() {
+			fmt.Fprintln(w)
+			return "QUIT", in.Err()
+		}
+		answer := strings.ToUpper(strings.TrimSpace(in.Text()))
+		switch answer {
+		case "", "SKIP":
+			return "SKIP", nil
+		case "Q", "QUIT":
+			return "QUIT", nil
+		case "PASS":
+			return answer, nil
+		}
+		if _, _, err := parseVertex(answer, size); err != nil {
+			fmt.Fprintln(w, err)
+			continue
+		}
+		return answer, nil
+	}
+}
+
+func trainCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("train", flag.ContinueOnError)
+	avx := fs.String("a", "", "Champion Go archive")
+	slot := fs.String("g", "latest", `the game: an entry name, "latest" or "latest-online"`)
+	color := fs.String("color", "", "guess only the moves of b or w, by default the human player of archive games and both colors of SGF games")
+	from := fs.Int("from", 1, "the first move to guess, playing the moves before it")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		return fmt.Errorf("usage: chamgo train [-a archive [-g game] | game.sgf]")
+	}
+	if jsonOutput {
+		return fmt.Errorf("train asks for moves on the terminal and does not support -json")
+	}
+	name, g, err := loadGame(ctx, *avx, *slot, fs.Args())
+	if err != nil {
+		return err
+	}
+
+	guess := Empty
+	switch {
+	case *color == "b":
+		guess = Black
+	case *color == "w":
+		guess = White
+	case *color != "":
+		return fmt.Errorf("-color must be b or w")
+	case fs.NArg() == 0 && g.Color == 1:
+		guess = White
+	case fs.NArg() == 0:
+		guess = Black
+	}
+	fmt.Printf("%s, %d moves\n", name, len(g.Moves))
+	asked, matched, err := train(ctx, os.Stdin, os.Stdout, g, guess, *from)
+	if asked > 0 {
+		fmt.Printf("%d of %d moves guessed (%.0f%%)\n", matched, asked, 100*float64(matched)/float64(asked))
+	}
+	return err
+}
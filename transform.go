@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Transform modifies a decoded game.
+// Transforms that take long, such as external scripts, stop when ctx is done.
+type Transform interface {
+	Apply(ctx context.Context, g *Game) error
+}
+
+// TransformFunc adapts an ordinary function to a Transform.
+type TransformFunc func(ctx context.Context, g *Game) error
+
+func (f TransformFunc) Apply(ctx context.Context, g *Game) error { return f(ctx, g) }
+
+// transforms is the registry of transforms available to -t chains and pipeline transform steps.
+// Each entry creates a Transform from the argument following "=" in a chain such as "flip180,truncate=30".
+var transforms = map[string]func(arg string) (Transform, error){
+	// swapcolors switches the color played by the human.
+	"swapcolors": func(string) (Transform, error) {
+		return TransformFunc(func(_ context.Context, g *Game) error {
+			g.Color = 1 - g.Color
+			return nil
+		}), nil
+	},
+	// truncate keeps the first n moves.
+	"truncate": func(arg string) (Transform, error) {
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("truncate needs a move count, got %q", arg)
+		}
+		return TransformFunc(func(_ context.Context, g *Game) error {
+			if n < len(g.Moves) {
+				g.Moves = g.Moves[:n]
+			}
+			return nil
+		}), nil
+	},
+	// times sets the elapsed times of the moves, which the app shows on its game info screen:
+	// "zero" clears them, "synth" or "synth:n" gives n seconds, 10 by default, to every move but passes,
+	// and "preserve" keeps the times of the moves from before the chain, which scripts and rebuilt boards lose.
+	"times": func(arg string) (Transform, error) {
+		mode, n, _ := strings.Cut(arg, ":")
+		switch mode {
+		case "zero":
+			return setTimes(func(Move) int32 { return 0 }), nil
+		case "synth":
+			secs := 10
+			if n != "" {
+				var err error
+				if secs, err = strconv.Atoi(n); err != nil || secs < 0 {
+					return nil, fmt.Errorf("times=synth needs a number of seconds, got %q", n)
+				}
+			}
+			return setTimes(func(m Move) int32 {
+				if m.IsPass() {
+					return 0
+				}
+				return int32(secs)
+			}), nil
+		case "preserve":
+			return preserveTimes{}, nil
+		}
+		return nil, fmt.Errorf("times needs zero, synth or preserve, got %q", arg)
+	},
+	// handicap gives black the handicap stones for a difference of n ranks, from the usual handicap tables.
+	// Records have no komi, so differences of one rank or less change nothing.
+	"handicap": func(arg string) (Transform, error) {
+		n, err := strconv.Atoi(arg)
+		if err != nil {
+			return nil, fmt.Errorf("handicap needs a rank difference, got %q", arg)
+		}
+		return TransformFunc(func(ctx context.Context, g *Game) error {
+			_, err := addHandicap(g, n, settingsOf(ctx).rules)
+			return err
+		}), nil
+	},
+	// exec runs an external transform using the protocol of runScript.
+	"exec": func(arg string) (Transform, error) {
+		if arg == "" {
+			return nil, fmt.Errorf("exec needs a command")
+		}
+		return TransformFunc(func(ctx context.Context, g *Game) error {
+			b, err := runScript(ctx, g.Encode(), arg)
+			if err != nil {
+				return err
+			}
+			ng, err := settingsOf(ctx).decode(b)
+			if err != nil {
+				return err
+			}
+			*g = *ng
+			return nil
+		}), nil
+	},
+}
+
+func setTimes(f func(Move) int32) Transform {
+	return TransformFunc(func(_ context.Context, g *Game) error {
+		for i := range g.Moves {
+			g.Moves[i].Time = f(g.Moves[i])
+		}
+		return nil
+	})
+}
+
+// preserveTimes is the times=preserve transform.
+// applyTransforms restores the times of the game from before the chain onto the moves found at the same place afterwards.
+type preserveTimes struct{}
+
+func (preserveTimes) Apply(context.Context, *Game) error { return nil }
+
+// restoreTimes copies the times of the moves of orig to the moves of g at the same index and point.
+func restoreTimes(g, orig *Game) {
+	for i := range g.Moves {
+		if i < len(orig.Moves) && g.Moves[i].X == orig.Moves[i].X && g.Moves[i].Y == orig.Moves[i].Y {
+			g.Moves[i].Time = orig.Moves[i].Time
+		}
+	}
+}
+
+// symmetries are the board symmetries, mapping a point of a board of size n.
+var symmetries = map[string]func(x, y, n int32) (int32, int32){
+	"flip180":       func(x, y, n int32) (int32, int32) { return n - x + 1, n - y + 1 },
+	"rot90":         func(x, y, n int32) (int32, int32) { return n - y + 1, x },
+	"rot270":        func(x, y, n int32) (int32, int32) { return y, n - x + 1 },
+	"fliph":         func(x, y, n int32) (int32, int32) { return n - x + 1, y },
+	"flipv":         func(x, y, n int32) (int32, int32) { return x, n - y + 1 },
+	"transpose":     func(x, y, n int32) (int32, int32) { return y, x },
+	"antitranspose": func(x, y, n int32) (int32, int32) { return n - y + 1, n - x + 1 },
+}
+
+// symmetryTransform maps the moves of a game through a board symmetry.
+// Passes and other records whose coordinates are not on the board are left untouched.
+func symmetryTransform(f func(x, y, n int32) (int32, int32)) Transform {
+	return TransformFunc(func(_ context.Context, g *Game) error {
+		for i, m := range g.Moves {
+			if !g.onBoard(m) {
+				continue
+			}
+			g.Moves[i].X, g.Moves[i].Y = f(m.X, m.Y, g.Size)
+		}
+		return nil
+	})
+}
+
+func init() {
+	for name, f := range symmetries {
+		f := f
+		transforms[name] = func(string) (Transform, error) { return symmetryTransform(f), nil }
+	}
+}
+
+// parseTransforms parses a comma separated chain of transforms.
+func parseTransforms(chain string) ([]Transform, error) {
+	var ts []Transform
+	for _, s := range strings.Split(chain, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		name, arg, _ := strings.Cut(s, "=")
+		newTransform, ok := transforms[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown transform %q, available: %s", name, strings.Join(transformNames(), ", "))
+		}
+		t, err := newTransform(arg)
+		if err != nil {
+			return nil, err
+		}
+		ts = append(ts, t)
+	}
+	return ts, nil
+}
+
+func transformNames() []string {
+	var names []string
+	for name := range transforms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// applyTransforms applies the chain of transforms to a saved game record.
+func applyTransforms(ctx context.Context, body []byte, chain string) ([]byte, error) {
+	ts, err := parseTransforms(chain)
+	if err != nil {
+		return nil, err
+	}
+	if len(ts) == 0 {
+		return body, nil
+	}
+	s := settingsOf(ctx)
+	g, err := s.decode(body)
+	if err != nil {
+		return nil, err
+	}
+	orig, err := s.decode(body)
+	if err != nil {
+		return nil, err
+	}
+	preserve := false
+	for _, t := range ts {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if _, ok := t.(preserveTimes); ok {
+			preserve = true
+		}
+		if err := t.Apply(ctx, g); err != nil {
+			return nil, err
+		}
+	}
+	if preserve {
+		restoreTimes(g, orig)
+	}
+	return g.Encode(), nil
+}
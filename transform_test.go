@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// transformed returns a copy of g after the chain of transforms.
+func transformed(t *testing.T, g *Game, chain string) *Game {
+	t.Helper()
+	body, err := applyTransforms(context.Background(), g.Encode(), chain)
+	if err != nil {
+		t.Fatalf("%s: %v", chain, err)
+	}
+	d, err := Decode(body)
+	if err != nil {
+		t.Fatalf("%s: %v", chain, err)
+	}
+	return d
+}
+
+func TestSymmetries(t *testing.T) {
+	g := synthGame(rand.New(rand.NewSource(2)), currentLayout, 19, 80, synthEpoch)
+	same := [][2]string{
+		{"flip180,flip180", ""},
+		{"rot90,rot270", ""},
+		{"rot90,rot90", "flip180"},
+		{"fliph,flipv", "flip180"},
+		{"transpose,transpose", ""},
+		{"transpose,flip180", "antitranspose"},
+	}
+	for _, c := range same {
+		a, b := transformed(t, g, c[0]), transformed(t, g, c[1])
+		if !reflect.DeepEqual(a.Moves, b.Moves) {
+			t.Errorf("%s and %q give different games", c[0], c[1])
+		}
+	}
+
+	// A symmetry of a legal game is legal, and its final position is the symmetry of the final position.
+	want, err := replay(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, f := range symmetries {
+		b, err := replay(transformed(t, g, name))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		for y := 1; y <= 19; y++ {
+			for x := 1; x <= 19; x++ {
+				tx, ty := f(int32(x), int32(y), 19)
+				if b.At(int(tx), int(ty)) != want.At(x, y) {
+					t.Fatalf("%s: the stone of %d,%d is not at %d,%d", name, x, y, tx, ty)
+				}
+			}
+		}
+	}
+}
+
+func TestUnknownTransform(t *testing.T) {
+	if _, err := parseTransforms("flip180,nosuch"); err == nil {
+		t.Error("no error for an unknown transform")
+	}
+}
@@ -0,0 +1,11 @@
+#!/bin/sh
+# Builds chamgo.wasm, the record codec of chamgo for web pages, and copies the wasm_exec.js loader of Go next to it.
+set -e
+cd "$(dirname "$0")"
+tmp=$(mktemp -d)
+trap 'rm -rf "$tmp"' EXIT
+cp ../*.go ../demo.imazingapp chamgo_js.go "$tmp"
+(cd "$tmp" && GOOS=js GOARCH=wasm go build -o chamgo.wasm *.go)
+mv "$tmp/chamgo.wasm" .
+root=$(go env GOROOT)
+cp "$root/lib/wasm/wasm_exec.js" . 2>/dev/null || cp "$root/misc/wasm/wasm_exec.js" .
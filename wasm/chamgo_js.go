@@ -0,0 +1,146 @@
+//go:build js && wasm
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"syscall/js"
+)
+
+// This file is built with the sources of chamgo by build.sh, as it cannot sit next to them:
+// "go build *.go" would then import syscall/js on every platform.
+
+func init() { jsMain = serveJS }
+
+// jsBytes copies the Uint8Array v.
+func jsBytes(v js.Value) []byte {
+	b := make([]byte, v.Get("length").Int())
+	js.CopyBytesToGo(b, v)
+	return b
+}
+
+// jsArray returns b as a Uint8Array.
+func jsArray(b []byte) js.Value {
+	a := js.Global().Get("Uint8Array").New(len(b))
+	js.CopyBytesToJS(a, b)
+	return a
+}
+
+// jsJSON converts v to a JavaScript value through JSON.
+func jsJSON(v interface{}) (js.Value, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return js.Undefined(), err
+	}
+	return js.Global().Get("JSON").Call("parse", string(b)), nil
+}
+
+// jsFunc wraps f as a JavaScript function returning an object like the -json reports: ok, and result or error.
+func jsFunc(nargs int, f func(args []js.Value) (js.Value, error)) js.Func {
+	return js.FuncOf(func(this js.Value, args []js.Value) (ret interface{}) {
+		defer func() {
+			if r := recover(); r != nil {
+				ret = map[string]interface{}{"ok": false, "error": fmt.Sprint(r)}
+			}
+		}()
+		if len(args) < nargs {
+			return map[string]interface{}{"ok": false, "error": fmt.Sprintf("%d arguments needed", nargs)}
+		}
+		v, err := f(args)
+		if err != nil {
+			return map[string]interface{}{"ok": false, "error": err.Error()}
+		}
+		return map[string]interface{}{"ok": true, "result": v}
+	})
+}
+
+// serveJS exposes the record codec to JavaScript as the global chamgo object, working on bytes only,
+// so that a page can edit an archive without sending it anywhere. It then waits for calls.
+func serveJS() {
+	api := map[string]interface{}{
+		// decode(record) returns the game of a record.
+		"decode": jsFunc(1, func(args []js.Value) (js.Value, error) {
+			g, err := Decode(jsBytes(args[0]))
+			if err != nil {
+				return js.Undefined(), err
+			}
+			return jsJSON(g)
+		}),
+		// encode(game, base) returns the record of a game, keeping the unknown bytes of the record base.
+		"encode": jsFunc(2, func(args []js.Value) (js.Value, error) {
+			game := js.Global().Get("JSON").Call("stringify", args[0]).String()
+			b, err := libEncode([]byte(game), jsBytes(args[1]))
+			if err != nil {
+				return js.Undefined(), err
+			}
+			return jsArray(b), nil
+		}),
+		// transform(record, chain) applies a chain of transforms such as "flip180,truncate=30".
+		"transform": jsFunc(2, func(args []js.Value) (js.Value, error) {
+			b, err := applyTransforms(context.Background(), jsBytes(args[0]), args[1].String())
+			if err != nil {
+				return js.Undefined(), err
+			}
+			return jsArray(b), nil
+		}),
+		// toSGF(record) returns the SGF of a record.
+		"toSGF": jsFunc(1, func(args []js.Value) (js.Value, error) {
+			g, err := Decode(jsBytes(args[0]))
+			if err != nil {
+				return js.Undefined(), err
+			}
+			return js.ValueOf(writeSGF(g)), nil
+		}),
+		// fromSGF(sgf, base) returns the record base with the main line of an SGF game.
+		"fromSGF": jsFunc(2, func(args []js.Value) (js.Value, error) {
+			b, err := sgfGame(context.Background(), args[0].String(), jsBytes(args[1]))
+			if err != nil {
+				return js.Undefined(), err
+			}
+			return jsArray(b), nil
+		}),
+		// toComputer(record, player) returns the record as a game of the player, "b" or "w", against level 10,
+		// dated now, as chamgo makes the games it injects.
+		"toComputer": jsFunc(2, func(args []js.Value) (js.Value, error) {
+			b, err := libToComputer(jsBytes(args[0]), args[1].String())
+			if err != nil {
+				return js.Undefined(), err
+			}
+			return jsArray(b), nil
+		}),
+		// games(archive) returns the local and online games of a zip archive, decoded.
+		"games": jsFunc(1, func(args []js.Value) (js.Value, error) {
+			gs, err := libGames(jsBytes(args[0]))
+			if err != nil {
+				return js.Undefined(), err
+			}
+			return jsJSON(gs)
+		}),
+		// record(archive, name) returns the record of the entry name of a zip archive.
+		"record": jsFunc(2, func(args []js.Value) (js.Value, error) {
+			b, err := libRecord(jsBytes(args[0]), args[1].String())
+			if err != nil {
+				return js.Undefined(), err
+			}
+			return jsArray(b), nil
+		}),
+		// replace(archive, records) returns the zip archive with the records of an object keyed by entry name.
+		"replace": jsFunc(2, func(args []js.Value) (js.Value, error) {
+			replace := make(map[string][]byte)
+			keys := js.Global().Get("Object").Call("keys", args[1])
+			for i := 0; i < keys.Length(); i++ {
+				k := keys.Index(i).String()
+				replace[k] = jsBytes(args[1].Get(k))
+			}
+			b, err := libReplace(jsBytes(args[0]), replace)
+			if err != nil {
+				return js.Undefined(), err
+			}
+			return jsArray(b), nil
+		}),
+	}
+	js.Global().Set("chamgo", js.ValueOf(api))
+	select {}
+}
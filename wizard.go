@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// wizardDirs are where the wizard looks for archives, besides the current directory.
+var wizardDirs = []string{"Downloads", "Desktop", "Documents"}
+
+// findArchives returns the archives in the current directory and the wizardDirs of the home directory.
+func findArchives() []string {
+	dirs := []string{"."}
+	if home, err := os.UserHomeDir(); err == nil {
+		for _, d := range wizardDirs {
+			dirs = append(dirs, filepath.Join(home, d))
+		}
+	}
+	var found []string
+	for _, d := range dirs {
+		entries, err := os.ReadDir(d)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			name := strings.ToLower(e.Name())
+			if !e.IsDir() && (strings.HasSuffix(name, ".imazingapp") || strings.HasSuffix(name, ".zip") || isTarName(name)) {
+				found = append(found, filepath.Join(d, e.Name()))
+			}
+		}
+	}
+	return found
+}
+
+// ask asks the question on stderr and returns the trimmed answer, or def for an empty one.
+func ask(in *bufio.Reader, question, def string) (string, error) {
+	if def != "" {
+		fmt.Fprintf(os.Stderr, "%s [%s]: ", question, def)
+	} else {
+		fmt.Fprintf(os.Stderr, "%s: ", question)
+	}
+	line, err := in.ReadString('\n')
+	line = strings.TrimSpace(line)
+	if line == "" && err != nil {
+		return "", fmt.Errorf("wizard: %w", err)
+	}
+	if line == "" {
+		return def, nil
+	}
+	return line, nil
+}
+
+// wizardArchive asks for the archive until one with games is given, and returns it with its local and online games.
+func wizardArchive(ctx context.Context, in *bufio.Reader) (string, []gameEntry, []gameEntry, error) {
+	found := findArchives()
+	if len(found) > 0 {
+		fmt.Fprintln(os.Stderr, "Archives found:")
+		for i, f := range found {
+			fmt.Fprintf(os.Stderr, "%2d) %s\n", i+1, f)
+		}
+	} else {
+		fmt.Fprintln(os.Stderr, "No archives found here or in your Downloads, Desktop or Documents.")
+		fmt.Fprintln(os.Stderr, "Export the app with its documents from iMazing, or your backup tool, as an .imazingapp file.")
+	}
+	def := ""
+	if len(found) > 0 {
+		def = "1"
+	}
+	for {
+		a, err := ask(in, "Backup to read, by number or path", def)
+		if err != nil {
+			return "", nil, nil, err
+		}
+		if n, err := strconv.Atoi(a); err == nil && n >= 1 && n <= len(found) {
+			a = found[n-1]
+		}
+		local, err := listGames(ctx, a, false)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "cannot read %s: %v\n", a, err)
+			continue
+		}
+		online, err := listGames(ctx, a, true)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "cannot read %s: %v\n", a, err)
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "%s: %s, %d local games, %d online games\n", a, currentApp.About, len(local), len(online))
+		if len(local) == 0 {
+			fmt.Fprintln(os.Stderr, "it has no games to inject, play one in the app and export it again")
+			appDetected = false
+			continue
+		}
+		if len(online) == 0 && currentApp.hasOnlineGames() {
+			fmt.Fprintln(os.Stderr, "it has no online game to replace, play one on Game Center and export it again")
+			appDetected = false
+			continue
+		}
+		return a, local, online, nil
+	}
+}
+
+// wizardCmd asks for what the flags of the main command give, showing what each answer chooses,
+// and writes the archive once confirmed.
+func wizardCmd(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("wizard", flag.ContinueOnError)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("usage: chamgo wizard")
+	}
+	in := bufio.NewReader(selectInput)
+	// selectGame reads the same buffered input, as bufio.NewReader returns in itself.
+	saved := selectInput
+	selectInput = in
+	defer func() { selectInput = saved }()
+
+	fmt.Fprintln(os.Stderr, "This wizard injects one of your local games into an online game slot, so that you can play on from it against the computer.")
+	fmt.Fprintln(os.Stderr, "Press return to take the answer in brackets.")
+	fmt.Fprintln(os.Stderr)
+
+	avx, local, online, err := wizardArchive(ctx, in)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr)
+	source, err := selectGame(local, "local")
+	if err != nil {
+		return err
+	}
+	target := source
+	if currentApp.hasOnlineGames() {
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "The game will replace one of the online games, which the app lets you play on against the computer.")
+		if target, err = selectGame(online, "online"); err != nil {
+			return err
+		}
+	} else {
+		fmt.Fprintf(os.Stderr, "%s has no online games, so the game is saved again as a game against the computer.\n", currentApp.About)
+	}
+
+	var color string
+	for {
+		if color, err = ask(in, "\nPlay as black or white (b/w)", "b"); err != nil {
+			return err
+		}
+		if color = strings.ToLower(color[:1]); color == "b" || color == "w" {
+			break
+		}
+	}
+	var level int
+	for {
+		l, err := ask(in, "Computer level (1-10)", "10")
+		if err != nil {
+			return err
+		}
+		if level, err = strconv.Atoi(l); err == nil && level >= 1 && level <= 10 {
+			break
+		}
+		fmt.Fprintf(os.Stderr, "%q is not a level from 1 to 10\n", l)
+	}
+
+	body := append([]byte(nil), source.Body...)
+	if err := setPlayer(body, color); err != nil {
+		return err
+	}
+	setLevel(body, byte(level))
+	touchDates(body)
+	g, err := Decode(body)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "\n%s, %d moves, replacing %s, you play %s at level %d:\n",
+		filepath.Base(source.Name), len(g.Moves), filepath.Base(target.Name), colorName(map[string]Stone{"b": Black, "w": White}[color]), level)
+	for _, l := range miniBoard(g) {
+		fmt.Fprintf(os.Stderr, "      %s\n", l)
+	}
+
+	ext := filepath.Ext(avx)
+	def := strings.TrimSuffix(avx, ext) + "-chamgo" + ext
+	var out string
+	for {
+		if out, err = ask(in, "\nWrite the new archive to", def); err != nil {
+			return err
+		}
+		if !sameFile(out, avx) {
+			break
+		}
+		fmt.Fprintln(os.Stderr, "write to another file than the backup, which is read while writing")
+	}
+	if exists(out) {
+		if a, err := ask(in, out+" exists, replace it (y/n)", "n"); err != nil || !strings.HasPrefix(strings.ToLower(a), "y") {
+			return fmt.Errorf("wizard: nothing written")
+		}
+	}
+	if err := writeAvxFile(ctx, out, avx, avxEdit{Replace: map[string][]byte{target.Name: body}}); err != nil {
+		return err
+	}
+	note(event{"event": "wizard", "archive": out, "game": source.Name, "entry": target.Name},
+		"wrote %s, restore it with iMazing and open the online games of the app", out)
+	return nil
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"crypto/aes"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+)
+
+// Entries of password-protected zips, as written by WinZip, 7-Zip and some backup tools,
+// use the WinZip AES format: compression method 99, with the actual method in an extra field,
+// and data made of a salt, a password verifier, the AES-CTR encrypted compressed data and an HMAC-SHA1 code.
+const (
+	zipAESMethod = 99
+	zipAESExtra  = 0x9901
+	zipAESRounds = 1000
+	zipAESMACLen = 10
+)
+
+// zipPassword is the password of AES-encrypted zip entries, set by -password or $CHAMGO_PASSWORD.
+var zipPassword = os.Getenv("CHAMGO_PASSWORD")
+
+var errNoPassword = errors.New("encrypted zip entry, set the password with -password or $CHAMGO_PASSWORD")
+
+type zipAESInfo struct {
+	Version  uint16 // 1 for AE-1, which keeps the CRC, and 2 for AE-2, which does not
+	Strength byte   // 1, 2 and 3 for 128, 192 and 256 bit keys
+	Method   uint16 // the actual compression method
+}
+
+func (i zipAESInfo) keyLen() int  { return 8 + 8*int(i.Strength) }
+func (i zipAESInfo) saltLen() int { return 4 + 4*int(i.Strength) }
+
+// parseZipAESExtra finds the WinZip AES field in the extra data of a zip entry.
+func parseZipAESExtra(extra []byte) (zipAESInfo, error) {
+	for len(extra) >= 4 {
+		tag := binary.LittleEndian.Uint16(extra)
+		n := int(binary.LittleEndian.Uint16(extra[2:]))
+		if 4+n > len(extra) {
+			break
+		}
+		if tag == zipAESExtra && n >= 7 {
+			d := extra[4 : 4+n]
+			i := zipAESInfo{Version: binary.LittleEndian.Uint16(d), Strength: d[4], Method: binary.LittleEndian.Uint16(d[5:])}
+			if string(d[2:4]) != "AE" || i.Strength < 1 || i.Strength > 3 {
+				return i, unsupported(fmt.Errorf("unsupported AES zip field % x", d))
+			}
+			return i, nil
+		}
+		extra = extra[4+n:]
+	}
+	return zipAESInfo{}, unsupported(errors.New("encrypted zip entry without an AES field, only AES encryption is supported"))
+}
+
+// zipAESKeys derives the encryption key, the authentication key and the password verifier.
+func zipAESKeys(password string, salt []byte, keyLen int) (enc, mac, verifier []byte, err error) {
+	k, err := pbkdf2.Key(sha1.New, password, salt, zipAESRounds, 2*keyLen+2)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return k[:keyLen], k[keyLen : 2*keyLen], k[2*keyLen:], nil
+}
+
+// zipAESCTR encrypts or decrypts data in place.
+// Unlike cipher.NewCTR, WinZip's counter is little endian and starts at 1.
+func zipAESCTR(key, data []byte) error {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+	var ctr, ks [aes.BlockSize]byte
+	for off := 0; off < len(data); off += aes.BlockSize {
+		for i := range ctr {
+			ctr[i]++
+			if ctr[i] != 0 {
+				break
+			}
+		}
+		block.Encrypt(ks[:], ctr[:])
+		for i := 0; i < aes.BlockSize && off+i < len(data); i++ {
+			data[off+i] ^= ks[i]
+		}
+	}
+	return nil
+}
+
+// openZipAES decrypts and decompresses an AES-encrypted zip entry with password.
+func openZipAES(f *zip.File, password string) ([]byte, error) {
+	if password == "" {
+		return nil, fmt.Errorf("%s: %w", f.Name, errNoPassword)
+	}
+	info, err := parseZipAESExtra(f.Extra)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", f.Name, err)
+	}
+	r, err := f.OpenRaw()
+	if err != nil {
+		return nil, err
+	}
+	raw, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) < info.saltLen()+2+zipAESMACLen {
+		return nil, fmt.Errorf("%s: truncated encrypted data", f.Name)
+	}
+	salt, verifier := raw[:info.saltLen()], raw[info.saltLen():info.saltLen()+2]
+	data, code := raw[info.saltLen()+2:len(raw)-zipAESMACLen], raw[len(raw)-zipAESMACLen:]
+
+	encKey, macKey, wantVerifier, err := zipAESKeys(password, salt, info.keyLen())
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(verifier, wantVerifier) {
+		return nil, fmt.Errorf("%s: wrong password", f.Name)
+	}
+	m := hmac.New(sha1.New, macKey)
+	m.Write(data)
+	if !hmac.Equal(m.Sum(nil)[:zipAESMACLen], code) {
+		return nil, invalid(fmt.Errorf("%s: authentication failed, the entry is corrupt", f.Name))
+	}
+	data = append([]byte(nil), data...)
+	if err := zipAESCTR(encKey, data); err != nil {
+		return nil, err
+	}
+
+	var body []byte
+	switch info.Method {
+	case zip.Store:
+		body = data
+	case zip.Deflate:
+		fr := flate.NewReader(bytes.NewReader(data))
+		defer fr.Close()
+		if body, err = ioutil.ReadAll(fr); err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Name, err)
+		}
+	default:
+		return nil, unsupported(fmt.Errorf("%s: unsupported compression method %d", f.Name, info.Method))
+	}
+	if info.Version == 1 && crc32.ChecksumIEEE(body) != f.CRC32 {
+		return nil, invalid(fmt.Errorf("%s: checksum mismatch", f.Name))
+	}
+	return body, nil
+}
+
+// writeZipAES writes body as an AE-2 entry with a 256 bit key derived from the password of s.
+func writeZipAES(s *settings, zw *zip.Writer, name string, body []byte) error {
+	if s.password == "" {
+		return fmt.Errorf("%s: %w", name, errNoPassword)
+	}
+	var data bytes.Buffer
+	fw, err := flate.NewWriter(&data, flate.DefaultCompression)
+	if err != nil {
+		return err
+	}
+	fw.Write(body)
+	if err := fw.Close(); err != nil {
+		return err
+	}
+
+	info := zipAESInfo{Version: 2, Strength: 3, Method: zip.Deflate}
+	salt := make([]byte, info.saltLen())
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	encKey, macKey, verifier, err := zipAESKeys(s.password, salt, info.keyLen())
+	if err != nil {
+		return err
+	}
+	enc := data.Bytes()
+	if err := zipAESCTR(encKey, enc); err != nil {
+		return err
+	}
+	m := hmac.New(sha1.New, macKey)
+	m.Write(enc)
+
+	extra := make([]byte, 4+7)
+	binary.LittleEndian.PutUint16(extra, zipAESExtra)
+	binary.LittleEndian.PutUint16(extra[2:], 7)
+	binary.LittleEndian.PutUint16(extra[4:], info.Version)
+	copy(extra[6:], "AE")
+	extra[8] = info.Strength
+	binary.LittleEndian.PutUint16(extra[9:], info.Method)
+
+	size := len(salt) + len(verifier) + len(enc) + zipAESMACLen
+	w, err := zw.CreateRaw(&zip.FileHeader{
+		Name:               name,
+		Method:             zipAESMethod,
+		Flags:              0x1, // encrypted
+		Modified:           s.clock(),
+		Extra:              extra,
+		CompressedSize64:   uint64(size),
+		UncompressedSize64: uint64(len(body)),
+	})
+	if err != nil {
+		return err
+	}
+	for _, b := range [][]byte{salt, verifier, enc, m.Sum(nil)[:zipAESMACLen]} {
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
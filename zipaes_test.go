@@ -0,0 +1,83 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseZipAESExtra(t *testing.T) {
+	tests := []struct {
+		name  string
+		extra []byte
+		want  zipAESInfo
+		err   bool
+	}{
+		{"AE-2", []byte{0x01, 0x99, 7, 0, 2, 0, 'A', 'E', 3, 8, 0}, zipAESInfo{2, 3, zip.Deflate}, false},
+		{"after another field", []byte{0x55, 0x54, 1, 0, 0, 0x01, 0x99, 7, 0, 1, 0, 'A', 'E', 1, 0, 0}, zipAESInfo{1, 1, zip.Store}, false},
+		{"bad vendor", []byte{0x01, 0x99, 7, 0, 2, 0, 'X', 'X', 3, 8, 0}, zipAESInfo{}, true},
+		{"bad strength", []byte{0x01, 0x99, 7, 0, 2, 0, 'A', 'E', 4, 8, 0}, zipAESInfo{}, true},
+		{"truncated", []byte{0x01, 0x99, 7, 0, 2, 0, 'A', 'E'}, zipAESInfo{}, true},
+		{"none", nil, zipAESInfo{}, true},
+	}
+	for _, tt := range tests {
+		got, err := parseZipAESExtra(tt.extra)
+		if (err != nil) != tt.err || err == nil && got != tt.want {
+			t.Errorf("%s: %+v, %v, want %+v", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestZipAES(t *testing.T) {
+	body := []byte(strings.Repeat("an encrypted game record ", 40))
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	s := &settings{password: "secret", clock: func() time.Time { return synthEpoch }}
+	if err := writeZipAES(s, zw, "Container/Documents/game/1", body); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeZipAES(&settings{}, zw, "x", body); !errors.Is(err, errNoPassword) {
+		t.Errorf("writing without a password: %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	b := buf.Bytes()
+	if bytes.Contains(b, body[:25]) {
+		t.Error("the entry is not encrypted")
+	}
+	corrupt := append([]byte(nil), b...)
+	corrupt[bytes.Index(corrupt, []byte("game/1"))+6+4+7+16+2+5] ^= 1 // a data byte after the name, the extra field, the salt and the verifier
+
+	tests := []struct {
+		name     string
+		b        []byte
+		password string
+		err      string
+	}{
+		{"right password", b, "secret", ""},
+		{"wrong password", b, "Secret", "wrong password"},
+		{"no password", b, "", "set the password"},
+		{"corrupt", corrupt, "secret", "authentication failed"},
+	}
+	for _, tt := range tests {
+		zr, err := zip.NewReader(bytes.NewReader(tt.b), int64(len(tt.b)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		f := zr.File[0]
+		if f.Method != zipAESMethod || f.Flags&0x1 == 0 {
+			t.Errorf("%s: method %d, flags %#x", tt.name, f.Method, f.Flags)
+		}
+		got, err := openZipAES(f, tt.password)
+		switch {
+		case tt.err == "" && (err != nil || !bytes.Equal(got, body)):
+			t.Errorf("%s: %q, %v", tt.name, got, err)
+		case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+			t.Errorf("%s: %v, want %s", tt.name, err, tt.err)
+		}
+	}
+}
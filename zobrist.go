@@ -0,0 +1,70 @@
+package main
+
+import "fmt"
+
+// maxBoardSize is the largest board size with point labels, and with Zobrist keys.
+const maxBoardSize = len(columns)
+
+// zobristKeys are the random keys of a black and a white stone on each point of the largest board,
+// and of each board size. They are generated from a fixed seed, so hashes stay the same between runs
+// and can be stored in indexes.
+var zobristKeys, zobristSizeKeys = func() (keys [2][maxBoardSize * maxBoardSize]uint64, sizes [maxBoardSize + 1]uint64) {
+	// splitmix64
+	x := uint64(0x6368616d676f) // "chamgo"
+	next := func() uint64 {
+		x += 0x9e3779b97f4a7c15
+		z := x
+		z = (z ^ z>>30) * 0xbf58476d1ce4e5b9
+		z = (z ^ z>>27) * 0x94d049bb133111eb
+		return z ^ z>>31
+	}
+	for c := range keys {
+		for p := range keys[c] {
+			keys[c][p] = next()
+		}
+	}
+	for n := range sizes {
+		sizes[n] = next()
+	}
+	return keys, sizes
+}()
+
+// Hash returns the Zobrist hash of the stones on the board and its size.
+// Boards of up to maxBoardSize are hashed, larger ones return 0.
+func (b *Board) Hash() uint64 {
+	return b.symmetryHash(func(x, y, n int32) (int32, int32) { return x, y })
+}
+
+// symmetryHash returns the Zobrist hash of the board mapped through the symmetry f.
+func (b *Board) symmetryHash(f func(x, y, n int32) (int32, int32)) uint64 {
+	if b.Size > maxBoardSize {
+		return 0
+	}
+	h := zobristSizeKeys[b.Size]
+	n := int32(b.Size)
+	for p, s := range b.Points {
+		if s == Black || s == White {
+			x, y := f(int32(p%b.Size+1), int32(p/b.Size+1), n)
+			h ^= zobristKeys[s-Black][(y-1)*int32(maxBoardSize)+x-1]
+		}
+	}
+	return h
+}
+
+// CanonicalHash returns the smallest Zobrist hash of the board under the eight board symmetries,
+// which is the same for all rotations and reflections of a position,
+// and the name of the symmetry of transforms giving it, or "" if the board itself has the smallest hash.
+func (b *Board) CanonicalHash() (uint64, string) {
+	h, sym := b.Hash(), ""
+	for _, name := range sortedKeys(symmetries) {
+		if s := b.symmetryHash(symmetries[name]); s < h {
+			h, sym = s, name
+		}
+	}
+	return h, sym
+}
+
+// formatHash formats a position hash as it is stored in indexes and printed.
+func formatHash(h uint64) string {
+	return fmt.Sprintf("%016x", h)
+}
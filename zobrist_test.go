@@ -0,0 +1,97 @@
+package main
+
+import "testing"
+
+// playBoard returns a board of size with the moves played on it, as "b" or "w" and x, y, alternately.
+func playBoard(t *testing.T, size int, moves ...interface{}) *Board {
+	t.Helper()
+	b := newBoardRules(size, rulesets["japanese"])
+	for i := 0; i < len(moves); i += 3 {
+		s := Black
+		if moves[i] == "w" {
+			s = White
+		}
+		if _, err := b.Play(s, moves[i+1].(int), moves[i+2].(int)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return b
+}
+
+func TestHash(t *testing.T) {
+	tests := []struct {
+		name  string
+		a, b  *Board
+		equal bool
+	}{
+		{"move order", playBoard(t, 9, "b", 3, 3, "w", 7, 7, "b", 3, 7), playBoard(t, 9, "b", 3, 7, "w", 7, 7, "b", 3, 3), true},
+		{"passes", playBoard(t, 9, "b", 3, 3, "w", 0, 0, "b", 5, 5), playBoard(t, 9, "b", 5, 5, "w", 0, 0, "b", 3, 3), true},
+		{"color", playBoard(t, 9, "b", 3, 3), playBoard(t, 9, "w", 3, 3), false},
+		{"point", playBoard(t, 9, "b", 3, 3), playBoard(t, 9, "b", 3, 4), false},
+		{"board size", playBoard(t, 9, "b", 3, 3), playBoard(t, 13, "b", 3, 3), false},
+		{"empty boards", playBoard(t, 9), playBoard(t, 19), false},
+		// The black stone at 1,1 is captured, leaving the white stones alone.
+		{"capture", playBoard(t, 9, "b", 1, 1, "w", 2, 1, "b", 5, 5, "w", 1, 2), playBoard(t, 9, "w", 2, 1, "b", 5, 5, "w", 1, 2), true},
+		{"before the capture", playBoard(t, 9, "b", 1, 1, "w", 2, 1, "b", 5, 5), playBoard(t, 9, "w", 2, 1, "b", 5, 5, "w", 1, 2), false},
+	}
+	for _, tt := range tests {
+		if equal := tt.a.Hash() == tt.b.Hash(); equal != tt.equal {
+			t.Errorf("%s: equal hashes %v, want %v", tt.name, equal, tt.equal)
+		}
+	}
+}
+
+func TestHashKo(t *testing.T) {
+	// Black takes the white stone at 2,2 in ko.
+	b := playBoard(t, 9, "b", 2, 1, "w", 3, 1, "b", 1, 2, "w", 4, 2, "b", 2, 3, "w", 3, 3, "b", 9, 9, "w", 2, 2)
+	before := b.Hash()
+	if n, err := b.Play(Black, 3, 2); err != nil || n != 1 {
+		t.Fatalf("ko capture: %d stones, %v", n, err)
+	}
+	taken := b.Hash()
+	if taken == before {
+		t.Error("the ko capture left the hash unchanged")
+	}
+	if _, err := b.Play(White, 2, 2); err == nil {
+		t.Fatal("white retook the ko at once")
+	}
+	if b.Hash() != taken {
+		t.Error("the forbidden retake changed the hash")
+	}
+	b.Play(White, 0, 0)
+	b.Play(Black, 0, 0)
+	if _, err := b.Play(White, 2, 2); err != nil {
+		t.Fatal(err)
+	}
+	if b.Hash() != before {
+		t.Error("retaking the ko does not give back the hash of the position before it was taken")
+	}
+}
+
+func TestCanonicalHash(t *testing.T) {
+	// No symmetry maps this position to itself, so its eight symmetric boards have eight hashes.
+	moves := []interface{}{"b", 3, 3, "w", 5, 3, "b", 4, 7, "w", 7, 6, "b", 2, 8}
+	b := playBoard(t, 9, moves...)
+	want, _ := b.CanonicalHash()
+	hashes := map[uint64]bool{b.Hash(): true}
+	for name, f := range symmetries {
+		sb := newBoardRules(9, rulesets["japanese"])
+		for p, s := range b.Points {
+			x, y := f(int32(p%9+1), int32(p/9+1), 9)
+			sb.Points[(y-1)*9+x-1] = s
+		}
+		hashes[sb.Hash()] = true
+		if h, _ := sb.CanonicalHash(); h != want {
+			t.Errorf("%s: canonical hash %s, want %s", name, formatHash(h), formatHash(want))
+		}
+		if h, sym := sb.CanonicalHash(); sym != "" && sb.symmetryHash(symmetries[sym]) != h {
+			t.Errorf("%s: %s does not give the canonical hash", name, sym)
+		}
+	}
+	if len(hashes) != 8 {
+		t.Errorf("%d distinct hashes of the eight symmetric boards, want 8", len(hashes))
+	}
+	if (&Board{Size: maxBoardSize + 1}).Hash() != 0 {
+		t.Error("a board larger than maxBoardSize has a hash")
+	}
+}